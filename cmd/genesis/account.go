@@ -6,6 +6,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
 	"os"
 
@@ -24,12 +25,23 @@ func init() {
 	accountCreateCmd.Flags().String("provider", "aws", "Cloud provider hosting the account.")
 	accountCreateCmd.Flags().Bool("provision", false, "When set to true provision an account after creation.")
 	accountCreateCmd.Flags().String("subnet", "", "The subnet CIDR to use for VPC creation. If not specified a random one will be selected.")
+	accountCreateCmd.Flags().Int("subnet-prefix-length", 0, "The prefix length of the subnet to allocate for VPC creation. If not specified the split range of the parent subnet is used.")
+	accountCreateCmd.Flags().StringToString("pool-selector", nil, "The labels of the parent subnets to claim the account subnets from, i.e. environment=production,tier=enterprise.")
+	accountCreateCmd.Flags().Bool("dual-stack", false, "When set to true the account VPC will also be assigned an IPv6 subnet.")
+	accountCreateCmd.Flags().String("subnet-ipv6", "", "The IPv6 subnet CIDR to use for dual-stack VPC creation. If not specified a random one will be selected.")
 	accountCreateCmd.MarkFlagRequired("service-catalog-product") //nolint
 
 	accountProvisionCmd.Flags().String("account", "", "The id of the account to be deleted.")
 	accountProvisionCmd.Flags().String("subnet", "", "The subnet CIDR to use for VPC creation. If not specified a random one will be selected.")
+	accountProvisionCmd.Flags().Int("subnet-prefix-length", 0, "The prefix length of the subnet to allocate for VPC creation. If not specified the split range of the parent subnet is used.")
+	accountProvisionCmd.Flags().StringToString("pool-selector", nil, "The labels of the parent subnets to claim the account subnets from. If not specified the pool selector the account was created with is used.")
+	accountProvisionCmd.Flags().Bool("dual-stack", false, "When set to true the account VPC will also be assigned an IPv6 subnet.")
+	accountProvisionCmd.Flags().String("subnet-ipv6", "", "The IPv6 subnet CIDR to use for dual-stack VPC creation. If not specified a random one will be selected.")
 	accountProvisionCmd.MarkFlagRequired("account") //nolint
 
+	accountRefreshCmd.Flags().String("account", "", "The id of the account to have its metadata refreshed.")
+	accountRefreshCmd.MarkFlagRequired("account") //nolint
+
 	accountDeleteCmd.Flags().String("account", "", "The id of the account to be deleted.")
 	accountDeleteCmd.MarkFlagRequired("account") //nolint
 
@@ -43,6 +55,8 @@ func init() {
 
 	accountCmd.AddCommand(accountCreateCmd)
 	accountCmd.AddCommand(accountProvisionCmd)
+	accountCmd.AddCommand(accountRefreshCmd)
+	accountCmd.AddCommand(accountRefreshAllCmd)
 	accountCmd.AddCommand(accountDeleteCmd)
 	accountCmd.AddCommand(accountGetCmd)
 	accountCmd.AddCommand(accountListCmd)
@@ -76,12 +90,20 @@ var accountCreateCmd = &cobra.Command{
 		serviceCatalogProductID, _ := command.Flags().GetString("service-catalog-product")
 		provision, _ := command.Flags().GetBool("provision")
 		subnet, _ := command.Flags().GetString("subnet")
+		subnetPrefixLength, _ := command.Flags().GetInt("subnet-prefix-length")
+		poolSelector, _ := command.Flags().GetStringToString("pool-selector")
+		dualStack, _ := command.Flags().GetBool("dual-stack")
+		subnetIPv6, _ := command.Flags().GetString("subnet-ipv6")
 
 		request := &model.CreateAccountRequest{
 			Provider:                provider,
 			ServiceCatalogProductID: serviceCatalogProductID,
 			Provision:               provision,
 			Subnet:                  subnet,
+			SubnetPrefixLength:      subnetPrefixLength,
+			PoolSelector:            poolSelector,
+			DualStack:               dualStack,
+			SubnetIPv6:              subnetIPv6,
 		}
 
 		dryRun, _ := command.Flags().GetBool("dry-run")
@@ -121,9 +143,17 @@ var accountProvisionCmd = &cobra.Command{
 		client := model.NewClient(serverAddress)
 		accountID, _ := command.Flags().GetString("account")
 		subnet, _ := command.Flags().GetString("subnet")
+		subnetPrefixLength, _ := command.Flags().GetInt("subnet-prefix-length")
+		poolSelector, _ := command.Flags().GetStringToString("pool-selector")
+		dualStack, _ := command.Flags().GetBool("dual-stack")
+		subnetIPv6, _ := command.Flags().GetString("subnet-ipv6")
 
 		request := &model.ProvisionAccountRequest{
-			Subnet: subnet,
+			Subnet:             subnet,
+			SubnetPrefixLength: subnetPrefixLength,
+			PoolSelector:       poolSelector,
+			DualStack:          dualStack,
+			SubnetIPv6:         subnetIPv6,
 		}
 
 		dryRun, _ := command.Flags().GetBool("dry-run")
@@ -149,6 +179,98 @@ var accountProvisionCmd = &cobra.Command{
 	},
 }
 
+var accountRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refresh an account's metadata from the cloud provider.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+		accountID, _ := command.Flags().GetString("account")
+
+		account, err := client.RefreshAccount(accountID)
+		if err != nil {
+			return errors.Wrap(err, "failed to refresh account metadata")
+		}
+
+		if err = printJSON(account); err != nil {
+			return errors.Wrap(err, "failed to print account response")
+		}
+
+		return nil
+	},
+}
+
+var accountRefreshAllCmd = &cobra.Command{
+	Use:   "refresh-all",
+	Short: "Refresh the metadata of all accounts that are able to be refreshed.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		accounts, err := client.GetAccounts(&model.GetAccountsRequest{
+			Page:    0,
+			PerPage: model.AllPerPage,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to query accounts")
+		}
+
+		var refreshable []*model.Account
+		for _, account := range accounts {
+			if account.ValidTransitionState(model.AccountStateRefreshMetadata) {
+				refreshable = append(refreshable, account)
+			}
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			accountIDs := []string{}
+			for _, account := range refreshable {
+				accountIDs = append(accountIDs, account.ID)
+			}
+			if err = printJSON(accountIDs); err != nil {
+				return errors.Wrap(err, "failed to print accounts to be refreshed")
+			}
+
+			return nil
+		}
+
+		refreshed := []*model.Account{}
+		var failed int
+		for _, account := range refreshable {
+			refreshedAccount, err := client.RefreshAccount(account.ID)
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "failed to refresh account %s: %s\n", account.ID, err)
+				continue
+			}
+			refreshed = append(refreshed, refreshedAccount)
+		}
+
+		if err = printJSON(refreshed); err != nil {
+			return errors.Wrap(err, "failed to print account response")
+		}
+
+		if failed > 0 {
+			return errors.Errorf("failed to refresh %d of %d accounts", failed, len(refreshable))
+		}
+
+		return nil
+	},
+}
+
 var accountDeleteCmd = &cobra.Command{
 	Use:   "delete",
 	Short: "Delete an account.",
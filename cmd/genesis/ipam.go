@@ -0,0 +1,179 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/genesis/model"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	ipamCmd.PersistentFlags().String("server", defaultLocalServerAPI, "The genesis server whose API will be queried.")
+
+	ipamExportCmd.Flags().String("format", model.IPAMExportFormatJSON, "The format of the export, json or csv.")
+	ipamExportCmd.Flags().String("output", "", "The file the export is written to. If not specified the export is printed.")
+
+	ipamImportCmd.Flags().String("file", "", "The file of the export to import.")
+	ipamImportCmd.Flags().String("format", "", "The format of the export, json or csv. If not specified it is guessed from the file extension.")
+	ipamImportCmd.Flags().Bool("apply", false, "When set to true the import is applied if it has no conflicts. Otherwise it is only validated.")
+	ipamImportCmd.Flags().Bool("table", false, "Whether to display the import report in a table or not")
+	ipamImportCmd.MarkFlagRequired("file") //nolint
+
+	ipamCmd.AddCommand(ipamExportCmd)
+	ipamCmd.AddCommand(ipamImportCmd)
+}
+
+var ipamCmd = &cobra.Command{
+	Use:   "ipam",
+	Short: "Back up, restore or migrate the parent subnets, subnets and subnet exclusions.",
+}
+
+var ipamExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the parent subnets, the claimed and reserved subnets and the subnet exclusions.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		format, _ := command.Flags().GetString("format")
+		if format != model.IPAMExportFormatJSON && format != model.IPAMExportFormatCSV {
+			return errors.Errorf("unsupported export format %s", format)
+		}
+
+		client := model.NewClient(serverAddress)
+
+		export, err := client.ExportIPAM()
+		if err != nil {
+			return errors.Wrap(err, "failed to export IPAM state")
+		}
+
+		output, _ := command.Flags().GetString("output")
+		if output == "" {
+			return writeIPAMExport(os.Stdout, export, format)
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return errors.Wrap(err, "failed to create export file")
+		}
+		defer file.Close()
+
+		if err = writeIPAMExport(file, export, format); err != nil {
+			return errors.Wrap(err, "failed to write export file")
+		}
+
+		return file.Close()
+	},
+}
+
+var ipamImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Validate an IPAM export against the existing state and import it.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		path, _ := command.Flags().GetString("file")
+		format, _ := command.Flags().GetString("format")
+		if format == "" {
+			format = model.IPAMExportFormatJSON
+			if strings.EqualFold(filepath.Ext(path), ".csv") {
+				format = model.IPAMExportFormatCSV
+			}
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to open export file")
+		}
+		defer file.Close()
+
+		export, err := readIPAMExport(file, format)
+		if err != nil {
+			return errors.Wrap(err, "failed to read export file")
+		}
+
+		apply, _ := command.Flags().GetBool("apply")
+		report, err := client.ImportIPAM(&model.ImportIPAMRequest{
+			Apply:  apply,
+			Export: export,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to import IPAM state")
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"TYPE", "ADDED", "EXISTING"})
+			table.Append([]string{model.IPAMRecordParentSubnet, strconv.Itoa(report.ParentSubnetsAdded), strconv.Itoa(report.ParentSubnetsExisting)})
+			table.Append([]string{model.IPAMRecordSubnet, strconv.Itoa(report.SubnetsAdded), strconv.Itoa(report.SubnetsExisting)})
+			table.Append([]string{model.IPAMRecordExclusion, strconv.Itoa(report.ExclusionsAdded), strconv.Itoa(report.ExclusionsExisting)})
+			table.Render()
+
+			if len(report.Conflicts) != 0 {
+				table = tablewriter.NewWriter(os.Stdout)
+				table.SetAlignment(tablewriter.ALIGN_LEFT)
+				table.SetHeader([]string{"TYPE", "CIDR", "CONFLICT"})
+				for _, conflict := range report.Conflicts {
+					table.Append([]string{conflict.Type, conflict.CIDR, conflict.Reason})
+				}
+				table.Render()
+			}
+		} else if err = printJSON(report); err != nil {
+			return errors.Wrap(err, "failed to print IPAM import report")
+		}
+
+		if len(report.Conflicts) != 0 {
+			return errors.Errorf("IPAM export has %d conflicts with the existing state, nothing was imported", len(report.Conflicts))
+		}
+
+		return nil
+	},
+}
+
+// writeIPAMExport writes the given IPAM export in the given format.
+func writeIPAMExport(writer io.Writer, export *model.IPAMExport, format string) error {
+	if format == model.IPAMExportFormatCSV {
+		return export.WriteCSV(writer)
+	}
+
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(export)
+}
+
+// readIPAMExport reads an IPAM export in the given format.
+func readIPAMExport(reader io.Reader, format string) (*model.IPAMExport, error) {
+	switch format {
+	case model.IPAMExportFormatJSON:
+		return model.IPAMExportFromReader(reader)
+	case model.IPAMExportFormatCSV:
+		return model.IPAMExportFromCSV(reader)
+	default:
+		return nil, errors.Errorf("unsupported export format %s", format)
+	}
+}
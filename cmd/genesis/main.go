@@ -31,6 +31,7 @@ func init() {
 	rootCmd.AddCommand(securityCmd)
 	rootCmd.AddCommand(parentSubnetCmd)
 	rootCmd.AddCommand(subnetCmd)
+	rootCmd.AddCommand(ipamCmd)
 }
 
 func main() {
@@ -7,6 +7,9 @@ package main
 import (
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/mattermost/genesis/model"
 	"github.com/olekukonko/tablewriter"
@@ -20,11 +23,33 @@ func init() {
 
 	parentSubnetAddCmd.Flags().String("cidr", "", "The subnet that will be added in the parent subnet pool.")
 	parentSubnetAddCmd.Flags().Int("split-range", 24, "The range that the passed subnet range will be split into.")
+	parentSubnetAddCmd.Flags().StringToString("labels", nil, "The labels of the parent subnet used to select it when claiming subnets, i.e. environment=production,region=us-east-1.")
+	parentSubnetAddCmd.Flags().String("allocation-strategy", "", "The allocation strategy of the subnets claimed in the parent subnet: sequential, random, spread or best-fit. If not specified the allocation strategy of the server is used.")
 	parentSubnetAddCmd.MarkFlagRequired("cidr") //nolint
 
+	parentSubnetLabelCmd.Flags().String("subnet", "", "The id of the parent subnet to label.")
+	parentSubnetLabelCmd.Flags().StringToString("labels", nil, "The labels that replace the labels of the parent subnet. If not specified the labels are removed.")
+	parentSubnetLabelCmd.MarkFlagRequired("subnet") //nolint
+
+	parentSubnetStrategyCmd.Flags().String("subnet", "", "The id of the parent subnet to set the allocation strategy of.")
+	parentSubnetStrategyCmd.Flags().String("allocation-strategy", "", "The allocation strategy of the subnets claimed in the parent subnet: sequential, random, spread or best-fit. If not specified the allocation strategy of the server is used.")
+	parentSubnetStrategyCmd.MarkFlagRequired("subnet") //nolint
+
 	parentSubnetGetCmd.Flags().String("subnet", "", "The subnet id to get from the parent subnets.")
 	parentSubnetGetCmd.MarkFlagRequired("subnet") //nolint
 
+	parentSubnetDeleteCmd.Flags().String("subnet", "", "The id of the parent subnet to be deleted.")
+	parentSubnetDeleteCmd.MarkFlagRequired("subnet") //nolint
+
+	parentSubnetRetireCmd.Flags().String("subnet", "", "The id of the parent subnet to be retired.")
+	parentSubnetRetireCmd.MarkFlagRequired("subnet") //nolint
+
+	parentSubnetUnretireCmd.Flags().String("subnet", "", "The id of the parent subnet to be unretired.")
+	parentSubnetUnretireCmd.MarkFlagRequired("subnet") //nolint
+
+	parentSubnetUsageCmd.Flags().String("subnet", "", "The id of the parent subnet to report the usage of. If not specified the usage of all parent subnets is reported.")
+	parentSubnetUsageCmd.Flags().Bool("table", false, "Whether to display the returned parent subnet usage in a table or not")
+
 	parentSubnetListCmd.Flags().Int("page", 0, "The page of subnets to fetch, starting at 0.")
 	parentSubnetListCmd.Flags().Int("per-page", 100, "The number of parent subnets to fetch per page.")
 	parentSubnetListCmd.Flags().Bool("table", false, "Whether to display the returned parent subnet list in a table or not")
@@ -32,11 +57,18 @@ func init() {
 	parentSubnetCmd.AddCommand(parentSubnetAddCmd)
 	parentSubnetCmd.AddCommand(parentSubnetListCmd)
 	parentSubnetCmd.AddCommand(parentSubnetGetCmd)
+	parentSubnetCmd.AddCommand(parentSubnetDeleteCmd)
+	parentSubnetCmd.AddCommand(parentSubnetLabelCmd)
+	parentSubnetCmd.AddCommand(parentSubnetStrategyCmd)
+	parentSubnetCmd.AddCommand(parentSubnetRetireCmd)
+	parentSubnetCmd.AddCommand(parentSubnetUnretireCmd)
+	parentSubnetCmd.AddCommand(parentSubnetUsageCmd)
 }
 
 var parentSubnetCmd = &cobra.Command{
-	Use:   "parent-subnet",
-	Short: "Manipulate parent subnets managed by the genesis server.",
+	Use:     "parent-subnet",
+	Aliases: []string{"parentsubnet"},
+	Short:   "Manipulate parent subnets managed by the genesis server.",
 }
 
 var parentSubnetAddCmd = &cobra.Command{
@@ -54,9 +86,13 @@ var parentSubnetAddCmd = &cobra.Command{
 
 		cidr, _ := command.Flags().GetString("cidr")
 		splitRange, _ := command.Flags().GetInt("split-range")
+		labels, _ := command.Flags().GetStringToString("labels")
+		allocationStrategy, _ := command.Flags().GetString("allocation-strategy")
 		request := &model.AddParentSubnetRequest{
-			CIDR:       cidr,
-			SplitRange: splitRange,
+			CIDR:               cidr,
+			SplitRange:         splitRange,
+			Labels:             labels,
+			AllocationStrategy: allocationStrategy,
 		}
 
 		dryRun, _ := command.Flags().GetBool("dry-run")
@@ -108,12 +144,16 @@ var parentSubnetListCmd = &cobra.Command{
 		if outputToTable {
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetAlignment(tablewriter.ALIGN_LEFT)
-			table.SetHeader([]string{"PARENT SUBNET", "CIDR"})
+			table.SetHeader([]string{"PARENT SUBNET", "CIDR", "SPLIT RANGE", "RETIRED", "LABELS", "ALLOCATION STRATEGY"})
 
 			for _, subnet := range parentSubnets {
 				table.Append([]string{
 					subnet.ID,
 					subnet.CIDR,
+					strconv.Itoa(subnet.SplitRange),
+					strconv.FormatBool(subnet.Retired),
+					formatLabels(subnet.Labels),
+					subnet.AllocationStrategy,
 				})
 			}
 			table.Render()
@@ -158,3 +198,227 @@ var parentSubnetGetCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var parentSubnetDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a parent subnet that has no claimed subnets.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		subnet, _ := command.Flags().GetString("subnet")
+		if err := client.DeleteParentSubnet(subnet); err != nil {
+			return errors.Wrap(err, "failed to delete parent subnet")
+		}
+
+		return nil
+	},
+}
+
+var parentSubnetRetireCmd = &cobra.Command{
+	Use:   "retire",
+	Short: "Stop new subnets from being claimed from a parent subnet.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		subnet, _ := command.Flags().GetString("subnet")
+		if err := client.RetireParentSubnet(subnet); err != nil {
+			return errors.Wrap(err, "failed to retire parent subnet")
+		}
+
+		return nil
+	},
+}
+
+var parentSubnetUnretireCmd = &cobra.Command{
+	Use:   "unretire",
+	Short: "Allow subnets to be claimed again from a retired parent subnet.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		subnet, _ := command.Flags().GetString("subnet")
+		if err := client.UnretireParentSubnet(subnet); err != nil {
+			return errors.Wrap(err, "failed to unretire parent subnet")
+		}
+
+		return nil
+	},
+}
+
+var parentSubnetUsageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report how much of the parent subnets is allocated.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		var usages []*model.ParentSubnetUsage
+		subnet, _ := command.Flags().GetString("subnet")
+		if subnet != "" {
+			usage, err := client.GetParentSubnetUsage(subnet)
+			if err != nil {
+				return errors.Wrap(err, "failed to query parent subnet usage")
+			}
+			if usage == nil {
+				return nil
+			}
+			usages = append(usages, usage)
+		} else {
+			var err error
+			usages, err = client.GetParentSubnetsUsage()
+			if err != nil {
+				return errors.Wrap(err, "failed to query parent subnets usage")
+			}
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"PARENT SUBNET", "CIDR", "SPLIT RANGE", "RETIRED", "TOTAL", "CLAIMED", "RESERVED", "EXCLUDED", "FREE", "FREE %"})
+
+			for _, usage := range usages {
+				table.Append([]string{
+					usage.ParentSubnetID,
+					usage.CIDR,
+					strconv.Itoa(usage.SplitRange),
+					strconv.FormatBool(usage.Retired),
+					usage.Total.String(),
+					usage.Claimed.String(),
+					usage.Reserved.String(),
+					usage.Excluded.String(),
+					usage.Free.String(),
+					strconv.FormatFloat(usage.FreePercent, 'f', 2, 64),
+				})
+			}
+			table.Render()
+
+			return nil
+		}
+
+		if err := printJSON(usages); err != nil {
+			return errors.Wrap(err, "failed to print parent subnet usage response")
+		}
+
+		return nil
+	},
+}
+
+var parentSubnetLabelCmd = &cobra.Command{
+	Use:   "label",
+	Short: "Replace the labels of a parent subnet.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		subnet, _ := command.Flags().GetString("subnet")
+		labels, _ := command.Flags().GetStringToString("labels")
+		request := &model.UpdateParentSubnetLabelsRequest{
+			Labels: labels,
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			if err := printJSON(request); err != nil {
+				return errors.Wrap(err, "failed to print API request")
+			}
+
+			return nil
+		}
+
+		parentSubnet, err := client.UpdateParentSubnetLabels(subnet, request)
+		if err != nil {
+			return errors.Wrap(err, "failed to update parent subnet labels")
+		}
+
+		if err = printJSON(parentSubnet); err != nil {
+			return errors.Wrap(err, "failed to print parent subnet response")
+		}
+
+		return nil
+	},
+}
+
+var parentSubnetStrategyCmd = &cobra.Command{
+	Use:   "strategy",
+	Short: "Set the allocation strategy of a parent subnet.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		subnet, _ := command.Flags().GetString("subnet")
+		allocationStrategy, _ := command.Flags().GetString("allocation-strategy")
+		request := &model.UpdateParentSubnetAllocationStrategyRequest{
+			AllocationStrategy: allocationStrategy,
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			if err := printJSON(request); err != nil {
+				return errors.Wrap(err, "failed to print API request")
+			}
+
+			return nil
+		}
+
+		parentSubnet, err := client.UpdateParentSubnetAllocationStrategy(subnet, request)
+		if err != nil {
+			return errors.Wrap(err, "failed to update parent subnet allocation strategy")
+		}
+
+		if err = printJSON(parentSubnet); err != nil {
+			return errors.Wrap(err, "failed to print parent subnet response")
+		}
+
+		return nil
+	},
+}
+
+// formatLabels formats labels as sorted key=value pairs.
+func formatLabels(labels map[string]string) string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
@@ -6,6 +6,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -55,6 +56,11 @@ func init() {
 	serverCmd.PersistentFlags().String("teleport-cidr", "", "The Teleport CIDR that will be allowing teleport to the cluster and nodes")
 	serverCmd.PersistentFlags().String("cnc-cidrs", "", "The CIDRs of the CnC subnets that will get access to the clusters")
 	serverCmd.PersistentFlags().String("bind-ips", "", "The Bind servers that should be passed in the VPC DHCP options")
+	serverCmd.PersistentFlags().IntSlice("subnet-low-watermarks", nil, "The percentages of free subnets of a parent subnet below which a parent subnet webhook is sent, i.e. 20,10,5")
+	serverCmd.PersistentFlags().String("subnet-allocation-strategy", model.DefaultSubnetAllocationStrategy, "The allocation strategy of the subnets claimed for accounts: sequential, random, spread or best-fit. Parent subnets can override it")
+	serverCmd.PersistentFlags().Bool("seed-subnet-exclusions", true, "Whether the ranges of the tgw-routes, teleport-cidr, cnc-cidrs and bind-ips flags are excluded from the subnets claimed for accounts")
+	serverCmd.PersistentFlags().String("subnet-discovery-role", "OrganizationAccountAccessRole", "The IAM role assumed from the Control Tower account in the AWS accounts whose VPCs are discovered")
+	serverCmd.PersistentFlags().String("ipv6-pool-id", "", "The IPv6 address pool that IPv6 VPC CIDR blocks of dual-stack accounts are allocated from")
 
 	serverCmd.MarkFlagRequired("sso-user-email")        //nolint
 	serverCmd.MarkFlagRequired("sso-first-name")        //nolint
@@ -74,6 +80,14 @@ func init() {
 	// Supervisors
 	serverCmd.PersistentFlags().Int("poll", 30, "The interval in seconds to poll for background work.")
 	serverCmd.PersistentFlags().Bool("account-supervisor", true, "Whether this server will run an account supervisor or not.")
+	serverCmd.PersistentFlags().Int("subnet-reconcile-poll", 0, "The interval in seconds to reconcile the subnet claims with the accounts. Set to 0 to disable the subnet reconciler.")
+	serverCmd.PersistentFlags().Bool("subnet-reconcile-fix", false, "Whether the subnet reconciler fixes the subnet claims out of sync with the accounts or only reports them.")
+	serverCmd.PersistentFlags().Duration("subnet-reconcile-min-claim-age", 10*time.Minute, "The age a subnet claim must have before the subnet reconciler considers it orphaned.")
+	serverCmd.PersistentFlags().Duration("subnet-quarantine", 0, "How long the subnets of deleted accounts and the orphaned claims released by the subnet reconciliation are kept in quarantine before they can be claimed again. Set to 0 to release them immediately.")
+	serverCmd.PersistentFlags().Int("webhook-delivery-poll", 1, "The interval in seconds to deliver the webhook payloads in the outbox. Set to 0 to disable the webhook deliverer.")
+	serverCmd.PersistentFlags().Duration("webhook-max-age", 24*time.Hour, "How long failed webhook deliveries are retried before they are given up on. Set to 0 to retry them forever.")
+	serverCmd.PersistentFlags().Duration("webhook-retention", 30*24*time.Hour, "How long delivered and failed webhook deliveries and their attempts are kept. Set to 0 to keep them forever.")
+	serverCmd.PersistentFlags().Int("webhook-disable-after", 10, "The number of consecutive failed delivery attempts after which a webhook is disabled. Set to 0 to never disable webhooks.")
 }
 
 var serverCmd = &cobra.Command{
@@ -156,6 +170,34 @@ var serverCmd = &cobra.Command{
 		teleportCIDR, _ := command.Flags().GetString("teleport-cidr")
 		cncCIDRs, _ := command.Flags().GetString("cnc-cidrs")
 		bindServerIPs, _ := command.Flags().GetString("bind-ips")
+		ipv6PoolID, _ := command.Flags().GetString("ipv6-pool-id")
+
+		subnetLowWatermarks, _ := command.Flags().GetIntSlice("subnet-low-watermarks")
+		for _, watermark := range subnetLowWatermarks {
+			if watermark <= 0 || watermark >= 100 {
+				return errors.Errorf("subnet low watermark %d must be between 0 and 100", watermark)
+			}
+		}
+
+		subnetAllocationStrategy, _ := command.Flags().GetString("subnet-allocation-strategy")
+		if !model.IsValidSubnetAllocationStrategy(subnetAllocationStrategy) {
+			return errors.Errorf("unsupported subnet allocation strategy %s", subnetAllocationStrategy)
+		}
+
+		subnetDiscoveryRole, _ := command.Flags().GetString("subnet-discovery-role")
+
+		seedSubnetExclusions, _ := command.Flags().GetBool("seed-subnet-exclusions")
+		if seedSubnetExclusions {
+			err = seedInfrastructureSubnetExclusions(sqlStore, []exclusionFlag{
+				{name: "tgw-routes", value: transitGatewayRoutes},
+				{name: "teleport-cidr", value: teleportCIDR},
+				{name: "cnc-cidrs", value: cncCIDRs},
+				{name: "bind-ips", value: bindServerIPs},
+			}, logger)
+			if err != nil {
+				return errors.Wrap(err, "failed to seed subnet exclusions")
+			}
+		}
 
 		accountCreation := model.AccountCreation{
 			SSOUserEmail:          ssoUserEmail,
@@ -176,6 +218,7 @@ var serverCmd = &cobra.Command{
 			TeleportCIDR:         teleportCIDR,
 			CncCIDRs:             cncCIDRs,
 			BindServerIPs:        bindServerIPs,
+			IPv6PoolID:           ipv6PoolID,
 		}
 
 		// Setup the provisioner for actually effecting changes to enterprise resources.
@@ -185,9 +228,11 @@ var serverCmd = &cobra.Command{
 			logger,
 		)
 
+		subnetQuarantine, _ := command.Flags().GetDuration("subnet-quarantine")
+
 		var multiDoer supervisor.MultiDoer
 		if accountSupervisor {
-			multiDoer = append(multiDoer, supervisor.NewAccountSupervisor(sqlStore, genesisProvisioner, awsClient, instanceID, logger))
+			multiDoer = append(multiDoer, supervisor.NewAccountSupervisor(sqlStore, genesisProvisioner, awsClient, subnetQuarantine, instanceID, logger))
 		}
 
 		// Setup the supervisor to effect any requested changes. It is wrapped in a
@@ -198,6 +243,33 @@ var serverCmd = &cobra.Command{
 			logger.WithField("poll", poll).Info("Scheduler is disabled")
 		}
 
+		subnetReconcilePoll, _ := command.Flags().GetInt("subnet-reconcile-poll")
+		subnetReconcileFix, _ := command.Flags().GetBool("subnet-reconcile-fix")
+		subnetReconcileMinClaimAge, _ := command.Flags().GetDuration("subnet-reconcile-min-claim-age")
+		if subnetReconcilePoll == 0 {
+			logger.Info("Subnet reconciler is disabled")
+		}
+
+		subnetReconciler := supervisor.NewScheduler(
+			supervisor.NewSubnetReconciler(sqlStore, subnetReconcileFix, subnetReconcileMinClaimAge, subnetQuarantine, logger),
+			time.Duration(subnetReconcilePoll)*time.Second,
+		)
+		defer subnetReconciler.Close()
+
+		webhookDeliveryPoll, _ := command.Flags().GetInt("webhook-delivery-poll")
+		webhookMaxAge, _ := command.Flags().GetDuration("webhook-max-age")
+		webhookRetention, _ := command.Flags().GetDuration("webhook-retention")
+		webhookDisableAfter, _ := command.Flags().GetInt("webhook-disable-after")
+		if webhookDeliveryPoll == 0 {
+			logger.Warn("Webhook deliverer is disabled, webhook payloads are kept in the outbox")
+		}
+
+		webhookDeliverer := supervisor.NewScheduler(
+			supervisor.NewWebhookDeliverer(sqlStore, webhookMaxAge, webhookRetention, webhookDisableAfter, instanceID, logger),
+			time.Duration(webhookDeliveryPoll)*time.Second,
+		)
+		defer webhookDeliverer.Close()
+
 		supervisor := supervisor.NewScheduler(multiDoer, time.Duration(poll)*time.Second)
 		defer supervisor.Close()
 
@@ -207,8 +279,14 @@ var serverCmd = &cobra.Command{
 			Store:       sqlStore,
 			Supervisor:  supervisor,
 			Genesis:     genesisProvisioner,
+			AWS:         awsClient,
 			Environment: environment,
 			Logger:      logger,
+
+			SubnetLowWatermarks:      subnetLowWatermarks,
+			SubnetAllocationStrategy: subnetAllocationStrategy,
+			SubnetDiscoveryRole:      subnetDiscoveryRole,
+			SubnetQuarantine:         subnetQuarantine,
 		})
 
 		listen, _ := command.Flags().GetString("listen")
@@ -249,6 +327,43 @@ var serverCmd = &cobra.Command{
 	},
 }
 
+// exclusionFlag is a server flag whose ranges are excluded from the subnets.
+type exclusionFlag struct {
+	name  string
+	value string
+}
+
+// seedInfrastructureSubnetExclusions excludes the ranges of the given server
+// flags from the subnets claimed for accounts. A range given by several flags
+// is seeded from the first of them, so that its source does not change from one
+// start to the next.
+func seedInfrastructureSubnetExclusions(sqlStore *store.SQLStore, flags []exclusionFlag, logger logrus.FieldLogger) error {
+	seen := make(map[string]bool)
+	for _, flag := range flags {
+		parsed, err := model.ParseExclusionCIDRs(flag.value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse the ranges of the %s flag", flag.name)
+		}
+
+		var cidrs []string
+		for _, cidr := range parsed {
+			if seen[cidr] {
+				continue
+			}
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+
+		err = sqlStore.SeedSubnetExclusions(flag.name, cidrs, fmt.Sprintf("Seeded from the --%s server flag", flag.name))
+		if err != nil {
+			return err
+		}
+		logger.WithField("flag", flag.name).Debugf("Excluded %d ranges from the subnets", len(cidrs))
+	}
+
+	return nil
+}
+
 // deprecationWarnings performs all checks for deprecated settings and warns if
 // any are found.
 func deprecationWarnings(logger logrus.FieldLogger, cmd *cobra.Command) {
@@ -5,8 +5,12 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mattermost/genesis/model"
 	"github.com/olekukonko/tablewriter"
@@ -23,11 +27,49 @@ func init() {
 
 	subnetListCmd.Flags().Int("page", 0, "The page of subnets to fetch, starting at 0.")
 	subnetListCmd.Flags().Int("per-page", 100, "The number of subnets to fetch per page.")
-	subnetListCmd.Flags().Bool("free-subnets", false, "When set to true only available subnets are returned .")
+	subnetListCmd.Flags().Bool("free-subnets", false, "When set to true the available subnets are returned instead of the claimed ones.")
+	subnetListCmd.Flags().String("family", "", "When set only subnets of the given address family (ipv4 or ipv6) are returned.")
+	subnetListCmd.Flags().Bool("reserved", false, "When set to true the reserved subnets are returned instead of the claimed ones.")
+	subnetListCmd.Flags().Bool("quarantined", false, "When set to true the subnets in quarantine after their release are returned instead of the claimed ones.")
 	subnetListCmd.Flags().Bool("table", false, "Whether to display the returned subnet list in a table or not")
 
+	subnetReserveCmd.Flags().String("cidr", "", "The free subnet CIDR to reserve.")
+	subnetReserveCmd.Flags().String("subnet", "", "The id of a stored subnet to reserve instead of a free subnet CIDR.")
+	subnetReserveCmd.Flags().String("owner", "", "The owner of the reservation.")
+	subnetReserveCmd.Flags().String("reason", "", "The reason of the reservation.")
+	subnetReserveCmd.Flags().Duration("expires-in", 0, "The duration after which the reservation expires. If not specified the reservation never expires.")
+	subnetReserveCmd.MarkFlagRequired("owner")  //nolint
+	subnetReserveCmd.MarkFlagRequired("reason") //nolint
+
+	subnetReleaseCmd.Flags().String("subnet", "", "The id of the reserved subnet to release.")
+	subnetReleaseCmd.MarkFlagRequired("subnet") //nolint
+
+	subnetReconcileCmd.Flags().Bool("fix", false, "When set to true the subnet claims out of sync with the accounts are fixed instead of only reported.")
+	subnetReconcileCmd.Flags().Duration("min-claim-age", 10*time.Minute, "The age a subnet claim must have to be considered orphaned, so that the claims of accounts being created are kept.")
+	subnetReconcileCmd.Flags().Bool("table", false, "Whether to display the subnet drifts in a table or not")
+
+	subnetDiscoverCmd.Flags().StringSlice("aws-account", nil, "The AWS accounts whose VPCs are discovered. If not specified all the active accounts of the organization are discovered.")
+	subnetDiscoverCmd.Flags().StringSlice("region", nil, "The regions whose VPCs are discovered. If not specified all the regions enabled in each account are discovered.")
+	subnetDiscoverCmd.Flags().String("role", "", "The IAM role assumed in the discovered accounts. If not specified the role configured on the server is used.")
+	subnetDiscoverCmd.Flags().Bool("report-only", false, "When set to true the VPCs overlapping parent subnets are only reported instead of marking their subnets as externally used.")
+	subnetDiscoverCmd.Flags().Bool("table", false, "Whether to display the overlap report in a table or not")
+
+	subnetWhoisCmd.Flags().Bool("table", false, "Whether to display the subnets containing the IP address in a table or not")
+
+	subnetHistoryCmd.Flags().Int("page", 0, "The page of subnet allocations to fetch, starting at 0.")
+	subnetHistoryCmd.Flags().Int("per-page", 100, "The number of subnet allocations to fetch per page.")
+	subnetHistoryCmd.Flags().String("cidr", "", "When set only the allocations of the given subnet CIDR are returned.")
+	subnetHistoryCmd.Flags().String("account", "", "When set only the allocations of the given account are returned.")
+	subnetHistoryCmd.Flags().Bool("table", false, "Whether to display the subnet allocations in a table or not")
+
 	subnetCmd.AddCommand(subnetListCmd)
 	subnetCmd.AddCommand(subnetGetCmd)
+	subnetCmd.AddCommand(subnetReserveCmd)
+	subnetCmd.AddCommand(subnetReleaseCmd)
+	subnetCmd.AddCommand(subnetReconcileCmd)
+	subnetCmd.AddCommand(subnetDiscoverCmd)
+	subnetCmd.AddCommand(subnetWhoisCmd)
+	subnetCmd.AddCommand(subnetHistoryCmd)
 }
 
 var subnetCmd = &cobra.Command{
@@ -51,10 +93,16 @@ var subnetListCmd = &cobra.Command{
 		page, _ := command.Flags().GetInt("page")
 		perPage, _ := command.Flags().GetInt("per-page")
 		free, _ := command.Flags().GetBool("free-subnets")
+		family, _ := command.Flags().GetString("family")
+		reserved, _ := command.Flags().GetBool("reserved")
+		quarantined, _ := command.Flags().GetBool("quarantined")
 		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{
-			Page:    page,
-			PerPage: perPage,
-			Free:    free,
+			Page:        page,
+			PerPage:     perPage,
+			Free:        free,
+			Family:      family,
+			Reserved:    reserved,
+			Quarantined: quarantined,
 		})
 		if err != nil {
 			return errors.Wrap(err, "failed to query subnets")
@@ -64,6 +112,29 @@ var subnetListCmd = &cobra.Command{
 		if outputToTable {
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+			if reserved || quarantined {
+				table.SetHeader([]string{"SUBNET", "CIDR", "OWNER", "REASON", "EXPIRES AT", "PARENT SUBNET"})
+
+				for _, subnet := range subnets {
+					expiresAt := "never"
+					if subnet.ReservationExpiresAt != 0 {
+						expiresAt = time.Unix(0, subnet.ReservationExpiresAt*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+					}
+					table.Append([]string{
+						subnet.ID,
+						subnet.CIDR,
+						subnet.ReservationOwner,
+						subnet.ReservationReason,
+						expiresAt,
+						subnet.ParentSubnet,
+					})
+				}
+				table.Render()
+
+				return nil
+			}
+
 			table.SetHeader([]string{"SUBNET", "CIDR", "ACCOUNT ID", "PARENT SUBNET"})
 
 			for _, subnet := range subnets {
@@ -116,3 +187,349 @@ var subnetGetCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var subnetReserveCmd = &cobra.Command{
+	Use:   "reserve",
+	Short: "Reserve a subnet so that it cannot be claimed by accounts.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		cidr, _ := command.Flags().GetString("cidr")
+		subnetID, _ := command.Flags().GetString("subnet")
+		if (cidr == "") == (subnetID == "") {
+			return errors.New("exactly one of --cidr and --subnet must be specified")
+		}
+
+		owner, _ := command.Flags().GetString("owner")
+		reason, _ := command.Flags().GetString("reason")
+		expiresIn, _ := command.Flags().GetDuration("expires-in")
+
+		request := &model.ReserveSubnetRequest{
+			CIDR:   cidr,
+			Owner:  owner,
+			Reason: reason,
+		}
+		if expiresIn > 0 {
+			request.ExpiresAt = time.Now().Add(expiresIn).UnixNano() / int64(time.Millisecond)
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			if err := printJSON(request); err != nil {
+				return errors.Wrap(err, "failed to print API request")
+			}
+
+			return nil
+		}
+
+		var subnet *model.Subnet
+		var err error
+		if subnetID != "" {
+			subnet, err = client.ReserveExistingSubnet(subnetID, request)
+		} else {
+			subnet, err = client.ReserveSubnet(request)
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to reserve subnet")
+		}
+
+		if err = printJSON(subnet); err != nil {
+			return errors.Wrap(err, "failed to print subnet response")
+		}
+
+		return nil
+	},
+}
+
+var subnetReleaseCmd = &cobra.Command{
+	Use:   "release",
+	Short: "Release a subnet reservation.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		subnetID, _ := command.Flags().GetString("subnet")
+		if err := client.ReleaseSubnet(subnetID); err != nil {
+			return errors.Wrap(err, "failed to release subnet")
+		}
+
+		return nil
+	},
+}
+
+var subnetReconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Report and optionally fix the subnet claims out of sync with the accounts.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		fix, _ := command.Flags().GetBool("fix")
+		minClaimAge, _ := command.Flags().GetDuration("min-claim-age")
+		request := &model.ReconcileSubnetsRequest{
+			Fix:         fix,
+			MinClaimAge: int64(minClaimAge / time.Millisecond),
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			if err := printJSON(request); err != nil {
+				return errors.Wrap(err, "failed to print API request")
+			}
+
+			return nil
+		}
+
+		drifts, err := client.ReconcileSubnets(request)
+		if err != nil {
+			return errors.Wrap(err, "failed to reconcile subnets")
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"DRIFT", "CIDR", "SUBNET", "CLAIM ACCOUNT ID", "ACCOUNT ID", "FIXED", "ERROR"})
+
+			for _, drift := range drifts {
+				table.Append([]string{
+					drift.Type,
+					drift.CIDR,
+					drift.SubnetID,
+					drift.ClaimAccountID,
+					drift.AccountID,
+					strconv.FormatBool(drift.Fixed),
+					drift.Error,
+				})
+			}
+			table.Render()
+
+			return nil
+		}
+
+		if err = printJSON(drifts); err != nil {
+			return errors.Wrap(err, "failed to print subnet drifts response")
+		}
+
+		return nil
+	},
+}
+
+var subnetDiscoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Discover the VPCs of AWS accounts and mark the subnets they use as externally used.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		awsAccountIDs, _ := command.Flags().GetStringSlice("aws-account")
+		regions, _ := command.Flags().GetStringSlice("region")
+		roleName, _ := command.Flags().GetString("role")
+		reportOnly, _ := command.Flags().GetBool("report-only")
+		request := &model.DiscoverSubnetsRequest{
+			AWSAccountIDs: awsAccountIDs,
+			Regions:       regions,
+			RoleName:      roleName,
+			ReportOnly:    reportOnly,
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			if err := printJSON(request); err != nil {
+				return errors.Wrap(err, "failed to print API request")
+			}
+
+			return nil
+		}
+
+		discovery, err := client.DiscoverSubnets(request)
+		if err != nil {
+			return errors.Wrap(err, "failed to discover subnets")
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"AWS ACCOUNT", "REGION", "VPC", "CIDR", "PARENT SUBNET", "SUBNET CIDR", "OVERLAPPING SUBNETS", "STATUS"})
+
+			for _, overlap := range discovery.Overlaps {
+				table.Append([]string{
+					overlap.AWSAccountID,
+					overlap.Region,
+					overlap.VPCID,
+					overlap.CIDR,
+					overlap.ParentSubnet,
+					overlap.SubnetCIDR,
+					strings.Join(overlap.Subnets, ","),
+					overlap.Status,
+				})
+			}
+			table.Render()
+
+			for _, failure := range discovery.Failures {
+				target := failure.AWSAccountID
+				if failure.Region != "" {
+					target = fmt.Sprintf("%s in %s", failure.AWSAccountID, failure.Region)
+				}
+				fmt.Fprintf(os.Stderr, "failed to discover AWS account %s: %s\n", target, failure.Error)
+			}
+
+			return nil
+		}
+
+		if err = printJSON(discovery); err != nil {
+			return errors.Wrap(err, "failed to print subnet discovery response")
+		}
+
+		return nil
+	},
+}
+
+var subnetWhoisCmd = &cobra.Command{
+	Use:   "whois <ip>",
+	Short: "Find the subnet containing an IP address and the account it belongs to.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		owners, err := client.GetSubnetOwners(args[0])
+		if err != nil {
+			return errors.Wrap(err, "failed to query subnets containing the IP address")
+		}
+		if len(owners) == 0 {
+			return errors.Errorf("no subnet contains %s", args[0])
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"SUBNET", "CIDR", "PARENT SUBNET", "LABELS", "ACCOUNT", "AWS ACCOUNT", "RESERVATION OWNER"})
+
+			for _, owner := range owners {
+				var labels, accountID, awsAccountID string
+				if owner.ParentSubnet != nil {
+					labels = formatLabels(owner.ParentSubnet.Labels)
+				}
+				if owner.Account != nil {
+					accountID = owner.Account.ID
+					if owner.Account.ProviderMetadataAWS != nil {
+						awsAccountID = owner.Account.ProviderMetadataAWS.AWSAccountID
+					}
+				} else {
+					accountID = owner.Subnet.AccountID
+				}
+				table.Append([]string{
+					owner.Subnet.ID,
+					owner.Subnet.CIDR,
+					owner.Subnet.ParentSubnet,
+					labels,
+					accountID,
+					awsAccountID,
+					owner.Subnet.ReservationOwner,
+				})
+			}
+			table.Render()
+
+			return nil
+		}
+
+		if err = printJSON(owners); err != nil {
+			return errors.Wrap(err, "failed to print subnet owners response")
+		}
+
+		return nil
+	},
+}
+
+var subnetHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the accounts that held subnets and when.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		page, _ := command.Flags().GetInt("page")
+		perPage, _ := command.Flags().GetInt("per-page")
+		cidr, _ := command.Flags().GetString("cidr")
+		accountID, _ := command.Flags().GetString("account")
+		allocations, err := client.GetSubnetAllocations(&model.GetSubnetAllocationsRequest{
+			Page:      page,
+			PerPage:   perPage,
+			CIDR:      cidr,
+			AccountID: accountID,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to query subnet allocations")
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"CIDR", "ACCOUNT ID", "PARENT SUBNET", "CLAIMED AT", "RELEASED AT"})
+
+			for _, allocation := range allocations {
+				releasedAt := ""
+				if allocation.IsReleased() {
+					releasedAt = time.Unix(0, allocation.ReleasedAt*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+				}
+				table.Append([]string{
+					allocation.CIDR,
+					allocation.AccountID,
+					allocation.ParentSubnet,
+					time.Unix(0, allocation.ClaimedAt*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+					releasedAt,
+				})
+			}
+			table.Render()
+
+			return nil
+		}
+
+		if err = printJSON(allocations); err != nil {
+			return errors.Wrap(err, "failed to print subnet allocations response")
+		}
+
+		return nil
+	},
+}
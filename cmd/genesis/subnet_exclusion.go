@@ -0,0 +1,192 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package main
+
+import (
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mattermost/genesis/model"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	subnetExclusionAddCmd.Flags().String("cidr", "", "The range to exclude from the subnets claimed for accounts.")
+	subnetExclusionAddCmd.Flags().String("reason", "", "The reason of the exclusion.")
+	subnetExclusionAddCmd.MarkFlagRequired("cidr")   //nolint
+	subnetExclusionAddCmd.MarkFlagRequired("reason") //nolint
+
+	subnetExclusionGetCmd.Flags().String("exclusion", "", "The id of the subnet exclusion to get.")
+	subnetExclusionGetCmd.MarkFlagRequired("exclusion") //nolint
+
+	subnetExclusionListCmd.Flags().Int("page", 0, "The page of subnet exclusions to fetch, starting at 0.")
+	subnetExclusionListCmd.Flags().Int("per-page", 100, "The number of subnet exclusions to fetch per page.")
+	subnetExclusionListCmd.Flags().String("source", "", "When set only the subnet exclusions of the given source (api or the name of a server flag) are returned.")
+	subnetExclusionListCmd.Flags().Bool("table", false, "Whether to display the returned subnet exclusion list in a table or not")
+
+	subnetExclusionDeleteCmd.Flags().String("exclusion", "", "The id of the subnet exclusion to delete.")
+	subnetExclusionDeleteCmd.MarkFlagRequired("exclusion") //nolint
+
+	subnetExclusionCmd.AddCommand(subnetExclusionAddCmd)
+	subnetExclusionCmd.AddCommand(subnetExclusionGetCmd)
+	subnetExclusionCmd.AddCommand(subnetExclusionListCmd)
+	subnetExclusionCmd.AddCommand(subnetExclusionDeleteCmd)
+	subnetCmd.AddCommand(subnetExclusionCmd)
+}
+
+var subnetExclusionCmd = &cobra.Command{
+	Use:   "exclusion",
+	Short: "Manipulate the ranges excluded from the subnets claimed for accounts.",
+}
+
+var subnetExclusionAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Exclude a range from the subnets claimed for accounts.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		cidr, _ := command.Flags().GetString("cidr")
+		reason, _ := command.Flags().GetString("reason")
+		request := &model.AddSubnetExclusionRequest{
+			CIDR:   cidr,
+			Reason: reason,
+		}
+
+		dryRun, _ := command.Flags().GetBool("dry-run")
+		if dryRun {
+			if err := printJSON(request); err != nil {
+				return errors.Wrap(err, "failed to print API request")
+			}
+
+			return nil
+		}
+
+		exclusion, err := client.AddSubnetExclusion(request)
+		if err != nil {
+			return errors.Wrap(err, "failed to add subnet exclusion")
+		}
+
+		if err = printJSON(exclusion); err != nil {
+			return errors.Wrap(err, "failed to print subnet exclusion response")
+		}
+
+		return nil
+	},
+}
+
+var subnetExclusionGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Get a particular subnet exclusion.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		exclusionID, _ := command.Flags().GetString("exclusion")
+		exclusion, err := client.GetSubnetExclusion(exclusionID)
+		if err != nil {
+			return errors.Wrap(err, "failed to query subnet exclusion")
+		}
+		if exclusion == nil {
+			return nil
+		}
+
+		if err = printJSON(exclusion); err != nil {
+			return errors.Wrap(err, "failed to print subnet exclusion response")
+		}
+
+		return nil
+	},
+}
+
+var subnetExclusionListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the ranges excluded from the subnets claimed for accounts.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		page, _ := command.Flags().GetInt("page")
+		perPage, _ := command.Flags().GetInt("per-page")
+		source, _ := command.Flags().GetString("source")
+		exclusions, err := client.GetSubnetExclusions(&model.GetSubnetExclusionsRequest{
+			Page:    page,
+			PerPage: perPage,
+			Source:  source,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to query subnet exclusions")
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"ID", "CIDR", "SOURCE", "REASON", "CREATED AT"})
+
+			for _, exclusion := range exclusions {
+				table.Append([]string{
+					exclusion.ID,
+					exclusion.CIDR,
+					exclusion.Source,
+					exclusion.Reason,
+					time.Unix(0, exclusion.CreateAt*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+				})
+			}
+			table.Render()
+
+			return nil
+		}
+
+		if err = printJSON(exclusions); err != nil {
+			return errors.Wrap(err, "failed to print subnet exclusions response")
+		}
+
+		return nil
+	},
+}
+
+var subnetExclusionDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a subnet exclusion added through the API.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		exclusionID, _ := command.Flags().GetString("exclusion")
+		if err := client.DeleteSubnetExclusion(exclusionID); err != nil {
+			return errors.Wrap(err, "failed to delete subnet exclusion")
+		}
+
+		return nil
+	},
+}
@@ -7,6 +7,8 @@ package main
 import (
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/mattermost/genesis/model"
 	"github.com/olekukonko/tablewriter"
@@ -19,6 +21,15 @@ func init() {
 
 	webhookCreateCmd.Flags().String("owner", "", "An opaque identifier describing the owner of the webhook.")
 	webhookCreateCmd.Flags().String("url", "", "The callback URL of the webhook.")
+	webhookCreateCmd.Flags().String("secret", "", "The secret used to sign the payloads sent to the webhook. If not specified the payloads are not signed.")
+	webhookCreateCmd.Flags().StringSlice("types", nil, "The resource types of the events sent to the webhook, i.e. account,parent_subnet,subnet. If not specified events of every type are sent.")
+	webhookCreateCmd.Flags().StringSlice("states", nil, "The new states of the events sent to the webhook, which may contain patterns, i.e. '*-failed'. If not specified events of every state are sent.")
+	webhookCreateCmd.Flags().String("format", model.WebhookFormatGenesis, "The format of the payloads sent to the webhook: genesis, or mattermost and slack to post messages to an incoming webhook of a chat.")
+	webhookCreateCmd.Flags().StringArray("header", nil, "A custom header set on the requests sent to the webhook, as 'Name: value'. Can be repeated.")
+	webhookCreateCmd.Flags().String("basic-auth", "", "The credentials of the basic authentication of the requests sent to the webhook, as 'username:password'.")
+	webhookCreateCmd.Flags().String("bearer-token", "", "The bearer token authenticating the requests sent to the webhook.")
+	webhookCreateCmd.Flags().Int("timeout", model.DefaultWebhookTimeoutSeconds, "How long, in seconds, the webhook receiver has to respond.")
+	webhookCreateCmd.Flags().String("environment", "", "The environment of the events sent to the webhook. If not specified events of every environment are sent.")
 	webhookCreateCmd.MarkFlagRequired("owner") //nolint
 	webhookCreateCmd.MarkFlagRequired("url")   //nolint
 
@@ -34,10 +45,18 @@ func init() {
 	webhookDeleteCmd.Flags().String("webhook", "", "The id of the webhook to be deleted.")
 	webhookDeleteCmd.MarkFlagRequired("webhook") //nolint
 
+	webhookEnableCmd.Flags().String("webhook", "", "The id of the webhook to be enabled.")
+	webhookEnableCmd.MarkFlagRequired("webhook") //nolint
+
+	webhookPingCmd.Flags().String("webhook", "", "The id of the webhook to be pinged.")
+	webhookPingCmd.MarkFlagRequired("webhook") //nolint
+
 	webhookCmd.AddCommand(webhookCreateCmd)
 	webhookCmd.AddCommand(webhookGetCmd)
 	webhookCmd.AddCommand(webhookListCmd)
 	webhookCmd.AddCommand(webhookDeleteCmd)
+	webhookCmd.AddCommand(webhookEnableCmd)
+	webhookCmd.AddCommand(webhookPingCmd)
 }
 
 var webhookCmd = &cobra.Command{
@@ -60,11 +79,56 @@ var webhookCreateCmd = &cobra.Command{
 
 		ownerID, _ := command.Flags().GetString("owner")
 		url, _ := command.Flags().GetString("url")
+		secret, _ := command.Flags().GetString("secret")
+		types, _ := command.Flags().GetStringSlice("types")
+		states, _ := command.Flags().GetStringSlice("states")
+		environment, _ := command.Flags().GetString("environment")
+		format, _ := command.Flags().GetString("format")
+		headers, _ := command.Flags().GetStringArray("header")
+		basicAuth, _ := command.Flags().GetString("basic-auth")
+		bearerToken, _ := command.Flags().GetString("bearer-token")
+		timeout, _ := command.Flags().GetInt("timeout")
+
+		request := &model.CreateWebhookRequest{
+			OwnerID:        ownerID,
+			URL:            url,
+			Secret:         secret,
+			Format:         format,
+			TimeoutSeconds: timeout,
+		}
+		for _, header := range headers {
+			parts := strings.SplitN(header, ":", 2)
+			if len(parts) != 2 {
+				return errors.Errorf("invalid header %s: must be formatted as 'Name: value'", header)
+			}
+			if request.Headers == nil {
+				request.Headers = make(map[string]string)
+			}
+			request.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		if basicAuth != "" && bearerToken != "" {
+			return errors.New("only one of basic-auth and bearer-token can be set")
+		}
+		if basicAuth != "" {
+			parts := strings.SplitN(basicAuth, ":", 2)
+			request.Auth = &model.WebhookAuth{Type: model.WebhookAuthBasic, Username: parts[0]}
+			if len(parts) == 2 {
+				request.Auth.Password = parts[1]
+			}
+		}
+		if bearerToken != "" {
+			request.Auth = &model.WebhookAuth{Type: model.WebhookAuthBearer, Token: bearerToken}
+		}
+		subscription := &model.WebhookSubscription{
+			Types:       types,
+			States:      states,
+			Environment: environment,
+		}
+		if !subscription.IsEmpty() {
+			request.Subscription = subscription
+		}
 
-		webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
-			OwnerID: ownerID,
-			URL:     url,
-		})
+		webhook, err := client.CreateWebhook(request)
 		if err != nil {
 			return errors.Wrap(err, "failed to create webhook")
 		}
@@ -142,10 +206,17 @@ var webhookListCmd = &cobra.Command{
 		if outputToTable {
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetAlignment(tablewriter.ALIGN_LEFT)
-			table.SetHeader([]string{"ID", "OWNER", "URL"})
+			table.SetHeader([]string{"ID", "OWNER", "URL", "FORMAT", "DISABLED", "FAILURES"})
 
 			for _, webhook := range webhooks {
-				table.Append([]string{webhook.ID, webhook.OwnerID, webhook.URL})
+				table.Append([]string{
+					webhook.ID,
+					webhook.OwnerID,
+					webhook.URL,
+					webhook.Format,
+					strconv.FormatBool(webhook.Disabled),
+					strconv.Itoa(webhook.ConsecutiveFailures),
+				})
 			}
 			table.Render()
 
@@ -186,3 +257,60 @@ var webhookDeleteCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var webhookEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Ping a disabled webhook and enable it again if the ping is delivered.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		webhookID, _ := command.Flags().GetString("webhook")
+		webhook, err := client.EnableWebhook(webhookID)
+		if err != nil {
+			return errors.Wrap(err, "failed to enable webhook")
+		}
+
+		if err = printJSON(webhook); err != nil {
+			return errors.Wrap(err, "failed to print webhook response")
+		}
+
+		return nil
+	},
+}
+
+var webhookPingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "Send a ping to a webhook and show how the receiver responded.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		webhookID, _ := command.Flags().GetString("webhook")
+		result, err := client.PingWebhook(webhookID)
+		if err != nil {
+			return errors.Wrap(err, "failed to ping webhook")
+		}
+
+		if err = printJSON(result); err != nil {
+			return errors.Wrap(err, "failed to print webhook ping response")
+		}
+		if !result.IsSuccessful() {
+			return errors.Errorf("webhook ping failed: %s", result.Error)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/genesis/model"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	webhookDeliveriesListCmd.Flags().String("webhook", "", "The id of the webhook whose deliveries are listed.")
+	webhookDeliveriesListCmd.Flags().String("state", "", "When set only the deliveries in the given state (pending, delivered or failed) are returned.")
+	webhookDeliveriesListCmd.Flags().Int("page", 0, "The page of webhook deliveries to fetch, starting at 0.")
+	webhookDeliveriesListCmd.Flags().Int("per-page", 100, "The number of webhook deliveries to fetch per page.")
+	webhookDeliveriesListCmd.Flags().Bool("table", false, "Whether to display the returned webhook delivery list in a table or not")
+	webhookDeliveriesListCmd.MarkFlagRequired("webhook") //nolint
+
+	webhookDeliveriesRedeliverCmd.Flags().String("webhook", "", "The id of the webhook of the delivery.")
+	webhookDeliveriesRedeliverCmd.Flags().String("delivery", "", "The id of the delivery whose payload is sent again.")
+	webhookDeliveriesRedeliverCmd.MarkFlagRequired("webhook")  //nolint
+	webhookDeliveriesRedeliverCmd.MarkFlagRequired("delivery") //nolint
+
+	webhookDeliveriesCmd.AddCommand(webhookDeliveriesListCmd)
+	webhookDeliveriesCmd.AddCommand(webhookDeliveriesRedeliverCmd)
+	webhookCmd.AddCommand(webhookDeliveriesCmd)
+}
+
+var webhookDeliveriesCmd = &cobra.Command{
+	Use:   "deliveries",
+	Short: "Inspect and replay the payloads delivered to a webhook.",
+}
+
+var webhookDeliveriesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the deliveries of a webhook with their attempts.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		webhookID, _ := command.Flags().GetString("webhook")
+		state, _ := command.Flags().GetString("state")
+		page, _ := command.Flags().GetInt("page")
+		perPage, _ := command.Flags().GetInt("per-page")
+		deliveries, err := client.GetWebhookDeliveries(webhookID, &model.GetWebhookDeliveriesRequest{
+			Page:    page,
+			PerPage: perPage,
+			State:   state,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to query webhook deliveries")
+		}
+
+		outputToTable, _ := command.Flags().GetBool("table")
+		if outputToTable {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			table.SetHeader([]string{"ID", "PAYLOAD ID", "TYPE", "NEW STATE", "STATE", "ATTEMPTS", "LAST STATUS", "LAST LATENCY", "LAST ERROR", "CREATED AT"})
+
+			for _, delivery := range deliveries {
+				var lastStatus, lastLatency string
+				if len(delivery.AttemptLog) != 0 {
+					lastStatus = strconv.Itoa(delivery.AttemptLog[0].StatusCode)
+					lastLatency = fmt.Sprintf("%dms", delivery.AttemptLog[0].Latency)
+				}
+				table.Append([]string{
+					delivery.ID,
+					delivery.Payload.ID,
+					delivery.Payload.Type,
+					delivery.Payload.NewState,
+					delivery.State,
+					strconv.Itoa(delivery.Attempts),
+					lastStatus,
+					lastLatency,
+					delivery.LastError,
+					time.Unix(0, delivery.CreateAt*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+				})
+			}
+			table.Render()
+
+			return nil
+		}
+
+		if err = printJSON(deliveries); err != nil {
+			return errors.Wrap(err, "failed to print webhook deliveries response")
+		}
+
+		return nil
+	},
+}
+
+var webhookDeliveriesRedeliverCmd = &cobra.Command{
+	Use:   "redeliver",
+	Short: "Send the payload of a webhook delivery again.",
+	RunE: func(command *cobra.Command, args []string) error {
+		command.SilenceUsage = true
+
+		serverAddress, _ := command.Flags().GetString("server")
+		if _, err := url.Parse(serverAddress); err != nil {
+			return errors.Wrap(err, "provided server address not a valid address")
+		}
+
+		client := model.NewClient(serverAddress)
+
+		webhookID, _ := command.Flags().GetString("webhook")
+		deliveryID, _ := command.Flags().GetString("delivery")
+		delivery, err := client.RedeliverWebhookDelivery(webhookID, deliveryID)
+		if err != nil {
+			return errors.Wrap(err, "failed to redeliver webhook delivery")
+		}
+
+		if err = printJSON(delivery); err != nil {
+			return errors.Wrap(err, "failed to print webhook delivery response")
+		}
+
+		return nil
+	},
+}
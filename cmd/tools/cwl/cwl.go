@@ -5,9 +5,12 @@
 package main
 
 import (
+	"bytes"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	cloud "github.com/mattermost/genesis/model"
 )
@@ -17,14 +20,44 @@ const (
 	DefaultPort = "8065"
 	// ListenPortEnv is the env var name for overriding the default listen port.
 	ListenPortEnv = "CWL_PORT"
+	// SecretEnv is the env var name for the secret of the webhook. When set,
+	// only payloads with a valid signature are accepted.
+	SecretEnv = "CWL_SECRET"
+	// MaxAgeEnv is the env var name for overriding the default maximum age of
+	// the signed requests.
+	MaxAgeEnv = "CWL_MAX_AGE"
+	// DefaultMaxAge is the default maximum age of the signed requests. They
+	// are signed when they are sent, so it only needs to cover clock skew.
+	DefaultMaxAge = 5 * time.Minute
+)
+
+var (
+	secret string
+	maxAge = DefaultMaxAge
 )
 
 func handler(w http.ResponseWriter, r *http.Request) {
-	webhook, err := cloud.WebhookPayloadFromReader(r.Body)
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error: failed to parse webhook: %s", err)
+		log.Printf("Error: failed to read webhook: %s", err)
 		return
 	}
+
+	var webhook *cloud.WebhookPayload
+	if len(secret) != 0 {
+		webhook, err = cloud.VerifyWebhookSignature(secret, r.Header, body, maxAge)
+		if err != nil {
+			log.Printf("Error: rejected webhook: %s", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	} else {
+		webhook, err = cloud.WebhookPayloadFromReader(bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error: failed to parse webhook: %s", err)
+			return
+		}
+	}
 	if len(webhook.ID) == 0 {
 		return
 	}
@@ -45,6 +78,17 @@ func main() {
 	if len(os.Getenv(ListenPortEnv)) != 0 {
 		port = os.Getenv(ListenPortEnv)
 	}
+	secret = os.Getenv(SecretEnv)
+	if len(os.Getenv(MaxAgeEnv)) != 0 {
+		var err error
+		maxAge, err = time.ParseDuration(os.Getenv(MaxAgeEnv))
+		if err != nil {
+			log.Fatalf("Invalid %s: %s", MaxAgeEnv, err)
+		}
+	}
+	if len(secret) == 0 {
+		log.Printf("Warning: %s is not set, webhook signatures are not verified", SecretEnv)
+	}
 
 	log.Printf("Starting cloud webhook listener on port %s", port)
 
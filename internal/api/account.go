@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/mattermost/genesis/internal/webhook"
 	"github.com/mattermost/genesis/model"
 )
 
@@ -27,6 +26,7 @@ func initAccount(apiRouter *mux.Router, context *Context) {
 	accountRouter.Handle("", addContext(handleGetAccount)).Methods("GET")
 	accountRouter.Handle("", addContext(handleRetryCreateAccount)).Methods("POST")
 	accountRouter.Handle("/provision", addContext(handleProvisionAccount)).Methods("POST")
+	accountRouter.Handle("/refresh", addContext(handleRefreshAccount)).Methods("POST")
 
 	accountRouter.Handle("", addContext(handleDeleteAccount)).Methods("DELETE")
 }
@@ -97,7 +97,9 @@ func handleCreateAccount(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The ID is assigned upfront so that the subnets are claimed for the account.
 	account := model.Account{
+		ID:       model.NewID(),
 		Provider: createAccountRequest.Provider,
 		ProviderMetadataAWS: &model.AWSMetadata{
 			ServiceCatalogProductID: createAccountRequest.ServiceCatalogProductID,
@@ -105,29 +107,52 @@ func handleCreateAccount(c *Context, w http.ResponseWriter, r *http.Request) {
 			AccountProductID:        "",
 		},
 		AccountMetadata: &model.AccountMetadata{
-			Provision: createAccountRequest.Provision,
-			Subnet:    createAccountRequest.Subnet,
+			Provision:    createAccountRequest.Provision,
+			Subnet:       createAccountRequest.Subnet,
+			SubnetIPv6:   createAccountRequest.SubnetIPv6,
+			PoolSelector: createAccountRequest.PoolSelector,
 		},
 		Provisioner:     "genesis",
 		APISecurityLock: createAccountRequest.APISecurityLock,
 		State:           model.AccountStateCreationRequested,
 	}
 
+	var claimedSubnets []string
 	if createAccountRequest.Provision {
 		var subnet *model.Subnet
-		subnet, err := c.Store.ClaimSubnet(createAccountRequest.Subnet, account.ProviderMetadataAWS.AWSAccountID)
+		subnet, err := claimSubnet(c, &model.SubnetClaim{
+			CIDR:         createAccountRequest.Subnet,
+			Family:       model.SubnetFamilyIPv4,
+			AccountID:    account.ID,
+			PrefixLength: createAccountRequest.SubnetPrefixLength,
+			PoolSelector: createAccountRequest.PoolSelector,
+		})
 		if err != nil {
 			c.Logger.WithError(err).Error("failed to claim subnet")
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(subnetErrorStatus(err))
+			return
 		}
 
 		account.AccountMetadata.Subnet = subnet.CIDR
-	}
+		claimedSubnets = append(claimedSubnets, subnet.CIDR)
+
+		if createAccountRequest.DualStack {
+			subnet, err = claimSubnet(c, &model.SubnetClaim{
+				CIDR:         createAccountRequest.SubnetIPv6,
+				Family:       model.SubnetFamilyIPv6,
+				AccountID:    account.ID,
+				PoolSelector: createAccountRequest.PoolSelector,
+			})
+			if err != nil {
+				c.Logger.WithError(err).Error("failed to claim IPv6 subnet")
+				releaseSubnets(c, claimedSubnets)
+				w.WriteHeader(subnetErrorStatus(err))
+				return
+			}
 
-	if err = c.Store.CreateAccount(&account); err != nil {
-		c.Logger.WithError(err).Error("failed to create account")
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+			account.AccountMetadata.SubnetIPv6 = subnet.CIDR
+			claimedSubnets = append(claimedSubnets, subnet.CIDR)
+		}
 	}
 
 	webhookPayload := &model.WebhookPayload{
@@ -138,8 +163,11 @@ func handleCreateAccount(c *Context, w http.ResponseWriter, r *http.Request) {
 		Timestamp: time.Now().UnixNano(),
 		ExtraData: map[string]string{"Environment": c.Environment},
 	}
-	if err = webhook.SendToAllWebhooks(c.Store, webhookPayload, c.Logger.WithField("webhookEvent", webhookPayload.NewState)); err != nil {
-		c.Logger.WithError(err).Error("Unable to process and send webhooks")
+	if err = c.Store.CreateAccountWithEvent(&account, webhookPayload); err != nil {
+		c.Logger.WithError(err).Error("failed to create account")
+		releaseSubnets(c, claimedSubnets)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
 	c.Supervisor.Do() //nolint
@@ -182,15 +210,11 @@ func handleRetryCreateAccount(c *Context, w http.ResponseWriter, r *http.Request
 		}
 		account.State = newState
 
-		if err := c.Store.UpdateAccount(account); err != nil {
+		if err := c.Store.UpdateAccountWithEvent(account, webhookPayload); err != nil {
 			c.Logger.WithError(err).Errorf("failed to retry account creation")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-
-		if err := webhook.SendToAllWebhooks(c.Store, webhookPayload, c.Logger.WithField("webhookEvent", webhookPayload.NewState)); err != nil {
-			c.Logger.WithError(err).Error("Unable to process and send webhooks")
-		}
 	}
 
 	// Notify even if we didn't make changes, to expedite even the no-op operations above.
@@ -225,7 +249,7 @@ func handleProvisionAccount(c *Context, w http.ResponseWriter, r *http.Request)
 	provisionAccountRequest, err := model.NewProvisionAccountRequestFromReader(r.Body)
 	if err != nil {
 		c.Logger.WithError(err).Error("failed to deserialize account provision request body")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
@@ -248,31 +272,115 @@ func handleProvisionAccount(c *Context, w http.ResponseWriter, r *http.Request)
 		}
 		account.State = newState
 		account.AccountMetadata.Provision = true
+		if len(provisionAccountRequest.PoolSelector) != 0 {
+			account.AccountMetadata.PoolSelector = provisionAccountRequest.PoolSelector
+		}
 
+		// The subnets claimed by this request are released if it fails.
+		var claimedSubnets []string
 		if account.AccountMetadata.Subnet == "" {
 			var subnet *model.Subnet
 
-			subnet, err := c.Store.ClaimSubnet(provisionAccountRequest.Subnet, account.ProviderMetadataAWS.AWSAccountID)
+			subnet, err := claimSubnet(c, &model.SubnetClaim{
+				CIDR:         provisionAccountRequest.Subnet,
+				Family:       model.SubnetFamilyIPv4,
+				AccountID:    account.ID,
+				PrefixLength: provisionAccountRequest.SubnetPrefixLength,
+				PoolSelector: account.AccountMetadata.PoolSelector,
+			})
 			if err != nil {
 				c.Logger.WithError(err).Error("failed to claim subnet")
-				w.WriteHeader(http.StatusInternalServerError)
+				w.WriteHeader(subnetErrorStatus(err))
+				return
 			}
 
 			account.AccountMetadata.Subnet = subnet.CIDR
-
+			claimedSubnets = append(claimedSubnets, subnet.CIDR)
 		} else if account.AccountMetadata.Subnet != "" && provisionAccountRequest.Subnet != "" {
 			c.Logger.Error("There is a subnet already allocated to the account")
 			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if provisionAccountRequest.DualStack {
+			if account.AccountMetadata.SubnetIPv6 == "" {
+				subnet, err := claimSubnet(c, &model.SubnetClaim{
+					CIDR:         provisionAccountRequest.SubnetIPv6,
+					Family:       model.SubnetFamilyIPv6,
+					AccountID:    account.ID,
+					PoolSelector: account.AccountMetadata.PoolSelector,
+				})
+				if err != nil {
+					c.Logger.WithError(err).Error("failed to claim IPv6 subnet")
+					releaseSubnets(c, claimedSubnets)
+					w.WriteHeader(subnetErrorStatus(err))
+					return
+				}
+
+				account.AccountMetadata.SubnetIPv6 = subnet.CIDR
+				claimedSubnets = append(claimedSubnets, subnet.CIDR)
+			} else if provisionAccountRequest.SubnetIPv6 != "" {
+				c.Logger.Error("There is an IPv6 subnet already allocated to the account")
+				releaseSubnets(c, claimedSubnets)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
 		}
 
-		if err := c.Store.UpdateAccount(account); err != nil {
+		if err := c.Store.UpdateAccountWithEvent(account, webhookPayload); err != nil {
 			c.Logger.WithError(err).Errorf("failed to mark account provisioning state")
+			releaseSubnets(c, claimedSubnets)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+	}
+
+	// Notify even if we didn't make changes, to expedite even the no-op operations above.
+	unlockOnce()
+	c.Supervisor.Do() //nolint
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	outputJSON(c, w, account)
+}
+
+// handleRefreshAccount responds to POST /api/account/{account}/refresh,
+// refreshing the stored metadata of the account from AWS.
+func handleRefreshAccount(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	accountID := vars["account"]
+	c.Logger = c.Logger.WithField("account", accountID)
+
+	account, status, unlockOnce := lockAccount(c, accountID)
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	defer unlockOnce()
+
+	newState := model.AccountStateRefreshMetadata
+
+	if !account.ValidTransitionState(newState) {
+		c.Logger.Warnf("unable to refresh account metadata while in state %s", account.State)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if account.State != newState {
+		webhookPayload := &model.WebhookPayload{
+			Type:      model.TypeAccount,
+			ID:        account.ID,
+			NewState:  newState,
+			OldState:  account.State,
+			Timestamp: time.Now().UnixNano(),
+			ExtraData: map[string]string{"Environment": c.Environment},
+		}
+		account.State = newState
 
-		if err := webhook.SendToAllWebhooks(c.Store, webhookPayload, c.Logger.WithField("webhookEvent", webhookPayload.NewState)); err != nil {
-			c.Logger.WithError(err).Error("unable to process and send webhooks")
+		if err := c.Store.UpdateAccountWithEvent(account, webhookPayload); err != nil {
+			c.Logger.WithError(err).Errorf("failed to mark account metadata refresh state")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 	}
 
@@ -324,15 +432,11 @@ func handleDeleteAccount(c *Context, w http.ResponseWriter, r *http.Request) {
 		}
 		account.State = newState
 
-		if err := c.Store.UpdateAccount(account); err != nil {
+		if err := c.Store.UpdateAccountWithEvent(account, webhookPayload); err != nil {
 			c.Logger.WithError(err).Error("failed to mark account for deletion")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-
-		if err := webhook.SendToAllWebhooks(c.Store, webhookPayload, c.Logger.WithField("webhookEvent", webhookPayload.NewState)); err != nil {
-			c.Logger.WithError(err).Error("Unable to process and send webhooks")
-		}
 	}
 
 	unlockOnce()
@@ -325,6 +325,79 @@ func TestCreateAccount(t *testing.T) {
 		require.Equal(t, "service-catalog-id", account.ProviderMetadataAWS.ServiceCatalogProductID)
 		require.Equal(t, false, account.AccountMetadata.Provision)
 	})
+
+	t.Run("valid dual stack", func(t *testing.T) {
+		parentSubnetIPv4 := model.ParentSubnet{ID: model.NewID(), CIDR: "10.0.0.0/24", SplitRange: 24}
+		err := sqlStore.AddParentSubnet(&parentSubnetIPv4)
+		require.NoError(t, err)
+		parentSubnetIPv6 := model.ParentSubnet{ID: model.NewID(), CIDR: "2600:1f18:1000::/56", SplitRange: 56}
+		err = sqlStore.AddParentSubnet(&parentSubnetIPv6)
+		require.NoError(t, err)
+
+		account, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+			DualStack:               true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.0/24", account.AccountMetadata.Subnet)
+		require.Equal(t, "2600:1f18:1000::/56", account.AccountMetadata.SubnetIPv6)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, subnets, 2)
+		for _, subnet := range subnets {
+			require.Equal(t, account.ID, subnet.AccountID)
+		}
+	})
+
+	t.Run("valid prefix length", func(t *testing.T) {
+		parentSubnet := model.ParentSubnet{ID: model.NewID(), CIDR: "10.1.0.0/20", SplitRange: 24}
+		err := sqlStore.AddParentSubnet(&parentSubnet)
+		require.NoError(t, err)
+
+		account, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+			SubnetPrefixLength:      20,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "10.1.0.0/20", account.AccountMetadata.Subnet)
+
+		_, err = client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+			SubnetPrefixLength:      20,
+		})
+		require.EqualError(t, err, "failed with status code 507")
+	})
+
+	t.Run("capacity exhausted", func(t *testing.T) {
+		// Every IPv4 parent subnet is fully claimed by the previous accounts.
+		_, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+		})
+		require.EqualError(t, err, "failed with status code 507")
+
+		accounts, err := sqlStore.GetAccounts(&model.AccountFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, accounts, 3)
+	})
+
+	t.Run("ipv6 subnet without dual stack", func(t *testing.T) {
+		_, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+			SubnetIPv6:              "2600:1f18:1000::/56",
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
 }
 
 func TestRetryCreateAccount(t *testing.T) {
@@ -411,6 +484,88 @@ func TestRetryCreateAccount(t *testing.T) {
 	})
 }
 
+func TestRefreshAccount(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	account1, err := client.CreateAccount(&model.CreateAccountRequest{
+		Provider:                model.ProviderAWS,
+		ServiceCatalogProductID: "service-catalog-id",
+		Provision:               false,
+	})
+	require.NoError(t, err)
+
+	t.Run("unknown account", func(t *testing.T) {
+		_, err := client.RefreshAccount(model.NewID())
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("while locked", func(t *testing.T) {
+		account1.State = model.AccountStateStable
+		err = sqlStore.UpdateAccount(account1)
+		require.NoError(t, err)
+
+		lockerID := model.NewID()
+
+		locked, err := sqlStore.LockAccount(account1.ID, lockerID)
+		require.NoError(t, err)
+		require.True(t, locked)
+		defer func() {
+			unlocked, err := sqlStore.UnlockAccount(account1.ID, lockerID, false)
+			require.NoError(t, err)
+			require.True(t, unlocked)
+		}()
+
+		_, err = client.RefreshAccount(account1.ID)
+		require.EqualError(t, err, "failed with status code 409")
+	})
+
+	t.Run("while creating", func(t *testing.T) {
+		account1.State = model.AccountStateCreationRequested
+		err = sqlStore.UpdateAccount(account1)
+		require.NoError(t, err)
+
+		_, err = client.RefreshAccount(account1.ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("while stable", func(t *testing.T) {
+		account1.State = model.AccountStateStable
+		err = sqlStore.UpdateAccount(account1)
+		require.NoError(t, err)
+
+		account1, err = client.RefreshAccount(account1.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.AccountStateRefreshMetadata, account1.State)
+
+		account1, err = client.GetAccount(account1.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.AccountStateRefreshMetadata, account1.State)
+	})
+
+	t.Run("while refresh failed", func(t *testing.T) {
+		account1.State = model.AccountStateRefreshMetadataFailed
+		err = sqlStore.UpdateAccount(account1)
+		require.NoError(t, err)
+
+		account1, err = client.RefreshAccount(account1.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.AccountStateRefreshMetadata, account1.State)
+	})
+}
+
 func TestProvisionAccount(t *testing.T) {
 	logger := testlib.MakeLogger(t)
 	sqlStore := store.MakeTestSQLStore(t, logger)
@@ -525,6 +680,33 @@ func TestProvisionAccount(t *testing.T) {
 		require.EqualError(t, err, "failed with status code 400")
 		assert.Nil(t, accountResp)
 	})
+
+	t.Run("failed ipv6 claim releases the ipv4 subnet", func(t *testing.T) {
+		account2, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+		})
+		require.NoError(t, err)
+		account2.State = model.AccountStateStable
+		require.NoError(t, sqlStore.UpdateAccount(account2))
+
+		parentSubnet := model.ParentSubnet{ID: model.NewID(), CIDR: "10.1.0.0/24", SplitRange: 24}
+		require.NoError(t, sqlStore.AddParentSubnet(&parentSubnet))
+
+		// There is no IPv6 parent subnet to claim from.
+		accountResp, err := client.ProvisionAccount(account2.ID, &model.ProvisionAccountRequest{DualStack: true})
+		require.EqualError(t, err, "failed with status code 507")
+		assert.Nil(t, accountResp)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, IncludeReserved: true})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+
+		account2, err = client.GetAccount(account2.ID)
+		require.NoError(t, err)
+		require.Empty(t, account2.AccountMetadata.Subnet)
+		require.Equal(t, model.AccountStateStable, account2.State)
+	})
 }
 
 func TestDeleteCluster(t *testing.T) {
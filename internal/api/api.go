@@ -17,4 +17,6 @@ func Register(rootRouter *mux.Router, context *Context) {
 	initSecurity(apiRouter, context)
 	initParentSubnet(apiRouter, context)
 	initSubnet(apiRouter, context)
+	initSubnetExclusion(apiRouter, context)
+	initIPAM(apiRouter, context)
 }
@@ -4,9 +4,27 @@
 
 package api_test
 
+import (
+	"github.com/mattermost/genesis/internal/aws"
+	"github.com/mattermost/genesis/model"
+)
+
 type mockSupervisor struct {
 }
 
 func (s *mockSupervisor) Do() error {
 	return nil
 }
+
+type mockGenesis struct {
+	vpcs     []*model.DiscoveredVPC
+	failures []*model.SubnetDiscoveryFailure
+	err      error
+
+	request *model.DiscoverSubnetsRequest
+}
+
+func (g *mockGenesis) DiscoverVPCs(request *model.DiscoverSubnetsRequest, awsClient aws.AWS) ([]*model.DiscoveredVPC, []*model.SubnetDiscoveryFailure, error) {
+	g.request = request
+	return g.vpcs, g.failures, g.err
+}
@@ -5,6 +5,9 @@
 package api
 
 import (
+	"time"
+
+	"github.com/mattermost/genesis/internal/aws"
 	"github.com/mattermost/genesis/model"
 	"github.com/sirupsen/logrus"
 )
@@ -16,10 +19,10 @@ type Supervisor interface {
 
 // Store describes the interface required to persist changes made via API requests.
 type Store interface {
-	CreateAccount(account *model.Account) error
+	CreateAccountWithEvent(account *model.Account, payload *model.WebhookPayload) error
 	GetAccount(accountID string) (*model.Account, error)
 	GetAccounts(filter *model.AccountFilter) ([]*model.Account, error)
-	UpdateAccount(account *model.Account) error
+	UpdateAccountWithEvent(account *model.Account, payload *model.WebhookPayload) error
 	LockAccount(accountID, lockerID string) (bool, error)
 	UnlockAccount(accountID, lockerID string, force bool) (bool, error)
 	LockAccountAPI(accountID string) error
@@ -30,23 +33,50 @@ type Store interface {
 	GetWebhook(webhookID string) (*model.Webhook, error)
 	GetWebhooks(filter *model.WebhookFilter) ([]*model.Webhook, error)
 	DeleteWebhook(webhookID string) error
+	EnableWebhook(webhookID string) error
+	EnqueueWebhookPayload(payload *model.WebhookPayload) error
+	GetWebhookDelivery(id string) (*model.WebhookDelivery, error)
+	GetWebhookDeliveries(filter *model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, error)
+	GetWebhookDeliveryAttempts(deliveryIDs []string) ([]*model.WebhookDeliveryAttempt, error)
+	RedeliverWebhookDelivery(delivery *model.WebhookDelivery) (*model.WebhookDelivery, error)
 
 	GetParentSubnet(id string) (model.ParentSubnet, error)
 	GetParentSubnets(filter *model.ParentSubnetFilter) ([]model.ParentSubnet, error)
-	AddParentSubnet(parentSubnet *model.ParentSubnet, subnets *[]model.Subnet) error
+	AddParentSubnet(parentSubnet *model.ParentSubnet) error
+	UpdateParentSubnetLabels(id string, labels map[string]string) error
+	UpdateParentSubnetAllocationStrategy(id, strategy string) error
+	RetireParentSubnet(id string) error
+	UnretireParentSubnet(id string) error
+	DeleteParentSubnet(id string) error
+	GetParentSubnetUsage(id string) (*model.ParentSubnetUsage, error)
+	GetParentSubnetsUsage() ([]*model.ParentSubnetUsage, error)
+	GetParentSubnetUsageChange(cidr string) (*model.ParentSubnetUsage, *model.ParentSubnetUsage, error)
 	LockParentSubnet(subnet, lockerID string) (bool, error)
 	UnlockParentSubnet(subnet, lockerID string, force bool) (bool, error)
 
-	ClaimSubnet(cidr string, accountID string) (*model.Subnet, error)
+	ClaimSubnet(claim *model.SubnetClaim) (*model.Subnet, error)
+	ReserveSubnet(cidr, owner, reason string, expiresAt int64) (*model.Subnet, error)
+	SubnetCleanup(cidr string, quarantinedUntil int64) error
 	GetSubnet(id string) (*model.Subnet, error)
 	GetSubnets(filter *model.SubnetFilter) ([]*model.Subnet, error)
-	UpdateSubnet(Subnet *model.Subnet) error
-}
+	GetSubnetsContaining(address string) ([]*model.Subnet, error)
+	ReserveExistingSubnet(id, owner, reason string, expiresAt int64) (*model.Subnet, error)
+	ReconcileSubnets(fix bool, claimedBefore, quarantinedUntil int64) ([]*model.SubnetDrift, error)
+	MarkExternalSubnets(vpcs []*model.DiscoveredVPC, mark bool) ([]*model.SubnetOverlap, error)
+	GetSubnetAllocations(filter *model.SubnetAllocationFilter) ([]*model.SubnetAllocation, error)
 
-// TODO: will be used
+	GetSubnetExclusion(id string) (*model.SubnetExclusion, error)
+	GetSubnetExclusions(filter *model.SubnetExclusionFilter) ([]*model.SubnetExclusion, error)
+	AddSubnetExclusion(exclusion *model.SubnetExclusion) error
+	DeleteSubnetExclusion(id string) error
+
+	ExportIPAM() (*model.IPAMExport, error)
+	ImportIPAM(export *model.IPAMExport, apply bool) (*model.IPAMImportReport, error)
+}
 
 // Genesis describes the interface required to communicate with the AWS account.
 type Genesis interface {
+	DiscoverVPCs(request *model.DiscoverSubnetsRequest, awsClient aws.AWS) ([]*model.DiscoveredVPC, []*model.SubnetDiscoveryFailure, error)
 }
 
 // Context provides the API with all necessary data and interfaces for responding to requests.
@@ -56,9 +86,23 @@ type Context struct {
 	Store       Store
 	Supervisor  Supervisor
 	Genesis     Genesis
+	AWS         aws.AWS
 	RequestID   string
 	Environment string
-	Logger      logrus.FieldLogger
+	// SubnetLowWatermarks are the percentages of free subnets of a parent
+	// subnet below which a parent subnet webhook is sent.
+	SubnetLowWatermarks []int
+	// SubnetAllocationStrategy is the allocation strategy of the subnets
+	// claimed for accounts. The default allocation strategy is used if it
+	// is empty.
+	SubnetAllocationStrategy string
+	// SubnetDiscoveryRole is the IAM role assumed in the AWS accounts whose
+	// VPCs are discovered when the discovery request does not set one.
+	SubnetDiscoveryRole string
+	// SubnetQuarantine is how long the orphaned claims released by a subnet
+	// reconciliation are kept in quarantine.
+	SubnetQuarantine time.Duration
+	Logger           logrus.FieldLogger
 }
 
 // Clone creates a shallow copy of context, allowing clones to apply per-request changes.
@@ -67,6 +111,12 @@ func (c *Context) Clone() *Context {
 		Store:      c.Store,
 		Supervisor: c.Supervisor,
 		Genesis:    c.Genesis,
+		AWS:        c.AWS,
 		Logger:     c.Logger,
+
+		SubnetLowWatermarks:      c.SubnetLowWatermarks,
+		SubnetAllocationStrategy: c.SubnetAllocationStrategy,
+		SubnetDiscoveryRole:      c.SubnetDiscoveryRole,
+		SubnetQuarantine:         c.SubnetQuarantine,
 	}
 }
@@ -0,0 +1,64 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/model"
+)
+
+// initIPAM registers IPAM export and import endpoints on the given router.
+func initIPAM(apiRouter *mux.Router, context *Context) {
+	addContext := func(handler contextHandlerFunc) *contextHandler {
+		return newContextHandler(context, handler)
+	}
+
+	ipamRouter := apiRouter.PathPrefix("/ipam").Subrouter()
+	ipamRouter.Handle("/export", addContext(handleExportIPAM)).Methods("GET")
+	ipamRouter.Handle("/import", addContext(handleImportIPAM)).Methods("POST")
+}
+
+// handleExportIPAM responds to GET /api/ipam/export, returning the parent
+// subnets, the claimed and reserved subnets and the subnet exclusions.
+func handleExportIPAM(c *Context, w http.ResponseWriter, r *http.Request) {
+	export, err := c.Store.ExportIPAM()
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to export IPAM state")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, export)
+}
+
+// handleImportIPAM responds to POST /api/ipam/import, validating an IPAM export
+// against the existing parent subnets, subnets and subnet exclusions and adding
+// its missing records unless in dry-run mode or in case of conflicts.
+func handleImportIPAM(c *Context, w http.ResponseWriter, r *http.Request) {
+	importIPAMRequest, err := model.NewImportIPAMRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	report, err := c.Store.ImportIPAM(importIPAMRequest.Export, importIPAMRequest.Apply)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to import IPAM state")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(report.Conflicts) != 0 {
+		c.Logger.Warnf("IPAM import has %d conflicts", len(report.Conflicts))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, report)
+}
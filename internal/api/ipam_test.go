@@ -0,0 +1,73 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package api_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/internal/api"
+	"github.com/mattermost/genesis/internal/store"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPAMExportImport(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.14.0.0/23", SplitRange: 24})
+	require.NoError(t, err)
+	_, err = client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.14.0.0/24", Owner: "network-team", Reason: "on-prem link"})
+	require.NoError(t, err)
+
+	export, err := client.ExportIPAM()
+	require.NoError(t, err)
+	require.Len(t, export.ParentSubnets, 1)
+	require.Len(t, export.Subnets, 1)
+
+	t.Run("invalid export", func(t *testing.T) {
+		_, err := client.ImportIPAM(&model.ImportIPAMRequest{Export: &model.IPAMExport{Version: 42}})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("missing export", func(t *testing.T) {
+		_, err := client.ImportIPAM(&model.ImportIPAMRequest{})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("import defaults to a dry run", func(t *testing.T) {
+		report, err := client.ImportIPAM(&model.ImportIPAMRequest{Export: export})
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		require.Equal(t, 1, report.ParentSubnetsExisting)
+		require.Equal(t, 1, report.SubnetsExisting)
+		require.Empty(t, report.Conflicts)
+	})
+
+	t.Run("conflicting reservation", func(t *testing.T) {
+		export.Subnets[0].ReservationOwner = "other-team"
+
+		report, err := client.ImportIPAM(&model.ImportIPAMRequest{Apply: true, Export: export})
+		require.NoError(t, err)
+		require.False(t, report.Applied)
+		require.Len(t, report.Conflicts, 1)
+		require.Equal(t, "reserved by network-team", report.Conflicts[0].Reason)
+	})
+}
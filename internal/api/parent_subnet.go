@@ -5,13 +5,17 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/mattermost/genesis/internal/genesis"
+	"github.com/mattermost/genesis/internal/ipam"
 	"github.com/mattermost/genesis/internal/webhook"
 	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
 )
 
 // initParentSubnet registers parent subnet endpoints on the given router.
@@ -23,9 +27,16 @@ func initParentSubnet(apiRouter *mux.Router, context *Context) {
 	parentSubnetsRouter := apiRouter.PathPrefix("/subnets/parent").Subrouter()
 	parentSubnetsRouter.Handle("", addContext(handleGetParentSubnets)).Methods("GET")
 	parentSubnetsRouter.Handle("", addContext(handleAddParentSubnet)).Methods("POST")
+	parentSubnetsRouter.Handle("/usage", addContext(handleGetParentSubnetsUsage)).Methods("GET")
+	parentSubnetsRouter.Handle("/{parentsubnet:[A-Za-z0-9]{26}}/usage", addContext(handleGetParentSubnetUsage)).Methods("GET")
 
 	parentSubnetRouter := apiRouter.PathPrefix("/subnet/parent/{parentsubnet:[A-Za-z0-9]{26}}").Subrouter()
 	parentSubnetRouter.Handle("", addContext(handleGetParentSubnet)).Methods("GET")
+	parentSubnetRouter.Handle("", addContext(handleDeleteParentSubnet)).Methods("DELETE")
+	parentSubnetRouter.Handle("/labels", addContext(handleUpdateParentSubnetLabels)).Methods("POST")
+	parentSubnetRouter.Handle("/allocation-strategy", addContext(handleUpdateParentSubnetAllocationStrategy)).Methods("POST")
+	parentSubnetRouter.Handle("/retire", addContext(handleRetireParentSubnet)).Methods("POST")
+	parentSubnetRouter.Handle("/unretire", addContext(handleUnretireParentSubnet)).Methods("POST")
 }
 
 // handleGetParentSubnet responds to GET /api/subnet/parent/{parentsubnet}, returning the parent subnet in question.
@@ -41,8 +52,7 @@ func handleGetParentSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	emptySub := model.ParentSubnet{}
-	if parentSubnet == emptySub {
+	if parentSubnet.ID == "" {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
@@ -91,36 +101,288 @@ func handleAddParentSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 
 	parentSubnet := model.ParentSubnet{
-		CIDR:       addParentSubnetRequest.CIDR,
-		SplitRange: addParentSubnetRequest.SplitRange,
+		CIDR:               addParentSubnetRequest.CIDR,
+		SplitRange:         addParentSubnetRequest.SplitRange,
+		Labels:             addParentSubnetRequest.Labels,
+		AllocationStrategy: addParentSubnetRequest.AllocationStrategy,
 	}
 
 	parentSubnet.ID = model.NewID()
 
-	subnets, err := genesis.SplitParentSubnet(&parentSubnet)
+	parentPrefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
 	if err != nil {
-		c.Logger.WithError(err).Error("Failed to split parent subnet")
+		c.Logger.WithError(err).Error("invalid parent subnet CIDR")
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	parentSubnet.CIDR = parentPrefix.String()
 
-	if err := c.Store.AddParentSubnet(&parentSubnet, &subnets); err != nil {
+	if err := c.Store.AddParentSubnet(&parentSubnet); err != nil {
+		if errors.Cause(err) == model.ErrParentSubnetOverlap {
+			c.Logger.WithError(err).Error("parent subnet overlaps an existing parent subnet")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 		c.Logger.WithError(err).Error("failed to add parent subnet")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	webhookPayload := &model.WebhookPayload{
-		Type:      model.TypeParentSubnet,
-		ID:        parentSubnet.ID,
-		Timestamp: time.Now().UnixNano(),
-		ExtraData: map[string]string{"CIDR": parentSubnet.CIDR},
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	outputJSON(c, w, parentSubnet)
+}
+
+// handleDeleteParentSubnet responds to DELETE /api/subnet/parent/{parentsubnet},
+// removing a parent subnet that has no claimed subnets.
+func handleDeleteParentSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	parentSubnetID := vars["parentsubnet"]
+	c.Logger = c.Logger.WithField("parent-subnet", parentSubnetID)
+
+	parentSubnet, err := c.Store.GetParentSubnet(parentSubnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query parent subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if parentSubnet.ID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	claimed, err := c.Store.GetSubnets(&model.SubnetFilter{PerPage: 1, ParentSubnet: parentSubnet.CIDR, IncludeReserved: true})
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query claimed subnets")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(claimed) != 0 {
+		c.Logger.Warn("unable to delete parent subnet with claimed subnets")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err = c.Store.DeleteParentSubnet(parentSubnet.ID); err != nil {
+		c.Logger.WithError(err).Error("failed to delete parent subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUpdateParentSubnetLabels responds to POST /api/subnet/parent/{parentsubnet}/labels,
+// replacing the labels of the parent subnet.
+func handleUpdateParentSubnetLabels(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	parentSubnetID := vars["parentsubnet"]
+	c.Logger = c.Logger.WithField("parent-subnet", parentSubnetID)
+
+	updateParentSubnetLabelsRequest, err := model.NewUpdateParentSubnetLabelsRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	parentSubnet, err := c.Store.GetParentSubnet(parentSubnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query parent subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if parentSubnet.ID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	if err := webhook.SendToAllWebhooks(c.Store, webhookPayload, c.Logger.WithField("webhookEvent", nil)); err != nil {
-		c.Logger.WithError(err).Error("Unable to process and send webhooks")
+	if err = c.Store.UpdateParentSubnetLabels(parentSubnet.ID, updateParentSubnetLabelsRequest.Labels); err != nil {
+		c.Logger.WithError(err).Error("failed to update parent subnet labels")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+	parentSubnet.Labels = updateParentSubnetLabelsRequest.Labels
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusOK)
 	outputJSON(c, w, parentSubnet)
 }
+
+// handleUpdateParentSubnetAllocationStrategy responds to POST
+// /api/subnet/parent/{parentsubnet}/allocation-strategy, setting the allocation
+// strategy of the subnets claimed in the parent subnet.
+func handleUpdateParentSubnetAllocationStrategy(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	parentSubnetID := vars["parentsubnet"]
+	c.Logger = c.Logger.WithField("parent-subnet", parentSubnetID)
+
+	updateRequest, err := model.NewUpdateParentSubnetAllocationStrategyRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	parentSubnet, err := c.Store.GetParentSubnet(parentSubnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query parent subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if parentSubnet.ID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err = c.Store.UpdateParentSubnetAllocationStrategy(parentSubnet.ID, updateRequest.AllocationStrategy); err != nil {
+		c.Logger.WithError(err).Error("failed to update parent subnet allocation strategy")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	parentSubnet.AllocationStrategy = updateRequest.AllocationStrategy
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, parentSubnet)
+}
+
+// handleRetireParentSubnet responds to POST /api/subnet/parent/{parentsubnet}/retire,
+// stopping new subnets from being claimed from the parent subnet.
+func handleRetireParentSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	parentSubnetID := vars["parentsubnet"]
+	c.Logger = c.Logger.WithField("parent-subnet", parentSubnetID)
+
+	parentSubnet, err := c.Store.GetParentSubnet(parentSubnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query parent subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if parentSubnet.ID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if !parentSubnet.Retired {
+		if err = c.Store.RetireParentSubnet(parentSubnet.ID); err != nil {
+			c.Logger.WithError(err).Error("failed to retire parent subnet")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleUnretireParentSubnet responds to POST /api/subnet/parent/{parentsubnet}/unretire,
+// allowing subnets to be claimed again from the parent subnet.
+func handleUnretireParentSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	parentSubnetID := vars["parentsubnet"]
+	c.Logger = c.Logger.WithField("parent-subnet", parentSubnetID)
+
+	parentSubnet, err := c.Store.GetParentSubnet(parentSubnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query parent subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if parentSubnet.ID == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if parentSubnet.Retired {
+		if err = c.Store.UnretireParentSubnet(parentSubnet.ID); err != nil {
+			c.Logger.WithError(err).Error("failed to unretire parent subnet")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleGetParentSubnetUsage responds to GET /api/subnets/parent/{parentsubnet}/usage,
+// returning the usage of the parent subnet in question.
+func handleGetParentSubnetUsage(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	parentSubnetID := vars["parentsubnet"]
+	c.Logger = c.Logger.WithField("parent-subnet", parentSubnetID)
+
+	usage, err := c.Store.GetParentSubnetUsage(parentSubnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to compute parent subnet usage")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if usage == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, usage)
+}
+
+// handleGetParentSubnetsUsage responds to GET /api/subnets/parent/usage,
+// returning the usage of all parent subnets.
+func handleGetParentSubnetsUsage(c *Context, w http.ResponseWriter, r *http.Request) {
+	usages, err := c.Store.GetParentSubnetsUsage()
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to compute parent subnets usage")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, usages)
+}
+
+// checkParentSubnetLowWatermarks sends a parent subnet webhook if claiming or
+// reserving the given subnet made its parent subnet cross a low watermark.
+func checkParentSubnetLowWatermarks(c *Context, subnetCIDR string) {
+	if len(c.SubnetLowWatermarks) == 0 {
+		return
+	}
+
+	before, after, err := c.Store.GetParentSubnetUsageChange(subnetCIDR)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to compute parent subnet usage")
+		return
+	}
+	if before == nil || after == nil {
+		return
+	}
+
+	watermarks := append([]int{}, c.SubnetLowWatermarks...)
+	sort.Ints(watermarks)
+	for _, watermark := range watermarks {
+		if before.FreePercent <= float64(watermark) || after.FreePercent > float64(watermark) {
+			continue
+		}
+
+		webhookPayload := &model.WebhookPayload{
+			Type:      model.TypeParentSubnet,
+			ID:        after.ParentSubnetID,
+			Timestamp: time.Now().UnixNano(),
+			ExtraData: map[string]string{
+				"CIDR":        after.CIDR,
+				"Event":       "low-watermark",
+				"Watermark":   strconv.Itoa(watermark),
+				"FreePercent": fmt.Sprintf("%.2f", after.FreePercent),
+				"Free":        after.Free.String(),
+				"Total":       after.Total.String(),
+			},
+		}
+		if err := webhook.SendToAllWebhooks(c.Store, webhookPayload, c.Logger.WithField("webhookEvent", "low-watermark")); err != nil {
+			c.Logger.WithError(err).Error("Unable to process and send webhooks")
+		}
+
+		return
+	}
+}
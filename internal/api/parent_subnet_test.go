@@ -0,0 +1,324 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package api_test
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/internal/api"
+	"github.com/mattermost/genesis/internal/store"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddParentSubnet(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	t.Run("valid", func(t *testing.T) {
+		parentSubnet, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/16", SplitRange: 24})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.0/16", parentSubnet.CIDR)
+		require.False(t, parentSubnet.Retired)
+	})
+
+	t.Run("host bits set", func(t *testing.T) {
+		_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.1.0.5/16", SplitRange: 24})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("split range smaller than parent", func(t *testing.T) {
+		_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.1.0.0/16", SplitRange: 8})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("overlapping parent subnet", func(t *testing.T) {
+		_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.128.0/17", SplitRange: 24})
+		require.EqualError(t, err, "failed with status code 400")
+
+		_, err = client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/8", SplitRange: 24})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+}
+
+func TestUpdateParentSubnetLabels(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	parentSubnet, err := client.AddParentSubnet(&model.AddParentSubnetRequest{
+		CIDR:       "10.0.0.0/16",
+		SplitRange: 24,
+		Labels:     map[string]string{"environment": "sandbox"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"environment": "sandbox"}, parentSubnet.Labels)
+
+	t.Run("invalid labels on add", func(t *testing.T) {
+		_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{
+			CIDR:       "10.1.0.0/16",
+			SplitRange: 24,
+			Labels:     map[string]string{"environment": "sand box"},
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("replace labels", func(t *testing.T) {
+		labels := map[string]string{"environment": "production", "region": "us-east-1"}
+		updated, err := client.UpdateParentSubnetLabels(parentSubnet.ID, &model.UpdateParentSubnetLabelsRequest{Labels: labels})
+		require.NoError(t, err)
+		require.Equal(t, labels, updated.Labels)
+
+		fetched, err := client.GetParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+		require.Equal(t, labels, fetched.Labels)
+	})
+
+	t.Run("remove labels", func(t *testing.T) {
+		updated, err := client.UpdateParentSubnetLabels(parentSubnet.ID, &model.UpdateParentSubnetLabelsRequest{})
+		require.NoError(t, err)
+		require.Empty(t, updated.Labels)
+	})
+
+	t.Run("invalid labels", func(t *testing.T) {
+		_, err := client.UpdateParentSubnetLabels(parentSubnet.ID, &model.UpdateParentSubnetLabelsRequest{Labels: map[string]string{"": "x"}})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("unknown parent subnet", func(t *testing.T) {
+		_, err := client.UpdateParentSubnetLabels(model.NewID(), &model.UpdateParentSubnetLabelsRequest{})
+		require.EqualError(t, err, "failed with status code 404")
+	})
+}
+
+func TestUpdateParentSubnetAllocationStrategy(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:                    sqlStore,
+		Supervisor:               &mockSupervisor{},
+		Logger:                   logger,
+		SubnetAllocationStrategy: model.SubnetAllocationSequential,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	parentSubnet, err := client.AddParentSubnet(&model.AddParentSubnetRequest{
+		CIDR:               "10.0.0.0/22",
+		SplitRange:         24,
+		AllocationStrategy: model.SubnetAllocationBestFit,
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.SubnetAllocationBestFit, parentSubnet.AllocationStrategy)
+
+	t.Run("unsupported allocation strategy", func(t *testing.T) {
+		_, err := client.UpdateParentSubnetAllocationStrategy(parentSubnet.ID, &model.UpdateParentSubnetAllocationStrategyRequest{AllocationStrategy: "first-fit"})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("unknown parent subnet", func(t *testing.T) {
+		_, err := client.UpdateParentSubnetAllocationStrategy(model.NewID(), &model.UpdateParentSubnetAllocationStrategyRequest{})
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("server allocation strategy", func(t *testing.T) {
+		updated, err := client.UpdateParentSubnetAllocationStrategy(parentSubnet.ID, &model.UpdateParentSubnetAllocationStrategyRequest{})
+		require.NoError(t, err)
+		require.Empty(t, updated.AllocationStrategy)
+
+		for _, expected := range []string{"10.0.0.0/24", "10.0.1.0/24"} {
+			account, err := client.CreateAccount(&model.CreateAccountRequest{
+				Provider:                model.ProviderAWS,
+				ServiceCatalogProductID: "service-catalog-id",
+				Provision:               true,
+			})
+			require.NoError(t, err)
+			require.Equal(t, expected, account.AccountMetadata.Subnet)
+		}
+	})
+
+	t.Run("parent subnet allocation strategy", func(t *testing.T) {
+		updated, err := client.UpdateParentSubnetAllocationStrategy(parentSubnet.ID, &model.UpdateParentSubnetAllocationStrategyRequest{AllocationStrategy: model.SubnetAllocationSpread})
+		require.NoError(t, err)
+		require.Equal(t, model.SubnetAllocationSpread, updated.AllocationStrategy)
+
+		account, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.3.0/24", account.AccountMetadata.Subnet)
+	})
+}
+
+func TestParentSubnetLifecycle(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	parentSubnet, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/23", SplitRange: 24})
+	require.NoError(t, err)
+
+	_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+
+	t.Run("unknown parent subnet", func(t *testing.T) {
+		err := client.RetireParentSubnet(model.NewID())
+		require.EqualError(t, err, "failed with status code 404")
+
+		err = client.DeleteParentSubnet(model.NewID())
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("retire", func(t *testing.T) {
+		err := client.RetireParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+
+		parentSubnet, err = client.GetParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+		require.True(t, parentSubnet.Retired)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+	})
+
+	t.Run("unretire", func(t *testing.T) {
+		err := client.UnretireParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+
+		parentSubnet, err = client.GetParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+		require.False(t, parentSubnet.Retired)
+	})
+
+	t.Run("delete with claimed subnets", func(t *testing.T) {
+		err := client.DeleteParentSubnet(parentSubnet.ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		err := sqlStore.SubnetCleanup("10.0.0.0/24", 0)
+		require.NoError(t, err)
+
+		err = client.DeleteParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+
+		parentSubnet, err := client.GetParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+		require.Nil(t, parentSubnet)
+	})
+}
+
+func TestParentSubnetUsage(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:               sqlStore,
+		Supervisor:          &mockSupervisor{},
+		Logger:              logger,
+		SubnetLowWatermarks: []int{50, 25},
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	parentSubnet, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/22", SplitRange: 24})
+	require.NoError(t, err)
+
+	webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{OwnerID: "owner", URL: "https://url.com"})
+	require.NoError(t, err)
+
+	t.Run("unknown parent subnet", func(t *testing.T) {
+		usage, err := client.GetParentSubnetUsage(model.NewID())
+		require.NoError(t, err)
+		require.Nil(t, usage)
+	})
+
+	t.Run("empty parent subnet", func(t *testing.T) {
+		usage, err := client.GetParentSubnetUsage(parentSubnet.ID)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(4), usage.Total)
+		require.Equal(t, big.NewInt(4), usage.Free)
+		require.Equal(t, float64(100), usage.FreePercent)
+	})
+
+	t.Run("low watermark crossed", func(t *testing.T) {
+		_, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.0.0/24", Owner: "network-team", Reason: "on-prem link"})
+		require.NoError(t, err)
+		_, err = client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.1.0/24", Owner: "network-team", Reason: "cnc vpc"})
+		require.NoError(t, err)
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, WebhookID: webhook.ID})
+		require.NoError(t, err)
+		var payload *model.WebhookPayload
+		for _, delivery := range deliveries {
+			if delivery.Payload.ExtraData["Event"] == "low-watermark" {
+				payload = delivery.Payload
+			}
+		}
+		require.NotNil(t, payload, "low watermark webhook not enqueued")
+		require.Equal(t, model.TypeParentSubnet, payload.Type)
+		require.Equal(t, parentSubnet.ID, payload.ID)
+		require.Equal(t, "50", payload.ExtraData["Watermark"])
+		require.Equal(t, "50.00", payload.ExtraData["FreePercent"])
+	})
+
+	t.Run("all parent subnets usage", func(t *testing.T) {
+		usages, err := client.GetParentSubnetsUsage()
+		require.NoError(t, err)
+		require.Len(t, usages, 1)
+		require.Equal(t, big.NewInt(2), usages[0].Reserved)
+		require.Equal(t, big.NewInt(2), usages[0].Free)
+	})
+}
@@ -5,10 +5,13 @@
 package api
 
 import (
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
 )
 
 // initSubnet registers subnet endpoints on the given router.
@@ -19,9 +22,15 @@ func initSubnet(apiRouter *mux.Router, context *Context) {
 
 	subnetsRouter := apiRouter.PathPrefix("/subnets").Subrouter()
 	subnetsRouter.Handle("", addContext(handleGetSubnets)).Methods("GET")
+	subnetsRouter.Handle("/reserve", addContext(handleReserveSubnet)).Methods("POST")
+	subnetsRouter.Handle("/reconcile", addContext(handleReconcileSubnets)).Methods("POST")
+	subnetsRouter.Handle("/discover", addContext(handleDiscoverSubnets)).Methods("POST")
+	subnetsRouter.Handle("/history", addContext(handleGetSubnetAllocations)).Methods("GET")
 
 	subnetRouter := apiRouter.PathPrefix("/subnet/{subnet:[A-Za-z0-9]{26}}").Subrouter()
 	subnetRouter.Handle("", addContext(handleGetSubnet)).Methods("GET")
+	subnetRouter.Handle("/reserve", addContext(handleReserveExistingSubnet)).Methods("POST")
+	subnetRouter.Handle("/release", addContext(handleReleaseSubnet)).Methods("POST")
 }
 
 // handleGetSubnet responds to GET /api/subnet/{subnet}, returning the parent subnet in question.
@@ -46,8 +55,14 @@ func handleGetSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
 	outputJSON(c, w, sub)
 }
 
-// handleGetSubnets responds to GET /api/subnets, returning the specified page of subnets.
+// handleGetSubnets responds to GET /api/subnets, returning the specified page of subnets,
+// or the owners of the subnets containing the IP address of the contains parameter.
 func handleGetSubnets(c *Context, w http.ResponseWriter, r *http.Request) {
+	if address := r.URL.Query().Get("contains"); address != "" {
+		getSubnetOwners(c, w, address)
+		return
+	}
+
 	page, perPage, _, freeSubnets, err := parsePaging(r.URL)
 	if err != nil {
 		c.Logger.WithError(err).Error("failed to parse paging parameters")
@@ -55,10 +70,40 @@ func handleGetSubnets(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if freeSubnets && (perPage < 0 || perPage > model.MaxFreeSubnetsPerPage) {
+		c.Logger.Errorf("free subnets must be listed in pages of at most %d subnets", model.MaxFreeSubnetsPerPage)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reserved, err := parseBool(r.URL, "reserved", false)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to parse reserved parameter")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	quarantined, err := parseBool(r.URL, "quarantined", false)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to parse quarantined parameter")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	family := r.URL.Query().Get("family")
+	if family != "" && !model.IsValidSubnetFamily(family) {
+		c.Logger.Errorf("unsupported subnet family %s", family)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	filter := &model.SubnetFilter{
-		Page:    page,
-		PerPage: perPage,
-		Free:    freeSubnets,
+		Page:        page,
+		PerPage:     perPage,
+		Free:        freeSubnets,
+		Family:      family,
+		Reserved:    reserved,
+		Quarantined: quarantined,
 	}
 
 	subnets, err := c.Store.GetSubnets(filter)
@@ -75,3 +120,329 @@ func handleGetSubnets(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	outputJSON(c, w, subnets)
 }
+
+// getSubnetOwners responds with the subnets containing the given IP address
+// along with their parent subnet and the account they are claimed for.
+func getSubnetOwners(c *Context, w http.ResponseWriter, address string) {
+	c.Logger = c.Logger.WithField("address", address)
+
+	if net.ParseIP(address) == nil {
+		c.Logger.Error("invalid IP address")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	subnets, err := c.Store.GetSubnetsContaining(address)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query subnets containing address")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	parentSubnets, err := c.Store.GetParentSubnets(&model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query parent subnets")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	owners := []*model.SubnetOwner{}
+	for _, subnet := range subnets {
+		owner := &model.SubnetOwner{Subnet: subnet}
+		for i := range parentSubnets {
+			if parentSubnets[i].CIDR == subnet.ParentSubnet {
+				owner.ParentSubnet = &parentSubnets[i]
+				break
+			}
+		}
+		if subnet.AccountID != "" {
+			owner.Account, err = c.Store.GetAccount(subnet.AccountID)
+			if err != nil {
+				c.Logger.WithError(err).Error("failed to query account")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		owners = append(owners, owner)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, owners)
+}
+
+// handleGetSubnetAllocations responds to GET /api/subnets/history, returning the
+// specified page of subnet allocations, the most recent first.
+func handleGetSubnetAllocations(c *Context, w http.ResponseWriter, r *http.Request) {
+	page, perPage, _, _, err := parsePaging(r.URL)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to parse paging parameters")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	filter := &model.SubnetAllocationFilter{
+		Page:      page,
+		PerPage:   perPage,
+		CIDR:      r.URL.Query().Get("cidr"),
+		AccountID: r.URL.Query().Get("account"),
+	}
+
+	allocations, err := c.Store.GetSubnetAllocations(filter)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query subnet allocations")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if allocations == nil {
+		allocations = []*model.SubnetAllocation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, allocations)
+}
+
+// handleReserveSubnet responds to POST /api/subnets/reserve, reserving a free
+// subnet so that it cannot be claimed by accounts.
+func handleReserveSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
+	reserveSubnetRequest, err := model.NewReserveSubnetRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if reserveSubnetRequest.CIDR == "" {
+		c.Logger.Error("a subnet CIDR is required to reserve a subnet")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !isValidReservationExpiry(reserveSubnetRequest.ExpiresAt) {
+		c.Logger.Error("subnet reservation expiry is in the past")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	subnet, err := c.Store.ReserveSubnet(reserveSubnetRequest.CIDR, reserveSubnetRequest.Owner, reserveSubnetRequest.Reason, reserveSubnetRequest.ExpiresAt)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to reserve subnet")
+		w.WriteHeader(subnetErrorStatus(err))
+		return
+	}
+
+	checkParentSubnetLowWatermarks(c, subnet.CIDR)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	outputJSON(c, w, subnet)
+}
+
+// handleReconcileSubnets responds to POST /api/subnets/reconcile, reporting the
+// subnet claims that are out of sync with the accounts and fixing them if requested.
+func handleReconcileSubnets(c *Context, w http.ResponseWriter, r *http.Request) {
+	reconcileSubnetsRequest, err := model.NewReconcileSubnetsRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	claimedBefore := time.Now().UnixNano()/int64(time.Millisecond) - reconcileSubnetsRequest.MinClaimAge
+	var quarantinedUntil int64
+	if c.SubnetQuarantine > 0 {
+		quarantinedUntil = time.Now().Add(c.SubnetQuarantine).UnixNano() / int64(time.Millisecond)
+	}
+	drifts, err := c.Store.ReconcileSubnets(reconcileSubnetsRequest.Fix, claimedBefore, quarantinedUntil)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to reconcile subnets")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if drifts == nil {
+		drifts = []*model.SubnetDrift{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, drifts)
+}
+
+// handleDiscoverSubnets responds to POST /api/subnets/discover, finding the VPCs
+// of the requested AWS accounts and marking the subnets they use as externally
+// used unless only a report is requested.
+func handleDiscoverSubnets(c *Context, w http.ResponseWriter, r *http.Request) {
+	discoverSubnetsRequest, err := model.NewDiscoverSubnetsRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if discoverSubnetsRequest.RoleName == "" {
+		discoverSubnetsRequest.RoleName = c.SubnetDiscoveryRole
+	}
+	if discoverSubnetsRequest.RoleName == "" {
+		c.Logger.Error("no role to assume in the discovered accounts")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	vpcs, failures, err := c.Genesis.DiscoverVPCs(discoverSubnetsRequest, c.AWS)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to discover VPCs")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	overlaps, err := c.Store.MarkExternalSubnets(vpcs, !discoverSubnetsRequest.ReportOnly)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to mark externally used subnets")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	discovery := &model.SubnetDiscovery{
+		VPCCount: len(vpcs),
+		Overlaps: overlaps,
+		Failures: failures,
+	}
+	if discovery.Overlaps == nil {
+		discovery.Overlaps = []*model.SubnetOverlap{}
+	}
+	if discovery.Failures == nil {
+		discovery.Failures = []*model.SubnetDiscoveryFailure{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, discovery)
+}
+
+// handleReserveExistingSubnet responds to POST /api/subnet/{subnet}/reserve,
+// turning a stored subnet that no account uses into a reservation or updating
+// the metadata of an existing reservation.
+func handleReserveExistingSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	subnetID := vars["subnet"]
+	c.Logger = c.Logger.WithField("subnet", subnetID)
+
+	reserveSubnetRequest, err := model.NewReserveSubnetRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !isValidReservationExpiry(reserveSubnetRequest.ExpiresAt) {
+		c.Logger.Error("subnet reservation expiry is in the past")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if reserveSubnetRequest.CIDR != "" {
+		subnet, err := c.Store.GetSubnet(subnetID)
+		if err != nil {
+			c.Logger.WithError(err).Error("failed to query subnet")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if subnet != nil && reserveSubnetRequest.CIDR != subnet.CIDR {
+			c.Logger.Errorf("requested CIDR %s does not match subnet CIDR %s", reserveSubnetRequest.CIDR, subnet.CIDR)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	subnet, err := c.Store.ReserveExistingSubnet(subnetID, reserveSubnetRequest.Owner, reserveSubnetRequest.Reason, reserveSubnetRequest.ExpiresAt)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to reserve subnet")
+		w.WriteHeader(subnetErrorStatus(err))
+		return
+	}
+	if subnet == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, subnet)
+}
+
+// handleReleaseSubnet responds to POST /api/subnet/{subnet}/release, releasing
+// a subnet reservation so that the subnet can be claimed again.
+func handleReleaseSubnet(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	subnetID := vars["subnet"]
+	c.Logger = c.Logger.WithField("subnet", subnetID)
+
+	subnet, err := c.Store.GetSubnet(subnetID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if subnet == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !subnet.IsReserved() {
+		c.Logger.Warn("unable to release subnet that is not reserved")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err = c.Store.SubnetCleanup(subnet.CIDR, 0); err != nil {
+		c.Logger.WithError(err).Error("failed to release subnet")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// isValidReservationExpiry returns true if the given reservation expiry in
+// milliseconds is either unset or in the future.
+func isValidReservationExpiry(expiresAt int64) bool {
+	return expiresAt == 0 || expiresAt > time.Now().UnixNano()/int64(time.Millisecond)
+}
+
+// claimSubnet claims a subnet for an account and sends a parent subnet webhook
+// if the claim makes its parent subnet cross a low watermark.
+func claimSubnet(c *Context, claim *model.SubnetClaim) (*model.Subnet, error) {
+	if claim.AllocationStrategy == "" {
+		claim.AllocationStrategy = c.SubnetAllocationStrategy
+	}
+	subnet, err := c.Store.ClaimSubnet(claim)
+	if err != nil {
+		return nil, err
+	}
+
+	checkParentSubnetLowWatermarks(c, subnet.CIDR)
+
+	return subnet, nil
+}
+
+// subnetErrorStatus returns the status code of a failed subnet claim or
+// reservation.
+func subnetErrorStatus(err error) int {
+	switch errors.Cause(err) {
+	case model.ErrSubnetCapacityExhausted:
+		return http.StatusInsufficientStorage
+	case model.ErrSubnetUnavailable:
+		return http.StatusConflict
+	case model.ErrSubnetOutsideParentSubnets, model.ErrSubnetExcluded:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// releaseSubnets releases the given subnets claimed for an account that could not
+// be created. They skip the quarantine since no resource ever used them. Failures
+// are left to the subnet reconciliation.
+func releaseSubnets(c *Context, cidrs []string) {
+	for _, cidr := range cidrs {
+		if err := c.Store.SubnetCleanup(cidr, 0); err != nil {
+			c.Logger.WithError(err).WithField("subnet", cidr).Warn("failed to release subnet claimed for the account")
+		}
+	}
+}
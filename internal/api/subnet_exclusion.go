@@ -0,0 +1,146 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+// initSubnetExclusion registers subnet exclusion endpoints on the given router.
+func initSubnetExclusion(apiRouter *mux.Router, context *Context) {
+	addContext := func(handler contextHandlerFunc) *contextHandler {
+		return newContextHandler(context, handler)
+	}
+
+	subnetExclusionsRouter := apiRouter.PathPrefix("/subnets/exclusions").Subrouter()
+	subnetExclusionsRouter.Handle("", addContext(handleGetSubnetExclusions)).Methods("GET")
+	subnetExclusionsRouter.Handle("", addContext(handleAddSubnetExclusion)).Methods("POST")
+
+	subnetExclusionRouter := apiRouter.PathPrefix("/subnet/exclusion/{exclusion:[A-Za-z0-9]{26}}").Subrouter()
+	subnetExclusionRouter.Handle("", addContext(handleGetSubnetExclusion)).Methods("GET")
+	subnetExclusionRouter.Handle("", addContext(handleDeleteSubnetExclusion)).Methods("DELETE")
+}
+
+// handleGetSubnetExclusion responds to GET /api/subnet/exclusion/{exclusion},
+// returning the subnet exclusion in question.
+func handleGetSubnetExclusion(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	exclusionID := vars["exclusion"]
+	c.Logger = c.Logger.WithField("subnet-exclusion", exclusionID)
+
+	exclusion, err := c.Store.GetSubnetExclusion(exclusionID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query subnet exclusion")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if exclusion == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, exclusion)
+}
+
+// handleGetSubnetExclusions responds to GET /api/subnets/exclusions, returning
+// the specified page of subnet exclusions.
+func handleGetSubnetExclusions(c *Context, w http.ResponseWriter, r *http.Request) {
+	page, perPage, _, _, err := parsePaging(r.URL)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to parse paging parameters")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	filter := &model.SubnetExclusionFilter{
+		Page:    page,
+		PerPage: perPage,
+		Source:  r.URL.Query().Get("source"),
+	}
+
+	exclusions, err := c.Store.GetSubnetExclusions(filter)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query subnet exclusions")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if exclusions == nil {
+		exclusions = []*model.SubnetExclusion{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, exclusions)
+}
+
+// handleAddSubnetExclusion responds to POST /api/subnets/exclusions, excluding
+// a range from the subnets that can be claimed.
+func handleAddSubnetExclusion(c *Context, w http.ResponseWriter, r *http.Request) {
+	addSubnetExclusionRequest, err := model.NewAddSubnetExclusionRequestFromReader(r.Body)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to decode request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	exclusion := &model.SubnetExclusion{
+		CIDR:   addSubnetExclusionRequest.CIDR,
+		Source: model.SubnetExclusionSourceAPI,
+		Reason: addSubnetExclusionRequest.Reason,
+	}
+	if err = c.Store.AddSubnetExclusion(exclusion); err != nil {
+		if errors.Cause(err) == model.ErrSubnetExclusionExists {
+			c.Logger.WithError(err).Error("subnet exclusion already exists")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		c.Logger.WithError(err).Error("failed to add subnet exclusion")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, exclusion)
+}
+
+// handleDeleteSubnetExclusion responds to DELETE /api/subnet/exclusion/{exclusion},
+// making the excluded range available again. Exclusions seeded from the server
+// flags can only be removed from the flags.
+func handleDeleteSubnetExclusion(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	exclusionID := vars["exclusion"]
+	c.Logger = c.Logger.WithField("subnet-exclusion", exclusionID)
+
+	exclusion, err := c.Store.GetSubnetExclusion(exclusionID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query subnet exclusion")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if exclusion == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if exclusion.IsSeeded() {
+		c.Logger.Warnf("unable to delete subnet exclusion seeded from the %s flag", exclusion.Source)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err = c.Store.DeleteSubnetExclusion(exclusionID); err != nil {
+		c.Logger.WithError(err).Error("failed to delete subnet exclusion")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
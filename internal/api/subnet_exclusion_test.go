@@ -0,0 +1,89 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package api_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/internal/api"
+	"github.com/mattermost/genesis/internal/store"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnetExclusions(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	require.NoError(t, sqlStore.SeedSubnetExclusions("teleport-cidr", []string{"10.0.0.0/24"}, "Teleport"))
+
+	_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/22", SplitRange: 24})
+	require.NoError(t, err)
+
+	t.Run("invalid CIDR", func(t *testing.T) {
+		_, err := client.AddSubnetExclusion(&model.AddSubnetExclusionRequest{CIDR: "10.0.1.0", Reason: "VPN"})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	var exclusion *model.SubnetExclusion
+	t.Run("add", func(t *testing.T) {
+		exclusion, err = client.AddSubnetExclusion(&model.AddSubnetExclusionRequest{CIDR: "10.0.1.0/24", Reason: "VPN"})
+		require.NoError(t, err)
+		require.Equal(t, model.SubnetExclusionSourceAPI, exclusion.Source)
+
+		fetched, err := client.GetSubnetExclusion(exclusion.ID)
+		require.NoError(t, err)
+		require.Equal(t, exclusion, fetched)
+
+		free, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, free, 2)
+	})
+
+	t.Run("already excluded", func(t *testing.T) {
+		_, err := client.AddSubnetExclusion(&model.AddSubnetExclusionRequest{CIDR: "10.0.1.7/24", Reason: "VPN"})
+		require.EqualError(t, err, "failed with status code 409")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		exclusions, err := client.GetSubnetExclusions(&model.GetSubnetExclusionsRequest{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, exclusions, 2)
+
+		exclusions, err = client.GetSubnetExclusions(&model.GetSubnetExclusionsRequest{PerPage: model.AllPerPage, Source: "teleport-cidr"})
+		require.NoError(t, err)
+		require.Len(t, exclusions, 1)
+		require.Equal(t, "10.0.0.0/24", exclusions[0].CIDR)
+
+		err = client.DeleteSubnetExclusion(exclusions[0].ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, client.DeleteSubnetExclusion(exclusion.ID))
+
+		fetched, err := client.GetSubnetExclusion(exclusion.ID)
+		require.NoError(t, err)
+		require.Nil(t, fetched)
+
+		err = client.DeleteSubnetExclusion(exclusion.ID)
+		require.EqualError(t, err, "failed with status code 404")
+	})
+}
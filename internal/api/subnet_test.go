@@ -0,0 +1,416 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package api_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/internal/api"
+	"github.com/mattermost/genesis/internal/store"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveSubnet(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/23", SplitRange: 24})
+	require.NoError(t, err)
+
+	t.Run("missing owner", func(t *testing.T) {
+		_, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.0.0/24", Reason: "on-prem link"})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("expiry in the past", func(t *testing.T) {
+		_, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.0.0/24", Owner: "network-team", Reason: "on-prem link", ExpiresAt: 1})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	var reserved *model.Subnet
+	t.Run("reserve", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+		reserved, err = client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.0.0/24", Owner: "network-team", Reason: "on-prem link", ExpiresAt: expiresAt})
+		require.NoError(t, err)
+		require.Equal(t, "network-team", reserved.ReservationOwner)
+		require.Equal(t, expiresAt, reserved.ReservationExpiresAt)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage, Reserved: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+
+		subnets, err = client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+	})
+
+	t.Run("reserve reserved subnet", func(t *testing.T) {
+		_, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.0.0/24", Owner: "network-team", Reason: "on-prem link"})
+		require.EqualError(t, err, "failed with status code 409")
+	})
+
+	t.Run("reserve subnet outside the parent subnets", func(t *testing.T) {
+		_, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.1.0.0/24", Owner: "network-team", Reason: "on-prem link"})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("reserve excluded subnet", func(t *testing.T) {
+		exclusion := &model.SubnetExclusion{CIDR: "10.0.1.0/28", Reason: "on-prem link"}
+		require.NoError(t, sqlStore.AddSubnetExclusion(exclusion))
+		defer func() {
+			require.NoError(t, sqlStore.DeleteSubnetExclusion(exclusion.ID))
+		}()
+
+		_, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: "10.0.1.0/24", Owner: "network-team", Reason: "on-prem link"})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("update reservation", func(t *testing.T) {
+		subnet, err := client.ReserveExistingSubnet(reserved.ID, &model.ReserveSubnetRequest{Owner: "network-team", Reason: "cnc vpc"})
+		require.NoError(t, err)
+		require.Equal(t, "cnc vpc", subnet.ReservationReason)
+		require.Zero(t, subnet.ReservationExpiresAt)
+	})
+
+	t.Run("reserve subnet used by an account", func(t *testing.T) {
+		account, err := client.CreateAccount(&model.CreateAccountRequest{
+			Provider:                model.ProviderAWS,
+			ServiceCatalogProductID: "service-catalog-id",
+			Provision:               true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.1.0/24", account.AccountMetadata.Subnet)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+
+		_, err = client.ReserveExistingSubnet(subnets[0].ID, &model.ReserveSubnetRequest{Owner: "network-team", Reason: "cnc vpc"})
+		require.EqualError(t, err, "failed with status code 409")
+	})
+
+	t.Run("release subnet that is not reserved", func(t *testing.T) {
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+
+		err = client.ReleaseSubnet(subnets[0].ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("release", func(t *testing.T) {
+		err := client.ReleaseSubnet(reserved.ID)
+		require.NoError(t, err)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: 10, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, "10.0.0.0/24", subnets[0].CIDR)
+
+		// Free subnets are always paginated.
+		_, err = client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage, Free: true})
+		require.EqualError(t, err, "failed with status code 400")
+		_, err = client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.MaxFreeSubnetsPerPage + 1, Free: true})
+		require.EqualError(t, err, "failed with status code 400")
+
+		err = client.ReleaseSubnet(reserved.ID)
+		require.EqualError(t, err, "failed with status code 404")
+	})
+}
+
+func TestReconcileSubnets(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/23", SplitRange: 24})
+	require.NoError(t, err)
+
+	account, err := client.CreateAccount(&model.CreateAccountRequest{
+		Provider:                model.ProviderAWS,
+		ServiceCatalogProductID: "service-catalog-id",
+		Provision:               true,
+	})
+	require.NoError(t, err)
+
+	orphaned, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: model.NewID()})
+	require.NoError(t, err)
+
+	// The claims are older than the minimum claim age of the reconciliations below.
+	time.Sleep(10 * time.Millisecond)
+
+	t.Run("negative minimum claim age", func(t *testing.T) {
+		_, err := client.ReconcileSubnets(&model.ReconcileSubnetsRequest{MinClaimAge: -1})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("recent claims are kept", func(t *testing.T) {
+		drifts, err := client.ReconcileSubnets(&model.ReconcileSubnetsRequest{MinClaimAge: time.Hour.Milliseconds()})
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+
+	t.Run("claims younger than the default minimum claim age are kept", func(t *testing.T) {
+		drifts, err := client.ReconcileSubnets(&model.ReconcileSubnetsRequest{Fix: true})
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		drifts, err := client.ReconcileSubnets(&model.ReconcileSubnetsRequest{MinClaimAge: 5})
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.Equal(t, model.SubnetDriftOrphanedClaim, drifts[0].Type)
+		require.Equal(t, orphaned.CIDR, drifts[0].CIDR)
+		require.False(t, drifts[0].Fixed)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, subnets, 2)
+	})
+
+	t.Run("fix", func(t *testing.T) {
+		drifts, err := client.ReconcileSubnets(&model.ReconcileSubnetsRequest{Fix: true, MinClaimAge: 5})
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.True(t, drifts[0].Fixed)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, account.AccountMetadata.Subnet, subnets[0].CIDR)
+		require.Equal(t, account.ID, subnets[0].AccountID)
+
+		drifts, err = client.ReconcileSubnets(&model.ReconcileSubnetsRequest{})
+		require.NoError(t, err)
+		require.Empty(t, drifts)
+	})
+}
+
+func TestDiscoverSubnets(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	genesis := &mockGenesis{
+		vpcs: []*model.DiscoveredVPC{
+			{AWSAccountID: "111111111111", Region: "us-east-1", VPCID: "vpc-external", CIDRs: []string{"10.0.1.0/24"}},
+			{AWSAccountID: "111111111111", Region: "us-east-1", VPCID: "vpc-unrelated", CIDRs: []string{"172.16.0.0/16"}},
+		},
+		failures: []*model.SubnetDiscoveryFailure{
+			{AWSAccountID: "222222222222", Error: "access denied"},
+		},
+	}
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:               sqlStore,
+		Supervisor:          &mockSupervisor{},
+		Genesis:             genesis,
+		SubnetDiscoveryRole: "discovery-role",
+		Logger:              logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.0.0.0/23", SplitRange: 24})
+	require.NoError(t, err)
+
+	t.Run("invalid account", func(t *testing.T) {
+		_, err := client.DiscoverSubnets(&model.DiscoverSubnetsRequest{AWSAccountIDs: []string{"invalid"}})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("report only", func(t *testing.T) {
+		discovery, err := client.DiscoverSubnets(&model.DiscoverSubnetsRequest{ReportOnly: true})
+		require.NoError(t, err)
+		require.Equal(t, "discovery-role", genesis.request.RoleName)
+		require.Equal(t, 2, discovery.VPCCount)
+		require.Len(t, discovery.Overlaps, 1)
+		require.Equal(t, model.SubnetOverlapMarked, discovery.Overlaps[0].Status)
+		require.Len(t, discovery.Failures, 1)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage, Reserved: true})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		discovery, err := client.DiscoverSubnets(&model.DiscoverSubnetsRequest{AWSAccountIDs: []string{"111111111111"}, RoleName: "custom-role"})
+		require.NoError(t, err)
+		require.Equal(t, "custom-role", genesis.request.RoleName)
+		require.Len(t, discovery.Overlaps, 1)
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage, Reserved: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, "10.0.1.0/24", subnets[0].CIDR)
+		require.Equal(t, model.SubnetReservationOwnerExternal, subnets[0].ReservationOwner)
+	})
+
+	t.Run("discovery failure", func(t *testing.T) {
+		genesis.err = errors.New("failed to assume control tower iam role")
+		_, err := client.DiscoverSubnets(&model.DiscoverSubnetsRequest{})
+		require.EqualError(t, err, "failed with status code 500")
+	})
+}
+
+func TestGetSubnetOwners(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	parentSubnet, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.12.0.0/23", SplitRange: 24, Labels: map[string]string{"region": "us-east-1"}})
+	require.NoError(t, err)
+
+	account, err := client.CreateAccount(&model.CreateAccountRequest{
+		Provider:                model.ProviderAWS,
+		ServiceCatalogProductID: "service-catalog-id",
+		Provision:               true,
+	})
+	require.NoError(t, err)
+	account.ProviderMetadataAWS.AWSAccountID = "111111111111"
+	require.NoError(t, sqlStore.UpdateAccount(account))
+
+	reservedCIDR := "10.12.0.0/24"
+	if account.AccountMetadata.Subnet == reservedCIDR {
+		reservedCIDR = "10.12.1.0/24"
+	}
+	reserved, err := client.ReserveSubnet(&model.ReserveSubnetRequest{CIDR: reservedCIDR, Owner: "network-team", Reason: "on-prem link"})
+	require.NoError(t, err)
+
+	t.Run("invalid address", func(t *testing.T) {
+		_, err := client.GetSubnetOwners("10.12.34")
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("claimed subnet", func(t *testing.T) {
+		address := strings.TrimSuffix(account.AccountMetadata.Subnet, "0/24") + "56"
+		owners, err := client.GetSubnetOwners(address)
+		require.NoError(t, err)
+		require.Len(t, owners, 1)
+		require.Equal(t, account.AccountMetadata.Subnet, owners[0].Subnet.CIDR)
+		require.Equal(t, parentSubnet.ID, owners[0].ParentSubnet.ID)
+		require.Equal(t, map[string]string{"region": "us-east-1"}, owners[0].ParentSubnet.Labels)
+		require.NotNil(t, owners[0].Account)
+		require.Equal(t, account.ID, owners[0].Account.ID)
+		require.Equal(t, "111111111111", owners[0].Account.ProviderMetadataAWS.AWSAccountID)
+	})
+
+	t.Run("reserved subnet", func(t *testing.T) {
+		address := strings.TrimSuffix(reserved.CIDR, "0/24") + "1"
+		owners, err := client.GetSubnetOwners(address)
+		require.NoError(t, err)
+		require.Len(t, owners, 1)
+		require.Equal(t, reserved.ID, owners[0].Subnet.ID)
+		require.Nil(t, owners[0].Account)
+	})
+
+	t.Run("unknown address", func(t *testing.T) {
+		owners, err := client.GetSubnetOwners("172.16.0.1")
+		require.NoError(t, err)
+		require.Empty(t, owners)
+	})
+}
+
+func TestGetSubnetAllocations(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	_, err := client.AddParentSubnet(&model.AddParentSubnetRequest{CIDR: "10.13.0.0/23", SplitRange: 24})
+	require.NoError(t, err)
+
+	account, err := client.CreateAccount(&model.CreateAccountRequest{
+		Provider:                model.ProviderAWS,
+		ServiceCatalogProductID: "service-catalog-id",
+		Provision:               true,
+	})
+	require.NoError(t, err)
+
+	t.Run("history of the account", func(t *testing.T) {
+		allocations, err := client.GetSubnetAllocations(&model.GetSubnetAllocationsRequest{PerPage: model.AllPerPage, AccountID: account.ID})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		require.Equal(t, account.AccountMetadata.Subnet, allocations[0].CIDR)
+		require.False(t, allocations[0].IsReleased())
+	})
+
+	t.Run("quarantined subnets", func(t *testing.T) {
+		quarantinedUntil := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+		require.NoError(t, sqlStore.SubnetCleanup(account.AccountMetadata.Subnet, quarantinedUntil))
+
+		subnets, err := client.GetSubnets(&model.GetSubnetsRequest{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, account.AccountMetadata.Subnet, subnets[0].CIDR)
+		require.Equal(t, model.SubnetReservationOwnerQuarantine, subnets[0].ReservationOwner)
+
+		allocations, err := client.GetSubnetAllocations(&model.GetSubnetAllocationsRequest{PerPage: model.AllPerPage, CIDR: account.AccountMetadata.Subnet})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		require.True(t, allocations[0].IsReleased())
+	})
+
+	t.Run("unknown subnet", func(t *testing.T) {
+		allocations, err := client.GetSubnetAllocations(&model.GetSubnetAllocationsRequest{PerPage: model.AllPerPage, CIDR: "172.16.0.0/24"})
+		require.NoError(t, err)
+		require.Empty(t, allocations)
+	})
+}
@@ -8,7 +8,9 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/mattermost/genesis/internal/webhook"
 	"github.com/mattermost/genesis/model"
+	log "github.com/sirupsen/logrus"
 )
 
 // initWebhook registers webhook endpoints on the given router.
@@ -24,6 +26,10 @@ func initWebhook(apiRouter *mux.Router, context *Context) {
 	webhookRouter := apiRouter.PathPrefix("/webhook/{webhook:[A-Za-z0-9]{26}}").Subrouter()
 	webhookRouter.Handle("", addContext(handleGetWebhook)).Methods("GET")
 	webhookRouter.Handle("", addContext(handleDeleteWebhook)).Methods("DELETE")
+	webhookRouter.Handle("/enable", addContext(handleEnableWebhook)).Methods("POST")
+	webhookRouter.Handle("/ping", addContext(handlePingWebhook)).Methods("POST")
+	webhookRouter.Handle("/deliveries", addContext(handleGetWebhookDeliveries)).Methods("GET")
+	webhookRouter.Handle("/deliveries/{delivery:[A-Za-z0-9]{26}}/redeliver", addContext(handleRedeliverWebhookDelivery)).Methods("POST")
 }
 
 // handleCreateWebhook responds to POST /api/webhooks, creating a new webhook.
@@ -36,8 +42,16 @@ func handleCreateWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 
 	webhook := model.Webhook{
-		OwnerID: createWebhookRequest.OwnerID,
-		URL:     createWebhookRequest.URL,
+		OwnerID:        createWebhookRequest.OwnerID,
+		URL:            createWebhookRequest.URL,
+		Secret:         createWebhookRequest.Secret,
+		Format:         createWebhookRequest.Format,
+		Headers:        createWebhookRequest.Headers,
+		Auth:           createWebhookRequest.Auth,
+		TimeoutSeconds: createWebhookRequest.TimeoutSeconds,
+	}
+	if !createWebhookRequest.Subscription.IsEmpty() {
+		webhook.Subscription = createWebhookRequest.Subscription
 	}
 
 	if err = c.Store.CreateWebhook(&webhook); err != nil {
@@ -48,7 +62,7 @@ func handleCreateWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	outputJSON(c, w, webhook)
+	outputJSON(c, w, webhook.Redacted())
 }
 
 // handleGetWebhook responds to GET /api/webhook/{webhook}, returning the webhook in question.
@@ -70,7 +84,7 @@ func handleGetWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	outputJSON(c, w, webhook)
+	outputJSON(c, w, webhook.Redacted())
 }
 
 // handleGetWebhooks responds to GET /api/webhooks, returning the specified page of webhooks.
@@ -100,6 +114,9 @@ func handleGetWebhooks(c *Context, w http.ResponseWriter, r *http.Request) {
 	if webhooks == nil {
 		webhooks = []*model.Webhook{}
 	}
+	for i, webhook := range webhooks {
+		webhooks[i] = webhook.Redacted()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -136,3 +153,200 @@ func handleDeleteWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// handleGetWebhookDeliveries responds to GET /api/webhook/{webhook}/deliveries,
+// returning the specified page of deliveries of the webhook with their attempts.
+func handleGetWebhookDeliveries(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID := vars["webhook"]
+	c.Logger = c.Logger.WithField("webhook", webhookID)
+
+	page, perPage, _, _, err := parsePaging(r.URL)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to parse paging parameters")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", model.WebhookDeliveryStatePending, model.WebhookDeliveryStateDelivered, model.WebhookDeliveryStateFailed:
+	default:
+		c.Logger.Errorf("unsupported webhook delivery state %s", state)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := c.Store.GetWebhook(webhookID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if webhook == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	deliveries, err := c.Store.GetWebhookDeliveries(&model.WebhookDeliveryFilter{
+		Page:      page,
+		PerPage:   perPage,
+		WebhookID: webhookID,
+		State:     state,
+	})
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook deliveries")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*model.WebhookDelivery{}
+	}
+
+	deliveryIDs := make([]string, 0, len(deliveries))
+	deliveriesByID := make(map[string]*model.WebhookDelivery, len(deliveries))
+	for _, delivery := range deliveries {
+		deliveryIDs = append(deliveryIDs, delivery.ID)
+		deliveriesByID[delivery.ID] = delivery
+	}
+	attempts, err := c.Store.GetWebhookDeliveryAttempts(deliveryIDs)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook delivery attempts")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for _, attempt := range attempts {
+		delivery := deliveriesByID[attempt.DeliveryID]
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, deliveries)
+}
+
+// handleRedeliverWebhookDelivery responds to POST
+// /api/webhook/{webhook}/deliveries/{delivery}/redeliver, adding a new delivery
+// of the payload of the given delivery to the outbox of the webhook.
+func handleRedeliverWebhookDelivery(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID := vars["webhook"]
+	deliveryID := vars["delivery"]
+	c.Logger = c.Logger.WithFields(log.Fields{
+		"webhook":  webhookID,
+		"delivery": deliveryID,
+	})
+
+	webhook, err := c.Store.GetWebhook(webhookID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if webhook == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if webhook.IsDeleted() {
+		c.Logger.Warn("unable to redeliver to a deleted webhook")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := c.Store.GetWebhookDelivery(deliveryID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook delivery")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if delivery == nil || delivery.WebhookID != webhookID {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	redelivery, err := c.Store.RedeliverWebhookDelivery(delivery)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to redeliver webhook delivery")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	outputJSON(c, w, redelivery)
+}
+
+// handleEnableWebhook responds to POST /api/webhook/{webhook}/enable, sending a
+// ping to the webhook and enabling it again if the receiver accepts the ping.
+func handleEnableWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID := vars["webhook"]
+	c.Logger = c.Logger.WithField("webhook", webhookID)
+
+	hook, err := c.Store.GetWebhook(webhookID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if hook == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if hook.IsDeleted() {
+		c.Logger.Warn("unable to enable a deleted webhook")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if _, err = webhook.Deliver(hook, model.NewWebhookPingPayload(hook.ID)); err != nil {
+		c.Logger.WithError(err).Warn("webhook ping failed, leaving the webhook disabled")
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	if err = c.Store.EnableWebhook(hook.ID); err != nil {
+		c.Logger.WithError(err).Error("failed to enable webhook")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	hook.Disabled = false
+	hook.ConsecutiveFailures = 0
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, hook.Redacted())
+}
+
+// handlePingWebhook responds to POST /api/webhook/{webhook}/ping, sending a ping
+// to the webhook and returning how the receiver responded.
+func handlePingWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	webhookID := vars["webhook"]
+	c.Logger = c.Logger.WithField("webhook", webhookID)
+
+	hook, err := c.Store.GetWebhook(webhookID)
+	if err != nil {
+		c.Logger.WithError(err).Error("failed to query webhook")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if hook == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if hook.IsDeleted() {
+		c.Logger.Warn("unable to ping a deleted webhook")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	result := webhook.Ping(hook)
+	if !result.IsSuccessful() {
+		c.Logger.Warnf("webhook ping failed: %s", result.Error)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	outputJSON(c, w, result)
+}
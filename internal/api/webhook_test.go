@@ -78,9 +78,136 @@ func TestCreateWebhook(t *testing.T) {
 		require.NotEmpty(t, webhook.ID)
 		require.Equal(t, "owner", webhook.OwnerID)
 		require.Equal(t, "https://validurl.com", webhook.URL)
+		require.Equal(t, model.WebhookFormatGenesis, webhook.Format)
 		require.NotEqual(t, 0, webhook.CreateAt)
 		require.EqualValues(t, 0, webhook.DeleteAt)
 	})
+
+	t.Run("invalid subscription", func(t *testing.T) {
+		_, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID:      "owner",
+			URL:          "https://validurl.com",
+			Subscription: &model.WebhookSubscription{Types: []string{"cluster"}},
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("subscription", func(t *testing.T) {
+		subscription := &model.WebhookSubscription{
+			Types:       []string{model.TypeAccount},
+			States:      []string{"*-failed"},
+			Environment: "prod",
+		}
+		webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID:      "pager",
+			URL:          "https://pagerurl.com",
+			Subscription: subscription,
+		})
+		require.NoError(t, err)
+		require.Equal(t, subscription, webhook.Subscription)
+
+		webhook, err = client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, subscription, webhook.Subscription)
+	})
+
+	t.Run("format", func(t *testing.T) {
+		webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID: "chat",
+			URL:     "https://chat.example.com/hooks/id",
+			Format:  model.WebhookFormatMattermost,
+		})
+		require.NoError(t, err)
+		require.Equal(t, model.WebhookFormatMattermost, webhook.Format)
+
+		webhook, err = client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.WebhookFormatMattermost, webhook.Format)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID: "chat",
+			URL:     "https://chat.example.com/hooks/id",
+			Format:  "teams",
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("secret with chat format", func(t *testing.T) {
+		_, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID: "chat",
+			URL:     "https://chat.example.com/hooks/id",
+			Format:  model.WebhookFormatSlack,
+			Secret:  "secret",
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("headers and auth are redacted", func(t *testing.T) {
+		webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID:        "gateway",
+			URL:            "https://gateway.com",
+			Headers:        map[string]string{"X-Tenant": "genesis"},
+			Auth:           &model.WebhookAuth{Type: model.WebhookAuthBearer, Token: "token"},
+			TimeoutSeconds: 10,
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"X-Tenant": model.WebhookRedacted}, webhook.Headers)
+		require.Equal(t, &model.WebhookAuth{Type: model.WebhookAuthBearer, Token: model.WebhookRedacted}, webhook.Auth)
+		require.Equal(t, 10, webhook.TimeoutSeconds)
+
+		webhook, err = client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"X-Tenant": model.WebhookRedacted}, webhook.Headers)
+		require.Equal(t, model.WebhookRedacted, webhook.Auth.Token)
+
+		webhooks, err := client.GetWebhooks(&model.GetWebhooksRequest{OwnerID: "gateway", PerPage: 10})
+		require.NoError(t, err)
+		require.Len(t, webhooks, 1)
+		require.Equal(t, model.WebhookRedacted, webhooks[0].Auth.Token)
+
+		storedWebhook, err := sqlStore.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"X-Tenant": "genesis"}, storedWebhook.Headers)
+		require.Equal(t, "token", storedWebhook.Auth.Token)
+	})
+
+	t.Run("invalid headers", func(t *testing.T) {
+		_, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID: "gateway",
+			URL:     "https://gateway.com",
+			Headers: map[string]string{"Content-Type": "text/plain"},
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		_, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID:        "gateway",
+			URL:            "https://gateway.com",
+			TimeoutSeconds: 120,
+		})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("secret is not returned", func(t *testing.T) {
+		webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+			OwnerID: "owner",
+			URL:     "https://signedurl.com",
+			Secret:  "secret",
+		})
+		require.NoError(t, err)
+		require.Empty(t, webhook.Secret)
+
+		webhook, err = client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Empty(t, webhook.Secret)
+
+		storedWebhook, err := sqlStore.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, "secret", storedWebhook.Secret)
+	})
 }
 
 func TestGetWebhooks(t *testing.T) {
@@ -309,3 +436,225 @@ func TestDeleteWebhook(t *testing.T) {
 		require.True(t, webhook.IsDeleted())
 	})
 }
+
+func TestWebhookDeliveries(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+	defer store.CloseConnection(t, sqlStore)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	client := model.NewClient(ts.URL)
+
+	webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+		OwnerID: "owner",
+		URL:     "https://validurl.com",
+	})
+	require.NoError(t, err)
+
+	payload := &model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), NewState: "stable", Timestamp: time.Now().UnixNano()}
+	require.NoError(t, sqlStore.EnqueueWebhookPayload(payload))
+
+	delivery, err := sqlStore.GetNextWebhookDelivery(webhook.ID)
+	require.NoError(t, err)
+	delivery.Attempts = 1
+	delivery.State = model.WebhookDeliveryStateDelivered
+	attempt := &model.WebhookDeliveryAttempt{StatusCode: http.StatusOK, Latency: 7}
+	require.NoError(t, sqlStore.RecordWebhookDeliveryAttempt(delivery, attempt))
+
+	t.Run("unknown webhook", func(t *testing.T) {
+		_, err := client.GetWebhookDeliveries(model.NewID(), &model.GetWebhookDeliveriesRequest{PerPage: 10})
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		_, err := client.GetWebhookDeliveries(webhook.ID, &model.GetWebhookDeliveriesRequest{PerPage: 10, State: "unknown"})
+		require.EqualError(t, err, "failed with status code 400")
+	})
+
+	t.Run("deliveries with attempts", func(t *testing.T) {
+		deliveries, err := client.GetWebhookDeliveries(webhook.ID, &model.GetWebhookDeliveriesRequest{PerPage: 10})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, delivery.ID, deliveries[0].ID)
+		require.Equal(t, payload, deliveries[0].Payload)
+		require.Equal(t, []*model.WebhookDeliveryAttempt{attempt}, deliveries[0].AttemptLog)
+
+		deliveries, err = client.GetWebhookDeliveries(webhook.ID, &model.GetWebhookDeliveriesRequest{PerPage: 10, State: model.WebhookDeliveryStatePending})
+		require.NoError(t, err)
+		require.Empty(t, deliveries)
+	})
+
+	t.Run("redeliver unknown delivery", func(t *testing.T) {
+		_, err := client.RedeliverWebhookDelivery(webhook.ID, model.NewID())
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("redeliver delivery of another webhook", func(t *testing.T) {
+		other, err := client.CreateWebhook(&model.CreateWebhookRequest{OwnerID: "owner", URL: "https://otherurl.com"})
+		require.NoError(t, err)
+
+		_, err = client.RedeliverWebhookDelivery(other.ID, delivery.ID)
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("redeliver", func(t *testing.T) {
+		redelivery, err := client.RedeliverWebhookDelivery(webhook.ID, delivery.ID)
+		require.NoError(t, err)
+		require.NotEqual(t, delivery.ID, redelivery.ID)
+		require.True(t, redelivery.IsPending())
+		require.Equal(t, payload, redelivery.Payload)
+
+		deliveries, err := client.GetWebhookDeliveries(webhook.ID, &model.GetWebhookDeliveriesRequest{PerPage: 10, State: model.WebhookDeliveryStatePending})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, redelivery.ID, deliveries[0].ID)
+	})
+
+	t.Run("redeliver to deleted webhook", func(t *testing.T) {
+		require.NoError(t, client.DeleteWebhook(webhook.ID))
+
+		_, err := client.RedeliverWebhookDelivery(webhook.ID, delivery.ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+}
+
+func TestEnableWebhook(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	var pings []*model.WebhookPayload
+	statusCode := http.StatusServiceUnavailable
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := model.WebhookPayloadFromReader(r.Body)
+		require.NoError(t, err)
+		pings = append(pings, payload)
+		w.WriteHeader(statusCode)
+	}))
+	defer receiver.Close()
+
+	client := model.NewClient(ts.URL)
+
+	webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+		OwnerID: "owner",
+		URL:     receiver.URL,
+	})
+	require.NoError(t, err)
+	_, err = sqlStore.RecordWebhookFailure(webhook.ID, 1)
+	require.NoError(t, err)
+
+	t.Run("unknown webhook", func(t *testing.T) {
+		_, err := client.EnableWebhook(model.NewID())
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("failed ping", func(t *testing.T) {
+		_, err := client.EnableWebhook(webhook.ID)
+		require.EqualError(t, err, "failed with status code 502")
+
+		webhook, err := client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.True(t, webhook.Disabled)
+		require.Equal(t, 1, webhook.ConsecutiveFailures)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		statusCode = http.StatusOK
+		enabled, err := client.EnableWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.False(t, enabled.Disabled)
+		require.Equal(t, 0, enabled.ConsecutiveFailures)
+
+		webhook, err := client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.False(t, webhook.Disabled)
+
+		require.Len(t, pings, 2)
+		require.Equal(t, model.TypeWebhookPing, pings[1].Type)
+		require.Equal(t, webhook.ID, pings[1].ID)
+	})
+
+	t.Run("deleted webhook", func(t *testing.T) {
+		require.NoError(t, client.DeleteWebhook(webhook.ID))
+		_, err := client.EnableWebhook(webhook.ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+}
+
+func TestPingWebhook(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := store.MakeTestSQLStore(t, logger)
+
+	router := mux.NewRouter()
+	api.Register(router, &api.Context{
+		Store:      sqlStore,
+		Supervisor: &mockSupervisor{},
+		Logger:     logger,
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	statusCode := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		fmt.Fprint(w, "pong")
+	}))
+	defer receiver.Close()
+
+	client := model.NewClient(ts.URL)
+
+	webhook, err := client.CreateWebhook(&model.CreateWebhookRequest{
+		OwnerID: "owner",
+		URL:     receiver.URL,
+	})
+	require.NoError(t, err)
+
+	t.Run("unknown webhook", func(t *testing.T) {
+		_, err := client.PingWebhook(model.NewID())
+		require.EqualError(t, err, "failed with status code 404")
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		result, err := client.PingWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.True(t, result.IsSuccessful())
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		require.Equal(t, "pong", result.Response)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		statusCode = http.StatusForbidden
+		result, err := client.PingWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.False(t, result.IsSuccessful())
+		require.Equal(t, http.StatusForbidden, result.StatusCode)
+		require.Equal(t, "webhook receiver responded with status code 403", result.Error)
+
+		// Pings do not count as delivery failures.
+		webhook, err := client.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 0, webhook.ConsecutiveFailures)
+	})
+
+	t.Run("deleted webhook", func(t *testing.T) {
+		require.NoError(t, client.DeleteWebhook(webhook.ID))
+		_, err := client.PingWebhook(webhook.ID)
+		require.EqualError(t, err, "failed with status code 400")
+	})
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
 	"github.com/aws/aws-sdk-go/service/ram"
 	"github.com/aws/aws-sdk-go/service/ram/ramiface"
 	"github.com/aws/aws-sdk-go/service/servicecatalog"
@@ -49,6 +51,7 @@ type Service struct {
 	sts            stsiface.STSAPI
 	serviceCatalog servicecatalogiface.ServiceCatalogAPI
 	ram            ramiface.RAMAPI
+	organizations  organizationsiface.OrganizationsAPI
 }
 
 // NewService creates a new instance of Service.
@@ -59,6 +62,7 @@ func NewService(sess *session.Session) *Service {
 		sts:            sts.New(sess),
 		serviceCatalog: servicecatalog.New(sess),
 		ram:            ram.New(sess),
+		organizations:  organizations.New(sess),
 	}
 }
 
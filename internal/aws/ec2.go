@@ -0,0 +1,132 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+// GetVPCIDByCIDR returns the ID of the VPC with the given CIDR block.
+func (a *Client) GetVPCIDByCIDR(cidr string) (string, error) {
+	vpcs, err := a.Service().ec2.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("cidr-block-association.cidr-block"),
+				Values: []*string{aws.String(cidr)},
+			},
+		},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to describe VPCs")
+	}
+	if len(vpcs.Vpcs) == 0 {
+		return "", errors.Errorf("no VPC found with CIDR %s", cidr)
+	}
+	if len(vpcs.Vpcs) > 1 {
+		return "", errors.Errorf("expected one VPC with CIDR %s, but found %d", cidr, len(vpcs.Vpcs))
+	}
+
+	return aws.StringValue(vpcs.Vpcs[0].VpcId), nil
+}
+
+// GetSubnetIDs returns the IDs of all subnets in the given VPC.
+func (a *Client) GetSubnetIDs(vpcID string) ([]string, error) {
+	var subnetIDs []string
+	err := a.Service().ec2.DescribeSubnetsPages(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcID)},
+			},
+		},
+	}, func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+		for _, subnet := range page.Subnets {
+			subnetIDs = append(subnetIDs, aws.StringValue(subnet.SubnetId))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe subnets")
+	}
+
+	return subnetIDs, nil
+}
+
+// GetTransitGatewayAttachment returns the ID and state of the transit gateway
+// attachment of the given VPC.
+func (a *Client) GetTransitGatewayAttachment(transitGatewayID, vpcID string) (string, string, error) {
+	attachments, err := a.Service().ec2.DescribeTransitGatewayVpcAttachments(&ec2.DescribeTransitGatewayVpcAttachmentsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("transit-gateway-id"),
+				Values: []*string{aws.String(transitGatewayID)},
+			},
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcID)},
+			},
+		},
+	})
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to describe transit gateway VPC attachments")
+	}
+	if len(attachments.TransitGatewayVpcAttachments) == 0 {
+		return "", "", nil
+	}
+
+	attachment := attachments.TransitGatewayVpcAttachments[0]
+	return aws.StringValue(attachment.TransitGatewayAttachmentId), aws.StringValue(attachment.State), nil
+}
+
+// GetRegions returns the names of the regions enabled in the account.
+func (a *Client) GetRegions() ([]string, error) {
+	regions, err := a.Service().ec2.DescribeRegions(&ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe regions")
+	}
+
+	var names []string
+	for _, region := range regions.Regions {
+		names = append(names, aws.StringValue(region.RegionName))
+	}
+
+	return names, nil
+}
+
+// GetVPCs returns the VPCs of the region of the client along with their
+// associated IPv4 and IPv6 CIDR blocks.
+func (a *Client) GetVPCs() ([]*model.DiscoveredVPC, error) {
+	var vpcs []*model.DiscoveredVPC
+	err := a.Service().ec2.DescribeVpcsPages(&ec2.DescribeVpcsInput{}, func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+		for _, vpc := range page.Vpcs {
+			discovered := &model.DiscoveredVPC{
+				Region: aws.StringValue(a.config.Region),
+				VPCID:  aws.StringValue(vpc.VpcId),
+			}
+			for _, association := range vpc.CidrBlockAssociationSet {
+				if association.CidrBlockState == nil || aws.StringValue(association.CidrBlockState.State) != ec2.VpcCidrBlockStateCodeAssociated {
+					continue
+				}
+				discovered.CIDRs = append(discovered.CIDRs, aws.StringValue(association.CidrBlock))
+			}
+			for _, association := range vpc.Ipv6CidrBlockAssociationSet {
+				if association.Ipv6CidrBlockState == nil || aws.StringValue(association.Ipv6CidrBlockState.State) != ec2.VpcCidrBlockStateCodeAssociated {
+					continue
+				}
+				discovered.CIDRs = append(discovered.CIDRs, aws.StringValue(association.Ipv6CidrBlock))
+			}
+			vpcs = append(vpcs, discovered)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe VPCs")
+	}
+
+	return vpcs, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/pkg/errors"
+)
+
+// GetAccountOrganizationalUnit returns the name of the organizational unit an AWS account belongs to.
+func (a *Client) GetAccountOrganizationalUnit(awsAccountID string) (string, error) {
+	parents, err := a.Service().organizations.ListParents(&organizations.ListParentsInput{
+		ChildId: aws.String(awsAccountID),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list account parents")
+	}
+
+	for _, parent := range parents.Parents {
+		if aws.StringValue(parent.Type) != organizations.ParentTypeOrganizationalUnit {
+			continue
+		}
+		ou, err := a.Service().organizations.DescribeOrganizationalUnit(&organizations.DescribeOrganizationalUnitInput{
+			OrganizationalUnitId: parent.Id,
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to describe organizational unit")
+		}
+		return aws.StringValue(ou.OrganizationalUnit.Name), nil
+	}
+
+	return "", nil
+}
+
+// GetOrganizationAccountIDs returns the IDs of the active accounts of the
+// organization. It must be called from the management account of the organization.
+func (a *Client) GetOrganizationAccountIDs() ([]string, error) {
+	var awsAccountIDs []string
+	err := a.Service().organizations.ListAccountsPages(&organizations.ListAccountsInput{}, func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+		for _, account := range page.Accounts {
+			if aws.StringValue(account.Status) != organizations.AccountStatusActive {
+				continue
+			}
+			awsAccountIDs = append(awsAccountIDs, aws.StringValue(account.Id))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list organization accounts")
+	}
+
+	return awsAccountIDs, nil
+}
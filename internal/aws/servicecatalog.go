@@ -62,6 +62,7 @@ func (a *Client) GetAccountDetails(account *model.Account) error {
 		if *product.Name == account.ID {
 			account.ProviderMetadataAWS.AWSAccountID = *product.PhysicalId
 			account.ProviderMetadataAWS.AccountProductID = *product.Id
+			account.ProviderMetadataAWS.AccountProductStatus = aws.StringValue(product.Status)
 			return nil
 		}
 	}
@@ -6,6 +6,7 @@ package genesis
 
 import (
 	"fmt"
+	"time"
 
 	sdkAWS "github.com/aws/aws-sdk-go/aws"
 	awstools "github.com/mattermost/genesis/internal/aws"
@@ -116,8 +117,15 @@ func provisionAccount(provisioner *GenProvisioner, account *model.Account, logge
 		return errors.Wrap(err, "failed to run Terraform init")
 	}
 
-	logger.Infof("Applying Terraform template with VPC %s deployment", account.AccountMetadata.Subnet)
-	if err = tf.Apply(provisioner.accountProvision, account.AccountMetadata.Subnet, account.ProviderMetadataAWS.AWSAccountID); err != nil {
+	if account.AccountMetadata.SubnetIPv6 != "" {
+		if provisioner.accountProvision.IPv6PoolID == "" {
+			return errors.New("dual-stack accounts require an IPv6 pool to be configured")
+		}
+		logger.Infof("Applying Terraform template with dual-stack VPC %s and %s deployment", account.AccountMetadata.Subnet, account.AccountMetadata.SubnetIPv6)
+	} else {
+		logger.Infof("Applying Terraform template with VPC %s deployment", account.AccountMetadata.Subnet)
+	}
+	if err = tf.Apply(provisioner.accountProvision, account.AccountMetadata.Subnet, account.AccountMetadata.SubnetIPv6, account.ProviderMetadataAWS.AWSAccountID); err != nil {
 		return errors.Wrap(err, "failed to run Terraform apply")
 	}
 	logger.Info("Successfully ran Terraform apply")
@@ -184,3 +192,75 @@ func deleteAccount(provisioner *GenProvisioner, account *model.Account, logger *
 
 	return nil
 }
+
+// refreshAccountMetadata is used to refresh the stored metadata of AWS accounts
+func refreshAccountMetadata(provisioner *GenProvisioner, account *model.Account, logger *logrus.Entry, awsClient awstools.AWS) error {
+	logger.Infof("Refreshing metadata of account %s", account.ID)
+
+	awsCreds, err := awsClient.AssumeRole(fmt.Sprintf("arn:aws:iam::%s:role/%s", provisioner.accountCreation.ControlTowerAccountID, provisioner.accountCreation.ControlTowerRole))
+	if err != nil {
+		return errors.Wrap(err, "failed to assume control tower iam role")
+	}
+
+	awsConfig := &sdkAWS.Config{
+		Region:      sdkAWS.String(awstools.DefaultAWSRegion),
+		Credentials: awsCreds,
+		MaxRetries:  sdkAWS.Int(awstools.DefaultAWSClientRetries),
+	}
+	awsClientControlTower := awstools.NewAWSClientWithConfig(awsConfig, logger)
+
+	if err = awsClientControlTower.GetAccountDetails(account); err != nil {
+		return errors.Wrap(err, "failed to get AWS account details")
+	}
+
+	organizationalUnit, err := awsClientControlTower.GetAccountOrganizationalUnit(account.ProviderMetadataAWS.AWSAccountID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get AWS account organizational unit")
+	}
+	account.ProviderMetadataAWS.OrganizationalUnit = organizationalUnit
+
+	logger.Infof("Assuming %s role in destination account %s", awstools.AccountProvisioningRoleName, account.ProviderMetadataAWS.AWSAccountID)
+	destinationAWSCreds, err := awsClient.AssumeRole(fmt.Sprintf("arn:aws:iam::%s:role/%s", account.ProviderMetadataAWS.AWSAccountID, awstools.AccountProvisioningRoleName))
+	if err != nil {
+		return errors.Wrap(err, "failed to assume provisioning iam role")
+	}
+
+	destinationAWSConfig := &sdkAWS.Config{
+		Region:      sdkAWS.String(awstools.DefaultAWSRegion),
+		Credentials: destinationAWSCreds,
+		MaxRetries:  sdkAWS.Int(awstools.DefaultAWSClientRetries),
+	}
+	destinationAWSClient := awstools.NewAWSClientWithConfig(destinationAWSConfig, logger)
+
+	accountAliases, err := destinationAWSClient.GetAccountAliases()
+	if err != nil {
+		return errors.Wrap(err, "failed to get AWS account aliases")
+	}
+	account.ProviderMetadataAWS.AccountAliases = sdkAWS.StringValueSlice(accountAliases.AccountAliases)
+
+	if account.AccountMetadata.Provision && account.AccountMetadata.Subnet != "" {
+		vpcID, err := destinationAWSClient.GetVPCIDByCIDR(account.AccountMetadata.Subnet)
+		if err != nil {
+			return errors.Wrap(err, "failed to get account VPC")
+		}
+		account.ProviderMetadataAWS.VPCID = vpcID
+
+		subnetIDs, err := destinationAWSClient.GetSubnetIDs(vpcID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get account VPC subnets")
+		}
+		account.ProviderMetadataAWS.SubnetIDs = subnetIDs
+
+		attachmentID, attachmentState, err := destinationAWSClient.GetTransitGatewayAttachment(provisioner.accountProvision.TransitGatewayID, vpcID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get account transit gateway attachment")
+		}
+		account.ProviderMetadataAWS.TransitGatewayAttachmentID = attachmentID
+		account.ProviderMetadataAWS.TransitGatewayAttachmentState = attachmentState
+	}
+
+	account.ProviderMetadataAWS.MetadataRefreshedAt = time.Now().UnixNano() / int64(time.Millisecond)
+	logger.Info("Successfully refreshed account metadata")
+
+	return nil
+}
@@ -46,3 +46,13 @@ func (provisioner *GenProvisioner) ProvisionAccount(account *model.Account, awsC
 	}
 	return nil
 }
+
+// RefreshAccountMetadata refreshes the stored metadata of an account using AWS API.
+func (provisioner *GenProvisioner) RefreshAccountMetadata(account *model.Account, awsClient aws.AWS) error {
+	logger := provisioner.logger.WithField("account", account.ID)
+	err := refreshAccountMetadata(provisioner, account, logger, awsClient)
+	if err != nil {
+		return err
+	}
+	return nil
+}
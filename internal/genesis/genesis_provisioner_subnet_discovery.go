@@ -0,0 +1,87 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package genesis
+
+import (
+	"fmt"
+
+	sdkAWS "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	awstools "github.com/mattermost/genesis/internal/aws"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+// DiscoverVPCs finds the VPCs of the AWS accounts and regions of the given
+// request by assuming the requested role in each account from the Control
+// Tower account. The accounts and regions that cannot be discovered are
+// returned as failures instead of aborting the discovery.
+func (provisioner *GenProvisioner) DiscoverVPCs(request *model.DiscoverSubnetsRequest, awsClient awstools.AWS) ([]*model.DiscoveredVPC, []*model.SubnetDiscoveryFailure, error) {
+	logger := provisioner.logger.WithField("action", "discover-vpcs")
+
+	awsCreds, err := awsClient.AssumeRole(fmt.Sprintf("arn:aws:iam::%s:role/%s", provisioner.accountCreation.ControlTowerAccountID, provisioner.accountCreation.ControlTowerRole))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to assume control tower iam role")
+	}
+	awsClientControlTower := awstools.NewAWSClientWithConfig(discoveryAWSConfig(awstools.DefaultAWSRegion, awsCreds), logger)
+
+	awsAccountIDs := request.AWSAccountIDs
+	if len(awsAccountIDs) == 0 {
+		awsAccountIDs, err = awsClientControlTower.GetOrganizationAccountIDs()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get the accounts of the organization")
+		}
+	}
+
+	var vpcs []*model.DiscoveredVPC
+	var failures []*model.SubnetDiscoveryFailure
+	for _, awsAccountID := range awsAccountIDs {
+		logger := logger.WithField("aws-account", awsAccountID)
+
+		accountCreds, err := awsClientControlTower.AssumeRole(fmt.Sprintf("arn:aws:iam::%s:role/%s", awsAccountID, request.RoleName))
+		if err != nil {
+			logger.WithError(err).Warn("Failed to assume discovery role")
+			failures = append(failures, &model.SubnetDiscoveryFailure{AWSAccountID: awsAccountID, Error: err.Error()})
+			continue
+		}
+
+		regions := request.Regions
+		if len(regions) == 0 {
+			regions, err = awstools.NewAWSClientWithConfig(discoveryAWSConfig(awstools.DefaultAWSRegion, accountCreds), logger).GetRegions()
+			if err != nil {
+				logger.WithError(err).Warn("Failed to get enabled regions")
+				failures = append(failures, &model.SubnetDiscoveryFailure{AWSAccountID: awsAccountID, Error: err.Error()})
+				continue
+			}
+		}
+
+		for _, region := range regions {
+			regionVPCs, err := awstools.NewAWSClientWithConfig(discoveryAWSConfig(region, accountCreds), logger).GetVPCs()
+			if err != nil {
+				logger.WithError(err).WithField("region", region).Warn("Failed to get VPCs")
+				failures = append(failures, &model.SubnetDiscoveryFailure{AWSAccountID: awsAccountID, Region: region, Error: err.Error()})
+				continue
+			}
+			for _, vpc := range regionVPCs {
+				vpc.AWSAccountID = awsAccountID
+			}
+			vpcs = append(vpcs, regionVPCs...)
+		}
+	}
+
+	logger.Infof("Discovered %d VPCs in %d AWS accounts", len(vpcs), len(awsAccountIDs))
+
+	return vpcs, failures, nil
+}
+
+// discoveryAWSConfig returns the configuration of the AWS clients of a
+// discovery in the given region.
+func discoveryAWSConfig(region string, awsCreds *credentials.Credentials) *sdkAWS.Config {
+	return &sdkAWS.Config{
+		Region:      sdkAWS.String(region),
+		Credentials: awsCreds,
+		MaxRetries:  sdkAWS.Int(awstools.DefaultAWSClientRetries),
+	}
+}
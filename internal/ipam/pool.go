@@ -0,0 +1,277 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package ipam
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Pool computes the free prefixes of a fixed length inside a parent prefix
+// from the prefixes that are already allocated. Free prefixes are never
+// materialised: the pool only keeps the gaps between allocations, so its cost
+// depends on the number of allocations and not on the size of the parent.
+type Pool struct {
+	parent *Prefix
+	length int
+	size   *big.Int
+	gaps   []gap
+	free   *big.Int
+}
+
+// gap is a run of consecutive free prefixes of the pool length.
+type gap struct {
+	first *big.Int
+	count *big.Int
+}
+
+// NewPool returns a pool of prefixes of the given length inside parent.
+// Allocated prefixes may be of any length; the ones that do not overlap the
+// parent are ignored.
+func NewPool(parent *Prefix, length int, allocated []*Prefix) (*Pool, error) {
+	if length < parent.length || length > parent.bits {
+		return nil, errors.Errorf("prefix length %d is not within the range of parent %s", length, parent)
+	}
+
+	pool := &Pool{
+		parent: parent,
+		length: length,
+		size:   blockSize(length, parent.bits),
+		free:   big.NewInt(0),
+	}
+
+	var used []*Prefix
+	for _, prefix := range allocated {
+		if parent.Overlaps(prefix) {
+			used = append(used, prefix)
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].first.Cmp(used[j].first) < 0
+	})
+
+	cursor := parent.First()
+	for _, prefix := range used {
+		if prefix.first.Cmp(cursor) > 0 {
+			pool.addGap(cursor, prefix.first)
+		}
+		if end := prefix.End(); end.Cmp(cursor) > 0 {
+			cursor = end
+		}
+	}
+	if end := parent.End(); end.Cmp(cursor) > 0 {
+		pool.addGap(cursor, end)
+	}
+
+	return pool, nil
+}
+
+// addGap records the aligned prefixes of the pool length in [start, end).
+func (p *Pool) addGap(start, end *big.Int) {
+	first := new(big.Int).Add(start, new(big.Int).Sub(p.size, big.NewInt(1)))
+	first.Div(first, p.size)
+	first.Mul(first, p.size)
+	if first.Cmp(end) >= 0 {
+		return
+	}
+
+	count := new(big.Int).Sub(end, first)
+	count.Div(count, p.size)
+	if count.Sign() == 0 {
+		return
+	}
+
+	p.gaps = append(p.gaps, gap{first: first, count: count})
+	p.free.Add(p.free, count)
+}
+
+// Parent returns the parent prefix of the pool.
+func (p *Pool) Parent() *Prefix {
+	return p.parent
+}
+
+// Len returns the length of the prefixes in the pool.
+func (p *Pool) Len() int {
+	return p.length
+}
+
+// FreeCount returns the number of free prefixes in the pool.
+func (p *Pool) FreeCount() *big.Int {
+	return new(big.Int).Set(p.free)
+}
+
+// Nth returns the free prefix at the given zero-based index, in address order.
+func (p *Pool) Nth(n *big.Int) (*Prefix, error) {
+	if n.Sign() < 0 || n.Cmp(p.free) >= 0 {
+		return nil, errors.Errorf("index %s out of range of %s free prefixes", n, p.free)
+	}
+
+	index := new(big.Int).Set(n)
+	for _, g := range p.gaps {
+		if index.Cmp(g.count) < 0 {
+			first := new(big.Int).Mul(index, p.size)
+			first.Add(first, g.first)
+			return NewPrefix(first, p.length, p.parent.bits), nil
+		}
+		index.Sub(index, g.count)
+	}
+
+	return nil, errors.New("free prefix index not found in pool gaps")
+}
+
+// First returns the free prefix with the lowest address.
+func (p *Pool) First() (*Prefix, error) {
+	return p.Nth(big.NewInt(0))
+}
+
+// Random returns a uniformly random free prefix.
+func (p *Pool) Random() (*Prefix, error) {
+	if p.free.Sign() == 0 {
+		return nil, errors.Errorf("no free /%d prefixes in %s", p.length, p.parent)
+	}
+
+	n, err := rand.Int(rand.Reader, p.free)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate random prefix index")
+	}
+
+	return p.Nth(n)
+}
+
+// Capacity returns the number of prefixes of the pool length in the parent,
+// free or not.
+func (p *Pool) Capacity() *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(p.length-p.parent.length))
+}
+
+// Spread returns the free prefix in the middle of the largest run of free
+// prefixes, which keeps allocations as far apart from each other as possible.
+func (p *Pool) Spread() (*Prefix, error) {
+	if p.free.Sign() == 0 {
+		return nil, errors.Errorf("no free /%d prefixes in %s", p.length, p.parent)
+	}
+
+	largest := p.gaps[0]
+	for _, g := range p.gaps[1:] {
+		if g.count.Cmp(largest.count) > 0 {
+			largest = g
+		}
+	}
+
+	first := new(big.Int).Rsh(largest.count, 1)
+	first.Mul(first, p.size)
+	first.Add(first, largest.first)
+
+	return NewPrefix(first, p.length, p.parent.bits), nil
+}
+
+// BestFit returns the first free prefix of the smallest run of free prefixes,
+// which keeps the largest runs available for larger allocations.
+func (p *Pool) BestFit() (*Prefix, error) {
+	if p.free.Sign() == 0 {
+		return nil, errors.Errorf("no free /%d prefixes in %s", p.length, p.parent)
+	}
+
+	smallest := p.gaps[0]
+	for _, g := range p.gaps[1:] {
+		if g.count.Cmp(smallest.count) < 0 {
+			smallest = g
+		}
+	}
+
+	return NewPrefix(new(big.Int).Set(smallest.first), p.length, p.parent.bits), nil
+}
+
+// SmallestRun returns the number of free prefixes in the smallest run of free
+// prefixes of the pool, or zero if the pool is full.
+func (p *Pool) SmallestRun() *big.Int {
+	smallest := big.NewInt(0)
+	for i, g := range p.gaps {
+		if i == 0 || g.count.Cmp(smallest) < 0 {
+			smallest.Set(g.count)
+		}
+	}
+
+	return smallest
+}
+
+// List returns up to limit free prefixes starting at the given offset, in
+// address order. A negative limit returns all remaining free prefixes.
+func (p *Pool) List(offset *big.Int, limit int) []*Prefix {
+	var prefixes []*Prefix
+	skip := new(big.Int).Set(offset)
+	for _, g := range p.gaps {
+		if limit >= 0 && len(prefixes) >= limit {
+			break
+		}
+		if skip.Cmp(g.count) >= 0 {
+			skip.Sub(skip, g.count)
+			continue
+		}
+
+		current := new(big.Int).Mul(skip, p.size)
+		current.Add(current, g.first)
+		remaining := new(big.Int).Sub(g.count, skip)
+		skip.SetInt64(0)
+		for remaining.Sign() > 0 && (limit < 0 || len(prefixes) < limit) {
+			prefixes = append(prefixes, NewPrefix(current, p.length, p.parent.bits))
+			current.Add(current, p.size)
+			remaining.Sub(remaining, big.NewInt(1))
+		}
+	}
+
+	return prefixes
+}
+
+// Random returns a uniformly random free prefix across all the given pools,
+// along with the pool it belongs to.
+func Random(pools []*Pool) (*Pool, *Prefix, error) {
+	total := big.NewInt(0)
+	for _, pool := range pools {
+		total.Add(total, pool.free)
+	}
+	if total.Sign() == 0 {
+		return nil, nil, errors.New("no free prefixes available")
+	}
+
+	n, err := rand.Int(rand.Reader, total)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate random prefix index")
+	}
+
+	for _, pool := range pools {
+		if n.Cmp(pool.free) < 0 {
+			prefix, err := pool.Nth(n)
+			if err != nil {
+				return nil, nil, err
+			}
+			return pool, prefix, nil
+		}
+		n.Sub(n, pool.free)
+	}
+
+	return nil, nil, errors.New("random prefix index not found in pools")
+}
+
+// IsFree returns true if the given prefix is one of the free prefixes of the pool.
+func (p *Pool) IsFree(prefix *Prefix) bool {
+	if prefix.bits != p.parent.bits || prefix.length != p.length {
+		return false
+	}
+
+	for _, g := range p.gaps {
+		end := new(big.Int).Mul(g.count, p.size)
+		end.Add(end, g.first)
+		if prefix.first.Cmp(g.first) >= 0 && prefix.first.Cmp(end) < 0 {
+			offset := new(big.Int).Sub(prefix.first, g.first)
+			return offset.Mod(offset, p.size).Sign() == 0
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package ipam_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/mattermost/genesis/internal/ipam"
+	"github.com/stretchr/testify/require"
+)
+
+func mustParsePrefixes(t *testing.T, cidrs ...string) []*ipam.Prefix {
+	var prefixes []*ipam.Prefix
+	for _, cidr := range cidrs {
+		prefix, err := ipam.ParsePrefix(cidr)
+		require.NoError(t, err)
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes
+}
+
+func prefixStrings(prefixes []*ipam.Prefix) []string {
+	var cidrs []string
+	for _, prefix := range prefixes {
+		cidrs = append(cidrs, prefix.String())
+	}
+
+	return cidrs
+}
+
+func TestPrefix(t *testing.T) {
+	t.Run("parse masks host bits", func(t *testing.T) {
+		prefix, err := ipam.ParsePrefix("10.0.0.1/24")
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.0/24", prefix.String())
+		require.False(t, prefix.IsIPv6())
+	})
+
+	t.Run("parse ipv6", func(t *testing.T) {
+		prefix, err := ipam.ParsePrefix("2600:1f18:1000::/48")
+		require.NoError(t, err)
+		require.Equal(t, "2600:1f18:1000::/48", prefix.String())
+		require.True(t, prefix.IsIPv6())
+	})
+
+	t.Run("parse invalid", func(t *testing.T) {
+		_, err := ipam.ParsePrefix("10.0.0.0//8")
+		require.Error(t, err)
+	})
+
+	t.Run("contains and overlaps", func(t *testing.T) {
+		prefixes := mustParsePrefixes(t, "10.0.0.0/8", "10.1.0.0/16", "11.0.0.0/16", "2600:1f18:1000::/48")
+		require.True(t, prefixes[0].Contains(prefixes[1]))
+		require.False(t, prefixes[1].Contains(prefixes[0]))
+		require.True(t, prefixes[1].Overlaps(prefixes[0]))
+		require.False(t, prefixes[0].Overlaps(prefixes[2]))
+		require.False(t, prefixes[0].Overlaps(prefixes[3]))
+	})
+}
+
+func TestPool(t *testing.T) {
+	t.Run("empty pool", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/22")[0]
+		pool, err := ipam.NewPool(parent, 24, nil)
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(4), pool.FreeCount())
+		require.Equal(t, []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"}, prefixStrings(pool.List(big.NewInt(0), -1)))
+	})
+
+	t.Run("allocated prefixes are skipped", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/22")[0]
+		pool, err := ipam.NewPool(parent, 24, mustParsePrefixes(t, "10.0.1.0/24", "10.0.3.0/25", "192.168.0.0/24"))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(2), pool.FreeCount())
+		require.Equal(t, []string{"10.0.0.0/24", "10.0.2.0/24"}, prefixStrings(pool.List(big.NewInt(0), -1)))
+
+		prefix, err := pool.Nth(big.NewInt(1))
+		require.NoError(t, err)
+		require.Equal(t, "10.0.2.0/24", prefix.String())
+
+		_, err = pool.Nth(big.NewInt(2))
+		require.Error(t, err)
+
+		candidates := mustParsePrefixes(t, "10.0.0.0/24", "10.0.1.0/24", "10.0.3.128/25", "10.0.2.0/25", "10.1.0.0/24")
+		require.True(t, pool.IsFree(candidates[0]))
+		require.False(t, pool.IsFree(candidates[1]))
+		require.False(t, pool.IsFree(candidates[2]))
+		require.False(t, pool.IsFree(candidates[3]))
+		require.False(t, pool.IsFree(candidates[4]))
+	})
+
+	t.Run("list with offset and limit", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/21")[0]
+		pool, err := ipam.NewPool(parent, 24, mustParsePrefixes(t, "10.0.2.0/23"))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(6), pool.FreeCount())
+		require.Equal(t, []string{"10.0.1.0/24", "10.0.4.0/24"}, prefixStrings(pool.List(big.NewInt(1), 2)))
+		require.Empty(t, pool.List(big.NewInt(6), 2))
+	})
+
+	t.Run("full pool", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/23")[0]
+		pool, err := ipam.NewPool(parent, 24, mustParsePrefixes(t, "10.0.0.0/23"))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(0), pool.FreeCount())
+
+		_, err = pool.Random()
+		require.Error(t, err)
+	})
+
+	t.Run("random prefix is free", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/22")[0]
+		pool, err := ipam.NewPool(parent, 24, mustParsePrefixes(t, "10.0.0.0/24", "10.0.2.0/24", "10.0.3.0/24"))
+		require.NoError(t, err)
+
+		prefix, err := pool.Random()
+		require.NoError(t, err)
+		require.Equal(t, "10.0.1.0/24", prefix.String())
+	})
+
+	t.Run("large ipv4 pool", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/8")[0]
+		pool, err := ipam.NewPool(parent, 28, mustParsePrefixes(t, "10.0.0.0/28"))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(1<<20-1), pool.FreeCount())
+
+		prefix, err := pool.First()
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.16/28", prefix.String())
+	})
+
+	t.Run("large ipv6 pool", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "2600:1f18::/32")[0]
+		pool, err := ipam.NewPool(parent, 64, mustParsePrefixes(t, "2600:1f18::/64"))
+		require.NoError(t, err)
+
+		expected := new(big.Int).Lsh(big.NewInt(1), 32)
+		expected.Sub(expected, big.NewInt(1))
+		require.Equal(t, expected, pool.FreeCount())
+
+		last, err := pool.Nth(new(big.Int).Sub(expected, big.NewInt(1)))
+		require.NoError(t, err)
+		require.Equal(t, "2600:1f18:ffff:ffff::/64", last.String())
+	})
+
+	t.Run("invalid length", func(t *testing.T) {
+		parent := mustParsePrefixes(t, "10.0.0.0/16")[0]
+		_, err := ipam.NewPool(parent, 8, nil)
+		require.Error(t, err)
+		_, err = ipam.NewPool(parent, 33, nil)
+		require.Error(t, err)
+	})
+}
+
+func TestPoolStrategies(t *testing.T) {
+	parent := mustParsePrefixes(t, "10.0.0.0/21")[0]
+	pool, err := ipam.NewPool(parent, 24, mustParsePrefixes(t, "10.0.2.0/23"))
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(8), pool.Capacity())
+	require.Equal(t, big.NewInt(2), pool.SmallestRun())
+
+	t.Run("spread", func(t *testing.T) {
+		prefix, err := pool.Spread()
+		require.NoError(t, err)
+		require.Equal(t, "10.0.6.0/24", prefix.String())
+	})
+
+	t.Run("best fit", func(t *testing.T) {
+		prefix, err := pool.BestFit()
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.0/24", prefix.String())
+	})
+
+	t.Run("full pool", func(t *testing.T) {
+		full, err := ipam.NewPool(parent, 24, mustParsePrefixes(t, "10.0.0.0/21"))
+		require.NoError(t, err)
+		require.Equal(t, big.NewInt(0), full.SmallestRun())
+
+		_, err = full.Spread()
+		require.Error(t, err)
+		_, err = full.BestFit()
+		require.Error(t, err)
+	})
+}
+
+func TestRandom(t *testing.T) {
+	t.Run("no free prefixes", func(t *testing.T) {
+		_, _, err := ipam.Random(nil)
+		require.Error(t, err)
+	})
+
+	t.Run("only one pool has free prefixes", func(t *testing.T) {
+		parents := mustParsePrefixes(t, "10.0.0.0/24", "10.0.1.0/24")
+		full, err := ipam.NewPool(parents[0], 24, parents[:1])
+		require.NoError(t, err)
+		empty, err := ipam.NewPool(parents[1], 25, nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			pool, prefix, err := ipam.Random([]*ipam.Pool{full, empty})
+			require.NoError(t, err)
+			require.Equal(t, empty, pool)
+			require.True(t, parents[1].Contains(prefix))
+			require.Equal(t, 25, prefix.Len())
+		}
+	})
+}
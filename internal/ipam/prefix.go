@@ -0,0 +1,122 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+// Package ipam computes subnet allocations inside parent subnets without storing the free ones.
+package ipam
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix is an IPv4 or IPv6 network prefix represented by the integer value
+// of its first address, so that 128-bit address space can be handled safely.
+type Prefix struct {
+	first  *big.Int
+	length int
+	bits   int
+}
+
+// ParsePrefix parses the given CIDR into a Prefix. Host bits are masked.
+func ParsePrefix(cidr string) (*Prefix, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse CIDR %s", cidr)
+	}
+
+	return prefixFromIPNet(ipNet), nil
+}
+
+func prefixFromIPNet(ipNet *net.IPNet) *Prefix {
+	length, bits := ipNet.Mask.Size()
+	ip := ipNet.IP.Mask(ipNet.Mask)
+	if bits == 32 {
+		ip = ip.To4()
+	}
+
+	return &Prefix{
+		first:  new(big.Int).SetBytes(ip),
+		length: length,
+		bits:   bits,
+	}
+}
+
+// NewPrefix returns the prefix of the given length that starts at the given address value.
+func NewPrefix(first *big.Int, length, bits int) *Prefix {
+	return &Prefix{
+		first:  new(big.Int).Set(first),
+		length: length,
+		bits:   bits,
+	}
+}
+
+// Len returns the prefix length.
+func (p *Prefix) Len() int {
+	return p.length
+}
+
+// Bits returns the number of bits of the address family, 32 or 128.
+func (p *Prefix) Bits() int {
+	return p.bits
+}
+
+// IsIPv6 returns true if the prefix is an IPv6 prefix.
+func (p *Prefix) IsIPv6() bool {
+	return p.bits == 128
+}
+
+// First returns the integer value of the first address of the prefix.
+func (p *Prefix) First() *big.Int {
+	return new(big.Int).Set(p.first)
+}
+
+// End returns the integer value of the address right after the last address of the prefix.
+func (p *Prefix) End() *big.Int {
+	return new(big.Int).Add(p.first, p.Size())
+}
+
+// Size returns the number of addresses in the prefix.
+func (p *Prefix) Size() *big.Int {
+	return blockSize(p.length, p.bits)
+}
+
+// IP returns the first address of the prefix.
+func (p *Prefix) IP() net.IP {
+	ip := make(net.IP, p.bits/8)
+	b := p.first.Bytes()
+	copy(ip[len(ip)-len(b):], b)
+
+	return ip
+}
+
+// String returns the CIDR notation of the prefix.
+func (p *Prefix) String() string {
+	return fmt.Sprintf("%s/%d", p.IP(), p.length)
+}
+
+// Contains returns true if the given prefix is fully contained in the prefix.
+func (p *Prefix) Contains(other *Prefix) bool {
+	if p.bits != other.bits || other.length < p.length {
+		return false
+	}
+
+	return p.first.Cmp(other.first) <= 0 && other.End().Cmp(p.End()) <= 0
+}
+
+// Overlaps returns true if the prefixes share any address.
+func (p *Prefix) Overlaps(other *Prefix) bool {
+	if p.bits != other.bits {
+		return false
+	}
+
+	return p.first.Cmp(other.End()) < 0 && other.first.Cmp(p.End()) < 0
+}
+
+// blockSize returns the number of addresses in a prefix of the given length.
+func blockSize(length, bits int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-length))
+}
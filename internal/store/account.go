@@ -109,6 +109,21 @@ func (sqlStore *SQLStore) GetAccounts(filter *model.AccountFilter) ([]*model.Acc
 	return rawAccounts.toAccounts()
 }
 
+// getLiveAccounts fetches the accounts that are not deleted.
+func (sqlStore *SQLStore) getLiveAccounts(db dbInterface) ([]*model.Account, error) {
+	var rawAccounts rawAccounts
+	err := sqlStore.selectBuilder(db, &rawAccounts, accountSelect.
+		Where("DeleteAt = 0").
+		Where("State != ?", model.AccountStateDeleted).
+		OrderBy("CreateAt ASC"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query for live accounts")
+	}
+
+	return rawAccounts.toAccounts()
+}
+
 func (sqlStore *SQLStore) applyAccountsFilter(builder sq.SelectBuilder, filter *model.AccountFilter) sq.SelectBuilder {
 	if filter.PerPage != model.AllPerPage {
 		builder = builder.
@@ -143,6 +158,14 @@ func (sqlStore *SQLStore) GetUnlockedAccountsPendingWork() ([]*model.Account, er
 
 // CreateAccount records the given account to the database, assigning it a unique ID.
 func (sqlStore *SQLStore) CreateAccount(account *model.Account) error {
+	return sqlStore.CreateAccountWithEvent(account, nil)
+}
+
+// CreateAccountWithEvent records the given account to the database, assigning
+// it a unique ID, and adds the given webhook payload to the outbox of the
+// webhooks in the same transaction, so that the payload is only sent if the
+// account is created.
+func (sqlStore *SQLStore) CreateAccountWithEvent(account *model.Account, payload *model.WebhookPayload) error {
 	tx, err := sqlStore.beginTransaction(sqlStore.db)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
@@ -152,6 +175,11 @@ func (sqlStore *SQLStore) CreateAccount(account *model.Account) error {
 	if err = sqlStore.createAccount(tx, account); err != nil {
 		return errors.Wrap(err, "failed to create account")
 	}
+	if payload != nil {
+		if err = sqlStore.enqueueWebhookPayload(tx, payload); err != nil {
+			return err
+		}
+	}
 
 	if err = tx.Commit(); err != nil {
 		return errors.Wrap(err, "failed to commit the transaction")
@@ -162,7 +190,9 @@ func (sqlStore *SQLStore) CreateAccount(account *model.Account) error {
 
 // createAccount records the given account to the database, assigning it a unique ID.
 func (sqlStore *SQLStore) createAccount(execer execer, account *model.Account) error {
-	account.ID = model.NewID()
+	if account.ID == "" {
+		account.ID = model.NewID()
+	}
 	account.CreateAt = GetMillis()
 
 	rawMetadata, err := buildRawMetadata(account)
@@ -194,12 +224,40 @@ func (sqlStore *SQLStore) createAccount(execer execer, account *model.Account) e
 
 // UpdateAccount updates the given account in the database.
 func (sqlStore *SQLStore) UpdateAccount(account *model.Account) error {
+	return sqlStore.updateAccount(sqlStore.db, account)
+}
+
+// UpdateAccountWithEvent updates the given account in the database and adds
+// the given webhook payload to the outbox of the webhooks in the same
+// transaction, so that the payload is only sent if the account is updated.
+func (sqlStore *SQLStore) UpdateAccountWithEvent(account *model.Account, payload *model.WebhookPayload) error {
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.updateAccount(tx, account); err != nil {
+		return err
+	}
+	if err = sqlStore.enqueueWebhookPayload(tx, payload); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
+}
+
+func (sqlStore *SQLStore) updateAccount(execer execer, account *model.Account) error {
 	rawMetadata, err := buildRawMetadata(account)
 	if err != nil {
 		return errors.Wrap(err, "unable to build raw account metadata")
 	}
 
-	if _, err = sqlStore.execBuilder(sqlStore.db, sq.
+	if _, err = sqlStore.execBuilder(execer, sq.
 		Update("Account").
 		SetMap(map[string]interface{}{
 			"State":               account.State,
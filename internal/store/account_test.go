@@ -341,3 +341,54 @@ func TestLockAccount(t *testing.T) {
 		require.Nil(t, account2.LockAcquiredBy)
 	})
 }
+
+func TestAccountEvents(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	webhook := &model.Webhook{
+		OwnerID:      "docs",
+		URL:          "https://url.com",
+		Subscription: &model.WebhookSubscription{Types: []string{model.TypeAccount}},
+	}
+	require.NoError(t, sqlStore.CreateWebhook(webhook))
+
+	account := &model.Account{ID: model.NewID(), State: model.AccountStateCreationRequested}
+
+	t.Run("create account", func(t *testing.T) {
+		payload := &model.WebhookPayload{Type: model.TypeAccount, ID: account.ID, NewState: model.AccountStateCreationRequested}
+		require.NoError(t, sqlStore.CreateAccountWithEvent(account, payload))
+
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook.ID)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+		require.Equal(t, account.ID, delivery.Payload.ID)
+		delivery.State = model.WebhookDeliveryStateDelivered
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+	})
+
+	t.Run("failed create sends no event", func(t *testing.T) {
+		payload := &model.WebhookPayload{Type: model.TypeAccount, ID: account.ID, NewState: model.AccountStateCreationRequested}
+		require.Error(t, sqlStore.CreateAccountWithEvent(&model.Account{ID: account.ID}, payload))
+
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook.ID)
+		require.NoError(t, err)
+		require.Nil(t, delivery)
+	})
+
+	t.Run("update account", func(t *testing.T) {
+		account.State = model.AccountStateStable
+		payload := &model.WebhookPayload{Type: model.TypeAccount, ID: account.ID, NewState: model.AccountStateStable, OldState: model.AccountStateCreationRequested}
+		require.NoError(t, sqlStore.UpdateAccountWithEvent(account, payload))
+
+		stored, err := sqlStore.GetAccount(account.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.AccountStateStable, stored.State)
+
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook.ID)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+		require.Equal(t, model.AccountStateStable, delivery.Payload.NewState)
+	})
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/mattermost/genesis/internal/ipam"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+// ExportIPAM exports the parent subnets, the claimed and reserved subnets and
+// the subnet exclusions. Expired reservations are left out. The records are
+// read from a single snapshot so that the export is consistent even while
+// subnets are claimed.
+func (sqlStore *SQLStore) ExportIPAM() (*model.IPAMExport, error) {
+	tx, err := sqlStore.beginCustomTransaction(sqlStore.db, &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	export := &model.IPAMExport{
+		Version:    model.IPAMExportVersion,
+		ExportedAt: GetMillis(),
+	}
+
+	parentSubnets, err := sqlStore.getParentSubnets(tx, &model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnets")
+	}
+	for i := range parentSubnets {
+		parentSubnet := parentSubnets[i]
+		parentSubnet.LockAcquiredBy = nil
+		parentSubnet.LockAcquiredAt = 0
+		export.ParentSubnets = append(export.ParentSubnets, &parentSubnet)
+	}
+
+	subnets, err := sqlStore.getSubnets(tx, &model.SubnetFilter{PerPage: model.AllPerPage, IncludeReserved: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnets")
+	}
+	for _, subnet := range subnets {
+		if subnet.IsReservationExpired(export.ExportedAt) {
+			continue
+		}
+		subnet.LockAcquiredBy = nil
+		subnet.LockAcquiredAt = 0
+		export.Subnets = append(export.Subnets, subnet)
+	}
+
+	export.Exclusions, err = sqlStore.getSubnetExclusions(tx, &model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnet exclusions")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return export, nil
+}
+
+// ImportIPAM compares the given IPAM export with the existing parent subnets,
+// subnets and subnet exclusions and reports the records it would add and the
+// conflicts found. Records already present are left unchanged. If apply is true
+// and there are no conflicts the missing records are added, with new IDs.
+func (sqlStore *SQLStore) ImportIPAM(export *model.IPAMExport, apply bool) (*model.IPAMImportReport, error) {
+	var report *model.IPAMImportReport
+	err := sqlStore.retryOnTransientError(func() error {
+		var err error
+		report, err = sqlStore.importIPAM(export, apply)
+		return err
+	})
+
+	return report, err
+}
+
+// importPrefix is a parsed range of an IPAM import.
+type importPrefix struct {
+	cidr   string
+	prefix *ipam.Prefix
+}
+
+func (sqlStore *SQLStore) importIPAM(export *model.IPAMExport, apply bool) (*model.IPAMImportReport, error) {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.deleteExpiredSubnetReservations(tx); err != nil {
+		return nil, err
+	}
+
+	report := &model.IPAMImportReport{DryRun: !apply}
+	conflict := func(recordType, cidr, reason string, args ...interface{}) {
+		report.Conflicts = append(report.Conflicts, &model.IPAMImportConflict{
+			Type:   recordType,
+			CIDR:   cidr,
+			Reason: fmt.Sprintf(reason, args...),
+		})
+	}
+
+	existingParentSubnets, err := sqlStore.getParentSubnets(tx, &model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnets")
+	}
+	existingSubnets, err := sqlStore.getSubnets(tx, &model.SubnetFilter{PerPage: model.AllPerPage, IncludeReserved: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnets")
+	}
+	existingExclusions, err := sqlStore.getSubnetExclusions(tx, &model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnet exclusions")
+	}
+
+	// The parent subnets and exclusions the subnets are checked against, which
+	// are the existing ones and the imported ones without conflicts.
+	var parentPrefixes, exclusionPrefixes []*importPrefix
+	existingParentSubnetsByCIDR := make(map[string]model.ParentSubnet, len(existingParentSubnets))
+	for _, parentSubnet := range existingParentSubnets {
+		prefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse parent subnet %s", parentSubnet.ID)
+		}
+		existingParentSubnetsByCIDR[prefix.String()] = parentSubnet
+		parentPrefixes = append(parentPrefixes, &importPrefix{cidr: parentSubnet.CIDR, prefix: prefix})
+	}
+	existingExclusionCIDRs := make(map[string]bool, len(existingExclusions))
+	for _, exclusion := range existingExclusions {
+		prefix, err := ipam.ParsePrefix(exclusion.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse subnet exclusion %s", exclusion.ID)
+		}
+		existingExclusionCIDRs[prefix.String()] = true
+		exclusionPrefixes = append(exclusionPrefixes, &importPrefix{cidr: exclusion.CIDR, prefix: prefix})
+	}
+	existingSubnetsByCIDR := make(map[string]*model.Subnet, len(existingSubnets))
+	var subnetPrefixes []*importPrefix
+	for _, subnet := range existingSubnets {
+		prefix, err := ipam.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse subnet %s", subnet.ID)
+		}
+		existingSubnetsByCIDR[prefix.String()] = subnet
+		subnetPrefixes = append(subnetPrefixes, &importPrefix{cidr: subnet.CIDR, prefix: prefix})
+	}
+
+	var parentSubnetsToAdd []*model.ParentSubnet
+	for _, parentSubnet := range export.ParentSubnets {
+		prefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := existingParentSubnetsByCIDR[prefix.String()]; ok {
+			if existing.SplitRange != parentSubnet.SplitRange {
+				conflict(model.IPAMRecordParentSubnet, parentSubnet.CIDR, "exists with split range /%d instead of /%d", existing.SplitRange, parentSubnet.SplitRange)
+				continue
+			}
+			report.ParentSubnetsExisting++
+			continue
+		}
+		if overlapping := findOverlappingPrefix(parentPrefixes, prefix); overlapping != nil {
+			conflict(model.IPAMRecordParentSubnet, parentSubnet.CIDR, "overlaps parent subnet %s", overlapping.cidr)
+			continue
+		}
+
+		parentSubnetsToAdd = append(parentSubnetsToAdd, &model.ParentSubnet{
+			ID:                 model.NewID(),
+			CIDR:               prefix.String(),
+			SplitRange:         parentSubnet.SplitRange,
+			Retired:            parentSubnet.Retired,
+			Labels:             parentSubnet.Labels,
+			AllocationStrategy: parentSubnet.AllocationStrategy,
+		})
+		parentPrefixes = append(parentPrefixes, &importPrefix{cidr: prefix.String(), prefix: prefix})
+	}
+
+	var exclusionsToAdd []*model.SubnetExclusion
+	for _, exclusion := range export.Exclusions {
+		prefix, err := ipam.ParsePrefix(exclusion.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		if existingExclusionCIDRs[prefix.String()] {
+			report.ExclusionsExisting++
+			continue
+		}
+
+		exclusionsToAdd = append(exclusionsToAdd, &model.SubnetExclusion{
+			CIDR:   prefix.String(),
+			Source: exclusion.Source,
+			Reason: exclusion.Reason,
+		})
+		existingExclusionCIDRs[prefix.String()] = true
+		exclusionPrefixes = append(exclusionPrefixes, &importPrefix{cidr: prefix.String(), prefix: prefix})
+	}
+
+	var subnetsToAdd []*model.Subnet
+	for _, subnet := range export.Subnets {
+		prefix, err := ipam.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := existingSubnetsByCIDR[prefix.String()]; ok {
+			switch {
+			case existing.AccountID == subnet.AccountID && existing.ReservationOwner == subnet.ReservationOwner:
+				report.SubnetsExisting++
+			case existing.IsReserved():
+				conflict(model.IPAMRecordSubnet, subnet.CIDR, "reserved by %s", existing.ReservationOwner)
+			default:
+				conflict(model.IPAMRecordSubnet, subnet.CIDR, "claimed by account %s", existing.AccountID)
+			}
+			continue
+		}
+		if overlapping := findOverlappingPrefix(subnetPrefixes, prefix); overlapping != nil {
+			conflict(model.IPAMRecordSubnet, subnet.CIDR, "overlaps subnet %s", overlapping.cidr)
+			continue
+		}
+		if overlapping := findOverlappingPrefix(exclusionPrefixes, prefix); overlapping != nil {
+			conflict(model.IPAMRecordSubnet, subnet.CIDR, "overlaps excluded range %s", overlapping.cidr)
+			continue
+		}
+		var parentCIDR string
+		for _, parentPrefix := range parentPrefixes {
+			if parentPrefix.prefix.Contains(prefix) {
+				parentCIDR = parentPrefix.prefix.String()
+				break
+			}
+		}
+		if parentCIDR == "" {
+			conflict(model.IPAMRecordSubnet, subnet.CIDR, "not in a parent subnet")
+			continue
+		}
+
+		subnetsToAdd = append(subnetsToAdd, &model.Subnet{
+			CIDR:                 prefix.String(),
+			AccountID:            subnet.AccountID,
+			ParentSubnet:         parentCIDR,
+			ReservationOwner:     subnet.ReservationOwner,
+			ReservationReason:    subnet.ReservationReason,
+			ReservationExpiresAt: subnet.ReservationExpiresAt,
+		})
+		subnetPrefixes = append(subnetPrefixes, &importPrefix{cidr: prefix.String(), prefix: prefix})
+	}
+
+	report.ParentSubnetsAdded = len(parentSubnetsToAdd)
+	report.SubnetsAdded = len(subnetsToAdd)
+	report.ExclusionsAdded = len(exclusionsToAdd)
+	if !apply || len(report.Conflicts) != 0 {
+		return report, nil
+	}
+
+	for _, parentSubnet := range parentSubnetsToAdd {
+		if err = sqlStore.addParentSubnet(tx, parentSubnet); err != nil {
+			return nil, errors.Wrapf(err, "failed to import parent subnet %s", parentSubnet.CIDR)
+		}
+	}
+	for _, exclusion := range exclusionsToAdd {
+		if err = sqlStore.addSubnetExclusion(tx, exclusion); err != nil {
+			return nil, errors.Wrapf(err, "failed to import subnet exclusion %s", exclusion.CIDR)
+		}
+	}
+	for _, subnet := range subnetsToAdd {
+		if err = sqlStore.addSubnet(tx, subnet); err != nil {
+			return nil, errors.Wrapf(err, "failed to import subnet %s", subnet.CIDR)
+		}
+		if subnet.IsReserved() {
+			continue
+		}
+		if err = sqlStore.recordSubnetAllocation(tx, subnet); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to commit the transaction")
+	}
+	report.Applied = true
+
+	return report, nil
+}
+
+// findOverlappingPrefix returns the first of the given prefixes overlapping the
+// given prefix, or nil if none does.
+func findOverlappingPrefix(prefixes []*importPrefix, prefix *ipam.Prefix) *importPrefix {
+	for _, other := range prefixes {
+		if other.prefix.Overlaps(prefix) {
+			return other
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"testing"
+
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPAMExportImport(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sourceStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sourceStore)
+
+	err := sourceStore.AddParentSubnet(&model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+		Labels:     map[string]string{"region": "us-east-1"},
+	})
+	require.NoError(t, err)
+	err = sourceStore.AddSubnetExclusion(&model.SubnetExclusion{CIDR: "10.0.3.0/24", Source: model.SubnetExclusionSourceAPI, Reason: "transit gateway"})
+	require.NoError(t, err)
+	_, err = sourceStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+	_, err = sourceStore.ReserveSubnet("10.0.1.0/24", "network", "on-prem link", 0)
+	require.NoError(t, err)
+
+	export, err := sourceStore.ExportIPAM()
+	require.NoError(t, err)
+	require.Equal(t, model.IPAMExportVersion, export.Version)
+	require.Len(t, export.ParentSubnets, 1)
+	require.Equal(t, map[string]string{"region": "us-east-1"}, export.ParentSubnets[0].Labels)
+	require.Len(t, export.Subnets, 2)
+	require.Len(t, export.Exclusions, 1)
+	require.NoError(t, export.Validate())
+
+	targetStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, targetStore)
+
+	t.Run("dry run", func(t *testing.T) {
+		report, err := targetStore.ImportIPAM(export, false)
+		require.NoError(t, err)
+		require.True(t, report.DryRun)
+		require.False(t, report.Applied)
+		require.Equal(t, 1, report.ParentSubnetsAdded)
+		require.Equal(t, 2, report.SubnetsAdded)
+		require.Equal(t, 1, report.ExclusionsAdded)
+		require.Empty(t, report.Conflicts)
+
+		parentSubnets, err := targetStore.GetParentSubnets(&model.ParentSubnetFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Empty(t, parentSubnets)
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		report, err := targetStore.ImportIPAM(export, true)
+		require.NoError(t, err)
+		require.True(t, report.Applied)
+		require.Empty(t, report.Conflicts)
+
+		imported, err := targetStore.ExportIPAM()
+		require.NoError(t, err)
+		require.Len(t, imported.ParentSubnets, 1)
+		require.Equal(t, export.ParentSubnets[0].CIDR, imported.ParentSubnets[0].CIDR)
+		require.Equal(t, export.ParentSubnets[0].Labels, imported.ParentSubnets[0].Labels)
+		require.Len(t, imported.Subnets, 2)
+		require.Len(t, imported.Exclusions, 1)
+
+		free, err := targetStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, free, 1)
+		require.Equal(t, "10.0.2.0/24", free[0].CIDR)
+
+		allocations, err := targetStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage, AccountID: "account1"})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+	})
+
+	t.Run("import again", func(t *testing.T) {
+		report, err := targetStore.ImportIPAM(export, true)
+		require.NoError(t, err)
+		require.True(t, report.Applied)
+		require.Zero(t, report.ParentSubnetsAdded+report.SubnetsAdded+report.ExclusionsAdded)
+		require.Equal(t, 1, report.ParentSubnetsExisting)
+		require.Equal(t, 2, report.SubnetsExisting)
+		require.Equal(t, 1, report.ExclusionsExisting)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		conflictStore := MakeTestSQLStore(t, logger)
+		defer CloseConnection(t, conflictStore)
+
+		err := conflictStore.AddParentSubnet(&model.ParentSubnet{ID: model.NewID(), CIDR: "10.0.0.0/23", SplitRange: 24})
+		require.NoError(t, err)
+		_, err = conflictStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.NoError(t, err)
+
+		report, err := conflictStore.ImportIPAM(export, true)
+		require.NoError(t, err)
+		require.False(t, report.Applied)
+		require.Len(t, report.Conflicts, 2)
+		require.Equal(t, model.IPAMRecordParentSubnet, report.Conflicts[0].Type)
+		require.Equal(t, "overlaps parent subnet 10.0.0.0/23", report.Conflicts[0].Reason)
+		require.Equal(t, model.IPAMRecordSubnet, report.Conflicts[1].Type)
+		require.Equal(t, "claimed by account account2", report.Conflicts[1].Reason)
+
+		exclusions, err := conflictStore.GetSubnetExclusions(&model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Empty(t, exclusions)
+	})
+}
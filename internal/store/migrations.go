@@ -98,6 +98,293 @@ var migrations = []migration{
 			return err
 		}
 
+		return nil
+	}},
+	{semver.MustParse("0.2.0"), semver.MustParse("0.3.0"), func(e execer) error {
+		// Free subnets are now computed from the parent subnets, so only claimed
+		// subnets are kept. Subnets claimed before the account had an AWS account
+		// ID are recognised by being referenced from the account metadata.
+		metadataText := "CAST(Account.AccountMetadataRaw AS TEXT)"
+		if e.DriverName() == "postgres" {
+			metadataText = "convert_from(Account.AccountMetadataRaw, 'UTF8')"
+		}
+
+		if _, err := e.Exec(`
+			DELETE FROM SubnetPool
+			WHERE AccountID = ''
+			AND NOT EXISTS (
+				SELECT 1 FROM Account
+				WHERE Account.DeleteAt = 0
+				AND ` + metadataText + ` LIKE '%"' || SubnetPool.CIDR || '"%'
+			);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			CREATE UNIQUE INDEX SubnetPool_CIDR ON SubnetPool (CIDR);
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.3.0"), semver.MustParse("0.4.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE ParentSubnet ADD COLUMN Retired BOOLEAN NOT NULL DEFAULT FALSE;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.4.0"), semver.MustParse("0.5.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE SubnetPool ADD COLUMN ReservationOwner TEXT NOT NULL DEFAULT '';
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE SubnetPool ADD COLUMN ReservationReason TEXT NOT NULL DEFAULT '';
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE SubnetPool ADD COLUMN ReservationExpiresAt BIGINT NOT NULL DEFAULT 0;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.5.0"), semver.MustParse("0.6.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE ParentSubnet ADD COLUMN LabelsRaw BYTEA NULL;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.6.0"), semver.MustParse("0.7.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE ParentSubnet ADD COLUMN AllocationStrategy TEXT NOT NULL DEFAULT '';
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.7.0"), semver.MustParse("0.8.0"), func(e execer) error {
+		// Changes to the subnet pool are serialized by updating this row first.
+		if _, err := e.Exec(`
+			CREATE TABLE SubnetPoolLock (
+				ID TEXT PRIMARY KEY,
+				LockedAt BIGINT NOT NULL
+			);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			INSERT INTO SubnetPoolLock (ID, LockedAt) VALUES ('SubnetPool', 0);
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.8.0"), semver.MustParse("0.9.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			CREATE TABLE SubnetExclusion (
+				ID TEXT PRIMARY KEY,
+				CIDR TEXT NOT NULL,
+				Source TEXT NOT NULL,
+				Reason TEXT NOT NULL,
+				CreateAt BIGINT NOT NULL
+			);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			CREATE UNIQUE INDEX SubnetExclusion_CIDR ON SubnetExclusion (CIDR);
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.9.0"), semver.MustParse("0.10.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			CREATE TABLE SubnetAllocation (
+				ID TEXT PRIMARY KEY,
+				CIDR TEXT NOT NULL,
+				ParentSubnet TEXT NOT NULL,
+				AccountID TEXT NOT NULL,
+				ClaimedAt BIGINT NOT NULL,
+				ReleasedAt BIGINT NOT NULL
+			);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			CREATE INDEX SubnetAllocation_CIDR ON SubnetAllocation (CIDR);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			CREATE INDEX SubnetAllocation_AccountID ON SubnetAllocation (AccountID);
+		`); err != nil {
+			return err
+		}
+
+		// The subnets claimed before the history was recorded start it.
+		if _, err := e.Exec(`
+			INSERT INTO SubnetAllocation (ID, CIDR, ParentSubnet, AccountID, ClaimedAt, ReleasedAt)
+			SELECT ID, CIDR, ParentSubnet, AccountID, CreateAt, 0 FROM SubnetPool WHERE ReservationOwner = '';
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.10.0"), semver.MustParse("0.11.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			CREATE TABLE WebhookDelivery (
+				ID TEXT PRIMARY KEY,
+				WebhookID TEXT NOT NULL,
+				PayloadRaw BYTEA NOT NULL,
+				Sequence BIGINT NOT NULL,
+				State TEXT NOT NULL,
+				Attempts INT NOT NULL,
+				NextAttemptAt BIGINT NOT NULL,
+				LastAttemptAt BIGINT NOT NULL,
+				LastError TEXT NOT NULL,
+				CreateAt BIGINT NOT NULL
+			);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			CREATE INDEX WebhookDelivery_WebhookID_State ON WebhookDelivery (WebhookID, State);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN LockAcquiredBy TEXT NULL;
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN LockAcquiredAt BIGINT NOT NULL DEFAULT 0;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.11.0"), semver.MustParse("0.12.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN Secret TEXT NOT NULL DEFAULT '';
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.12.0"), semver.MustParse("0.13.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN SubscriptionRaw BYTEA NULL;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.13.0"), semver.MustParse("0.14.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			CREATE TABLE WebhookDeliveryAttempt (
+				ID TEXT PRIMARY KEY,
+				DeliveryID TEXT NOT NULL,
+				WebhookID TEXT NOT NULL,
+				PayloadID TEXT NOT NULL,
+				StatusCode INT NOT NULL,
+				Latency BIGINT NOT NULL,
+				Error TEXT NOT NULL,
+				CreateAt BIGINT NOT NULL
+			);
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			CREATE INDEX WebhookDeliveryAttempt_DeliveryID ON WebhookDeliveryAttempt (DeliveryID);
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.14.0"), semver.MustParse("0.15.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN Format TEXT NOT NULL DEFAULT 'genesis';
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.15.0"), semver.MustParse("0.16.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN HeadersRaw BYTEA NULL;
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN AuthRaw BYTEA NULL;
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN TimeoutSeconds INTEGER NOT NULL DEFAULT 5;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.16.0"), semver.MustParse("0.17.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN ConsecutiveFailures INTEGER NOT NULL DEFAULT 0;
+		`); err != nil {
+			return err
+		}
+
+		if _, err := e.Exec(`
+			ALTER TABLE Webhooks ADD COLUMN Disabled BOOLEAN NOT NULL DEFAULT FALSE;
+		`); err != nil {
+			return err
+		}
+
+		return nil
+	}},
+	{semver.MustParse("0.17.0"), semver.MustParse("0.18.0"), func(e execer) error {
+		if _, err := e.Exec(`
+			CREATE INDEX WebhookDelivery_State_WebhookID ON WebhookDelivery (State, WebhookID);
+		`); err != nil {
+			return err
+		}
+
 		return nil
 	}},
 }
@@ -6,8 +6,11 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
+	"math/big"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/genesis/internal/ipam"
 	"github.com/mattermost/genesis/model"
 	"github.com/pkg/errors"
 )
@@ -16,17 +19,24 @@ var parentSubnetSelect sq.SelectBuilder
 
 func init() {
 	parentSubnetSelect = sq.
-		Select("ParentSubnet.ID", "CIDR", "SplitRange", "CreateAt",
-			"LockAcquiredBy", "LockAcquiredAt").
+		Select("ParentSubnet.ID", "CIDR", "SplitRange", "Retired", "LabelsRaw", "AllocationStrategy",
+			"CreateAt", "LockAcquiredBy", "LockAcquiredAt").
 		From("ParentSubnet")
 }
 
 type rawParentSubnet struct {
 	*model.ParentSubnet
+	LabelsRaw []byte
 }
 type rawParentSubnets []*rawParentSubnet
 
 func (r *rawParentSubnet) toParentSubnet() (model.ParentSubnet, error) {
+	var err error
+	r.ParentSubnet.Labels, err = model.NewParentSubnetLabels(r.LabelsRaw)
+	if err != nil {
+		return model.ParentSubnet{}, errors.Wrap(err, "failed to unmarshal parent subnet labels")
+	}
+
 	return *r.ParentSubnet, nil
 }
 
@@ -58,12 +68,16 @@ func (sqlStore *SQLStore) GetParentSubnet(id string) (model.ParentSubnet, error)
 
 // GetParentSubnets fetches the given page of added parent subnets. The first page is 0.
 func (sqlStore *SQLStore) GetParentSubnets(filter *model.ParentSubnetFilter) ([]model.ParentSubnet, error) {
+	return sqlStore.getParentSubnets(sqlStore.db, filter)
+}
+
+func (sqlStore *SQLStore) getParentSubnets(db dbInterface, filter *model.ParentSubnetFilter) ([]model.ParentSubnet, error) {
 	builder := parentSubnetSelect.
 		OrderBy("CreateAt ASC")
 	builder = sqlStore.applyParentSubnetsFilter(builder, filter)
 
 	var rawParentSubnets rawParentSubnets
-	err := sqlStore.selectBuilder(sqlStore.db, &rawParentSubnets, builder)
+	err := sqlStore.selectBuilder(db, &rawParentSubnets, builder)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query for parent subnets")
 	}
@@ -81,23 +95,54 @@ func (sqlStore *SQLStore) applyParentSubnetsFilter(builder sq.SelectBuilder, fil
 	return builder
 }
 
-// AddParentSubnet records the given parent subnet to the database.
-func (sqlStore *SQLStore) AddParentSubnet(parentSubnet *model.ParentSubnet, subnets *[]model.Subnet) error {
-	tx, err := sqlStore.beginTransaction(sqlStore.db)
+// AddParentSubnet records the given parent subnet to the database and sends a
+// parent subnet webhook announcing it. Subnets of the parent subnet are not
+// stored until they are claimed.
+func (sqlStore *SQLStore) AddParentSubnet(parentSubnet *model.ParentSubnet) error {
+	return sqlStore.retryOnTransientError(func() error {
+		return sqlStore.addNonOverlappingParentSubnet(parentSubnet)
+	})
+}
+
+// addNonOverlappingParentSubnet records the given parent subnet unless it
+// overlaps an existing parent subnet, in which case model.ErrParentSubnetOverlap
+// is returned. The check and the insert run under the subnet pool lock so that
+// concurrent requests cannot add overlapping parent subnets.
+func (sqlStore *SQLStore) addNonOverlappingParentSubnet(parentSubnet *model.ParentSubnet) error {
+	prefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
 	if err != nil {
-		return errors.Wrap(err, "failed to begin transaction")
+		return errors.Wrap(err, "invalid parent subnet CIDR")
+	}
+
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return err
 	}
 	defer tx.RollbackUnlessCommitted()
 
-	if err = sqlStore.addParentSubnet(tx, parentSubnet); err != nil {
-		return errors.Wrap(err, "failed to add parent subnet")
+	existingParentSubnets, err := sqlStore.getParentSubnets(tx, &model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return errors.Wrap(err, "failed to get parent subnets")
+	}
+	for _, existingParentSubnet := range existingParentSubnets {
+		existingPrefix, err := ipam.ParsePrefix(existingParentSubnet.CIDR)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse parent subnet %s", existingParentSubnet.ID)
+		}
+		if prefix.Overlaps(existingPrefix) {
+			return errors.Wrapf(model.ErrParentSubnetOverlap, "parent subnet %s overlaps with parent subnet %s", parentSubnet.CIDR, existingParentSubnet.CIDR)
+		}
 	}
 
-	if err = sqlStore.addSubnets(tx, subnets); err != nil {
-		return errors.Wrap(err, "failed to add subnets")
+	if err = sqlStore.addParentSubnet(tx, parentSubnet); err != nil {
+		return err
+	}
+	if err = sqlStore.enqueueParentSubnetEvent(tx, parentSubnet); err != nil {
+		return err
 	}
 
-	if err = tx.Commit(); err != nil {
+	err = tx.Commit()
+	if err != nil {
 		return errors.Wrap(err, "failed to commit the transaction")
 	}
 
@@ -108,15 +153,23 @@ func (sqlStore *SQLStore) AddParentSubnet(parentSubnet *model.ParentSubnet, subn
 func (sqlStore *SQLStore) addParentSubnet(execer execer, parentSubnet *model.ParentSubnet) error {
 	parentSubnet.CreateAt = GetMillis()
 
-	if _, err := sqlStore.execBuilder(execer, sq.
+	labelsJSON, err := json.Marshal(parentSubnet.Labels)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal parent subnet labels")
+	}
+
+	if _, err = sqlStore.execBuilder(execer, sq.
 		Insert("ParentSubnet").
 		SetMap(map[string]interface{}{
-			"ID":             parentSubnet.ID,
-			"CIDR":           parentSubnet.CIDR,
-			"SplitRange":     parentSubnet.SplitRange,
-			"CreateAt":       parentSubnet.CreateAt,
-			"LockAcquiredBy": nil,
-			"LockAcquiredAt": 0,
+			"ID":                 parentSubnet.ID,
+			"CIDR":               parentSubnet.CIDR,
+			"SplitRange":         parentSubnet.SplitRange,
+			"Retired":            parentSubnet.Retired,
+			"LabelsRaw":          labelsJSON,
+			"AllocationStrategy": parentSubnet.AllocationStrategy,
+			"CreateAt":           parentSubnet.CreateAt,
+			"LockAcquiredBy":     nil,
+			"LockAcquiredAt":     0,
 		}),
 	); err != nil {
 		return errors.Wrap(err, "failed to create parent subnet")
@@ -125,6 +178,307 @@ func (sqlStore *SQLStore) addParentSubnet(execer execer, parentSubnet *model.Par
 	return nil
 }
 
+// UpdateParentSubnetLabels replaces the labels of the given parent subnet.
+func (sqlStore *SQLStore) UpdateParentSubnetLabels(id string, labels map[string]string) error {
+	labelsJSON, err := json.Marshal(labels)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal parent subnet labels")
+	}
+
+	err = sqlStore.updateParentSubnet(id, "LabelsRaw", labelsJSON)
+	if err != nil {
+		return errors.Wrap(err, "failed to update parent subnet labels")
+	}
+
+	return nil
+}
+
+// UpdateParentSubnetAllocationStrategy sets the allocation strategy of the given
+// parent subnet. An empty allocation strategy removes it.
+func (sqlStore *SQLStore) UpdateParentSubnetAllocationStrategy(id, strategy string) error {
+	err := sqlStore.updateParentSubnet(id, "AllocationStrategy", strategy)
+	if err != nil {
+		return errors.Wrap(err, "failed to update parent subnet allocation strategy")
+	}
+
+	return nil
+}
+
+// RetireParentSubnet stops new subnets from being claimed from the parent subnet.
+// Subnets already claimed from it are kept.
+func (sqlStore *SQLStore) RetireParentSubnet(id string) error {
+	return sqlStore.setParentSubnetRetired(id, true)
+}
+
+// UnretireParentSubnet allows subnets to be claimed again from the parent subnet.
+func (sqlStore *SQLStore) UnretireParentSubnet(id string) error {
+	return sqlStore.setParentSubnetRetired(id, false)
+}
+
+func (sqlStore *SQLStore) setParentSubnetRetired(id string, retired bool) error {
+	err := sqlStore.updateParentSubnet(id, "Retired", retired)
+	if err != nil {
+		return errors.Wrap(err, "failed to store parent subnet retired flag")
+	}
+
+	return nil
+}
+
+// updateParentSubnet sets a column of the given parent subnet. The update runs
+// under the subnet pool lock so that the claims in progress see the parent
+// subnet either before or after it.
+func (sqlStore *SQLStore) updateParentSubnet(id, column string, value interface{}) error {
+	return sqlStore.retryOnTransientError(func() error {
+		tx, err := sqlStore.beginSubnetPoolTransaction()
+		if err != nil {
+			return err
+		}
+		defer tx.RollbackUnlessCommitted()
+
+		_, err = sqlStore.execBuilder(tx, sq.
+			Update("ParentSubnet").
+			Set(column, value).
+			Where("ID = ?", id),
+		)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return errors.Wrap(err, "failed to commit the transaction")
+		}
+
+		return nil
+	})
+}
+
+// DeleteParentSubnet removes the given parent subnet from the database. Parent
+// subnets with claimed subnets cannot be deleted.
+func (sqlStore *SQLStore) DeleteParentSubnet(id string) error {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	var rawParentSubnet rawParentSubnet
+	err = sqlStore.getBuilder(tx, &rawParentSubnet, parentSubnetSelect.Where("ID = ?", id))
+	if err == sql.ErrNoRows {
+		return errors.Errorf("parent subnet %s does not exist", id)
+	} else if err != nil {
+		return errors.Wrap(err, "failed to get parent subnet")
+	}
+
+	claimed, err := sqlStore.getSubnets(tx, &model.SubnetFilter{
+		PerPage:         1,
+		ParentSubnet:    rawParentSubnet.CIDR,
+		IncludeReserved: true,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get claimed subnets")
+	}
+	if len(claimed) != 0 {
+		return errors.Errorf("parent subnet %s still has claimed subnets", rawParentSubnet.CIDR)
+	}
+
+	if _, err = sqlStore.execBuilder(tx, sq.
+		Delete("ParentSubnet").
+		Where("ID = ?", id),
+	); err != nil {
+		return errors.Wrap(err, "failed to delete parent subnet")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
+}
+
+// GetParentSubnetUsage computes the usage of the given parent subnet. Nil is
+// returned if the parent subnet does not exist.
+func (sqlStore *SQLStore) GetParentSubnetUsage(id string) (*model.ParentSubnetUsage, error) {
+	var rawParentSubnet rawParentSubnet
+	err := sqlStore.getBuilder(sqlStore.db, &rawParentSubnet, parentSubnetSelect.Where("ID = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnet by id")
+	}
+	parentSubnet, err := rawParentSubnet.toParentSubnet()
+	if err != nil {
+		return nil, err
+	}
+
+	allocated, excludedPrefixes, err := sqlStore.getUsedSubnets(sqlStore.db)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeParentSubnetUsage(parentSubnet, allocated, excludedPrefixes)
+}
+
+// GetParentSubnetsUsage computes the usage of all parent subnets.
+func (sqlStore *SQLStore) GetParentSubnetsUsage() ([]*model.ParentSubnetUsage, error) {
+	parentSubnets, err := sqlStore.getParentSubnets(sqlStore.db, &model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnets")
+	}
+
+	allocated, excludedPrefixes, err := sqlStore.getUsedSubnets(sqlStore.db)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []*model.ParentSubnetUsage{}
+	for _, parentSubnet := range parentSubnets {
+		usage, err := computeParentSubnetUsage(parentSubnet, allocated, excludedPrefixes)
+		if err != nil {
+			return nil, err
+		}
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
+
+// GetParentSubnetUsageChange computes the usage of the parent subnet of the
+// given claimed or reserved subnet without the subnet and with it, so that a
+// claim or a reservation can be checked against the low watermarks. Nil usages
+// are returned if the subnet or its parent subnet does not exist.
+func (sqlStore *SQLStore) GetParentSubnetUsageChange(cidr string) (*model.ParentSubnetUsage, *model.ParentSubnetUsage, error) {
+	var rawSubnet rawSubnet
+	err := sqlStore.getBuilder(sqlStore.db, &rawSubnet, subnetSelect.Where("CIDR = ?", cidr))
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get subnet by range")
+	}
+
+	var rawParentSubnet rawParentSubnet
+	err = sqlStore.getBuilder(sqlStore.db, &rawParentSubnet, parentSubnetSelect.Where("CIDR = ?", rawSubnet.ParentSubnet))
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get parent subnet by range")
+	}
+	parentSubnet, err := rawParentSubnet.toParentSubnet()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allocated, excludedPrefixes, err := sqlStore.getUsedSubnets(sqlStore.db)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := computeParentSubnetUsage(parentSubnet, allocated, excludedPrefixes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var others []*model.Subnet
+	for _, subnet := range allocated {
+		if subnet.ID != rawSubnet.ID {
+			others = append(others, subnet)
+		}
+	}
+	before, err := computeParentSubnetUsage(parentSubnet, others, excludedPrefixes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, nil
+}
+
+// getUsedSubnets fetches the claimed and reserved subnets along with the parsed
+// prefixes of the subnet exclusions.
+func (sqlStore *SQLStore) getUsedSubnets(db dbInterface) ([]*model.Subnet, []*ipam.Prefix, error) {
+	allocated, _, err := sqlStore.getAllocatedSubnets(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	excludedPrefixes, err := sqlStore.getExcludedPrefixes(db)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get subnet exclusions")
+	}
+
+	return allocated, excludedPrefixes, nil
+}
+
+// computeParentSubnetUsage computes the usage of the given parent subnet. The
+// subnets of its split range that are partly used are counted once, as claimed
+// before reserved and reserved before excluded, so that the counts add up to
+// the total.
+func computeParentSubnetUsage(parentSubnet model.ParentSubnet, allocated []*model.Subnet, excludedPrefixes []*ipam.Prefix) (*model.ParentSubnetUsage, error) {
+	parentPrefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse parent subnet %s", parentSubnet.ID)
+	}
+
+	var claimedPrefixes, reservedPrefixes []*ipam.Prefix
+	for _, subnet := range allocated {
+		prefix, err := ipam.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse subnet %s", subnet.ID)
+		}
+		if !parentPrefix.Overlaps(prefix) {
+			continue
+		}
+		if subnet.IsReserved() {
+			reservedPrefixes = append(reservedPrefixes, prefix)
+		} else {
+			claimedPrefixes = append(claimedPrefixes, prefix)
+		}
+	}
+
+	total := new(big.Int).Lsh(big.NewInt(1), uint(parentSubnet.SplitRange-parentPrefix.Len()))
+	used := func(prefixes []*ipam.Prefix) (*big.Int, error) {
+		pool, err := ipam.NewPool(parentPrefix, parentSubnet.SplitRange, prefixes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute pool of parent subnet %s", parentSubnet.ID)
+		}
+		return new(big.Int).Sub(total, pool.FreeCount()), nil
+	}
+
+	usedPrefixes := append([]*ipam.Prefix{}, claimedPrefixes...)
+	claimed, err := used(usedPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	usedPrefixes = append(usedPrefixes, reservedPrefixes...)
+	claimedOrReserved, err := used(usedPrefixes)
+	if err != nil {
+		return nil, err
+	}
+	usedPrefixes = append(usedPrefixes, excludedPrefixes...)
+	usedAll, err := used(usedPrefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &model.ParentSubnetUsage{
+		ParentSubnetID: parentSubnet.ID,
+		CIDR:           parentSubnet.CIDR,
+		SplitRange:     parentSubnet.SplitRange,
+		Retired:        parentSubnet.Retired,
+		Labels:         parentSubnet.Labels,
+		Total:          total,
+		Free:           new(big.Int).Sub(total, usedAll),
+		Claimed:        claimed,
+		Reserved:       new(big.Int).Sub(claimedOrReserved, claimed),
+		Excluded:       new(big.Int).Sub(usedAll, claimedOrReserved),
+	}
+	usage.FreePercent, _ = new(big.Float).Quo(
+		new(big.Float).SetInt(new(big.Int).Mul(usage.Free, big.NewInt(100))),
+		new(big.Float).SetInt(usage.Total),
+	).Float64()
+
+	return usage, nil
+}
+
 // LockParentSubnet marks the parent subnet as locked for exclusive use by the caller.
 func (sqlStore *SQLStore) LockParentSubnet(subnet, lockerID string) (bool, error) {
 	return sqlStore.lockRows("ParentSubnet", []string{subnet}, lockerID)
@@ -5,6 +5,7 @@
 package store
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/mattermost/genesis/internal/testlib"
@@ -34,12 +35,7 @@ func TestParentSubnets(t *testing.T) {
 			SplitRange: 8,
 		}
 
-		subnet1 := &model.Subnet{
-			CIDR:         "10.80.0.0/24",
-			ParentSubnet: "10.0.0.0/8",
-		}
-
-		err := sqlStore.AddParentSubnet(&parentSubnet1, &[]model.Subnet{*subnet1})
+		err := sqlStore.AddParentSubnet(&parentSubnet1)
 		require.NoError(t, err)
 
 		actualParentSubnet1, err := sqlStore.GetParentSubnet(parentSubnet1.ID)
@@ -73,12 +69,7 @@ func TestParentSubnets(t *testing.T) {
 			SplitRange: 8,
 		}
 
-		subnet1 := &model.Subnet{
-			CIDR:         "10.80.0.0/24",
-			ParentSubnet: "10.0.0.0/8",
-		}
-
-		err := sqlStore.AddParentSubnet(&parentSubnet1, &[]model.Subnet{*subnet1})
+		err := sqlStore.AddParentSubnet(&parentSubnet1)
 		require.NoError(t, err)
 
 		actualParentSubnet1, err := sqlStore.GetParentSubnet(parentSubnet1.ID)
@@ -87,6 +78,167 @@ func TestParentSubnets(t *testing.T) {
 	})
 }
 
+func TestRetireParentSubnet(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/23",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet)
+	require.NoError(t, err)
+
+	claimed, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+
+	t.Run("retire parent subnet", func(t *testing.T) {
+		err := sqlStore.RetireParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+
+		actualParentSubnet, err := sqlStore.GetParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+		require.True(t, actualParentSubnet.Retired)
+	})
+
+	t.Run("no claims from retired parent subnet", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.Error(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+	})
+
+	t.Run("existing claims are kept", func(t *testing.T) {
+		subnet, err := sqlStore.GetSubnet(claimed.ID)
+		require.NoError(t, err)
+		require.Equal(t, "account1", subnet.AccountID)
+	})
+
+	t.Run("unretire parent subnet", func(t *testing.T) {
+		err := sqlStore.UnretireParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.1.0/24", subnet.CIDR)
+	})
+}
+
+func TestDeleteParentSubnet(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/23",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet)
+	require.NoError(t, err)
+
+	_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+
+	t.Run("delete unknown parent subnet", func(t *testing.T) {
+		err := sqlStore.DeleteParentSubnet(model.NewID())
+		require.Error(t, err)
+	})
+
+	t.Run("delete parent subnet with claimed subnets", func(t *testing.T) {
+		err := sqlStore.DeleteParentSubnet(parentSubnet.ID)
+		require.Error(t, err)
+	})
+
+	t.Run("delete parent subnet", func(t *testing.T) {
+		err := sqlStore.SubnetCleanup("10.0.0.0/24", 0)
+		require.NoError(t, err)
+
+		err = sqlStore.DeleteParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+
+		actualParentSubnet, err := sqlStore.GetParentSubnet(parentSubnet.ID)
+		require.NoError(t, err)
+		require.Empty(t, actualParentSubnet.ID)
+	})
+}
+
+func TestParentSubnetUsage(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet1 := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet1)
+	require.NoError(t, err)
+
+	parentSubnet2 := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "2600:1f18:1000::/48",
+		SplitRange: 56,
+	}
+	err = sqlStore.AddParentSubnet(&parentSubnet2)
+	require.NoError(t, err)
+
+	_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+	_, err = sqlStore.ReserveSubnet("10.0.1.0/25", "network-team", "on-prem link", 0)
+	require.NoError(t, err)
+	_, err = sqlStore.ReserveSubnet("10.0.2.0/24", "network-team", "expired", 1)
+	require.NoError(t, err)
+
+	t.Run("unknown parent subnet", func(t *testing.T) {
+		usage, err := sqlStore.GetParentSubnetUsage(model.NewID())
+		require.NoError(t, err)
+		require.Nil(t, usage)
+	})
+
+	t.Run("parent subnet usage", func(t *testing.T) {
+		usage, err := sqlStore.GetParentSubnetUsage(parentSubnet1.ID)
+		require.NoError(t, err)
+		require.Equal(t, parentSubnet1.CIDR, usage.CIDR)
+		require.Equal(t, big.NewInt(4), usage.Total)
+		require.Equal(t, big.NewInt(2), usage.Free)
+		require.Equal(t, big.NewInt(1), usage.Claimed)
+		require.Equal(t, big.NewInt(1), usage.Reserved)
+		require.Zero(t, usage.Excluded.Sign())
+		require.Equal(t, float64(50), usage.FreePercent)
+	})
+
+	t.Run("parent subnet usage change", func(t *testing.T) {
+		before, after, err := sqlStore.GetParentSubnetUsageChange("10.0.0.0/24")
+		require.NoError(t, err)
+		require.Equal(t, parentSubnet1.ID, before.ParentSubnetID)
+		require.Zero(t, before.Claimed.Sign())
+		require.Equal(t, big.NewInt(3), before.Free)
+		require.Equal(t, big.NewInt(1), after.Claimed)
+		require.Equal(t, big.NewInt(2), after.Free)
+
+		before, after, err = sqlStore.GetParentSubnetUsageChange("10.0.3.0/24")
+		require.NoError(t, err)
+		require.Nil(t, before)
+		require.Nil(t, after)
+	})
+
+	t.Run("all parent subnets usage", func(t *testing.T) {
+		usages, err := sqlStore.GetParentSubnetsUsage()
+		require.NoError(t, err)
+		require.Len(t, usages, 2)
+		require.Equal(t, parentSubnet2.ID, usages[1].ParentSubnetID)
+		require.Equal(t, big.NewInt(256), usages[1].Total)
+		require.Equal(t, big.NewInt(256), usages[1].Free)
+		require.Equal(t, float64(100), usages[1].FreePercent)
+	})
+}
+
 func TestLockParentSubnet(t *testing.T) {
 	logger := testlib.MakeLogger(t)
 	sqlStore := MakeTestSQLStore(t, logger)
@@ -95,18 +247,18 @@ func TestLockParentSubnet(t *testing.T) {
 	lockerID2 := model.NewID()
 
 	parentSubnet1 := model.ParentSubnet{
-		ID: model.NewID(),
+		ID:   model.NewID(),
+		CIDR: "10.0.0.0/24",
 	}
-	subnet1 := &model.Subnet{}
 
-	err := sqlStore.AddParentSubnet(&parentSubnet1, &[]model.Subnet{*subnet1})
+	err := sqlStore.AddParentSubnet(&parentSubnet1)
 	require.NoError(t, err)
 
 	parentSubnet2 := model.ParentSubnet{
-		ID: model.NewID(),
+		ID:   model.NewID(),
+		CIDR: "10.1.0.0/24",
 	}
-	subnet2 := &model.Subnet{}
-	err = sqlStore.AddParentSubnet(&parentSubnet2, &[]model.Subnet{*subnet2})
+	err = sqlStore.AddParentSubnet(&parentSubnet2)
 	require.NoError(t, err)
 
 	t.Run("parent subnets should start unlocked", func(t *testing.T) {
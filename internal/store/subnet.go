@@ -6,8 +6,12 @@ package store
 
 import (
 	"database/sql"
+	"fmt"
+	"math/big"
+	"net"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/genesis/internal/ipam"
 	"github.com/mattermost/genesis/model"
 	"github.com/pkg/errors"
 )
@@ -17,6 +21,7 @@ var subnetSelect sq.SelectBuilder
 func init() {
 	subnetSelect = sq.
 		Select("SubnetPool.ID", "CIDR", "AccountID", "ParentSubnet",
+			"ReservationOwner", "ReservationReason", "ReservationExpiresAt", "CreateAt",
 			"LockAcquiredBy", "LockAcquiredAt").
 		From("SubnetPool")
 }
@@ -43,70 +48,452 @@ func (rc *rawSubnets) toSubnets() ([]*model.Subnet, error) {
 	return subnets, nil
 }
 
-// getRandomAvailableSubnet fetches a random available subnet.
-func (sqlStore *SQLStore) getRandomAvailableSubnet(db dbInterface) (*model.Subnet, error) {
-	filter := &model.SubnetFilter{
-		Page:    1,
-		PerPage: 1,
-		Free:    true,
+// parentSubnetPool is the allocator of the subnets of a parent subnet.
+type parentSubnetPool struct {
+	parentSubnet model.ParentSubnet
+	pool         *ipam.Pool
+}
+
+// getParentSubnetPools computes the allocators of all parent subnets of the given
+// address family. An empty family returns the allocators of all parent subnets.
+// Retired parent subnets are skipped since no subnets can be claimed from them.
+// Reserved subnets are not free unless their reservation has expired, and the
+// subnets overlapping a subnet exclusion are never free.
+// The allocators hand out subnets of the split range of each parent subnet, or of
+// the given prefix length if it is not zero, in which case the parent subnets that
+// cannot hold a subnet of that size are skipped. Only the parent subnets matching
+// the given selector are returned.
+func (sqlStore *SQLStore) getParentSubnetPools(db dbInterface, family string, prefixLength int, selector map[string]string) ([]*parentSubnetPool, error) {
+	parentSubnets, err := sqlStore.getParentSubnets(db, &model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnets")
 	}
 
-	subnets, err := sqlStore.getSubnets(db, filter)
+	_, claimedPrefixes, err := sqlStore.getAllocatedSubnets(db)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to query for free subnet")
+		return nil, err
 	}
-	if subnets[0] == nil {
-		return nil, errors.Errorf("Returning nil when querying for free subnets")
+
+	excludedPrefixes, err := sqlStore.getExcludedPrefixes(db)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnet exclusions")
 	}
+	claimedPrefixes = append(claimedPrefixes, excludedPrefixes...)
 
-	return subnets[0], nil
+	var pools []*parentSubnetPool
+	for _, parentSubnet := range parentSubnets {
+		if parentSubnet.Retired {
+			continue
+		}
+		if family != "" && model.SubnetFamily(parentSubnet.CIDR) != family {
+			continue
+		}
+		if !parentSubnet.MatchesSelector(selector) {
+			continue
+		}
+
+		parentPrefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse parent subnet %s", parentSubnet.ID)
+		}
+
+		length := parentSubnet.SplitRange
+		if prefixLength != 0 {
+			if prefixLength < parentPrefix.Len() || prefixLength > parentPrefix.Bits() {
+				continue
+			}
+			length = prefixLength
+		}
+
+		pool, err := ipam.NewPool(parentPrefix, length, claimedPrefixes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compute pool of parent subnet %s", parentSubnet.ID)
+		}
+
+		pools = append(pools, &parentSubnetPool{parentSubnet: parentSubnet, pool: pool})
+	}
+
+	return pools, nil
 }
 
-// getSubnetByCIDR fetches a subnet by its provided CIDR range.
-func (sqlStore *SQLStore) getSubnetByCIDR(db dbInterface, cidr string) (*model.Subnet, error) {
-	var rawSubnet rawSubnet
-	err := sqlStore.getBuilder(db, &rawSubnet, subnetSelect.Where("CIDR = ?", cidr))
-	if err == sql.ErrNoRows {
-		return nil, nil
-	} else if err != nil {
-		return nil, errors.Wrap(err, "failed to get subnet by range")
+// getAllocatedSubnets fetches the claimed subnets and the reserved subnets whose
+// reservation has not expired, along with their parsed prefixes.
+func (sqlStore *SQLStore) getAllocatedSubnets(db dbInterface) ([]*model.Subnet, []*ipam.Prefix, error) {
+	subnets, err := sqlStore.getSubnets(db, &model.SubnetFilter{PerPage: model.AllPerPage, IncludeReserved: true})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get claimed subnets")
 	}
 
-	if rawSubnet.Subnet == nil {
-		return nil, errors.Errorf("Returning nil when getting subnet by CIDR")
+	now := GetMillis()
+	var allocated []*model.Subnet
+	var prefixes []*ipam.Prefix
+	for _, subnet := range subnets {
+		if subnet.IsReservationExpired(now) {
+			continue
+		}
+		prefix, err := ipam.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse claimed subnet %s", subnet.ID)
+		}
+		allocated = append(allocated, subnet)
+		prefixes = append(prefixes, prefix)
 	}
 
-	return rawSubnet.toSubnet()
+	return allocated, prefixes, nil
 }
 
-// ClaimSubnet claims a subnet and associates it with an account. If an empty subnet is passed a random one will be allocated.
-func (sqlStore *SQLStore) ClaimSubnet(cidr string, accountID string) (*model.Subnet, error) {
+// getFreeSubnets computes the given page of free subnets. Free subnets can only
+// be listed by pages of at most model.MaxFreeSubnetsPerPage, as a parent subnet
+// may hold millions of them.
+func (sqlStore *SQLStore) getFreeSubnets(db dbInterface, filter *model.SubnetFilter) ([]*model.Subnet, error) {
+	if filter.PerPage < 0 || filter.PerPage > model.MaxFreeSubnetsPerPage {
+		return nil, errors.Errorf("free subnets must be listed by pages of at most %d", model.MaxFreeSubnetsPerPage)
+	}
+
+	pools, err := sqlStore.getParentSubnetPools(db, filter.Family, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := filter.PerPage
+	offset := big.NewInt(int64(filter.Page) * int64(filter.PerPage))
+
+	subnets := []*model.Subnet{}
+	for _, parentSubnetPool := range pools {
+		if len(subnets) >= limit {
+			break
+		}
+
+		free := parentSubnetPool.pool.FreeCount()
+		if offset.Cmp(free) >= 0 {
+			offset.Sub(offset, free)
+			continue
+		}
+
+		for _, prefix := range parentSubnetPool.pool.List(offset, limit-len(subnets)) {
+			subnets = append(subnets, &model.Subnet{
+				CIDR:         prefix.String(),
+				ParentSubnet: parentSubnetPool.parentSubnet.CIDR,
+			})
+		}
+		offset.SetInt64(0)
+	}
+
+	return subnets, nil
+}
+
+// getRequestedSubnet validates that the given CIDR is a free subnet of one of the
+// given parent subnet pools and returns it.
+func (sqlStore *SQLStore) getRequestedSubnet(db dbInterface, pools []*parentSubnetPool, cidr string) (*model.Subnet, error) {
+	prefix, err := ipam.ParsePrefix(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, parentSubnetPool := range pools {
+		if !parentSubnetPool.pool.Parent().Contains(prefix) || parentSubnetPool.pool.Len() != prefix.Len() {
+			continue
+		}
+		if !parentSubnetPool.pool.IsFree(prefix) {
+			excludedPrefixes, err := sqlStore.getExcludedPrefixes(db)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get subnet exclusions")
+			}
+			for _, excludedPrefix := range excludedPrefixes {
+				if excludedPrefix.Overlaps(prefix) {
+					return nil, errors.Wrapf(model.ErrSubnetExcluded, "subnet %s overlaps excluded range %s", prefix, excludedPrefix)
+				}
+			}
+			return nil, errors.Wrapf(model.ErrSubnetUnavailable, "subnet %s", prefix)
+		}
+
+		return &model.Subnet{
+			CIDR:         prefix.String(),
+			ParentSubnet: parentSubnetPool.parentSubnet.CIDR,
+		}, nil
+	}
+
+	return nil, errors.Wrapf(model.ErrSubnetOutsideParentSubnets, "subnet %s", prefix)
+}
+
+// allocateSubnet picks a free subnet from the given parent subnet pools. The
+// allocation strategy chooses the parent subnet, then the allocation strategy
+// of that parent subnet, or the given one if it has none, chooses the subnet.
+func (sqlStore *SQLStore) allocateSubnet(pools []*parentSubnetPool, strategy string) (*parentSubnetPool, *ipam.Prefix, error) {
+	if strategy == "" {
+		strategy = model.DefaultSubnetAllocationStrategy
+	}
+
+	var available []*parentSubnetPool
+	var ipamPools []*ipam.Pool
+	for _, parentSubnetPool := range pools {
+		if parentSubnetPool.pool.FreeCount().Sign() > 0 {
+			available = append(available, parentSubnetPool)
+			ipamPools = append(ipamPools, parentSubnetPool.pool)
+		}
+	}
+	if len(available) == 0 {
+		return nil, nil, errors.Wrap(model.ErrSubnetCapacityExhausted, "no free prefixes available")
+	}
+
+	chosen := available[0]
+	switch strategy {
+	case model.SubnetAllocationSequential:
+	case model.SubnetAllocationSpread:
+		for _, candidate := range available[1:] {
+			// Compare the shares of free subnets without dividing big integers.
+			candidateShare := new(big.Int).Mul(candidate.pool.FreeCount(), chosen.pool.Capacity())
+			chosenShare := new(big.Int).Mul(chosen.pool.FreeCount(), candidate.pool.Capacity())
+			if candidateShare.Cmp(chosenShare) > 0 {
+				chosen = candidate
+			}
+		}
+	case model.SubnetAllocationBestFit:
+		for _, candidate := range available[1:] {
+			if candidate.pool.SmallestRun().Cmp(chosen.pool.SmallestRun()) < 0 {
+				chosen = candidate
+			}
+		}
+	case model.SubnetAllocationRandom:
+		pool, _, err := ipam.Random(ipamPools)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, parentSubnetPool := range available {
+			if parentSubnetPool.pool == pool {
+				chosen = parentSubnetPool
+			}
+		}
+	default:
+		return nil, nil, errors.Errorf("unsupported allocation strategy %s", strategy)
+	}
+
+	if chosen.parentSubnet.AllocationStrategy != "" {
+		strategy = chosen.parentSubnet.AllocationStrategy
+	}
+
+	var prefix *ipam.Prefix
+	var err error
+	switch strategy {
+	case model.SubnetAllocationSequential:
+		prefix, err = chosen.pool.First()
+	case model.SubnetAllocationSpread:
+		prefix, err = chosen.pool.Spread()
+	case model.SubnetAllocationBestFit:
+		prefix, err = chosen.pool.BestFit()
+	case model.SubnetAllocationRandom:
+		prefix, err = chosen.pool.Random()
+	default:
+		return nil, nil, errors.Errorf("unsupported allocation strategy %s of parent subnet %s", strategy, chosen.parentSubnet.ID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return chosen, prefix, nil
+}
+
+// ClaimSubnet claims a subnet and associates it with an account. If the claim has no CIDR a random one
+// of the given address family will be allocated. Subnets are of the split range of their parent subnet
+// unless a non-zero prefix length is passed, in which case they are carved from any parent subnet
+// large enough to hold them. Only the parent subnets matching the pool selector of the claim are used,
+// and the free subnet is chosen by the allocation strategy of the claim and of its parent subnet.
+func (sqlStore *SQLStore) ClaimSubnet(claim *model.SubnetClaim) (*model.Subnet, error) {
 	var subnet *model.Subnet
-	tx, err := sqlStore.beginCustomTransaction(sqlStore.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	err := sqlStore.retryOnTransientError(func() error {
+		var err error
+		subnet, err = sqlStore.claimSubnet(claim)
+		return err
+	})
+
+	return subnet, err
+}
+
+func (sqlStore *SQLStore) claimSubnet(claim *model.SubnetClaim) (*model.Subnet, error) {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to begin the transaction")
+		return nil, err
 	}
 	defer tx.RollbackUnlessCommitted()
 
-	if cidr != "" {
-		subnet, err = sqlStore.getSubnetByCIDR(tx, cidr)
+	if err = sqlStore.deleteExpiredSubnetReservations(tx); err != nil {
+		return nil, err
+	}
+
+	pools, err := sqlStore.getParentSubnetPools(tx, claim.Family, claim.PrefixLength, claim.PoolSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnet pools")
+	}
+
+	var subnet *model.Subnet
+	if claim.CIDR != "" {
+		if model.SubnetFamily(claim.CIDR) != claim.Family {
+			return nil, errors.Errorf("subnet %s is not an %s subnet", claim.CIDR, claim.Family)
+		}
+		subnet, err = sqlStore.getRequestedSubnet(tx, pools, claim.CIDR)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get subnet by cidr")
+			return nil, errors.Wrap(err, "failed to get requested subnet")
 		}
 	} else {
-		subnet, err = sqlStore.getRandomAvailableSubnet(tx)
+		parentSubnetPool, prefix, err := sqlStore.allocateSubnet(pools, claim.AllocationStrategy)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get random available subnet")
+			if len(claim.PoolSelector) != 0 {
+				err = errors.Wrapf(err, "in parent subnets matching %v", claim.PoolSelector)
+			}
+			if claim.PrefixLength != 0 {
+				return nil, errors.Wrapf(err, "no capacity for /%d %s subnets", claim.PrefixLength, claim.Family)
+			}
+			return nil, errors.Wrapf(err, "no free %s subnets available", claim.Family)
+		}
+		subnet = &model.Subnet{
+			CIDR:         prefix.String(),
+			ParentSubnet: parentSubnetPool.parentSubnet.CIDR,
 		}
 	}
+	subnet.AccountID = claim.AccountID
+
+	if err = sqlStore.addSubnet(tx, subnet); err != nil {
+		return nil, errors.Wrap(err, "failed to record claimed subnet")
+	}
+	if err = sqlStore.recordSubnetAllocation(tx, subnet); err != nil {
+		return nil, err
+	}
+	if err = sqlStore.enqueueSubnetEvent(tx, subnet, "", model.SubnetStateClaimed); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return subnet, nil
+}
+
+// ReserveSubnet reserves the given free subnet so that it cannot be claimed by accounts.
+func (sqlStore *SQLStore) ReserveSubnet(cidr, owner, reason string, expiresAt int64) (*model.Subnet, error) {
+	var subnet *model.Subnet
+	err := sqlStore.retryOnTransientError(func() error {
+		var err error
+		subnet, err = sqlStore.reserveSubnet(cidr, owner, reason, expiresAt)
+		return err
+	})
+
+	return subnet, err
+}
+
+func (sqlStore *SQLStore) reserveSubnet(cidr, owner, reason string, expiresAt int64) (*model.Subnet, error) {
+	prefix, err := ipam.ParsePrefix(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.deleteExpiredSubnetReservations(tx); err != nil {
+		return nil, err
+	}
+
+	pools, err := sqlStore.getParentSubnetPools(tx, model.SubnetFamily(cidr), prefix.Len(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnet pools")
+	}
+
+	subnet, err := sqlStore.getRequestedSubnet(tx, pools, cidr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get requested subnet")
+	}
+	subnet.ReservationOwner = owner
+	subnet.ReservationReason = reason
+	subnet.ReservationExpiresAt = expiresAt
+
+	if err = sqlStore.addSubnet(tx, subnet); err != nil {
+		return nil, errors.Wrap(err, "failed to record reserved subnet")
+	}
+	if err = sqlStore.enqueueSubnetEvent(tx, subnet, "", model.SubnetStateReserved); err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return subnet, nil
+}
+
+// ReserveExistingSubnet turns the given stored subnet into a reservation or
+// updates the metadata of its reservation. Subnets that an account uses and
+// quarantined subnets cannot be reserved. It returns nil if the subnet does not
+// exist.
+func (sqlStore *SQLStore) ReserveExistingSubnet(id, owner, reason string, expiresAt int64) (*model.Subnet, error) {
+	var subnet *model.Subnet
+	err := sqlStore.retryOnTransientError(func() error {
+		var err error
+		subnet, err = sqlStore.reserveExistingSubnet(id, owner, reason, expiresAt)
+		return err
+	})
+
+	return subnet, err
+}
+
+func (sqlStore *SQLStore) reserveExistingSubnet(id, owner, reason string, expiresAt int64) (*model.Subnet, error) {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	var rawSubnet rawSubnet
+	err = sqlStore.getBuilder(tx, &rawSubnet, subnetSelect.Where("ID = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnet by id")
+	}
+	subnet, err := rawSubnet.toSubnet()
+	if err != nil {
+		return nil, err
+	}
+
+	if subnet.IsQuarantined() {
+		return nil, errors.Wrapf(model.ErrSubnetUnavailable, "subnet %s is quarantined", subnet.CIDR)
+	}
 	if subnet.AccountID != "" {
-		return nil, errors.Errorf("The claimed subnet has already an assigned account")
-	} else {
-		subnet.AccountID = accountID
+		return nil, errors.Wrapf(model.ErrSubnetUnavailable, "subnet %s is claimed by account %s", subnet.CIDR, subnet.AccountID)
+	}
+	accounts, err := sqlStore.getLiveAccounts(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		if account.AccountMetadata == nil {
+			continue
+		}
+		if account.AccountMetadata.Subnet == subnet.CIDR || account.AccountMetadata.SubnetIPv6 == subnet.CIDR {
+			return nil, errors.Wrapf(model.ErrSubnetUnavailable, "subnet %s is used by account %s", subnet.CIDR, account.ID)
+		}
 	}
 
+	oldState := subnet.State()
+	subnet.ReservationOwner = owner
+	subnet.ReservationReason = reason
+	subnet.ReservationExpiresAt = expiresAt
+
 	if err = sqlStore.updateSubnet(tx, subnet); err != nil {
-		return nil, errors.Wrap(err, "failed to update subnet with account ID")
+		return nil, err
+	}
+	if oldState != model.SubnetStateReserved {
+		if err = sqlStore.releaseSubnetAllocation(tx, subnet.CIDR); err != nil {
+			return nil, err
+		}
+		if err = sqlStore.enqueueSubnetEvent(tx, subnet, oldState, model.SubnetStateReserved); err != nil {
+			return nil, err
+		}
 	}
 
 	err = tx.Commit()
@@ -117,31 +504,411 @@ func (sqlStore *SQLStore) ClaimSubnet(cidr string, accountID string) (*model.Sub
 	return subnet, nil
 }
 
-// SubnetCleanup is cleaning up a subnet making it available for claim.
-func (sqlStore *SQLStore) SubnetCleanup(cidr string) error {
-	tx, err := sqlStore.beginCustomTransaction(sqlStore.db, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+// deleteExpiredSubnetReservations releases the subnet reservations that have
+// expired, including the quarantines that are over.
+func (sqlStore *SQLStore) deleteExpiredSubnetReservations(db dbInterface) error {
+	var rawSubnets rawSubnets
+	err := sqlStore.selectBuilder(db, &rawSubnets, subnetSelect.
+		Where("ReservationOwner != ''").
+		Where("ReservationExpiresAt != 0").
+		Where("ReservationExpiresAt <= ?", GetMillis()),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to query for expired subnet reservations")
+	}
+	subnets, err := rawSubnets.toSubnets()
+	if err != nil {
+		return err
+	}
+
+	for _, subnet := range subnets {
+		_, err = sqlStore.execBuilder(db, sq.
+			Delete("SubnetPool").
+			Where("ID = ?", subnet.ID),
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete expired subnet reservation")
+		}
+		if err = sqlStore.enqueueSubnetEvent(db, subnet, subnet.State(), model.SubnetStateReleased); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SubnetCleanup is cleaning up a subnet making it available for claim. A claimed
+// subnet is kept in quarantine until quarantinedUntil, in milliseconds, if it is
+// in the future, and it cannot be claimed again before.
+func (sqlStore *SQLStore) SubnetCleanup(cidr string, quarantinedUntil int64) error {
+	return sqlStore.retryOnTransientError(func() error {
+		return sqlStore.subnetCleanup(cidr, quarantinedUntil)
+	})
+}
+
+func (sqlStore *SQLStore) subnetCleanup(cidr string, quarantinedUntil int64) error {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
 	if err != nil {
-		return errors.Wrap(err, "failed to begin the transaction")
+		return err
 	}
 	defer tx.RollbackUnlessCommitted()
 
-	subnet, err := sqlStore.getSubnetByCIDR(tx, cidr)
+	var rawSubnets rawSubnets
+	err = sqlStore.selectBuilder(tx, &rawSubnets, subnetSelect.Where("CIDR = ?", cidr))
 	if err != nil {
-		return errors.Wrap(err, "failed to get subnet by cidr")
+		return errors.Wrap(err, "failed to query for subnet")
+	}
+	subnets, err := rawSubnets.toSubnets()
+	if err != nil {
+		return err
 	}
 
-	subnet.AccountID = ""
-	if err = sqlStore.updateSubnet(tx, subnet); err != nil {
-		return errors.Wrap(err, "failed to update subnet with empty account id")
+	for _, subnet := range subnets {
+		if err = sqlStore.releaseSubnet(tx, subnet, quarantinedUntil); err != nil {
+			return err
+		}
+	}
+
+	if err = sqlStore.releaseSubnetAllocation(tx, cidr); err != nil {
+		return err
 	}
 
 	err = tx.Commit()
 	if err != nil {
 		return errors.Wrap(err, "failed to commit the transaction")
 	}
+
+	return nil
+}
+
+// releaseSubnet releases the given subnet, or keeps it in quarantine until
+// quarantinedUntil if the subnet is claimed and quarantinedUntil is in the
+// future. A subnet already in quarantine stays there, its quarantine being
+// extended to quarantinedUntil if that is later.
+func (sqlStore *SQLStore) releaseSubnet(db dbInterface, subnet *model.Subnet, quarantinedUntil int64) error {
+	if subnet.IsQuarantined() {
+		if quarantinedUntil <= subnet.ReservationExpiresAt {
+			return nil
+		}
+		subnet.ReservationExpiresAt = quarantinedUntil
+		if err := sqlStore.updateSubnet(db, subnet); err != nil {
+			return errors.Wrap(err, "failed to extend subnet quarantine")
+		}
+		return nil
+	}
+
+	if subnet.IsReserved() || quarantinedUntil <= GetMillis() {
+		_, err := sqlStore.execBuilder(db, sq.
+			Delete("SubnetPool").
+			Where("ID = ?", subnet.ID),
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to release subnet")
+		}
+		return sqlStore.enqueueSubnetEvent(db, subnet, subnet.State(), model.SubnetStateReleased)
+	}
+
+	if err := sqlStore.enqueueSubnetEvent(db, subnet, subnet.State(), model.SubnetStateQuarantined); err != nil {
+		return err
+	}
+	if subnet.AccountID != "" {
+		subnet.ReservationReason = fmt.Sprintf("Released by account %s", subnet.AccountID)
+	} else {
+		subnet.ReservationReason = "Released"
+	}
+	subnet.ReservationOwner = model.SubnetReservationOwnerQuarantine
+	subnet.ReservationExpiresAt = quarantinedUntil
+	subnet.AccountID = ""
+	if err := sqlStore.updateSubnet(db, subnet); err != nil {
+		return errors.Wrap(err, "failed to quarantine subnet")
+	}
+
 	return nil
 }
 
+// ReconcileSubnets compares the subnet claims with the subnets used by the live
+// accounts and returns the drifts found. Claims created after claimedBefore, in
+// milliseconds, are never considered orphaned so that the claims of accounts
+// being created are kept. If fix is true the drifts are fixed, except for the
+// conflicting claims which are left to an operator. Orphaned claims are released
+// like the subnets of deleted accounts, in quarantine until quarantinedUntil.
+func (sqlStore *SQLStore) ReconcileSubnets(fix bool, claimedBefore, quarantinedUntil int64) ([]*model.SubnetDrift, error) {
+	var drifts []*model.SubnetDrift
+	err := sqlStore.retryOnTransientError(func() error {
+		var err error
+		drifts, err = sqlStore.reconcileSubnets(fix, claimedBefore, quarantinedUntil)
+		return err
+	})
+
+	return drifts, err
+}
+
+func (sqlStore *SQLStore) reconcileSubnets(fix bool, claimedBefore, quarantinedUntil int64) ([]*model.SubnetDrift, error) {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.deleteExpiredSubnetReservations(tx); err != nil {
+		return nil, err
+	}
+
+	accounts, err := sqlStore.getLiveAccounts(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets, err := sqlStore.getSubnets(tx, &model.SubnetFilter{PerPage: model.AllPerPage, IncludeReserved: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get claimed subnets")
+	}
+	claims := make(map[string]*model.Subnet, len(subnets))
+	for _, subnet := range subnets {
+		claims[subnet.CIDR] = subnet
+	}
+
+	var drifts []*model.SubnetDrift
+	var usedCIDRs []string
+	usedBy := make(map[string]string)
+	for _, account := range accounts {
+		if account.AccountMetadata == nil {
+			continue
+		}
+		for _, cidr := range []string{account.AccountMetadata.Subnet, account.AccountMetadata.SubnetIPv6} {
+			if cidr == "" {
+				continue
+			}
+			if _, ok := usedBy[cidr]; ok {
+				drift := &model.SubnetDrift{Type: model.SubnetDriftConflictingClaim, CIDR: cidr, AccountID: account.ID}
+				if claim := claims[cidr]; claim != nil {
+					drift.SubnetID = claim.ID
+					drift.ClaimAccountID = claim.AccountID
+				}
+				drifts = append(drifts, drift)
+				continue
+			}
+			usedBy[cidr] = account.ID
+			usedCIDRs = append(usedCIDRs, cidr)
+		}
+	}
+
+	for _, cidr := range usedCIDRs {
+		accountID := usedBy[cidr]
+		claim := claims[cidr]
+		switch {
+		case claim == nil:
+			drift := &model.SubnetDrift{Type: model.SubnetDriftMissingClaim, CIDR: cidr, AccountID: accountID}
+			if fix {
+				subnet, err := sqlStore.getUnclaimedSubnet(tx, cidr)
+				if err != nil {
+					drift.Error = err.Error()
+					drifts = append(drifts, drift)
+					continue
+				}
+				subnet.AccountID = accountID
+				if err = sqlStore.addSubnet(tx, subnet); err != nil {
+					return nil, errors.Wrapf(err, "failed to claim subnet %s for account %s", cidr, accountID)
+				}
+				if err = sqlStore.recordSubnetAllocation(tx, subnet); err != nil {
+					return nil, err
+				}
+				if err = sqlStore.enqueueSubnetEvent(tx, subnet, "", model.SubnetStateClaimed); err != nil {
+					return nil, err
+				}
+				drift.Fixed = true
+			}
+			drifts = append(drifts, drift)
+		case claim.IsReserved():
+			drifts = append(drifts, &model.SubnetDrift{
+				Type:      model.SubnetDriftConflictingClaim,
+				CIDR:      cidr,
+				SubnetID:  claim.ID,
+				AccountID: accountID,
+			})
+		case claim.AccountID != accountID:
+			drift := &model.SubnetDrift{
+				Type:           model.SubnetDriftUnboundClaim,
+				CIDR:           cidr,
+				SubnetID:       claim.ID,
+				ClaimAccountID: claim.AccountID,
+				AccountID:      accountID,
+			}
+			if fix {
+				claim.AccountID = accountID
+				if err = sqlStore.updateSubnet(tx, claim); err != nil {
+					return nil, errors.Wrapf(err, "failed to bind subnet %s to account %s", cidr, accountID)
+				}
+				if err = sqlStore.bindSubnetAllocation(tx, cidr, accountID); err != nil {
+					return nil, err
+				}
+				drift.Fixed = true
+			}
+			drifts = append(drifts, drift)
+		}
+	}
+
+	for _, subnet := range subnets {
+		if subnet.IsReserved() || subnet.CreateAt > claimedBefore {
+			continue
+		}
+		if _, ok := usedBy[subnet.CIDR]; ok {
+			continue
+		}
+
+		drift := &model.SubnetDrift{
+			Type:           model.SubnetDriftOrphanedClaim,
+			CIDR:           subnet.CIDR,
+			SubnetID:       subnet.ID,
+			ClaimAccountID: subnet.AccountID,
+		}
+		if fix {
+			if err = sqlStore.releaseSubnet(tx, subnet, quarantinedUntil); err != nil {
+				return nil, errors.Wrapf(err, "failed to release orphaned subnet %s", subnet.CIDR)
+			}
+			if err = sqlStore.releaseSubnetAllocation(tx, subnet.CIDR); err != nil {
+				return nil, err
+			}
+			drift.Fixed = true
+		}
+		drifts = append(drifts, drift)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return drifts, nil
+}
+
+// MarkExternalSubnets compares the CIDR blocks of the given VPCs with the parent
+// subnets and returns the overlaps found. If mark is true the parts of the parent
+// subnets used by the VPCs are reserved as externally used so that they are never
+// claimed, except when they overlap subnets claimed or reserved in Genesis.
+func (sqlStore *SQLStore) MarkExternalSubnets(vpcs []*model.DiscoveredVPC, mark bool) ([]*model.SubnetOverlap, error) {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.deleteExpiredSubnetReservations(tx); err != nil {
+		return nil, err
+	}
+
+	parentSubnets, err := sqlStore.getParentSubnets(tx, &model.ParentSubnetFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnets")
+	}
+
+	subnets, prefixes, err := sqlStore.getAllocatedSubnets(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlaps []*model.SubnetOverlap
+	for _, vpc := range vpcs {
+		for _, cidr := range vpc.CIDRs {
+			prefix, err := ipam.ParsePrefix(cidr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse CIDR block %s of VPC %s", cidr, vpc.VPCID)
+			}
+
+			for _, parentSubnet := range parentSubnets {
+				parentPrefix, err := ipam.ParsePrefix(parentSubnet.CIDR)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to parse parent subnet %s", parentSubnet.ID)
+				}
+				if !parentPrefix.Overlaps(prefix) {
+					continue
+				}
+
+				// Prefixes are either nested or disjoint, so the part of the
+				// parent subnet used by the VPC is the smallest of the two.
+				used := prefix
+				if prefix.Contains(parentPrefix) {
+					used = parentPrefix
+				}
+
+				overlap := &model.SubnetOverlap{
+					AWSAccountID: vpc.AWSAccountID,
+					Region:       vpc.Region,
+					VPCID:        vpc.VPCID,
+					CIDR:         prefix.String(),
+					ParentSubnet: parentSubnet.CIDR,
+					SubnetCIDR:   used.String(),
+					Status:       model.SubnetOverlapMarked,
+				}
+				var marked, managed bool
+				for i, subnet := range subnets {
+					if !prefixes[i].Overlaps(used) {
+						continue
+					}
+					overlap.Subnets = append(overlap.Subnets, subnet.CIDR)
+					if subnet.CIDR == used.String() && subnet.ReservationOwner == model.SubnetReservationOwnerExternal {
+						marked = true
+					}
+					if !subnet.IsReserved() && prefixes[i].Contains(used) {
+						managed = true
+					}
+				}
+				switch {
+				case marked:
+					overlap.Status = model.SubnetOverlapAlreadyMarked
+				case managed:
+					overlap.Status = model.SubnetOverlapManaged
+				case len(overlap.Subnets) > 0:
+					overlap.Status = model.SubnetOverlapConflicting
+				}
+				overlaps = append(overlaps, overlap)
+
+				if !mark || overlap.Status != model.SubnetOverlapMarked {
+					continue
+				}
+
+				subnet := &model.Subnet{
+					CIDR:              used.String(),
+					ParentSubnet:      parentSubnet.CIDR,
+					ReservationOwner:  model.SubnetReservationOwnerExternal,
+					ReservationReason: fmt.Sprintf("VPC %s of AWS account %s in %s", vpc.VPCID, vpc.AWSAccountID, vpc.Region),
+				}
+				if err = sqlStore.addSubnet(tx, subnet); err != nil {
+					return nil, errors.Wrapf(err, "failed to mark subnet %s as externally used", subnet.CIDR)
+				}
+				if err = sqlStore.enqueueSubnetEvent(tx, subnet, "", model.SubnetStateReserved); err != nil {
+					return nil, err
+				}
+				subnets = append(subnets, subnet)
+				prefixes = append(prefixes, used)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return overlaps, nil
+}
+
+// getUnclaimedSubnet validates that the given CIDR is a free subnet of any parent
+// subnet and returns it.
+func (sqlStore *SQLStore) getUnclaimedSubnet(db dbInterface, cidr string) (*model.Subnet, error) {
+	prefix, err := ipam.ParsePrefix(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	pools, err := sqlStore.getParentSubnetPools(db, model.SubnetFamily(cidr), prefix.Len(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get parent subnet pools")
+	}
+
+	return sqlStore.getRequestedSubnet(db, pools, cidr)
+}
+
 // GetSubnet fetches the given subnet by subnet range.
 func (sqlStore *SQLStore) GetSubnet(id string) (*model.Subnet, error) {
 	var rawSubnet rawSubnet
@@ -160,14 +927,36 @@ func (sqlStore *SQLStore) GetSubnets(filter *model.SubnetFilter) ([]*model.Subne
 	return sqlStore.getSubnets(sqlStore.db, filter)
 }
 
-// GetSubnets fetches the given page of added subnets. The first page is 0.
+// getSubnets fetches the given page of claimed subnets, of reserved subnets or of
+// free subnets as requested by the filter. The first page is 0.
 func (sqlStore *SQLStore) getSubnets(db dbInterface, filter *model.SubnetFilter) ([]*model.Subnet, error) {
+	if filter.Free {
+		return sqlStore.getFreeSubnets(db, filter)
+	}
+
 	builder := subnetSelect.
 		OrderBy("CreateAt ASC")
 	builder = sqlStore.applySubnetsFilter(builder, filter)
 
-	if filter.Free {
-		builder = builder.Where("AccountID == '' ")
+	if filter.ParentSubnet != "" {
+		builder = builder.Where("ParentSubnet = ?", filter.ParentSubnet)
+	}
+
+	if filter.Quarantined {
+		builder = builder.Where("ReservationOwner = ?", model.SubnetReservationOwnerQuarantine)
+	} else if !filter.IncludeReserved {
+		if filter.Reserved {
+			builder = builder.Where("ReservationOwner != ''")
+		} else {
+			builder = builder.Where("ReservationOwner = ''")
+		}
+	}
+
+	switch filter.Family {
+	case model.SubnetFamilyIPv4:
+		builder = builder.Where("CIDR NOT LIKE ?", "%:%")
+	case model.SubnetFamilyIPv6:
+		builder = builder.Where("CIDR LIKE ?", "%:%")
 	}
 
 	var rawSubnets rawSubnets
@@ -179,6 +968,45 @@ func (sqlStore *SQLStore) getSubnets(db dbInterface, filter *model.SubnetFilter)
 	return rawSubnets.toSubnets()
 }
 
+// GetSubnetsContaining fetches the claimed and reserved subnets containing the
+// given IP address.
+func (sqlStore *SQLStore) GetSubnetsContaining(address string) ([]*model.Subnet, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, errors.Errorf("invalid IP address %s", address)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+	addressPrefix, err := ipam.ParsePrefix(fmt.Sprintf("%s/%d", ip, bits))
+	if err != nil {
+		return nil, err
+	}
+
+	subnets, err := sqlStore.getSubnets(sqlStore.db, &model.SubnetFilter{
+		PerPage:         model.AllPerPage,
+		Family:          model.SubnetFamily(ip.String()),
+		IncludeReserved: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var containing []*model.Subnet
+	for _, subnet := range subnets {
+		prefix, err := ipam.ParsePrefix(subnet.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse subnet %s", subnet.ID)
+		}
+		if prefix.Contains(addressPrefix) {
+			containing = append(containing, subnet)
+		}
+	}
+
+	return containing, nil
+}
+
 func (sqlStore *SQLStore) applySubnetsFilter(builder sq.SelectBuilder, filter *model.SubnetFilter) sq.SelectBuilder {
 	if filter.PerPage != model.AllPerPage {
 		builder = builder.
@@ -189,42 +1017,77 @@ func (sqlStore *SQLStore) applySubnetsFilter(builder sq.SelectBuilder, filter *m
 	return builder
 }
 
-// addSubnet records the given subnet to the database.
-func (sqlStore *SQLStore) addSubnets(execer execer, subnets *[]model.Subnet) error {
-	for _, subnet := range *subnets {
-		subnet.CreateAt = GetMillis()
-		subnet.ID = model.NewID()
-
-		_, err := sqlStore.execBuilder(execer, sq.
-			Insert("SubnetPool").
-			SetMap(map[string]interface{}{
-				"ID":             subnet.ID,
-				"CIDR":           subnet.CIDR,
-				"AccountID":      subnet.AccountID,
-				"ParentSubnet":   subnet.ParentSubnet,
-				"CreateAt":       subnet.CreateAt,
-				"LockAcquiredBy": nil,
-				"LockAcquiredAt": 0,
-			}),
-		)
-		if err != nil {
-			return errors.Wrap(err, "failed to add subnet")
-		}
+// addSubnet records the given claimed subnet to the database.
+func (sqlStore *SQLStore) addSubnet(execer execer, subnet *model.Subnet) error {
+	subnet.CreateAt = GetMillis()
+	subnet.ID = model.NewID()
+
+	_, err := sqlStore.execBuilder(execer, sq.
+		Insert("SubnetPool").
+		SetMap(map[string]interface{}{
+			"ID":                   subnet.ID,
+			"CIDR":                 subnet.CIDR,
+			"AccountID":            subnet.AccountID,
+			"ParentSubnet":         subnet.ParentSubnet,
+			"ReservationOwner":     subnet.ReservationOwner,
+			"ReservationReason":    subnet.ReservationReason,
+			"ReservationExpiresAt": subnet.ReservationExpiresAt,
+			"CreateAt":             subnet.CreateAt,
+			"LockAcquiredBy":       nil,
+			"LockAcquiredAt":       0,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to add subnet")
 	}
 
 	return nil
 }
 
-// UpdateSubnet updates the given subnet in the database.
+// UpdateSubnet updates the given subnet in the database. A subnet webhook is
+// sent when a subnet that was not reserved becomes reserved.
 func (sqlStore *SQLStore) UpdateSubnet(subnet *model.Subnet) error {
-	return sqlStore.updateSubnet(sqlStore.db, subnet)
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	var rawSubnet rawSubnet
+	err = sqlStore.getBuilder(tx, &rawSubnet, subnetSelect.Where("ID = ?", subnet.ID))
+	if err != nil {
+		return errors.Wrap(err, "failed to get subnet by id")
+	}
+	stored, err := rawSubnet.toSubnet()
+	if err != nil {
+		return err
+	}
+
+	if err = sqlStore.updateSubnet(tx, subnet); err != nil {
+		return err
+	}
+	if subnet.State() == model.SubnetStateReserved && stored.State() != model.SubnetStateReserved {
+		if err = sqlStore.enqueueSubnetEvent(tx, subnet, stored.State(), model.SubnetStateReserved); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
 }
 
 func (sqlStore *SQLStore) updateSubnet(db dbInterface, subnet *model.Subnet) error {
 	_, err := sqlStore.execBuilder(db, sq.
 		Update("SubnetPool").
 		SetMap(map[string]interface{}{
-			"AccountID": subnet.AccountID,
+			"AccountID":            subnet.AccountID,
+			"ReservationOwner":     subnet.ReservationOwner,
+			"ReservationReason":    subnet.ReservationReason,
+			"ReservationExpiresAt": subnet.ReservationExpiresAt,
 		}).
 		Where("ID = ?", subnet.ID),
 	)
@@ -0,0 +1,98 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+var subnetAllocationSelect sq.SelectBuilder
+
+func init() {
+	subnetAllocationSelect = sq.
+		Select("ID", "CIDR", "ParentSubnet", "AccountID", "ClaimedAt", "ReleasedAt").
+		From("SubnetAllocation")
+}
+
+// GetSubnetAllocations fetches the given page of subnet allocations, the most
+// recent first. The first page is 0.
+func (sqlStore *SQLStore) GetSubnetAllocations(filter *model.SubnetAllocationFilter) ([]*model.SubnetAllocation, error) {
+	builder := subnetAllocationSelect.
+		OrderBy("ClaimedAt DESC", "ID ASC")
+
+	if filter.PerPage != model.AllPerPage {
+		builder = builder.
+			Limit(uint64(filter.PerPage)).
+			Offset(uint64(filter.Page * filter.PerPage))
+	}
+
+	if filter.CIDR != "" {
+		builder = builder.Where("CIDR = ?", filter.CIDR)
+	}
+	if filter.AccountID != "" {
+		builder = builder.Where("AccountID = ?", filter.AccountID)
+	}
+
+	var allocations []*model.SubnetAllocation
+	err := sqlStore.selectBuilder(sqlStore.db, &allocations, builder)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query for subnet allocations")
+	}
+
+	return allocations, nil
+}
+
+// recordSubnetAllocation starts the allocation of the given claimed subnet.
+func (sqlStore *SQLStore) recordSubnetAllocation(execer execer, subnet *model.Subnet) error {
+	_, err := sqlStore.execBuilder(execer, sq.
+		Insert("SubnetAllocation").
+		SetMap(map[string]interface{}{
+			"ID":           model.NewID(),
+			"CIDR":         subnet.CIDR,
+			"ParentSubnet": subnet.ParentSubnet,
+			"AccountID":    subnet.AccountID,
+			"ClaimedAt":    subnet.CreateAt,
+			"ReleasedAt":   0,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to record subnet allocation")
+	}
+
+	return nil
+}
+
+// bindSubnetAllocation binds the current allocation of the given subnet to the
+// given account.
+func (sqlStore *SQLStore) bindSubnetAllocation(execer execer, cidr, accountID string) error {
+	_, err := sqlStore.execBuilder(execer, sq.
+		Update("SubnetAllocation").
+		Set("AccountID", accountID).
+		Where("CIDR = ?", cidr).
+		Where("ReleasedAt = 0"),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to bind subnet allocation")
+	}
+
+	return nil
+}
+
+// releaseSubnetAllocation ends the current allocation of the given subnet.
+func (sqlStore *SQLStore) releaseSubnetAllocation(execer execer, cidr string) error {
+	_, err := sqlStore.execBuilder(execer, sq.
+		Update("SubnetAllocation").
+		Set("ReleasedAt", GetMillis()).
+		Where("CIDR = ?", cidr).
+		Where("ReleasedAt = 0"),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to release subnet allocation")
+	}
+
+	return nil
+}
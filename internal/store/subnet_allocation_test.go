@@ -0,0 +1,104 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnetAllocations(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/23",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet)
+	require.NoError(t, err)
+
+	_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+
+	t.Run("claim is recorded", func(t *testing.T) {
+		allocations, err := sqlStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage, CIDR: "10.0.0.0/24"})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		require.Equal(t, "account1", allocations[0].AccountID)
+		require.Equal(t, parentSubnet.CIDR, allocations[0].ParentSubnet)
+		require.NotZero(t, allocations[0].ClaimedAt)
+		require.False(t, allocations[0].IsReleased())
+	})
+
+	t.Run("released subnet is quarantined", func(t *testing.T) {
+		quarantinedUntil := GetMillis() + int64(time.Hour/time.Millisecond)
+		err := sqlStore.SubnetCleanup("10.0.0.0/24", quarantinedUntil)
+		require.NoError(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, "10.0.0.0/24", subnets[0].CIDR)
+		require.True(t, subnets[0].IsQuarantined())
+		require.Equal(t, quarantinedUntil, subnets[0].ReservationExpiresAt)
+		require.Equal(t, "Released by account account1", subnets[0].ReservationReason)
+
+		free, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, free, 1)
+		require.Equal(t, "10.0.1.0/24", free[0].CIDR)
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.Error(t, err)
+
+		allocations, err := sqlStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage, AccountID: "account1"})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		require.True(t, allocations[0].IsReleased())
+	})
+
+	t.Run("subnet is claimable after the quarantine", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		subnets[0].ReservationExpiresAt = GetMillis() - 1
+		require.NoError(t, sqlStore.UpdateSubnet(subnets[0]))
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.NoError(t, err)
+
+		allocations, err := sqlStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage, CIDR: "10.0.0.0/24"})
+		require.NoError(t, err)
+		require.Len(t, allocations, 2)
+
+		allocations, err = sqlStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage, AccountID: "account2"})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		require.False(t, allocations[0].IsReleased())
+	})
+
+	t.Run("released subnet without quarantine is free", func(t *testing.T) {
+		err := sqlStore.SubnetCleanup("10.0.0.0/24", 0)
+		require.NoError(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, IncludeReserved: true})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+
+		allocations, err := sqlStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, allocations, 2)
+		for _, allocation := range allocations {
+			require.True(t, allocation.IsReleased())
+		}
+	})
+}
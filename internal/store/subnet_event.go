@@ -0,0 +1,49 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"time"
+
+	"github.com/mattermost/genesis/model"
+)
+
+// enqueueSubnetEvent adds a subnet webhook payload describing the transition of
+// the given subnet from oldState to newState to the outbox of the webhooks. A
+// released or quarantined subnet is passed as it was before its release, so
+// that the payload carries the account that held it.
+func (sqlStore *SQLStore) enqueueSubnetEvent(db dbInterface, subnet *model.Subnet, oldState, newState string) error {
+	extraData := map[string]string{
+		"CIDR":         subnet.CIDR,
+		"ParentSubnet": subnet.ParentSubnet,
+	}
+	if subnet.AccountID != "" {
+		extraData["AccountID"] = subnet.AccountID
+	}
+	if newState == model.SubnetStateReserved {
+		extraData["ReservationOwner"] = subnet.ReservationOwner
+		extraData["ReservationReason"] = subnet.ReservationReason
+	}
+
+	return sqlStore.enqueueWebhookPayload(db, &model.WebhookPayload{
+		Type:      model.TypeSubnet,
+		ID:        subnet.ID,
+		NewState:  newState,
+		OldState:  oldState,
+		Timestamp: time.Now().UnixNano(),
+		ExtraData: extraData,
+	})
+}
+
+// enqueueParentSubnetEvent adds a parent subnet webhook payload announcing the
+// given added parent subnet to the outbox of the webhooks.
+func (sqlStore *SQLStore) enqueueParentSubnetEvent(db dbInterface, parentSubnet *model.ParentSubnet) error {
+	return sqlStore.enqueueWebhookPayload(db, &model.WebhookPayload{
+		Type:      model.TypeParentSubnet,
+		ID:        parentSubnet.ID,
+		Timestamp: time.Now().UnixNano(),
+		ExtraData: map[string]string{"CIDR": parentSubnet.CIDR},
+	})
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnetEvents(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	require.NoError(t, sqlStore.AddParentSubnet(&parentSubnet))
+
+	webhook := &model.Webhook{
+		OwnerID:      "docs",
+		URL:          "https://url.com",
+		Subscription: &model.WebhookSubscription{Types: []string{model.TypeSubnet}},
+	}
+	require.NoError(t, sqlStore.CreateWebhook(webhook))
+
+	// nextEvent returns the oldest pending subnet webhook payload and marks it
+	// as delivered.
+	nextEvent := func(t *testing.T) *model.WebhookPayload {
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook.ID)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+		delivery.State = model.WebhookDeliveryStateDelivered
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+
+		return delivery.Payload
+	}
+
+	requireNoEvent := func(t *testing.T) {
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook.ID)
+		require.NoError(t, err)
+		require.Nil(t, delivery)
+	}
+
+	t.Run("claim", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+		require.NoError(t, err)
+
+		payload := nextEvent(t)
+		require.Equal(t, model.TypeSubnet, payload.Type)
+		require.Equal(t, subnet.ID, payload.ID)
+		require.Equal(t, model.SubnetStateClaimed, payload.NewState)
+		require.Empty(t, payload.OldState)
+		require.Equal(t, map[string]string{
+			"CIDR":         "10.0.0.0/24",
+			"ParentSubnet": parentSubnet.CIDR,
+			"AccountID":    "account1",
+		}, payload.ExtraData)
+		requireNoEvent(t)
+	})
+
+	quarantinedUntil := GetMillis() + int64(time.Hour/time.Millisecond)
+	t.Run("quarantine", func(t *testing.T) {
+		require.NoError(t, sqlStore.SubnetCleanup("10.0.0.0/24", quarantinedUntil))
+
+		payload := nextEvent(t)
+		require.Equal(t, model.SubnetStateQuarantined, payload.NewState)
+		require.Equal(t, model.SubnetStateClaimed, payload.OldState)
+		require.Equal(t, "account1", payload.ExtraData["AccountID"])
+		requireNoEvent(t)
+	})
+
+	t.Run("cleanup keeps quarantined subnet", func(t *testing.T) {
+		require.NoError(t, sqlStore.SubnetCleanup("10.0.0.0/24", 0))
+		requireNoEvent(t)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, quarantinedUntil, subnets[0].ReservationExpiresAt)
+
+		extendedUntil := quarantinedUntil + int64(time.Hour/time.Millisecond)
+		require.NoError(t, sqlStore.SubnetCleanup("10.0.0.0/24", extendedUntil))
+		requireNoEvent(t)
+
+		subnets, err = sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, extendedUntil, subnets[0].ReservationExpiresAt)
+	})
+
+	t.Run("reserve and release", func(t *testing.T) {
+		subnet, err := sqlStore.ReserveSubnet("10.0.1.0/24", "network-team", "on-prem link", 0)
+		require.NoError(t, err)
+
+		payload := nextEvent(t)
+		require.Equal(t, subnet.ID, payload.ID)
+		require.Equal(t, model.SubnetStateReserved, payload.NewState)
+		require.Equal(t, "network-team", payload.ExtraData["ReservationOwner"])
+		require.Equal(t, "on-prem link", payload.ExtraData["ReservationReason"])
+		require.Empty(t, payload.ExtraData["AccountID"])
+
+		require.NoError(t, sqlStore.SubnetCleanup("10.0.1.0/24", 0))
+		payload = nextEvent(t)
+		require.Equal(t, model.SubnetStateReleased, payload.NewState)
+		require.Equal(t, model.SubnetStateReserved, payload.OldState)
+		requireNoEvent(t)
+	})
+
+	t.Run("reserve claimed subnet", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.2.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.NoError(t, err)
+		nextEvent(t)
+
+		_, err = sqlStore.ReserveExistingSubnet(subnet.ID, "network-team", "", 0)
+		require.Equal(t, model.ErrSubnetUnavailable, errors.Cause(err))
+		requireNoEvent(t)
+
+		require.NoError(t, sqlStore.SubnetCleanup(subnet.CIDR, 0))
+		nextEvent(t)
+	})
+
+	t.Run("reserve unbound claim", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.1.0/24", Family: model.SubnetFamilyIPv4})
+		require.NoError(t, err)
+		nextEvent(t)
+
+		reserved, err := sqlStore.ReserveExistingSubnet(subnet.ID, "network-team", "", 0)
+		require.NoError(t, err)
+		require.Equal(t, "network-team", reserved.ReservationOwner)
+
+		payload := nextEvent(t)
+		require.Equal(t, model.SubnetStateReserved, payload.NewState)
+		require.Equal(t, model.SubnetStateClaimed, payload.OldState)
+		requireNoEvent(t)
+
+		reserved, err = sqlStore.ReserveExistingSubnet(subnet.ID, "network-team", "on-prem link", 0)
+		require.NoError(t, err)
+		require.Equal(t, "on-prem link", reserved.ReservationReason)
+		requireNoEvent(t)
+
+		allocations, err := sqlStore.GetSubnetAllocations(&model.SubnetAllocationFilter{PerPage: model.AllPerPage, CIDR: "10.0.1.0/24"})
+		require.NoError(t, err)
+		require.Len(t, allocations, 1)
+		require.True(t, allocations[0].IsReleased())
+	})
+
+	t.Run("reserve quarantined subnet", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+
+		_, err = sqlStore.ReserveExistingSubnet(subnets[0].ID, "network-team", "", 0)
+		require.Equal(t, model.ErrSubnetUnavailable, errors.Cause(err))
+		requireNoEvent(t)
+	})
+
+	t.Run("reserve unknown subnet", func(t *testing.T) {
+		subnet, err := sqlStore.ReserveExistingSubnet(model.NewID(), "network-team", "", 0)
+		require.NoError(t, err)
+		require.Nil(t, subnet)
+	})
+
+	t.Run("failed claim", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.1.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account3"})
+		require.Error(t, err)
+		requireNoEvent(t)
+	})
+
+	t.Run("expired quarantine", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		subnets[0].ReservationExpiresAt = GetMillis() - 1
+		require.NoError(t, sqlStore.UpdateSubnet(subnets[0]))
+		requireNoEvent(t)
+
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.3.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account4"})
+		require.NoError(t, err)
+
+		payload := nextEvent(t)
+		require.Equal(t, subnets[0].ID, payload.ID)
+		require.Equal(t, model.SubnetStateReleased, payload.NewState)
+		require.Equal(t, model.SubnetStateQuarantined, payload.OldState)
+
+		payload = nextEvent(t)
+		require.Equal(t, subnet.ID, payload.ID)
+		require.Equal(t, model.SubnetStateClaimed, payload.NewState)
+		requireNoEvent(t)
+	})
+
+	t.Run("orphaned claim", func(t *testing.T) {
+		drifts, err := sqlStore.ReconcileSubnets(true, GetMillis(), 0)
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.True(t, drifts[0].Fixed)
+
+		payload := nextEvent(t)
+		require.Equal(t, model.SubnetStateReleased, payload.NewState)
+		require.Equal(t, model.SubnetStateClaimed, payload.OldState)
+		require.Equal(t, "10.0.3.0/24", payload.ExtraData["CIDR"])
+		require.Equal(t, "account4", payload.ExtraData["AccountID"])
+		requireNoEvent(t)
+	})
+}
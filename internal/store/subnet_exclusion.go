@@ -0,0 +1,228 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/genesis/internal/ipam"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+var subnetExclusionSelect sq.SelectBuilder
+
+func init() {
+	subnetExclusionSelect = sq.
+		Select("ID", "CIDR", "Source", "Reason", "CreateAt").
+		From("SubnetExclusion")
+}
+
+// GetSubnetExclusion fetches the given subnet exclusion by id.
+func (sqlStore *SQLStore) GetSubnetExclusion(id string) (*model.SubnetExclusion, error) {
+	var exclusion model.SubnetExclusion
+	err := sqlStore.getBuilder(sqlStore.db, &exclusion,
+		subnetExclusionSelect.Where("ID = ?", id),
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get subnet exclusion by id")
+	}
+
+	return &exclusion, nil
+}
+
+// GetSubnetExclusions fetches the given page of subnet exclusions. The first page is 0.
+func (sqlStore *SQLStore) GetSubnetExclusions(filter *model.SubnetExclusionFilter) ([]*model.SubnetExclusion, error) {
+	return sqlStore.getSubnetExclusions(sqlStore.db, filter)
+}
+
+func (sqlStore *SQLStore) getSubnetExclusions(db dbInterface, filter *model.SubnetExclusionFilter) ([]*model.SubnetExclusion, error) {
+	builder := subnetExclusionSelect.
+		OrderBy("CreateAt ASC", "CIDR ASC")
+
+	if filter.PerPage != model.AllPerPage {
+		builder = builder.
+			Limit(uint64(filter.PerPage)).
+			Offset(uint64(filter.Page * filter.PerPage))
+	}
+
+	if filter.Source != "" {
+		builder = builder.Where("Source = ?", filter.Source)
+	}
+
+	var exclusions []*model.SubnetExclusion
+	err := sqlStore.selectBuilder(db, &exclusions, builder)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query for subnet exclusions")
+	}
+
+	return exclusions, nil
+}
+
+// getExcludedPrefixes fetches the parsed prefixes of all subnet exclusions.
+func (sqlStore *SQLStore) getExcludedPrefixes(db dbInterface) ([]*ipam.Prefix, error) {
+	exclusions, err := sqlStore.getSubnetExclusions(db, &model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []*ipam.Prefix
+	for _, exclusion := range exclusions {
+		prefix, err := ipam.ParsePrefix(exclusion.CIDR)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse subnet exclusion %s", exclusion.ID)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes, nil
+}
+
+// AddSubnetExclusion records the given subnet exclusion to the database, assigning it a unique ID.
+func (sqlStore *SQLStore) AddSubnetExclusion(exclusion *model.SubnetExclusion) error {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.addSubnetExclusion(tx, exclusion); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
+}
+
+func (sqlStore *SQLStore) addSubnetExclusion(execer execer, exclusion *model.SubnetExclusion) error {
+	prefix, err := ipam.ParsePrefix(exclusion.CIDR)
+	if err != nil {
+		return err
+	}
+	exclusion.CIDR = prefix.String()
+	exclusion.ID = model.NewID()
+	exclusion.CreateAt = GetMillis()
+
+	_, err = sqlStore.execBuilder(execer, sq.
+		Insert("SubnetExclusion").
+		SetMap(map[string]interface{}{
+			"ID":       exclusion.ID,
+			"CIDR":     exclusion.CIDR,
+			"Source":   exclusion.Source,
+			"Reason":   exclusion.Reason,
+			"CreateAt": exclusion.CreateAt,
+		}),
+	)
+	if isUniqueConstraintError(err) {
+		return errors.Wrapf(model.ErrSubnetExclusionExists, "range %s is already excluded", exclusion.CIDR)
+	} else if err != nil {
+		return errors.Wrap(err, "failed to add subnet exclusion")
+	}
+
+	return nil
+}
+
+// SeedSubnetExclusions makes the subnet exclusions of the given source match
+// the given ranges, adding the missing ones and deleting the ones no longer
+// given. Ranges seeded from another flag are taken over by the given source,
+// while ranges excluded through the API are left to the API.
+func (sqlStore *SQLStore) SeedSubnetExclusions(source string, cidrs []string, reason string) error {
+	tx, err := sqlStore.beginSubnetPoolTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	exclusions, err := sqlStore.getSubnetExclusions(tx, &model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]*model.SubnetExclusion, len(exclusions))
+	for _, exclusion := range exclusions {
+		existing[exclusion.CIDR] = exclusion
+	}
+
+	seeded := make(map[string]bool, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := ipam.ParsePrefix(cidr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse range %s", cidr)
+		}
+		seeded[prefix.String()] = true
+		if exclusion := existing[prefix.String()]; exclusion != nil {
+			if exclusion.IsSeeded() && exclusion.Source != source {
+				err = sqlStore.updateSubnetExclusionSource(tx, exclusion, source, reason)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		exclusion := &model.SubnetExclusion{CIDR: prefix.String(), Source: source, Reason: reason}
+		if err = sqlStore.addSubnetExclusion(tx, exclusion); err != nil {
+			return err
+		}
+		existing[exclusion.CIDR] = exclusion
+	}
+
+	for _, exclusion := range exclusions {
+		if exclusion.Source != source || seeded[exclusion.CIDR] {
+			continue
+		}
+		if err = sqlStore.deleteSubnetExclusion(tx, exclusion.ID); err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
+}
+
+func (sqlStore *SQLStore) updateSubnetExclusionSource(execer execer, exclusion *model.SubnetExclusion, source, reason string) error {
+	_, err := sqlStore.execBuilder(execer, sq.
+		Update("SubnetExclusion").
+		SetMap(map[string]interface{}{
+			"Source": source,
+			"Reason": reason,
+		}).
+		Where("ID = ?", exclusion.ID),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update subnet exclusion")
+	}
+	exclusion.Source = source
+	exclusion.Reason = reason
+
+	return nil
+}
+
+// DeleteSubnetExclusion removes the given subnet exclusion from the database.
+func (sqlStore *SQLStore) DeleteSubnetExclusion(id string) error {
+	return sqlStore.deleteSubnetExclusion(sqlStore.db, id)
+}
+
+func (sqlStore *SQLStore) deleteSubnetExclusion(execer execer, id string) error {
+	_, err := sqlStore.execBuilder(execer, sq.
+		Delete("SubnetExclusion").
+		Where("ID = ?", id),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete subnet exclusion")
+	}
+
+	return nil
+}
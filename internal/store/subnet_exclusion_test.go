@@ -0,0 +1,108 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"testing"
+
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnetExclusions(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	exclusion := &model.SubnetExclusion{CIDR: "10.0.1.7/24", Source: model.SubnetExclusionSourceAPI, Reason: "VPN"}
+	require.NoError(t, sqlStore.AddSubnetExclusion(exclusion))
+	require.Equal(t, "10.0.1.0/24", exclusion.CIDR)
+	require.NotEmpty(t, exclusion.ID)
+
+	fetched, err := sqlStore.GetSubnetExclusion(exclusion.ID)
+	require.NoError(t, err)
+	require.Equal(t, exclusion, fetched)
+
+	err = sqlStore.AddSubnetExclusion(&model.SubnetExclusion{CIDR: "10.0.1.0/24", Source: model.SubnetExclusionSourceAPI, Reason: "VPN"})
+	require.Equal(t, model.ErrSubnetExclusionExists, errors.Cause(err))
+
+	t.Run("seed", func(t *testing.T) {
+		err := sqlStore.SeedSubnetExclusions("cnc-cidrs", []string{"10.0.2.0/24", "10.0.1.0/24"}, "CnC")
+		require.NoError(t, err)
+
+		seeded, err := sqlStore.GetSubnetExclusions(&model.SubnetExclusionFilter{PerPage: model.AllPerPage, Source: "cnc-cidrs"})
+		require.NoError(t, err)
+		require.Len(t, seeded, 1)
+		require.Equal(t, "10.0.2.0/24", seeded[0].CIDR)
+		require.True(t, seeded[0].IsSeeded())
+
+		err = sqlStore.SeedSubnetExclusions("cnc-cidrs", []string{"10.0.3.0/24"}, "CnC")
+		require.NoError(t, err)
+
+		seeded, err = sqlStore.GetSubnetExclusions(&model.SubnetExclusionFilter{PerPage: model.AllPerPage, Source: "cnc-cidrs"})
+		require.NoError(t, err)
+		require.Len(t, seeded, 1)
+		require.Equal(t, "10.0.3.0/24", seeded[0].CIDR)
+
+		all, err := sqlStore.GetSubnetExclusions(&model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+	})
+
+	t.Run("seed takes over ranges of other flags", func(t *testing.T) {
+		err := sqlStore.SeedSubnetExclusions("bind-ips", []string{"10.0.3.0/24"}, "Bind")
+		require.NoError(t, err)
+		err = sqlStore.SeedSubnetExclusions("cnc-cidrs", nil, "CnC")
+		require.NoError(t, err)
+
+		seeded, err := sqlStore.GetSubnetExclusions(&model.SubnetExclusionFilter{PerPage: model.AllPerPage, Source: "bind-ips"})
+		require.NoError(t, err)
+		require.Len(t, seeded, 1)
+		require.Equal(t, "10.0.3.0/24", seeded[0].CIDR)
+		require.Equal(t, "Bind", seeded[0].Reason)
+
+		all, err := sqlStore.GetSubnetExclusions(&model.SubnetExclusionFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+	})
+
+	t.Run("claims skip excluded ranges", func(t *testing.T) {
+		parentSubnet := model.ParentSubnet{ID: model.NewID(), CIDR: "10.0.0.0/22", SplitRange: 24}
+		require.NoError(t, sqlStore.AddParentSubnet(&parentSubnet))
+
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.1.0/24", Family: model.SubnetFamilyIPv4})
+		require.Error(t, err)
+
+		usage, err := sqlStore.GetParentSubnetUsage(parentSubnet.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), usage.Free.Int64())
+		require.Equal(t, int64(2), usage.Excluded.Int64())
+
+		var claimed []string
+		for i := 0; i < 2; i++ {
+			subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AllocationStrategy: model.SubnetAllocationSequential})
+			require.NoError(t, err)
+			claimed = append(claimed, subnet.CIDR)
+		}
+		require.Equal(t, []string{"10.0.0.0/24", "10.0.2.0/24"}, claimed)
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4})
+		require.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, sqlStore.DeleteSubnetExclusion(exclusion.ID))
+
+		fetched, err := sqlStore.GetSubnetExclusion(exclusion.ID)
+		require.NoError(t, err)
+		require.Nil(t, fetched)
+
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.1.0/24", subnet.CIDR)
+	})
+}
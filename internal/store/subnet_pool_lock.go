@@ -0,0 +1,99 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+const (
+	// subnetPoolLockID is the ID of the SubnetPoolLock row that serializes
+	// the changes to the subnet pool across all Genesis instances.
+	subnetPoolLockID = "SubnetPool"
+
+	// maxTransientErrorAttempts is the number of times a subnet pool change
+	// is attempted when it fails with a transient database error.
+	maxTransientErrorAttempts = 5
+	// transientErrorBackoff is the delay before retrying a subnet pool change,
+	// multiplied by the number of attempts so far.
+	transientErrorBackoff = 50 * time.Millisecond
+)
+
+// beginSubnetPoolTransaction begins a transaction holding the subnet pool lock
+// until it is committed or rolled back.
+//
+// The lock is taken by updating the lock row before anything else is read. On
+// Postgres concurrent transactions block on the row and, running at read
+// committed, read the subnets committed in the meantime once they get it. On
+// sqlite the transaction holds the database write lock from its first
+// statement instead of upgrading a read snapshot that may have gone stale.
+func (sqlStore *SQLStore) beginSubnetPoolTransaction() (*Transaction, error) {
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to begin the transaction")
+	}
+
+	_, err = sqlStore.execBuilder(tx, sq.
+		Update("SubnetPoolLock").
+		Set("LockedAt", GetMillis()).
+		Where("ID = ?", subnetPoolLockID),
+	)
+	if err != nil {
+		tx.RollbackUnlessCommitted()
+		return nil, errors.Wrap(err, "failed to lock the subnet pool")
+	}
+
+	return tx, nil
+}
+
+// retryOnTransientError runs the given subnet pool change again when it fails
+// because the database was busy or aborted it to resolve a conflict.
+func (sqlStore *SQLStore) retryOnTransientError(change func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxTransientErrorAttempts; attempt++ {
+		err = change()
+		if err == nil || !isTransientError(err) {
+			return err
+		}
+
+		sqlStore.logger.WithError(err).Debugf("Retrying subnet pool change after transient error, attempt %d", attempt)
+		time.Sleep(time.Duration(attempt) * transientErrorBackoff)
+	}
+
+	return err
+}
+
+// isTransientError returns true if the error is a database error that goes away
+// when the transaction is retried.
+func isTransientError(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case sqlite3.Error:
+		return cause.Code == sqlite3.ErrBusy || cause.Code == sqlite3.ErrLocked
+	case *pq.Error:
+		// serialization_failure and deadlock_detected
+		return cause.Code == "40001" || cause.Code == "40P01"
+	}
+
+	return false
+}
+
+// isUniqueConstraintError returns true if the error is a database error caused
+// by a row violating a unique index.
+func isUniqueConstraintError(err error) bool {
+	switch cause := errors.Cause(err).(type) {
+	case sqlite3.Error:
+		return cause.ExtendedCode == sqlite3.ErrConstraintUnique
+	case *pq.Error:
+		// unique_violation
+		return cause.Code == "23505"
+	}
+
+	return false
+}
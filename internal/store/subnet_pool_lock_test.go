@@ -0,0 +1,251 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mattermost/genesis/internal/ipam"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeConcurrentTestSQLStores creates the given number of migrated stores
+// sharing the database of the given DSN, as several Genesis instances would.
+func makeConcurrentTestSQLStores(t *testing.T, dsn string, instances int) []*SQLStore {
+	logger := testlib.MakeLogger(t)
+
+	var sqlStores []*SQLStore
+	for i := 0; i < instances; i++ {
+		sqlStore, err := New(dsn, logger)
+		require.NoError(t, err)
+		t.Cleanup(func() { CloseConnection(t, sqlStore) })
+		sqlStores = append(sqlStores, sqlStore)
+	}
+	require.NoError(t, sqlStores[0].Migrate())
+
+	return sqlStores
+}
+
+// concurrentTestDSNs returns the databases the concurrency tests run against.
+// The stores need their own connections to a shared database, which neither
+// the in-memory sqlite database nor the temporary postgres schema of the other
+// tests allow, so an sqlite database on disk is used along with, when
+// GENESIS_DATABASE is a postgres database, a dedicated postgres schema.
+func concurrentTestDSNs(t *testing.T) map[string]string {
+	name := fmt.Sprintf("%s.db", model.NewID())
+	t.Cleanup(func() {
+		for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+			os.Remove(name + suffix) //nolint
+		}
+	})
+	dsns := map[string]string{"sqlite": fmt.Sprintf("sqlite3://file:%s", name)}
+
+	dsn := os.Getenv("GENESIS_DATABASE")
+	dsnURL, err := url.Parse(dsn)
+	require.NoError(t, err)
+	if dsnURL.Scheme != "postgres" && dsnURL.Scheme != "postgresql" {
+		return dsns
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	require.NoError(t, err)
+	schema := fmt.Sprintf("genesis_test_%s", model.NewID())
+	_, err = db.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := db.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		assert.NoError(t, err)
+		assert.NoError(t, db.Close())
+	})
+
+	q := dsnURL.Query()
+	q.Set("search_path", schema)
+	dsnURL.RawQuery = q.Encode()
+	dsns["postgres"] = dsnURL.String()
+
+	return dsns
+}
+
+// TestClaimSubnetConcurrently claims subnets of mixed sizes from several stores
+// sharing the same database until the parent subnet is exhausted.
+func TestClaimSubnetConcurrently(t *testing.T) {
+	for name, dsn := range concurrentTestDSNs(t) {
+		t.Run(name, func(t *testing.T) {
+			testClaimSubnetConcurrently(t, dsn)
+		})
+	}
+}
+
+func testClaimSubnetConcurrently(t *testing.T, dsn string) {
+	const instances = 4
+	const claimersPerInstance = 4
+
+	sqlStores := makeConcurrentTestSQLStores(t, dsn, instances)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/20",
+		SplitRange: 24,
+	}
+	require.NoError(t, sqlStores[0].AddParentSubnet(&parentSubnet))
+
+	strategies := []string{model.SubnetAllocationSequential, model.SubnetAllocationBestFit}
+	var lock sync.Mutex
+	var claimed []*model.Subnet
+	var wg sync.WaitGroup
+	for i, sqlStore := range sqlStores {
+		for j := 0; j < claimersPerInstance; j++ {
+			wg.Add(1)
+			go func(sqlStore *SQLStore, claimer int) {
+				defer wg.Done()
+
+				// Alternate /24 and /23 claims so that overlapping subnets of
+				// different sizes would not be caught by the CIDR unique index.
+				prefixLength := 24
+				if claimer%2 == 1 {
+					prefixLength = 23
+				}
+				for {
+					subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{
+						Family:             model.SubnetFamilyIPv4,
+						AccountID:          model.NewID(),
+						PrefixLength:       prefixLength,
+						AllocationStrategy: strategies[claimer%len(strategies)],
+					})
+					if err != nil {
+						assert.Contains(t, err.Error(), "no capacity")
+						return
+					}
+
+					lock.Lock()
+					claimed = append(claimed, subnet)
+					lock.Unlock()
+				}
+			}(sqlStore, i*claimersPerInstance+j)
+		}
+	}
+	wg.Wait()
+
+	var prefixes []*ipam.Prefix
+	addresses := 0
+	for _, subnet := range claimed {
+		prefix, err := ipam.ParsePrefix(subnet.CIDR)
+		require.NoError(t, err)
+		for _, other := range prefixes {
+			require.False(t, prefix.Overlaps(other), "subnet %s assigned twice", prefix)
+		}
+		prefixes = append(prefixes, prefix)
+		addresses += int(prefix.Size().Int64())
+	}
+
+	stored, err := sqlStores[0].GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage})
+	require.NoError(t, err)
+	require.Len(t, stored, len(claimed))
+
+	// Only /23 claims can fail while /24 subnets are left, so the parent
+	// subnet is exhausted unless a single /24 remains.
+	require.GreaterOrEqual(t, addresses, 4096-256)
+}
+
+// TestAddParentSubnetConcurrently adds overlapping parent subnets from several
+// stores sharing the same database: only one of them may be added.
+func TestAddParentSubnetConcurrently(t *testing.T) {
+	for name, dsn := range concurrentTestDSNs(t) {
+		t.Run(name, func(t *testing.T) {
+			sqlStores := makeConcurrentTestSQLStores(t, dsn, 4)
+
+			cidrs := []string{"10.0.0.0/20", "10.0.0.0/21", "10.0.4.0/22", "10.0.6.0/23"}
+			var lock sync.Mutex
+			var added []string
+			var wg sync.WaitGroup
+			for i, cidr := range cidrs {
+				wg.Add(1)
+				go func(sqlStore *SQLStore, cidr string) {
+					defer wg.Done()
+
+					err := sqlStore.AddParentSubnet(&model.ParentSubnet{ID: model.NewID(), CIDR: cidr, SplitRange: 24})
+					if err != nil {
+						assert.Equal(t, model.ErrParentSubnetOverlap, errors.Cause(err))
+						return
+					}
+
+					lock.Lock()
+					added = append(added, cidr)
+					lock.Unlock()
+				}(sqlStores[i%len(sqlStores)], cidr)
+			}
+			wg.Wait()
+
+			require.Len(t, added, 1)
+			parentSubnets, err := sqlStores[0].GetParentSubnets(&model.ParentSubnetFilter{PerPage: model.AllPerPage})
+			require.NoError(t, err)
+			require.Len(t, parentSubnets, 1)
+			require.Equal(t, added[0], parentSubnets[0].CIDR)
+		})
+	}
+}
+
+func TestRetryOnTransientError(t *testing.T) {
+	sqlStore := &SQLStore{logger: testlib.MakeLogger(t)}
+
+	for _, code := range []pq.ErrorCode{"40001", "40P01"} {
+		t.Run(string(code), func(t *testing.T) {
+			attempts := 0
+			err := sqlStore.retryOnTransientError(func() error {
+				attempts++
+				if attempts < 3 {
+					return errors.Wrap(&pq.Error{Code: code}, "failed to claim subnet")
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, 3, attempts)
+		})
+	}
+
+	t.Run("sqlite busy", func(t *testing.T) {
+		attempts := 0
+		err := sqlStore.retryOnTransientError(func() error {
+			attempts++
+			if attempts == 1 {
+				return sqlite3.Error{Code: sqlite3.ErrBusy}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		attempts := 0
+		err := sqlStore.retryOnTransientError(func() error {
+			attempts++
+			return &pq.Error{Code: "23505"}
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("gives up after the maximum attempts", func(t *testing.T) {
+		attempts := 0
+		err := sqlStore.retryOnTransientError(func() error {
+			attempts++
+			return &pq.Error{Code: "40001"}
+		})
+		require.Error(t, err)
+		require.Equal(t, maxTransientErrorAttempts, attempts)
+	})
+}
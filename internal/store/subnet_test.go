@@ -0,0 +1,671 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimSubnet(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnetIPv4 := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/23",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnetIPv4)
+	require.NoError(t, err)
+
+	parentSubnetIPv6 := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "2600:1f18:1000::/55",
+		SplitRange: 56,
+	}
+	err = sqlStore.AddParentSubnet(&parentSubnetIPv6)
+	require.NoError(t, err)
+
+	t.Run("claim random ipv4 subnet", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+		require.NoError(t, err)
+		require.Equal(t, model.SubnetFamilyIPv4, subnet.Family())
+		require.Equal(t, "account1", subnet.AccountID)
+	})
+
+	t.Run("claim random ipv6 subnet", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv6, AccountID: "account1"})
+		require.NoError(t, err)
+		require.Equal(t, model.SubnetFamilyIPv6, subnet.Family())
+		require.Equal(t, "account1", subnet.AccountID)
+	})
+
+	t.Run("claim specific ipv6 subnet", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true, Family: model.SubnetFamilyIPv6})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: subnets[0].CIDR, Family: model.SubnetFamilyIPv6, AccountID: "account2"})
+		require.NoError(t, err)
+		require.Equal(t, subnets[0].CIDR, subnet.CIDR)
+		require.Equal(t, parentSubnetIPv6.CIDR, subnet.ParentSubnet)
+	})
+
+	t.Run("claim already claimed subnet", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Family: model.SubnetFamilyIPv6})
+		require.NoError(t, err)
+		require.Len(t, subnets, 2)
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: subnets[0].CIDR, Family: model.SubnetFamilyIPv6, AccountID: "account3"})
+		require.Equal(t, model.ErrSubnetUnavailable, errors.Cause(err))
+	})
+
+	t.Run("claim subnet of the wrong size", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/25", Family: model.SubnetFamilyIPv4, AccountID: "account3"})
+		require.Equal(t, model.ErrSubnetOutsideParentSubnets, errors.Cause(err))
+	})
+
+	t.Run("claim subnet of the wrong family", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.1.0/24", Family: model.SubnetFamilyIPv6, AccountID: "account2"})
+		require.Error(t, err)
+	})
+
+	t.Run("claim unknown subnet", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.1.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.Error(t, err)
+	})
+
+	t.Run("no free ipv6 subnets", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv6, AccountID: "account3"})
+		require.Error(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, model.SubnetFamilyIPv4, subnets[0].Family())
+	})
+
+	t.Run("cleanup ipv6 subnet", func(t *testing.T) {
+		err := sqlStore.SubnetCleanup("2600:1f18:1000:100::/56", 0)
+		require.NoError(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true, Family: model.SubnetFamilyIPv6})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, "2600:1f18:1000:100::/56", subnets[0].CIDR)
+
+		subnets, err = sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Family: model.SubnetFamilyIPv6})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+	})
+}
+
+func TestClaimSubnetPrefixLength(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	smallParentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&smallParentSubnet)
+	require.NoError(t, err)
+
+	largeParentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.1.0.0/20",
+		SplitRange: 24,
+	}
+	err = sqlStore.AddParentSubnet(&largeParentSubnet)
+	require.NoError(t, err)
+
+	t.Run("claim large subnets", func(t *testing.T) {
+		first, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account1", PrefixLength: 21})
+		require.NoError(t, err)
+		require.Equal(t, largeParentSubnet.CIDR, first.ParentSubnet)
+
+		second, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account2", PrefixLength: 21})
+		require.NoError(t, err)
+		require.Equal(t, largeParentSubnet.CIDR, second.ParentSubnet)
+		require.ElementsMatch(t, []string{"10.1.0.0/21", "10.1.8.0/21"}, []string{first.CIDR, second.CIDR})
+	})
+
+	t.Run("no capacity for large subnet", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account3", PrefixLength: 21})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no capacity for /21 ipv4 subnets")
+	})
+
+	t.Run("claim specific subnet of the requested size", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/23", Family: model.SubnetFamilyIPv4, AccountID: "account3", PrefixLength: 23})
+		require.NoError(t, err)
+		require.Equal(t, smallParentSubnet.CIDR, subnet.ParentSubnet)
+	})
+
+	t.Run("small and large subnets share the parent subnet", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account4"})
+		require.NoError(t, err)
+		require.Contains(t, []string{"10.0.2.0/24", "10.0.3.0/24"}, subnet.CIDR)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account5", PrefixLength: 23})
+		require.Error(t, err)
+	})
+}
+
+func TestClaimSubnetAllocationStrategies(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	largeParentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&largeParentSubnet)
+	require.NoError(t, err)
+
+	smallParentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.1.0.0/23",
+		SplitRange: 24,
+	}
+	err = sqlStore.AddParentSubnet(&smallParentSubnet)
+	require.NoError(t, err)
+
+	claim := func(strategy string) (*model.Subnet, error) {
+		return sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: model.NewID(), AllocationStrategy: strategy})
+	}
+
+	t.Run("unsupported strategy", func(t *testing.T) {
+		_, err := claim("unknown")
+		require.Error(t, err)
+	})
+
+	t.Run("sequential", func(t *testing.T) {
+		subnet, err := claim(model.SubnetAllocationSequential)
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.0/24", subnet.CIDR)
+
+		subnet, err = claim(model.SubnetAllocationSequential)
+		require.NoError(t, err)
+		require.Equal(t, "10.0.1.0/24", subnet.CIDR)
+	})
+
+	t.Run("spread", func(t *testing.T) {
+		subnet, err := claim(model.SubnetAllocationSpread)
+		require.NoError(t, err)
+		require.Equal(t, "10.1.1.0/24", subnet.CIDR)
+	})
+
+	t.Run("best fit", func(t *testing.T) {
+		subnet, err := claim(model.SubnetAllocationBestFit)
+		require.NoError(t, err)
+		require.Equal(t, "10.1.0.0/24", subnet.CIDR)
+	})
+
+	t.Run("parent subnet strategy", func(t *testing.T) {
+		err := sqlStore.UpdateParentSubnetAllocationStrategy(largeParentSubnet.ID, model.SubnetAllocationSequential)
+		require.NoError(t, err)
+
+		parentSubnet, err := sqlStore.GetParentSubnet(largeParentSubnet.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.SubnetAllocationSequential, parentSubnet.AllocationStrategy)
+
+		subnet, err := claim(model.SubnetAllocationRandom)
+		require.NoError(t, err)
+		require.Equal(t, "10.0.2.0/24", subnet.CIDR)
+	})
+}
+
+func TestClaimSubnetPoolSelector(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	productionParentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/23",
+		SplitRange: 24,
+		Labels:     map[string]string{"environment": "production"},
+	}
+	err := sqlStore.AddParentSubnet(&productionParentSubnet)
+	require.NoError(t, err)
+
+	sandboxParentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.1.0.0/23",
+		SplitRange: 24,
+		Labels:     map[string]string{"environment": "sandbox"},
+	}
+	err = sqlStore.AddParentSubnet(&sandboxParentSubnet)
+	require.NoError(t, err)
+
+	selector := map[string]string{"environment": "sandbox"}
+
+	t.Run("claim from matching parent subnets only", func(t *testing.T) {
+		for _, accountID := range []string{"account1", "account2"} {
+			subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: accountID, PoolSelector: selector})
+			require.NoError(t, err)
+			require.Equal(t, sandboxParentSubnet.CIDR, subnet.ParentSubnet)
+		}
+	})
+
+	t.Run("matching parent subnets exhausted", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account3", PoolSelector: selector})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "in parent subnets matching")
+	})
+
+	t.Run("no parent subnet matches", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account3", PoolSelector: map[string]string{"environment": "staging"}})
+		require.Error(t, err)
+	})
+
+	t.Run("claim without selector", func(t *testing.T) {
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account3"})
+		require.NoError(t, err)
+		require.Equal(t, productionParentSubnet.CIDR, subnet.ParentSubnet)
+	})
+
+	t.Run("update labels", func(t *testing.T) {
+		err := sqlStore.UpdateParentSubnetLabels(productionParentSubnet.ID, selector)
+		require.NoError(t, err)
+
+		parentSubnet, err := sqlStore.GetParentSubnet(productionParentSubnet.ID)
+		require.NoError(t, err)
+		require.Equal(t, selector, parentSubnet.Labels)
+
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account4", PoolSelector: selector})
+		require.NoError(t, err)
+		require.Equal(t, productionParentSubnet.CIDR, subnet.ParentSubnet)
+	})
+}
+
+func TestFreeSubnets(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet1 := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet1)
+	require.NoError(t, err)
+
+	parentSubnet2 := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.128.0.0/9",
+		SplitRange: 28,
+	}
+	err = sqlStore.AddParentSubnet(&parentSubnet2)
+	require.NoError(t, err)
+
+	_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.1.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+	require.NoError(t, err)
+
+	t.Run("first page spans parent subnets", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{Page: 0, PerPage: 4, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 4)
+		require.Equal(t, "10.0.0.0/24", subnets[0].CIDR)
+		require.Equal(t, "10.0.2.0/24", subnets[1].CIDR)
+		require.Equal(t, "10.0.3.0/24", subnets[2].CIDR)
+		require.Equal(t, "10.128.0.0/28", subnets[3].CIDR)
+		require.Equal(t, parentSubnet2.CIDR, subnets[3].ParentSubnet)
+	})
+
+	t.Run("deep page of a large parent subnet", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{Page: 100000, PerPage: 2, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 2)
+		require.Equal(t, "10.176.211.208/28", subnets[0].CIDR)
+		require.Equal(t, "10.176.211.224/28", subnets[1].CIDR)
+	})
+
+	t.Run("pages of free subnets are bounded", func(t *testing.T) {
+		_, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Free: true})
+		require.Error(t, err)
+
+		_, err = sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage + 1, Free: true})
+		require.Error(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, model.MaxFreeSubnetsPerPage)
+	})
+
+	t.Run("page past the end", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{Page: 1 << 20, PerPage: 100, Free: true})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+	})
+
+	t.Run("claimed subnets are not free", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, subnets, 1)
+		require.Equal(t, "10.0.1.0/24", subnets[0].CIDR)
+		require.Equal(t, "account1", subnets[0].AccountID)
+	})
+}
+
+func TestReserveSubnet(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet)
+	require.NoError(t, err)
+
+	t.Run("reserve free subnet", func(t *testing.T) {
+		subnet, err := sqlStore.ReserveSubnet("10.0.0.0/24", "network-team", "on-prem link", 0)
+		require.NoError(t, err)
+		require.True(t, subnet.IsReserved())
+		require.Equal(t, parentSubnet.CIDR, subnet.ParentSubnet)
+	})
+
+	t.Run("reserve subnet of another size", func(t *testing.T) {
+		_, err := sqlStore.ReserveSubnet("10.0.1.0/25", "network-team", "partner peer", 0)
+		require.NoError(t, err)
+	})
+
+	t.Run("reserve already reserved subnet", func(t *testing.T) {
+		_, err := sqlStore.ReserveSubnet("10.0.0.0/24", "network-team", "on-prem link", 0)
+		require.Error(t, err)
+	})
+
+	t.Run("reserve expired reservation again", func(t *testing.T) {
+		_, err := sqlStore.ReserveSubnet("10.0.2.0/24", "network-team", "temporary", 1)
+		require.NoError(t, err)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.MaxFreeSubnetsPerPage, Free: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 2)
+
+		subnet, err := sqlStore.ReserveSubnet("10.0.2.0/24", "network-team", "cnc vpc", 0)
+		require.NoError(t, err)
+		require.Equal(t, "cnc vpc", subnet.ReservationReason)
+	})
+
+	t.Run("reserved subnets are listed separately", func(t *testing.T) {
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Reserved: true})
+		require.NoError(t, err)
+		require.Len(t, subnets, 3)
+
+		subnets, err = sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Empty(t, subnets)
+	})
+
+	t.Run("reserved subnets cannot be claimed", func(t *testing.T) {
+		_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+		require.Error(t, err)
+
+		subnet, err := sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account1"})
+		require.NoError(t, err)
+		require.Equal(t, "10.0.3.0/24", subnet.CIDR)
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{Family: model.SubnetFamilyIPv4, AccountID: "account2"})
+		require.Error(t, err)
+	})
+}
+
+func TestReconcileSubnets(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	parentSubnet := model.ParentSubnet{
+		ID:         model.NewID(),
+		CIDR:       "10.0.0.0/22",
+		SplitRange: 24,
+	}
+	err := sqlStore.AddParentSubnet(&parentSubnet)
+	require.NoError(t, err)
+
+	createAccount := func(cidr string) *model.Account {
+		account := &model.Account{
+			Provider:        model.ProviderAWS,
+			AccountMetadata: &model.AccountMetadata{Subnet: cidr},
+			State:           model.AccountStateStable,
+		}
+		require.NoError(t, sqlStore.CreateAccount(account))
+		return account
+	}
+
+	unboundAccount := createAccount("10.0.0.0/24")
+	_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4})
+	require.NoError(t, err)
+
+	missingAccount := createAccount("10.0.1.0/24")
+
+	conflictingAccount := createAccount("10.0.2.0/24")
+	_, err = sqlStore.ReserveSubnet("10.0.2.0/24", "network-team", "on-prem link", 0)
+	require.NoError(t, err)
+
+	deletedAccount := createAccount("10.0.3.0/24")
+	orphaned, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.3.0/24", Family: model.SubnetFamilyIPv4, AccountID: deletedAccount.ID})
+	require.NoError(t, err)
+	require.NoError(t, sqlStore.DeleteAccount(deletedAccount.ID))
+
+	driftsByType := func(drifts []*model.SubnetDrift) map[string]*model.SubnetDrift {
+		byType := make(map[string]*model.SubnetDrift)
+		for _, drift := range drifts {
+			byType[drift.Type] = drift
+		}
+		return byType
+	}
+
+	t.Run("recent claims are not orphaned", func(t *testing.T) {
+		drifts, err := sqlStore.ReconcileSubnets(false, orphaned.CreateAt-1, 0)
+		require.NoError(t, err)
+		require.NotContains(t, driftsByType(drifts), model.SubnetDriftOrphanedClaim)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		drifts, err := sqlStore.ReconcileSubnets(false, GetMillis(), 0)
+		require.NoError(t, err)
+		require.Len(t, drifts, 4)
+
+		byType := driftsByType(drifts)
+		require.Equal(t, unboundAccount.ID, byType[model.SubnetDriftUnboundClaim].AccountID)
+		require.Equal(t, "", byType[model.SubnetDriftUnboundClaim].ClaimAccountID)
+		require.Equal(t, missingAccount.ID, byType[model.SubnetDriftMissingClaim].AccountID)
+		require.Equal(t, conflictingAccount.ID, byType[model.SubnetDriftConflictingClaim].AccountID)
+		require.Equal(t, deletedAccount.ID, byType[model.SubnetDriftOrphanedClaim].ClaimAccountID)
+		for _, drift := range drifts {
+			require.False(t, drift.Fixed)
+		}
+	})
+
+	t.Run("fix", func(t *testing.T) {
+		quarantinedUntil := GetMillis() + int64(time.Hour/time.Millisecond)
+		drifts, err := sqlStore.ReconcileSubnets(true, GetMillis(), quarantinedUntil)
+		require.NoError(t, err)
+		require.Len(t, drifts, 4)
+
+		byType := driftsByType(drifts)
+		require.True(t, byType[model.SubnetDriftUnboundClaim].Fixed)
+		require.True(t, byType[model.SubnetDriftMissingClaim].Fixed)
+		require.True(t, byType[model.SubnetDriftOrphanedClaim].Fixed)
+		require.False(t, byType[model.SubnetDriftConflictingClaim].Fixed)
+
+		subnets, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		accountIDs := make(map[string]string)
+		for _, subnet := range subnets {
+			accountIDs[subnet.CIDR] = subnet.AccountID
+		}
+		require.Equal(t, map[string]string{
+			"10.0.0.0/24": unboundAccount.ID,
+			"10.0.1.0/24": missingAccount.ID,
+		}, accountIDs)
+
+		quarantined, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Quarantined: true})
+		require.NoError(t, err)
+		require.Len(t, quarantined, 1)
+		require.Equal(t, "10.0.3.0/24", quarantined[0].CIDR)
+		require.Equal(t, quarantinedUntil, quarantined[0].ReservationExpiresAt)
+
+		drifts, err = sqlStore.ReconcileSubnets(true, GetMillis(), quarantinedUntil)
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		require.Equal(t, model.SubnetDriftConflictingClaim, drifts[0].Type)
+	})
+}
+
+func TestMarkExternalSubnets(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	for _, cidr := range []string{"10.0.0.0/22", "10.0.4.0/23"} {
+		err := sqlStore.AddParentSubnet(&model.ParentSubnet{ID: model.NewID(), CIDR: cidr, SplitRange: 24})
+		require.NoError(t, err)
+	}
+
+	_, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.0.0/24", Family: model.SubnetFamilyIPv4, AccountID: model.NewID()})
+	require.NoError(t, err)
+	_, err = sqlStore.ReserveSubnet("10.0.1.0/24", "network-team", "on-prem link", 0)
+	require.NoError(t, err)
+
+	vpcs := []*model.DiscoveredVPC{
+		{AWSAccountID: "111111111111", Region: "us-east-1", VPCID: "vpc-managed", CIDRs: []string{"10.0.0.0/24"}},
+		{AWSAccountID: "111111111111", Region: "us-east-1", VPCID: "vpc-conflicting", CIDRs: []string{"10.0.1.128/25"}},
+		{AWSAccountID: "222222222222", Region: "us-west-2", VPCID: "vpc-external", CIDRs: []string{"10.0.2.64/26", "2600:1f18::/56"}},
+		{AWSAccountID: "222222222222", Region: "us-west-2", VPCID: "vpc-large", CIDRs: []string{"10.0.4.0/22"}},
+		{AWSAccountID: "333333333333", Region: "us-east-1", VPCID: "vpc-unrelated", CIDRs: []string{"172.16.0.0/16"}},
+	}
+
+	statuses := func(overlaps []*model.SubnetOverlap) map[string]string {
+		byVPC := make(map[string]string)
+		for _, overlap := range overlaps {
+			byVPC[overlap.VPCID+" "+overlap.SubnetCIDR] = overlap.Status
+		}
+		return byVPC
+	}
+
+	t.Run("report only", func(t *testing.T) {
+		overlaps, err := sqlStore.MarkExternalSubnets(vpcs, false)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"vpc-managed 10.0.0.0/24":       model.SubnetOverlapManaged,
+			"vpc-conflicting 10.0.1.128/25": model.SubnetOverlapConflicting,
+			"vpc-external 10.0.2.64/26":     model.SubnetOverlapMarked,
+			"vpc-large 10.0.4.0/23":         model.SubnetOverlapMarked,
+		}, statuses(overlaps))
+
+		reserved, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Reserved: true})
+		require.NoError(t, err)
+		require.Len(t, reserved, 1)
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		overlaps, err := sqlStore.MarkExternalSubnets(vpcs, true)
+		require.NoError(t, err)
+		require.Len(t, overlaps, 4)
+
+		reserved, err := sqlStore.GetSubnets(&model.SubnetFilter{PerPage: model.AllPerPage, Reserved: true})
+		require.NoError(t, err)
+		require.Len(t, reserved, 3)
+		for _, subnet := range reserved {
+			if subnet.CIDR == "10.0.1.0/24" {
+				continue
+			}
+			require.Equal(t, model.SubnetReservationOwnerExternal, subnet.ReservationOwner)
+			require.Zero(t, subnet.ReservationExpiresAt)
+		}
+
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.2.0/24", Family: model.SubnetFamilyIPv4})
+		require.Error(t, err)
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.5.0/24", Family: model.SubnetFamilyIPv4})
+		require.Error(t, err)
+		_, err = sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.3.0/24", Family: model.SubnetFamilyIPv4})
+		require.NoError(t, err)
+	})
+
+	t.Run("mark again", func(t *testing.T) {
+		overlaps, err := sqlStore.MarkExternalSubnets(vpcs, true)
+		require.NoError(t, err)
+		byVPC := statuses(overlaps)
+		require.Equal(t, model.SubnetOverlapAlreadyMarked, byVPC["vpc-external 10.0.2.64/26"])
+		require.Equal(t, model.SubnetOverlapAlreadyMarked, byVPC["vpc-large 10.0.4.0/23"])
+	})
+
+	t.Run("invalid CIDR", func(t *testing.T) {
+		_, err := sqlStore.MarkExternalSubnets([]*model.DiscoveredVPC{{VPCID: "vpc-invalid", CIDRs: []string{"10.0.0.0"}}}, true)
+		require.Error(t, err)
+	})
+}
+
+func TestGetSubnetsContaining(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	err := sqlStore.AddParentSubnet(&model.ParentSubnet{ID: model.NewID(), CIDR: "10.0.0.0/22", SplitRange: 24})
+	require.NoError(t, err)
+	err = sqlStore.AddParentSubnet(&model.ParentSubnet{ID: model.NewID(), CIDR: "2600:1f18::/54", SplitRange: 56})
+	require.NoError(t, err)
+
+	claimed, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "10.0.1.0/24", Family: model.SubnetFamilyIPv4, AccountID: model.NewID()})
+	require.NoError(t, err)
+	reserved, err := sqlStore.ReserveSubnet("10.0.2.0/24", "network-team", "on-prem link", 0)
+	require.NoError(t, err)
+	claimedIPv6, err := sqlStore.ClaimSubnet(&model.SubnetClaim{CIDR: "2600:1f18:0:100::/56", Family: model.SubnetFamilyIPv6})
+	require.NoError(t, err)
+
+	var testCases = []struct {
+		address  string
+		expected *model.Subnet
+	}{
+		{"10.0.1.0", claimed},
+		{"10.0.1.255", claimed},
+		{"10.0.2.34", reserved},
+		{"10.0.0.1", nil},
+		{"172.16.0.1", nil},
+		{"2600:1f18:0:1ab::1", claimedIPv6},
+		{"2600:1f18::1", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.address, func(t *testing.T) {
+			subnets, err := sqlStore.GetSubnetsContaining(tc.address)
+			require.NoError(t, err)
+			if tc.expected == nil {
+				require.Empty(t, subnets)
+				return
+			}
+			require.Len(t, subnets, 1)
+			require.Equal(t, tc.expected.ID, subnets[0].ID)
+		})
+	}
+
+	t.Run("invalid address", func(t *testing.T) {
+		_, err := sqlStore.GetSubnetsContaining("10.0.1")
+		require.Error(t, err)
+	})
+}
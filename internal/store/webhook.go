@@ -6,6 +6,7 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/mattermost/genesis/model"
@@ -16,13 +17,56 @@ var webhookSelect sq.SelectBuilder
 
 func init() {
 	webhookSelect = sq.
-		Select("ID", "OwnerID", "URL", "CreateAt", "DeleteAt").From("Webhooks")
+		Select("ID", "OwnerID", "URL", "Secret", "SubscriptionRaw", "Format",
+			"HeadersRaw", "AuthRaw", "TimeoutSeconds", "ConsecutiveFailures", "Disabled",
+			"CreateAt", "DeleteAt").From("Webhooks")
+}
+
+type rawWebhook struct {
+	*model.Webhook
+	SubscriptionRaw []byte
+	HeadersRaw      []byte
+	AuthRaw         []byte
+}
+type rawWebhooks []*rawWebhook
+
+func (r *rawWebhook) toWebhook() (*model.Webhook, error) {
+	var err error
+	r.Webhook.Subscription, err = model.NewWebhookSubscription(r.SubscriptionRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal webhook subscription")
+	}
+	if r.HeadersRaw != nil {
+		if err = json.Unmarshal(r.HeadersRaw, &r.Webhook.Headers); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal webhook headers")
+		}
+	}
+	if r.AuthRaw != nil {
+		if err = json.Unmarshal(r.AuthRaw, &r.Webhook.Auth); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal webhook auth")
+		}
+	}
+
+	return r.Webhook, nil
+}
+
+func (rc *rawWebhooks) toWebhooks() ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	for _, rawWebhook := range *rc {
+		webhook, err := rawWebhook.toWebhook()
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
 }
 
 // GetWebhook fetches the given webhook by id.
 func (sqlStore *SQLStore) GetWebhook(id string) (*model.Webhook, error) {
-	var webhook model.Webhook
-	err := sqlStore.getBuilder(sqlStore.db, &webhook,
+	var rawWebhook rawWebhook
+	err := sqlStore.getBuilder(sqlStore.db, &rawWebhook,
 		webhookSelect.Where("ID = ?", id),
 	)
 	if err == sql.ErrNoRows {
@@ -31,7 +75,7 @@ func (sqlStore *SQLStore) GetWebhook(id string) (*model.Webhook, error) {
 		return nil, errors.Wrap(err, "failed to get webhook by id")
 	}
 
-	return &webhook, nil
+	return rawWebhook.toWebhook()
 }
 
 // GetWebhooks fetches the given page of created webhooks. The first page is 0.
@@ -52,28 +96,66 @@ func (sqlStore *SQLStore) GetWebhooks(filter *model.WebhookFilter) ([]*model.Web
 		builder = builder.Where("DeleteAt = 0")
 	}
 
-	var webhooks []*model.Webhook
-	err := sqlStore.selectBuilder(sqlStore.db, &webhooks, builder)
+	var rawWebhooks rawWebhooks
+	err := sqlStore.selectBuilder(sqlStore.db, &rawWebhooks, builder)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query for webhooks")
 	}
 
-	return webhooks, nil
+	return rawWebhooks.toWebhooks()
 }
 
 // CreateWebhook records the given webhook to the database, assigning it a unique ID.
 func (sqlStore *SQLStore) CreateWebhook(webhook *model.Webhook) error {
+	var subscriptionJSON []byte
+	if !webhook.Subscription.IsEmpty() {
+		var err error
+		subscriptionJSON, err = json.Marshal(webhook.Subscription)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal webhook subscription")
+		}
+	}
+	var headersJSON, authJSON []byte
+	if len(webhook.Headers) != 0 {
+		var err error
+		headersJSON, err = json.Marshal(webhook.Headers)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal webhook headers")
+		}
+	}
+	if webhook.Auth != nil {
+		var err error
+		authJSON, err = json.Marshal(webhook.Auth)
+		if err != nil {
+			return errors.Wrap(err, "unable to marshal webhook auth")
+		}
+	}
+
 	webhook.ID = model.NewID()
 	webhook.CreateAt = GetMillis()
+	if webhook.Format == "" {
+		webhook.Format = model.WebhookFormatGenesis
+	}
+	if webhook.TimeoutSeconds == 0 {
+		webhook.TimeoutSeconds = model.DefaultWebhookTimeoutSeconds
+	}
 
 	_, err := sqlStore.execBuilder(sqlStore.db, sq.
 		Insert("Webhooks").
 		SetMap(map[string]interface{}{
-			"ID":       webhook.ID,
-			"OwnerID":  webhook.OwnerID,
-			"URL":      webhook.URL,
-			"CreateAt": webhook.CreateAt,
-			"DeleteAt": 0,
+			"ID":                  webhook.ID,
+			"OwnerID":             webhook.OwnerID,
+			"URL":                 webhook.URL,
+			"Secret":              webhook.Secret,
+			"SubscriptionRaw":     subscriptionJSON,
+			"Format":              webhook.Format,
+			"HeadersRaw":          headersJSON,
+			"AuthRaw":             authJSON,
+			"TimeoutSeconds":      webhook.TimeoutSeconds,
+			"ConsecutiveFailures": 0,
+			"Disabled":            false,
+			"CreateAt":            webhook.CreateAt,
+			"DeleteAt":            0,
 		}),
 	)
 	if err != nil {
@@ -83,6 +165,83 @@ func (sqlStore *SQLStore) CreateWebhook(webhook *model.Webhook) error {
 	return nil
 }
 
+// RecordWebhookFailure counts a failed delivery attempt to the given webhook and
+// disables the webhook once it reaches the given number of consecutive failures,
+// unless it is zero. It returns true if the webhook was disabled.
+func (sqlStore *SQLStore) RecordWebhookFailure(id string, disableAfter int) (bool, error) {
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return false, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	_, err = sqlStore.execBuilder(tx, sq.
+		Update("Webhooks").
+		Set("ConsecutiveFailures", sq.Expr("ConsecutiveFailures + 1")).
+		Where("ID = ?", id),
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to count webhook failure")
+	}
+
+	var disabled bool
+	if disableAfter > 0 {
+		result, err := sqlStore.execBuilder(tx, sq.
+			Update("Webhooks").
+			Set("Disabled", true).
+			Where("ID = ?", id).
+			Where("Disabled = ?", false).
+			Where("ConsecutiveFailures >= ?", disableAfter),
+		)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to disable webhook")
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, errors.Wrap(err, "failed to count disabled webhooks")
+		}
+		disabled = rows == 1
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return disabled, nil
+}
+
+// ResetWebhookFailures clears the consecutive failures of the given webhook
+// after a successful delivery.
+func (sqlStore *SQLStore) ResetWebhookFailures(id string) error {
+	_, err := sqlStore.execBuilder(sqlStore.db, sq.
+		Update("Webhooks").
+		Set("ConsecutiveFailures", 0).
+		Where("ID = ?", id).
+		Where("ConsecutiveFailures > 0"),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to reset webhook failures")
+	}
+
+	return nil
+}
+
+// EnableWebhook enables the given webhook again and clears its consecutive failures.
+func (sqlStore *SQLStore) EnableWebhook(id string) error {
+	_, err := sqlStore.execBuilder(sqlStore.db, sq.
+		Update("Webhooks").
+		Set("Disabled", false).
+		Set("ConsecutiveFailures", 0).
+		Where("ID = ?", id),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to enable webhook")
+	}
+
+	return nil
+}
+
 // DeleteWebhook marks the given webhook as deleted, but does not remove the
 // record from the database.
 func (sqlStore *SQLStore) DeleteWebhook(id string) error {
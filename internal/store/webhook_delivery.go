@@ -0,0 +1,397 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+var webhookDeliverySelect sq.SelectBuilder
+var webhookDeliveryAttemptSelect sq.SelectBuilder
+
+func init() {
+	webhookDeliverySelect = sq.
+		Select("ID", "WebhookID", "PayloadRaw", "Sequence", "State", "Attempts",
+			"NextAttemptAt", "LastAttemptAt", "LastError", "CreateAt").
+		From("WebhookDelivery")
+
+	webhookDeliveryAttemptSelect = sq.
+		Select("ID", "DeliveryID", "WebhookID", "PayloadID", "StatusCode", "Latency",
+			"Error", "CreateAt").
+		From("WebhookDeliveryAttempt")
+}
+
+type rawWebhookDelivery struct {
+	*model.WebhookDelivery
+	PayloadRaw []byte
+}
+type rawWebhookDeliveries []*rawWebhookDelivery
+
+func (r *rawWebhookDelivery) toWebhookDelivery() (*model.WebhookDelivery, error) {
+	r.WebhookDelivery.Payload = &model.WebhookPayload{}
+	if err := json.Unmarshal(r.PayloadRaw, r.WebhookDelivery.Payload); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal webhook payload")
+	}
+
+	return r.WebhookDelivery, nil
+}
+
+func (rc *rawWebhookDeliveries) toWebhookDeliveries() ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	for _, rawWebhookDelivery := range *rc {
+		delivery, err := rawWebhookDelivery.toWebhookDelivery()
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// EnqueueWebhookPayload adds a delivery of the given payload to the outbox of
+// every webhook that is neither deleted nor disabled and whose subscription
+// matches the payload.
+func (sqlStore *SQLStore) EnqueueWebhookPayload(payload *model.WebhookPayload) error {
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.enqueueWebhookPayload(tx, payload); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
+}
+
+// enqueueWebhookPayload adds the given payload to the outbox of the matching
+// webhooks within the given transaction, so that the payload is only sent if
+// the change it describes is committed.
+func (sqlStore *SQLStore) enqueueWebhookPayload(db dbInterface, payload *model.WebhookPayload) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal webhook payload")
+	}
+
+	sequence := payload.Timestamp
+	if sequence == 0 {
+		sequence = time.Now().UnixNano()
+	}
+
+	var rawWebhooks rawWebhooks
+	err = sqlStore.selectBuilder(db, &rawWebhooks, webhookSelect.
+		Where("DeleteAt = 0").
+		Where("Disabled = ?", false),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to query for webhooks")
+	}
+	webhooks, err := rawWebhooks.toWebhooks()
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscription.Matches(payload) {
+			continue
+		}
+
+		delivery := &model.WebhookDelivery{
+			WebhookID: webhook.ID,
+			Payload:   payload,
+			Sequence:  sequence,
+		}
+		if err = sqlStore.createWebhookDelivery(db, delivery, payloadJSON); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createWebhookDelivery adds the given pending delivery to the outbox of its
+// webhook, assigning it a unique ID.
+func (sqlStore *SQLStore) createWebhookDelivery(execer execer, delivery *model.WebhookDelivery, payloadJSON []byte) error {
+	delivery.ID = model.NewID()
+	delivery.State = model.WebhookDeliveryStatePending
+	delivery.Attempts = 0
+	delivery.CreateAt = GetMillis()
+	delivery.NextAttemptAt = delivery.CreateAt
+	delivery.LastAttemptAt = 0
+	delivery.LastError = ""
+
+	_, err := sqlStore.execBuilder(execer, sq.
+		Insert("WebhookDelivery").
+		SetMap(map[string]interface{}{
+			"ID":            delivery.ID,
+			"WebhookID":     delivery.WebhookID,
+			"PayloadRaw":    payloadJSON,
+			"Sequence":      delivery.Sequence,
+			"State":         delivery.State,
+			"Attempts":      delivery.Attempts,
+			"NextAttemptAt": delivery.NextAttemptAt,
+			"LastAttemptAt": delivery.LastAttemptAt,
+			"LastError":     delivery.LastError,
+			"CreateAt":      delivery.CreateAt,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to enqueue webhook delivery")
+	}
+
+	return nil
+}
+
+// RedeliverWebhookDelivery adds a new pending delivery of the payload of the
+// given delivery to the outbox of its webhook. It is given a new sequence so
+// that it is sent after the payloads already pending, which are not held back
+// by an old payload sent again.
+func (sqlStore *SQLStore) RedeliverWebhookDelivery(delivery *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	payloadJSON, err := json.Marshal(delivery.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal webhook payload")
+	}
+
+	redelivery := &model.WebhookDelivery{
+		WebhookID: delivery.WebhookID,
+		Payload:   delivery.Payload,
+		Sequence:  time.Now().UnixNano(),
+	}
+	if err = sqlStore.createWebhookDelivery(sqlStore.db, redelivery, payloadJSON); err != nil {
+		return nil, err
+	}
+
+	return redelivery, nil
+}
+
+// GetWebhookDelivery fetches the given webhook delivery by id.
+func (sqlStore *SQLStore) GetWebhookDelivery(id string) (*model.WebhookDelivery, error) {
+	var rawWebhookDelivery rawWebhookDelivery
+	err := sqlStore.getBuilder(sqlStore.db, &rawWebhookDelivery, webhookDeliverySelect.Where("ID = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get webhook delivery by id")
+	}
+
+	return rawWebhookDelivery.toWebhookDelivery()
+}
+
+// GetWebhookDeliveries fetches the given page of webhook deliveries, the most
+// recent first. The first page is 0.
+func (sqlStore *SQLStore) GetWebhookDeliveries(filter *model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, error) {
+	builder := webhookDeliverySelect.
+		OrderBy("Sequence DESC", "ID DESC")
+
+	if filter.PerPage != model.AllPerPage {
+		builder = builder.
+			Limit(uint64(filter.PerPage)).
+			Offset(uint64(filter.Page * filter.PerPage))
+	}
+
+	if filter.WebhookID != "" {
+		builder = builder.Where("WebhookID = ?", filter.WebhookID)
+	}
+	if filter.State != "" {
+		builder = builder.Where("State = ?", filter.State)
+	}
+
+	var rawWebhookDeliveries rawWebhookDeliveries
+	err := sqlStore.selectBuilder(sqlStore.db, &rawWebhookDeliveries, builder)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query for webhook deliveries")
+	}
+
+	return rawWebhookDeliveries.toWebhookDeliveries()
+}
+
+// GetUnlockedWebhooksPendingDelivery fetches the webhooks that are not deleted,
+// disabled nor locked and have pending deliveries.
+func (sqlStore *SQLStore) GetUnlockedWebhooksPendingDelivery() ([]*model.Webhook, error) {
+	builder := webhookSelect.
+		Where("DeleteAt = 0").
+		Where("Disabled = ?", false).
+		Where("LockAcquiredAt = 0").
+		Where(sq.Expr("ID IN (SELECT WebhookID FROM WebhookDelivery WHERE State = ?)", model.WebhookDeliveryStatePending)).
+		OrderBy("CreateAt ASC")
+
+	var rawWebhooks rawWebhooks
+	err := sqlStore.selectBuilder(sqlStore.db, &rawWebhooks, builder)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query for webhooks pending delivery")
+	}
+
+	return rawWebhooks.toWebhooks()
+}
+
+// GetNextWebhookDelivery fetches the first pending delivery of the given
+// webhook, or nil if it has none.
+func (sqlStore *SQLStore) GetNextWebhookDelivery(webhookID string) (*model.WebhookDelivery, error) {
+	var rawWebhookDelivery rawWebhookDelivery
+	err := sqlStore.getBuilder(sqlStore.db, &rawWebhookDelivery, webhookDeliverySelect.
+		Where("WebhookID = ?", webhookID).
+		Where("State = ?", model.WebhookDeliveryStatePending).
+		OrderBy("Sequence ASC", "CreateAt ASC", "ID ASC").
+		Limit(1),
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get next webhook delivery")
+	}
+
+	return rawWebhookDelivery.toWebhookDelivery()
+}
+
+// UpdateWebhookDelivery records the state and the attempts of the given webhook delivery.
+func (sqlStore *SQLStore) UpdateWebhookDelivery(delivery *model.WebhookDelivery) error {
+	return sqlStore.updateWebhookDelivery(sqlStore.db, delivery)
+}
+
+func (sqlStore *SQLStore) updateWebhookDelivery(execer execer, delivery *model.WebhookDelivery) error {
+	_, err := sqlStore.execBuilder(execer, sq.
+		Update("WebhookDelivery").
+		SetMap(map[string]interface{}{
+			"State":         delivery.State,
+			"Attempts":      delivery.Attempts,
+			"NextAttemptAt": delivery.NextAttemptAt,
+			"LastAttemptAt": delivery.LastAttemptAt,
+			"LastError":     delivery.LastError,
+		}).
+		Where("ID = ?", delivery.ID),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to update webhook delivery")
+	}
+
+	return nil
+}
+
+// RecordWebhookDeliveryAttempt records the state of the given webhook delivery
+// along with the given attempt to deliver it, assigning the attempt a unique ID.
+func (sqlStore *SQLStore) RecordWebhookDeliveryAttempt(delivery *model.WebhookDelivery, attempt *model.WebhookDeliveryAttempt) error {
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	if err = sqlStore.updateWebhookDelivery(tx, delivery); err != nil {
+		return err
+	}
+
+	attempt.ID = model.NewID()
+	attempt.DeliveryID = delivery.ID
+	attempt.WebhookID = delivery.WebhookID
+	attempt.PayloadID = delivery.Payload.ID
+	attempt.CreateAt = GetMillis()
+
+	_, err = sqlStore.execBuilder(tx, sq.
+		Insert("WebhookDeliveryAttempt").
+		SetMap(map[string]interface{}{
+			"ID":         attempt.ID,
+			"DeliveryID": attempt.DeliveryID,
+			"WebhookID":  attempt.WebhookID,
+			"PayloadID":  attempt.PayloadID,
+			"StatusCode": attempt.StatusCode,
+			"Latency":    attempt.Latency,
+			"Error":      attempt.Error,
+			"CreateAt":   attempt.CreateAt,
+		}),
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to record webhook delivery attempt")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveryAttempts fetches the attempts of the given webhook
+// deliveries, the most recent first.
+func (sqlStore *SQLStore) GetWebhookDeliveryAttempts(deliveryIDs []string) ([]*model.WebhookDeliveryAttempt, error) {
+	if len(deliveryIDs) == 0 {
+		return nil, nil
+	}
+
+	var attempts []*model.WebhookDeliveryAttempt
+	err := sqlStore.selectBuilder(sqlStore.db, &attempts, webhookDeliveryAttemptSelect.
+		Where(sq.Eq{"DeliveryID": deliveryIDs}).
+		OrderBy("CreateAt DESC", "ID DESC"),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query for webhook delivery attempts")
+	}
+
+	return attempts, nil
+}
+
+// DeleteWebhookDeliveriesCreatedBefore deletes the delivered and failed webhook
+// deliveries created before the given time in milliseconds, along with their
+// attempts, and returns the number of deliveries deleted. Pending deliveries
+// are kept whatever their age.
+func (sqlStore *SQLStore) DeleteWebhookDeliveriesCreatedBefore(createdBefore int64) (int64, error) {
+	tx, err := sqlStore.beginTransaction(sqlStore.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.RollbackUnlessCommitted()
+
+	_, err = sqlStore.execBuilder(tx, sq.
+		Delete("WebhookDeliveryAttempt").
+		Where(sq.Expr("DeliveryID IN (SELECT ID FROM WebhookDelivery WHERE State IN (?, ?) AND CreateAt < ?)",
+			model.WebhookDeliveryStateDelivered, model.WebhookDeliveryStateFailed, createdBefore)),
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete webhook delivery attempts")
+	}
+
+	result, err := sqlStore.execBuilder(tx, sq.
+		Delete("WebhookDelivery").
+		Where(sq.Eq{"State": []string{model.WebhookDeliveryStateDelivered, model.WebhookDeliveryStateFailed}}).
+		Where("CreateAt < ?", createdBefore),
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete webhook deliveries")
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count deleted webhook deliveries")
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to commit the transaction")
+	}
+
+	return deleted, nil
+}
+
+// LockWebhook marks the webhook as locked for exclusive delivery by the caller.
+func (sqlStore *SQLStore) LockWebhook(webhookID, lockerID string) (bool, error) {
+	return sqlStore.lockRows("Webhooks", []string{webhookID}, lockerID)
+}
+
+// UnlockWebhook releases a lock previously acquired against a caller.
+func (sqlStore *SQLStore) UnlockWebhook(webhookID, lockerID string, force bool) (bool, error) {
+	return sqlStore.unlockRows("Webhooks", []string{webhookID}, lockerID, force)
+}
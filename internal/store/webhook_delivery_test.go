@@ -0,0 +1,228 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	webhook1 := &model.Webhook{OwnerID: "owner1", URL: "https://url1.com"}
+	webhook2 := &model.Webhook{OwnerID: "owner2", URL: "https://url2.com"}
+	deleted := &model.Webhook{OwnerID: "owner3", URL: "https://url3.com"}
+	for _, webhook := range []*model.Webhook{webhook1, webhook2, deleted} {
+		require.NoError(t, sqlStore.CreateWebhook(webhook))
+	}
+	require.NoError(t, sqlStore.DeleteWebhook(deleted.ID))
+
+	payload1 := &model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), NewState: "new", Timestamp: 200}
+	payload2 := &model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), NewState: "old", Timestamp: 100}
+
+	t.Run("no pending delivery", func(t *testing.T) {
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook1.ID)
+		require.NoError(t, err)
+		require.Nil(t, delivery)
+
+		webhooks, err := sqlStore.GetUnlockedWebhooksPendingDelivery()
+		require.NoError(t, err)
+		require.Empty(t, webhooks)
+	})
+
+	t.Run("enqueue", func(t *testing.T) {
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(payload1))
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(payload2))
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 4)
+		for _, delivery := range deliveries {
+			require.NotEqual(t, deleted.ID, delivery.WebhookID)
+			require.True(t, delivery.IsPending())
+			require.Equal(t, delivery.CreateAt, delivery.NextAttemptAt)
+		}
+
+		deliveries, err = sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, WebhookID: webhook1.ID})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		require.Equal(t, payload1, deliveries[0].Payload)
+		require.Equal(t, payload2, deliveries[1].Payload)
+
+		webhooks, err := sqlStore.GetUnlockedWebhooksPendingDelivery()
+		require.NoError(t, err)
+		require.Len(t, webhooks, 2)
+	})
+
+	t.Run("next delivery in sequence order", func(t *testing.T) {
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook1.ID)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+		require.Equal(t, payload2, delivery.Payload)
+		require.EqualValues(t, 100, delivery.Sequence)
+
+		delivery.State = model.WebhookDeliveryStateDelivered
+		delivery.Attempts = 1
+		delivery.LastAttemptAt = 300
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+
+		next, err := sqlStore.GetNextWebhookDelivery(webhook1.ID)
+		require.NoError(t, err)
+		require.NotNil(t, next)
+		require.Equal(t, payload1, next.Payload)
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, State: model.WebhookDeliveryStateDelivered})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, delivery, deliveries[0])
+	})
+
+	t.Run("attempts and redelivery", func(t *testing.T) {
+		delivery, err := sqlStore.GetNextWebhookDelivery(webhook2.ID)
+		require.NoError(t, err)
+		require.NotNil(t, delivery)
+
+		delivery.Attempts = 1
+		delivery.LastAttemptAt = 300
+		delivery.NextAttemptAt = 400
+		delivery.LastError = "webhook receiver responded with status code 500"
+		failed := &model.WebhookDeliveryAttempt{StatusCode: 500, Latency: 12, Error: delivery.LastError}
+		require.NoError(t, sqlStore.RecordWebhookDeliveryAttempt(delivery, failed))
+		require.NotEmpty(t, failed.ID)
+		require.Equal(t, payload2.ID, failed.PayloadID)
+
+		time.Sleep(time.Millisecond)
+		delivery.Attempts = 2
+		delivery.State = model.WebhookDeliveryStateDelivered
+		delivery.LastError = ""
+		delivered := &model.WebhookDeliveryAttempt{StatusCode: 200, Latency: 5}
+		require.NoError(t, sqlStore.RecordWebhookDeliveryAttempt(delivery, delivered))
+
+		stored, err := sqlStore.GetWebhookDelivery(delivery.ID)
+		require.NoError(t, err)
+		require.Equal(t, delivery, stored)
+
+		attempts, err := sqlStore.GetWebhookDeliveryAttempts([]string{delivery.ID, model.NewID()})
+		require.NoError(t, err)
+		require.Equal(t, []*model.WebhookDeliveryAttempt{delivered, failed}, attempts)
+
+		redelivery, err := sqlStore.RedeliverWebhookDelivery(delivery)
+		require.NoError(t, err)
+		require.NotEqual(t, delivery.ID, redelivery.ID)
+		require.True(t, redelivery.IsPending())
+		require.Greater(t, redelivery.Sequence, delivery.Sequence)
+
+		next, err := sqlStore.GetNextWebhookDelivery(webhook2.ID)
+		require.NoError(t, err)
+		require.Equal(t, payload1, next.Payload)
+
+		redelivery.State = model.WebhookDeliveryStateDelivered
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(redelivery))
+	})
+
+	t.Run("locked webhooks are skipped", func(t *testing.T) {
+		locked, err := sqlStore.LockWebhook(webhook1.ID, "locker")
+		require.NoError(t, err)
+		require.True(t, locked)
+
+		webhooks, err := sqlStore.GetUnlockedWebhooksPendingDelivery()
+		require.NoError(t, err)
+		require.Len(t, webhooks, 1)
+		require.Equal(t, webhook2.ID, webhooks[0].ID)
+
+		unlocked, err := sqlStore.UnlockWebhook(webhook1.ID, "locker", false)
+		require.NoError(t, err)
+		require.True(t, unlocked)
+
+		webhooks, err = sqlStore.GetUnlockedWebhooksPendingDelivery()
+		require.NoError(t, err)
+		require.Len(t, webhooks, 2)
+	})
+
+	t.Run("subscriptions", func(t *testing.T) {
+		subscribed := &model.Webhook{
+			OwnerID:      "pager",
+			URL:          "https://pager.com",
+			Subscription: &model.WebhookSubscription{Types: []string{model.TypeAccount}, States: []string{"*-failed"}},
+		}
+		require.NoError(t, sqlStore.CreateWebhook(subscribed))
+
+		stored, err := sqlStore.GetWebhook(subscribed.ID)
+		require.NoError(t, err)
+		require.Equal(t, subscribed, stored)
+
+		failed := &model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), NewState: "creation-failed", Timestamp: 300}
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(failed))
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(&model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), NewState: "stable", Timestamp: 400}))
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(&model.WebhookPayload{Type: model.TypeParentSubnet, ID: model.NewID(), Timestamp: 500}))
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, WebhookID: subscribed.ID})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, failed, deliveries[0].Payload)
+
+		deliveries, err = sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, WebhookID: webhook2.ID, State: model.WebhookDeliveryStatePending})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 4)
+	})
+}
+
+func TestDeleteWebhookDeliveriesCreatedBefore(t *testing.T) {
+	logger := testlib.MakeLogger(t)
+	sqlStore := MakeTestSQLStore(t, logger)
+	defer CloseConnection(t, sqlStore)
+
+	webhook := &model.Webhook{OwnerID: "owner", URL: "https://url.com"}
+	require.NoError(t, sqlStore.CreateWebhook(webhook))
+
+	for i := 1; i <= 3; i++ {
+		payload := &model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), NewState: "stable", Timestamp: int64(i)}
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(payload))
+	}
+	deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+
+	var deliveryIDs []string
+	for i, state := range []string{model.WebhookDeliveryStateDelivered, model.WebhookDeliveryStateFailed} {
+		deliveries[i].State = state
+		deliveries[i].Attempts = 1
+		require.NoError(t, sqlStore.RecordWebhookDeliveryAttempt(deliveries[i], &model.WebhookDeliveryAttempt{StatusCode: 200}))
+		deliveryIDs = append(deliveryIDs, deliveries[i].ID)
+	}
+	pending := deliveries[2]
+
+	t.Run("recent deliveries are kept", func(t *testing.T) {
+		deleted, err := sqlStore.DeleteWebhookDeliveriesCreatedBefore(pending.CreateAt - 1000)
+		require.NoError(t, err)
+		require.Zero(t, deleted)
+
+		attempts, err := sqlStore.GetWebhookDeliveryAttempts(deliveryIDs)
+		require.NoError(t, err)
+		require.Len(t, attempts, 2)
+	})
+
+	t.Run("old deliveries and their attempts are deleted", func(t *testing.T) {
+		deleted, err := sqlStore.DeleteWebhookDeliveriesCreatedBefore(GetMillis() + 1)
+		require.NoError(t, err)
+		require.EqualValues(t, 2, deleted)
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, pending.ID, deliveries[0].ID)
+
+		attempts, err := sqlStore.GetWebhookDeliveryAttempts(deliveryIDs)
+		require.NoError(t, err)
+		require.Empty(t, attempts)
+	})
+}
@@ -33,8 +33,12 @@ func TestWebhooks(t *testing.T) {
 		}
 
 		webhook2 := &model.Webhook{
-			OwnerID: "owner2",
-			URL:     "https://url2.com",
+			OwnerID:        "owner2",
+			URL:            "https://url2.com",
+			Secret:         "secret",
+			Headers:        map[string]string{"X-Tenant": "genesis"},
+			Auth:           &model.WebhookAuth{Type: model.WebhookAuthBasic, Username: "user", Password: "password"},
+			TimeoutSeconds: 30,
 		}
 
 		err := sqlStore.CreateWebhook(webhook1)
@@ -143,4 +147,66 @@ func TestWebhooks(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, webhook1, actualWebhook1)
 	})
+
+	t.Run("failures and disabling", func(t *testing.T) {
+		logger := testlib.MakeLogger(t)
+		sqlStore := MakeTestSQLStore(t, logger)
+
+		webhook := &model.Webhook{
+			OwnerID: "owner1",
+			URL:     "https://url1.com",
+		}
+		require.NoError(t, sqlStore.CreateWebhook(webhook))
+
+		disabled, err := sqlStore.RecordWebhookFailure(webhook.ID, 2)
+		require.NoError(t, err)
+		require.False(t, disabled)
+
+		require.NoError(t, sqlStore.ResetWebhookFailures(webhook.ID))
+		actualWebhook, err := sqlStore.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 0, actualWebhook.ConsecutiveFailures)
+
+		// Without a threshold the webhook is never disabled.
+		for i := 0; i < 3; i++ {
+			disabled, err = sqlStore.RecordWebhookFailure(webhook.ID, 0)
+			require.NoError(t, err)
+			require.False(t, disabled)
+		}
+
+		disabled, err = sqlStore.RecordWebhookFailure(webhook.ID, 4)
+		require.NoError(t, err)
+		require.True(t, disabled)
+
+		disabled, err = sqlStore.RecordWebhookFailure(webhook.ID, 4)
+		require.NoError(t, err)
+		require.False(t, disabled)
+
+		actualWebhook, err = sqlStore.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.True(t, actualWebhook.Disabled)
+		require.Equal(t, 5, actualWebhook.ConsecutiveFailures)
+
+		// Disabled webhooks are not sent new payloads.
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(&model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), Timestamp: 1}))
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, WebhookID: webhook.ID})
+		require.NoError(t, err)
+		require.Empty(t, deliveries)
+
+		require.NoError(t, sqlStore.EnableWebhook(webhook.ID))
+		actualWebhook, err = sqlStore.GetWebhook(webhook.ID)
+		require.NoError(t, err)
+		require.Equal(t, webhook, actualWebhook)
+
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(&model.WebhookPayload{Type: model.TypeAccount, ID: model.NewID(), Timestamp: 2}))
+		webhooks, err := sqlStore.GetUnlockedWebhooksPendingDelivery()
+		require.NoError(t, err)
+		require.Equal(t, []*model.Webhook{webhook}, webhooks)
+
+		_, err = sqlStore.RecordWebhookFailure(webhook.ID, 1)
+		require.NoError(t, err)
+		webhooks, err = sqlStore.GetUnlockedWebhooksPendingDelivery()
+		require.NoError(t, err)
+		require.Empty(t, webhooks)
+	})
 }
@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/mattermost/genesis/internal/aws"
-	"github.com/mattermost/genesis/internal/webhook"
 
 	"github.com/mattermost/genesis/model"
 	log "github.com/sirupsen/logrus"
@@ -24,10 +23,10 @@ type accountStore interface {
 	UnlockAccount(accountID string, lockerID string, force bool) (bool, error)
 	DeleteAccount(accountID string) error
 
-	ClaimSubnet(cidr string, accountID string) (*model.Subnet, error)
-	SubnetCleanup(cidr string) error
+	ClaimSubnet(claim *model.SubnetClaim) (*model.Subnet, error)
+	SubnetCleanup(cidr string, quarantinedUntil int64) error
 
-	GetWebhooks(filter *model.WebhookFilter) ([]*model.Webhook, error)
+	UpdateAccountWithEvent(account *model.Account, payload *model.WebhookPayload) error
 }
 
 // accountProvisioner abstracts the provisioning operations required by the account supervisor.
@@ -36,6 +35,7 @@ type accountProvisioner interface {
 	CreateAccount(account *model.Account, aws aws.AWS) error
 	ProvisionAccount(account *model.Account, aws aws.AWS) error
 	DeleteAccount(account *model.Account, aws aws.AWS) error
+	RefreshAccountMetadata(account *model.Account, aws aws.AWS) error
 }
 
 // AccountSupervisor finds accounts pending work and effects the required changes.
@@ -43,21 +43,24 @@ type accountProvisioner interface {
 // The degree of parallelism is controlled by a weighted semaphore, intended to be shared with
 // other clients needing to coordinate background jobs.
 type AccountSupervisor struct {
-	store       accountStore
-	provisioner accountProvisioner
-	aws         aws.AWS
-	instanceID  string
-	logger      log.FieldLogger
+	store            accountStore
+	provisioner      accountProvisioner
+	aws              aws.AWS
+	subnetQuarantine time.Duration
+	instanceID       string
+	logger           log.FieldLogger
 }
 
-// NewAccountSupervisor creates a new AccountSupervisor.
-func NewAccountSupervisor(store accountStore, accountProvisioner accountProvisioner, aws aws.AWS, instanceID string, logger log.FieldLogger) *AccountSupervisor {
+// NewAccountSupervisor creates a new AccountSupervisor. The subnets of deleted
+// accounts are kept in quarantine for subnetQuarantine before they can be claimed again.
+func NewAccountSupervisor(store accountStore, accountProvisioner accountProvisioner, aws aws.AWS, subnetQuarantine time.Duration, instanceID string, logger log.FieldLogger) *AccountSupervisor {
 	return &AccountSupervisor{
-		store:       store,
-		provisioner: accountProvisioner,
-		aws:         aws,
-		instanceID:  instanceID,
-		logger:      logger,
+		store:            store,
+		provisioner:      accountProvisioner,
+		aws:              aws,
+		subnetQuarantine: subnetQuarantine,
+		instanceID:       instanceID,
+		logger:           logger,
 	}
 }
 
@@ -124,14 +127,14 @@ func (s *AccountSupervisor) Supervise(account *model.Account) {
 
 	oldState := account.State
 	account.State = newState
-	if err = s.store.UpdateAccount(account); err != nil {
-		logger.WithError(err).Warnf("failed to set account state to %s", newState)
-		return
-	}
 
 	environment, err := s.aws.GetCloudEnvironmentName()
 	if err != nil {
+		// The state is still persisted, without sending webhooks.
 		logger.WithError(err).Error("getting the AWS Cloud environment")
+		if err = s.store.UpdateAccount(account); err != nil {
+			logger.WithError(err).Warnf("failed to set account state to %s", newState)
+		}
 		return
 	}
 
@@ -143,8 +146,9 @@ func (s *AccountSupervisor) Supervise(account *model.Account) {
 		Timestamp: time.Now().UnixNano(),
 		ExtraData: map[string]string{"Environment": environment},
 	}
-	if err = webhook.SendToAllWebhooks(s.store, webhookPayload, logger.WithField("webhookEvent", webhookPayload.NewState)); err != nil {
-		logger.WithError(err).Error("Unable to process and send webhooks")
+	if err = s.store.UpdateAccountWithEvent(account, webhookPayload); err != nil {
+		logger.WithError(err).Warnf("failed to set account state to %s", newState)
+		return
 	}
 
 	logger.Debugf("Transitioned account from %s to %s", oldState, newState)
@@ -201,12 +205,19 @@ func (s *AccountSupervisor) provisionAccount(account *model.Account, logger log.
 }
 
 func (s *AccountSupervisor) refreshAccountMetadata(account *model.Account, logger log.FieldLogger) string {
-	err := s.store.UpdateAccount(account)
+	err := s.provisioner.RefreshAccountMetadata(account, s.aws)
+	if err != nil {
+		logger.WithError(err).Error("Failed to refresh account metadata")
+		return model.AccountStateRefreshMetadataFailed
+	}
+
+	err = s.store.UpdateAccount(account)
 	if err != nil {
 		logger.WithError(err).Error("Failed to save updated account metadata")
-		return model.AccountStateProvisioningFailed
+		return model.AccountStateRefreshMetadataFailed
 	}
 
+	logger.Info("Finished refreshing account metadata")
 	return model.AccountStateStable
 }
 
@@ -222,11 +233,23 @@ func (s *AccountSupervisor) deleteAccount(account *model.Account, logger log.Fie
 		return model.AccountStateDeletionFailed
 	}
 
-	if err = s.store.SubnetCleanup(account.AccountMetadata.Subnet); err != nil {
+	var quarantinedUntil int64
+	if s.subnetQuarantine > 0 {
+		quarantinedUntil = time.Now().Add(s.subnetQuarantine).UnixNano() / int64(time.Millisecond)
+	}
+
+	if err = s.store.SubnetCleanup(account.AccountMetadata.Subnet, quarantinedUntil); err != nil {
 		logger.WithError(err).Error("Failed to do subnet store cleanup")
 		return model.AccountStateDeletionFailed
 	}
 
+	if account.AccountMetadata.SubnetIPv6 != "" {
+		if err = s.store.SubnetCleanup(account.AccountMetadata.SubnetIPv6, quarantinedUntil); err != nil {
+			logger.WithError(err).Error("Failed to do IPv6 subnet store cleanup")
+			return model.AccountStateDeletionFailed
+		}
+	}
+
 	logger.Info("Finished deleting account")
 	return model.AccountStateDeleted
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/mattermost/genesis/internal/supervisor"
 	"github.com/mattermost/genesis/internal/testlib"
 	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -83,19 +84,22 @@ func (s *mockAccountStore) DeleteAccount(AccountID string) error {
 	return nil
 }
 
-func (s *mockAccountStore) ClaimSubnet(cidr string, accountID string) (*model.Subnet, error) {
+func (s *mockAccountStore) ClaimSubnet(claim *model.SubnetClaim) (*model.Subnet, error) {
 	return nil, nil
 }
 
-func (s *mockAccountStore) SubnetCleanup(cidr string) error {
+func (s *mockAccountStore) SubnetCleanup(cidr string, quarantinedUntil int64) error {
 	return nil
 }
 
-func (s *mockAccountStore) GetWebhooks(filter *model.WebhookFilter) ([]*model.Webhook, error) {
-	return nil, nil
+func (s *mockAccountStore) UpdateAccountWithEvent(Account *model.Account, payload *model.WebhookPayload) error {
+	s.UpdateAccountCalls++
+	return nil
 }
 
-type mockAccountProvisioner struct{}
+type mockAccountProvisioner struct {
+	RefreshAccountMetadataError error
+}
 
 func (p *mockAccountProvisioner) PrepareAccount(Account *model.Account) bool {
 	return true
@@ -113,12 +117,16 @@ func (p *mockAccountProvisioner) DeleteAccount(Account *model.Account, aws aws.A
 	return nil
 }
 
+func (p *mockAccountProvisioner) RefreshAccountMetadata(Account *model.Account, aws aws.AWS) error {
+	return p.RefreshAccountMetadataError
+}
+
 func TestAccountSupervisorDo(t *testing.T) {
 	t.Run("no Accounts pending work", func(t *testing.T) {
 		logger := testlib.MakeLogger(t)
 		mockStore := &mockAccountStore{}
 
-		supervisor := supervisor.NewAccountSupervisor(mockStore, &mockAccountProvisioner{}, &mockAWS{}, "instanceID", logger)
+		supervisor := supervisor.NewAccountSupervisor(mockStore, &mockAccountProvisioner{}, &mockAWS{}, 0, "instanceID", logger)
 		err := supervisor.Do()
 		require.NoError(t, err)
 
@@ -137,7 +145,7 @@ func TestAccountSupervisorDo(t *testing.T) {
 		mockStore.Account = mockStore.UnlockedAccountsPendingWork[0]
 		mockStore.UnlockChan = make(chan interface{})
 
-		supervisor := supervisor.NewAccountSupervisor(mockStore, &mockAccountProvisioner{}, &mockAWS{}, "instanceID", logger)
+		supervisor := supervisor.NewAccountSupervisor(mockStore, &mockAccountProvisioner{}, &mockAWS{}, 0, "instanceID", logger)
 		err := supervisor.Do()
 		require.NoError(t, err)
 
@@ -159,7 +167,7 @@ func TestAccountSupervisorDo(t *testing.T) {
 		mockStore.Account = mockStore.UnlockedAccountsPendingWork[0]
 		mockStore.UnlockChan = make(chan interface{})
 
-		supervisor := supervisor.NewAccountSupervisor(mockStore, &mockAccountProvisioner{}, &mockAWS{}, "instanceID", logger)
+		supervisor := supervisor.NewAccountSupervisor(mockStore, &mockAccountProvisioner{}, &mockAWS{}, 0, "instanceID", logger)
 		err := supervisor.Do()
 		require.NoError(t, err)
 
@@ -177,13 +185,14 @@ func TestAccountSupervisorSupervise(t *testing.T) {
 		{"unexpected state", model.AccountStateStable, model.AccountStateStable},
 		{"creation requested", model.AccountStateCreationRequested, model.AccountStateStable},
 		{"provision requested", model.AccountStateProvisioningRequested, model.AccountStateStable},
+		{"refresh metadata requested", model.AccountStateRefreshMetadata, model.AccountStateStable},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.Description, func(t *testing.T) {
 			logger := testlib.MakeLogger(t)
 			sqlStore := store.MakeTestSQLStore(t, logger)
-			supervisor := supervisor.NewAccountSupervisor(sqlStore, &mockAccountProvisioner{}, &mockAWS{}, "instanceID", logger)
+			supervisor := supervisor.NewAccountSupervisor(sqlStore, &mockAccountProvisioner{}, &mockAWS{}, 0, "instanceID", logger)
 
 			Account := &model.Account{
 				Provider:        model.ProviderAWS,
@@ -201,10 +210,31 @@ func TestAccountSupervisorSupervise(t *testing.T) {
 		})
 	}
 
+	t.Run("refresh metadata failed", func(t *testing.T) {
+		logger := testlib.MakeLogger(t)
+		sqlStore := store.MakeTestSQLStore(t, logger)
+		provisioner := &mockAccountProvisioner{RefreshAccountMetadataError: errors.New("refresh failed")}
+		supervisor := supervisor.NewAccountSupervisor(sqlStore, provisioner, &mockAWS{}, 0, "instanceID", logger)
+
+		Account := &model.Account{
+			Provider:        model.ProviderAWS,
+			State:           model.AccountStateRefreshMetadata,
+			AccountMetadata: &model.AccountMetadata{},
+		}
+		err := sqlStore.CreateAccount(Account)
+		require.NoError(t, err)
+
+		supervisor.Supervise(Account)
+
+		Account, err = sqlStore.GetAccount(Account.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.AccountStateRefreshMetadataFailed, Account.State)
+	})
+
 	t.Run("state has changed since Account was selected to be worked on", func(t *testing.T) {
 		logger := testlib.MakeLogger(t)
 		sqlStore := store.MakeTestSQLStore(t, logger)
-		supervisor := supervisor.NewAccountSupervisor(sqlStore, &mockAccountProvisioner{}, &mockAWS{}, "instanceID", logger)
+		supervisor := supervisor.NewAccountSupervisor(sqlStore, &mockAccountProvisioner{}, &mockAWS{}, 0, "instanceID", logger)
 
 		Account := &model.Account{
 			Provider: model.ProviderAWS,
@@ -0,0 +1,78 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package supervisor
+
+import (
+	"time"
+
+	"github.com/mattermost/genesis/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// subnetReconcilerStore abstracts the database operations required to reconcile subnet claims.
+type subnetReconcilerStore interface {
+	ReconcileSubnets(fix bool, claimedBefore, quarantinedUntil int64) ([]*model.SubnetDrift, error)
+}
+
+// SubnetReconciler finds subnet claims out of sync with the accounts, such as claims
+// left behind by failed account creations and deletions, and optionally fixes them.
+type SubnetReconciler struct {
+	store            subnetReconcilerStore
+	fix              bool
+	minClaimAge      time.Duration
+	subnetQuarantine time.Duration
+	logger           log.FieldLogger
+}
+
+// NewSubnetReconciler creates a new SubnetReconciler. Claims younger than the
+// minimum claim age are never considered orphaned, and orphaned claims are kept
+// in quarantine for subnetQuarantine when released.
+func NewSubnetReconciler(store subnetReconcilerStore, fix bool, minClaimAge, subnetQuarantine time.Duration, logger log.FieldLogger) *SubnetReconciler {
+	return &SubnetReconciler{
+		store:            store,
+		fix:              fix,
+		minClaimAge:      minClaimAge,
+		subnetQuarantine: subnetQuarantine,
+		logger:           logger,
+	}
+}
+
+// Shutdown performs graceful shutdown tasks for the subnet reconciler.
+func (s *SubnetReconciler) Shutdown() {
+	s.logger.Debug("Shutting down subnet reconciler")
+}
+
+// Do reconciles the subnet claims with the accounts and logs the drifts found.
+func (s *SubnetReconciler) Do() error {
+	claimedBefore := time.Now().Add(-s.minClaimAge).UnixNano() / int64(time.Millisecond)
+	var quarantinedUntil int64
+	if s.subnetQuarantine > 0 {
+		quarantinedUntil = time.Now().Add(s.subnetQuarantine).UnixNano() / int64(time.Millisecond)
+	}
+	drifts, err := s.store.ReconcileSubnets(s.fix, claimedBefore, quarantinedUntil)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to reconcile subnet claims")
+		return nil
+	}
+
+	for _, drift := range drifts {
+		logger := s.logger.WithFields(log.Fields{
+			"drift":         drift.Type,
+			"subnet":        drift.CIDR,
+			"claim-account": drift.ClaimAccountID,
+			"account":       drift.AccountID,
+		})
+		switch {
+		case drift.Error != "":
+			logger.WithField("error", drift.Error).Warn("Failed to fix subnet claim drift")
+		case drift.Fixed:
+			logger.Info("Fixed subnet claim drift")
+		default:
+			logger.Warn("Found subnet claim drift")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package supervisor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/internal/supervisor"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSubnetReconcilerStore struct {
+	Drifts []*model.SubnetDrift
+	Err    error
+
+	Fix              bool
+	ClaimedBefore    int64
+	QuarantinedUntil int64
+	Calls            int
+}
+
+func (s *mockSubnetReconcilerStore) ReconcileSubnets(fix bool, claimedBefore, quarantinedUntil int64) ([]*model.SubnetDrift, error) {
+	s.Calls++
+	s.Fix = fix
+	s.ClaimedBefore = claimedBefore
+	s.QuarantinedUntil = quarantinedUntil
+	return s.Drifts, s.Err
+}
+
+func TestSubnetReconcilerDo(t *testing.T) {
+	t.Run("reconcile", func(t *testing.T) {
+		logger := testlib.MakeLogger(t)
+		mockStore := &mockSubnetReconcilerStore{
+			Drifts: []*model.SubnetDrift{
+				{Type: model.SubnetDriftOrphanedClaim, CIDR: "10.0.0.0/24", Fixed: true},
+				{Type: model.SubnetDriftConflictingClaim, CIDR: "10.0.1.0/24"},
+			},
+		}
+
+		reconciler := supervisor.NewSubnetReconciler(mockStore, true, time.Hour, 2*time.Hour, logger)
+		before := time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)
+		quarantinedUntil := time.Now().Add(2*time.Hour).UnixNano() / int64(time.Millisecond)
+		err := reconciler.Do()
+		require.NoError(t, err)
+
+		require.Equal(t, 1, mockStore.Calls)
+		require.True(t, mockStore.Fix)
+		require.GreaterOrEqual(t, mockStore.ClaimedBefore, before)
+		require.Less(t, mockStore.ClaimedBefore, before+time.Minute.Milliseconds())
+		require.GreaterOrEqual(t, mockStore.QuarantinedUntil, quarantinedUntil)
+		require.Less(t, mockStore.QuarantinedUntil, quarantinedUntil+time.Minute.Milliseconds())
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		logger := testlib.MakeLogger(t)
+		mockStore := &mockSubnetReconcilerStore{Err: errors.New("failure")}
+
+		reconciler := supervisor.NewSubnetReconciler(mockStore, false, 0, 0, logger)
+		err := reconciler.Do()
+		require.NoError(t, err)
+		require.Equal(t, 1, mockStore.Calls)
+		require.False(t, mockStore.Fix)
+		require.Zero(t, mockStore.QuarantinedUntil)
+	})
+}
@@ -0,0 +1,229 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package supervisor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mattermost/genesis/internal/webhook"
+	"github.com/mattermost/genesis/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// webhookRetryBaseDelay is the delay before retrying a failed webhook
+	// delivery the first time. It doubles with every failed attempt.
+	webhookRetryBaseDelay = 5 * time.Second
+	// webhookRetryMaxDelay caps the delay between two webhook delivery attempts.
+	webhookRetryMaxDelay = 10 * time.Minute
+	// webhookRetentionSweepInterval is how often the webhook deliveries past
+	// their retention are deleted.
+	webhookRetentionSweepInterval = time.Hour
+)
+
+// webhookDelivererStore abstracts the database operations required to deliver webhooks.
+type webhookDelivererStore interface {
+	GetUnlockedWebhooksPendingDelivery() ([]*model.Webhook, error)
+	GetNextWebhookDelivery(webhookID string) (*model.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery *model.WebhookDelivery) error
+	RecordWebhookDeliveryAttempt(delivery *model.WebhookDelivery, attempt *model.WebhookDeliveryAttempt) error
+	DeleteWebhookDeliveriesCreatedBefore(createdBefore int64) (int64, error)
+	RecordWebhookFailure(webhookID string, disableAfter int) (bool, error)
+	ResetWebhookFailures(webhookID string) error
+	LockWebhook(webhookID, lockerID string) (bool, error)
+	UnlockWebhook(webhookID, lockerID string, force bool) (bool, error)
+}
+
+// WebhookDeliverer sends the payloads in the outbox of the webhooks, retrying
+// the failed deliveries with an exponential backoff. The deliveries of a webhook
+// are sent in order, one at a time, while webhooks are delivered in parallel.
+type WebhookDeliverer struct {
+	store        webhookDelivererStore
+	maxAge       time.Duration
+	retention    time.Duration
+	disableAfter int
+	instanceID   string
+	logger       log.FieldLogger
+
+	lastSweep time.Time
+}
+
+// NewWebhookDeliverer creates a new WebhookDeliverer. Deliveries still failing
+// after the maximum age are given up on, unless it is zero. Delivered and failed
+// deliveries are deleted with their attempts once older than the retention,
+// unless it is zero. Webhooks are disabled after the given number of consecutive
+// failed attempts, unless it is zero.
+func NewWebhookDeliverer(store webhookDelivererStore, maxAge, retention time.Duration, disableAfter int, instanceID string, logger log.FieldLogger) *WebhookDeliverer {
+	return &WebhookDeliverer{
+		store:        store,
+		maxAge:       maxAge,
+		retention:    retention,
+		disableAfter: disableAfter,
+		instanceID:   instanceID,
+		logger:       logger,
+	}
+}
+
+// Shutdown performs graceful shutdown tasks for the webhook deliverer.
+func (s *WebhookDeliverer) Shutdown() {
+	s.logger.Debug("Shutting down webhook deliverer")
+}
+
+// Do delivers the pending payloads of all webhooks and deletes the deliveries
+// past their retention.
+func (s *WebhookDeliverer) Do() error {
+	s.sweep()
+
+	hooks, err := s.store.GetUnlockedWebhooksPendingDelivery()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to query for webhooks pending delivery")
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		wg.Add(1)
+		go func(hook *model.Webhook) {
+			defer wg.Done()
+			s.deliver(hook)
+		}(hook)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// deliver sends the pending payloads of the given webhook in order, until one
+// fails or is not due yet.
+func (s *WebhookDeliverer) deliver(hook *model.Webhook) {
+	logger := s.logger.WithFields(log.Fields{
+		"webhook": hook.ID,
+	})
+
+	lock := newWebhookLock(hook.ID, s.instanceID, s.store, logger)
+	if !lock.TryLock() {
+		return
+	}
+	defer lock.Unlock()
+
+	for {
+		delivery, err := s.store.GetNextWebhookDelivery(hook.ID)
+		if err != nil {
+			logger.WithError(err).Error("Failed to get next webhook delivery")
+			return
+		}
+		if delivery == nil {
+			return
+		}
+
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		if delivery.NextAttemptAt > now {
+			return
+		}
+
+		deliveryLogger := logger.WithField("delivery", delivery.ID)
+		if s.isExpired(delivery, now) {
+			delivery.State = model.WebhookDeliveryStateFailed
+			if delivery.LastError == "" {
+				delivery.LastError = fmt.Sprintf("not delivered within %s", s.maxAge)
+			} else {
+				delivery.LastError = fmt.Sprintf("not delivered within %s: %s", s.maxAge, delivery.LastError)
+			}
+			if err = s.store.UpdateWebhookDelivery(delivery); err != nil {
+				deliveryLogger.WithError(err).Error("Failed to record expired webhook delivery")
+				return
+			}
+			deliveryLogger.Warnf("Gave up on webhook delivery after %d attempts", delivery.Attempts)
+			continue
+		}
+
+		start := time.Now()
+		statusCode, err := webhook.Deliver(hook, delivery.Payload)
+		attempt := &model.WebhookDeliveryAttempt{
+			StatusCode: statusCode,
+			Latency:    int64(time.Since(start) / time.Millisecond),
+		}
+		delivery.Attempts++
+		delivery.LastAttemptAt = now
+		if err == nil {
+			delivery.State = model.WebhookDeliveryStateDelivered
+			delivery.LastError = ""
+			if err = s.store.RecordWebhookDeliveryAttempt(delivery, attempt); err != nil {
+				deliveryLogger.WithError(err).Error("Failed to record webhook delivery")
+				return
+			}
+			deliveryLogger.Debug("Delivered webhook")
+			if hook.ConsecutiveFailures > 0 {
+				if err = s.store.ResetWebhookFailures(hook.ID); err != nil {
+					logger.WithError(err).Error("Failed to reset webhook failures")
+					return
+				}
+				hook.ConsecutiveFailures = 0
+			}
+			continue
+		}
+
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now + int64(webhookRetryDelay(delivery.Attempts)/time.Millisecond)
+		attempt.Error = delivery.LastError
+		if err = s.store.RecordWebhookDeliveryAttempt(delivery, attempt); err != nil {
+			deliveryLogger.WithError(err).Error("Failed to record failed webhook delivery")
+			return
+		}
+		deliveryLogger.WithField("attempts", delivery.Attempts).Warnf("Failed to deliver webhook: %s", delivery.LastError)
+
+		disabled, err := s.store.RecordWebhookFailure(hook.ID, s.disableAfter)
+		if err != nil {
+			logger.WithError(err).Error("Failed to record webhook failure")
+			return
+		}
+		if disabled {
+			logger.Warnf("Disabled webhook after %d consecutive failures", s.disableAfter)
+		}
+
+		return
+	}
+}
+
+// sweep deletes the delivered and failed deliveries older than the retention,
+// at most once per sweep interval.
+func (s *WebhookDeliverer) sweep() {
+	if s.retention <= 0 || time.Since(s.lastSweep) < webhookRetentionSweepInterval {
+		return
+	}
+	s.lastSweep = time.Now()
+
+	createdBefore := time.Now().Add(-s.retention).UnixNano() / int64(time.Millisecond)
+	deleted, err := s.store.DeleteWebhookDeliveriesCreatedBefore(createdBefore)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to delete old webhook deliveries")
+		return
+	}
+	if deleted > 0 {
+		s.logger.Debugf("Deleted %d webhook deliveries older than %s", deleted, s.retention)
+	}
+}
+
+// isExpired returns true if the given delivery is older than the maximum age
+// at the given time in milliseconds.
+func (s *WebhookDeliverer) isExpired(delivery *model.WebhookDelivery, now int64) bool {
+	return s.maxAge > 0 && delivery.CreateAt < now-int64(s.maxAge/time.Millisecond)
+}
+
+// webhookRetryDelay returns the delay before the next attempt of a delivery
+// that failed the given number of times.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookRetryMaxDelay {
+			return webhookRetryMaxDelay
+		}
+	}
+
+	return delay
+}
@@ -0,0 +1,299 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package supervisor_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/internal/store"
+	"github.com/mattermost/genesis/internal/supervisor"
+	"github.com/mattermost/genesis/internal/testlib"
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records the payloads it receives and responds with the
+// configured status code.
+type webhookReceiver struct {
+	lock       sync.Mutex
+	statusCode int
+	received   []*model.WebhookPayload
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	payload, err := model.WebhookPayloadFromReader(req.Body)
+	if err == nil {
+		r.received = append(r.received, payload)
+	}
+	w.WriteHeader(r.statusCode)
+}
+
+func (r *webhookReceiver) setStatusCode(statusCode int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.statusCode = statusCode
+}
+
+func (r *webhookReceiver) receivedIDs() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var ids []string
+	for _, payload := range r.received {
+		ids = append(ids, payload.ID)
+	}
+	return ids
+}
+
+func TestWebhookDelivererDo(t *testing.T) {
+	setup := func(t *testing.T, statusCode int) (*store.SQLStore, *model.Webhook, *webhookReceiver, func()) {
+		logger := testlib.MakeLogger(t)
+		sqlStore := store.MakeTestSQLStore(t, logger)
+
+		receiver := &webhookReceiver{statusCode: statusCode}
+		ts := httptest.NewServer(receiver)
+
+		hook := &model.Webhook{OwnerID: "owner", URL: ts.URL}
+		require.NoError(t, sqlStore.CreateWebhook(hook))
+
+		return sqlStore, hook, receiver, func() {
+			ts.Close()
+			store.CloseConnection(t, sqlStore)
+		}
+	}
+
+	enqueue := func(t *testing.T, sqlStore *store.SQLStore, sequence int64) *model.WebhookPayload {
+		payload := &model.WebhookPayload{
+			Type:      model.TypeAccount,
+			ID:        model.NewID(),
+			NewState:  model.AccountStateStable,
+			Timestamp: sequence,
+		}
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(payload))
+		return payload
+	}
+
+	t.Run("delivered in order", func(t *testing.T) {
+		sqlStore, hook, receiver, teardown := setup(t, http.StatusOK)
+		defer teardown()
+
+		payload2 := enqueue(t, sqlStore, 2)
+		payload1 := enqueue(t, sqlStore, 1)
+
+		deliverer := supervisor.NewWebhookDeliverer(sqlStore, time.Hour, 0, 0, model.NewID(), testlib.MakeLogger(t))
+		require.NoError(t, deliverer.Do())
+
+		require.Equal(t, []string{payload1.ID, payload2.ID}, receiver.receivedIDs())
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, WebhookID: hook.ID})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		for _, delivery := range deliveries {
+			require.Equal(t, model.WebhookDeliveryStateDelivered, delivery.State)
+			require.Equal(t, 1, delivery.Attempts)
+		}
+
+		locked, err := sqlStore.LockWebhook(hook.ID, model.NewID())
+		require.NoError(t, err)
+		require.True(t, locked)
+	})
+
+	t.Run("failure blocks following deliveries", func(t *testing.T) {
+		sqlStore, hook, receiver, teardown := setup(t, http.StatusInternalServerError)
+		defer teardown()
+
+		payload1 := enqueue(t, sqlStore, 1)
+		payload2 := enqueue(t, sqlStore, 2)
+
+		deliverer := supervisor.NewWebhookDeliverer(sqlStore, time.Hour, 0, 0, model.NewID(), testlib.MakeLogger(t))
+		require.NoError(t, deliverer.Do())
+		require.Equal(t, []string{payload1.ID}, receiver.receivedIDs())
+
+		delivery, err := sqlStore.GetNextWebhookDelivery(hook.ID)
+		require.NoError(t, err)
+		require.Equal(t, payload1.ID, delivery.Payload.ID)
+		require.True(t, delivery.IsPending())
+		require.Equal(t, 1, delivery.Attempts)
+		require.Equal(t, "webhook receiver responded with status code 500", delivery.LastError)
+		require.Greater(t, delivery.NextAttemptAt, delivery.LastAttemptAt)
+
+		attempts, err := sqlStore.GetWebhookDeliveryAttempts([]string{delivery.ID})
+		require.NoError(t, err)
+		require.Len(t, attempts, 1)
+		require.Equal(t, payload1.ID, attempts[0].PayloadID)
+		require.Equal(t, http.StatusInternalServerError, attempts[0].StatusCode)
+		require.Equal(t, delivery.LastError, attempts[0].Error)
+
+		// The retry is not due yet, so nothing is sent.
+		receiver.setStatusCode(http.StatusOK)
+		require.NoError(t, deliverer.Do())
+		require.Equal(t, []string{payload1.ID}, receiver.receivedIDs())
+
+		delivery.NextAttemptAt = 0
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+		require.NoError(t, deliverer.Do())
+		require.Equal(t, []string{payload1.ID, payload1.ID, payload2.ID}, receiver.receivedIDs())
+
+		delivery, err = sqlStore.GetNextWebhookDelivery(hook.ID)
+		require.NoError(t, err)
+		require.Nil(t, delivery)
+	})
+
+	t.Run("disabled after consecutive failures", func(t *testing.T) {
+		sqlStore, hook, receiver, teardown := setup(t, http.StatusInternalServerError)
+		defer teardown()
+
+		payload := enqueue(t, sqlStore, 1)
+		deliverer := supervisor.NewWebhookDeliverer(sqlStore, time.Hour, 0, 2, model.NewID(), testlib.MakeLogger(t))
+
+		retry := func() {
+			delivery, err := sqlStore.GetNextWebhookDelivery(hook.ID)
+			require.NoError(t, err)
+			delivery.NextAttemptAt = 0
+			require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+			require.NoError(t, deliverer.Do())
+		}
+
+		require.NoError(t, deliverer.Do())
+		stored, err := sqlStore.GetWebhook(hook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, stored.ConsecutiveFailures)
+		require.False(t, stored.Disabled)
+
+		// A successful delivery resets the failures.
+		receiver.setStatusCode(http.StatusOK)
+		retry()
+		stored, err = sqlStore.GetWebhook(hook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 0, stored.ConsecutiveFailures)
+
+		receiver.setStatusCode(http.StatusInternalServerError)
+		enqueue(t, sqlStore, 2)
+		require.NoError(t, deliverer.Do())
+		retry()
+		stored, err = sqlStore.GetWebhook(hook.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, stored.ConsecutiveFailures)
+		require.True(t, stored.Disabled)
+
+		// Disabled webhooks are skipped until they are enabled again.
+		receiver.setStatusCode(http.StatusOK)
+		retry()
+		require.Len(t, receiver.receivedIDs(), 4)
+		require.Equal(t, payload.ID, receiver.receivedIDs()[0])
+
+		require.NoError(t, sqlStore.EnableWebhook(hook.ID))
+		require.NoError(t, deliverer.Do())
+		require.Len(t, receiver.receivedIDs(), 5)
+	})
+
+	t.Run("expired delivery", func(t *testing.T) {
+		sqlStore, hook, receiver, teardown := setup(t, http.StatusOK)
+		defer teardown()
+
+		enqueue(t, sqlStore, 1)
+		payload2 := enqueue(t, sqlStore, 2)
+
+		delivery, err := sqlStore.GetNextWebhookDelivery(hook.ID)
+		require.NoError(t, err)
+		delivery.LastError = "connection refused"
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+
+		time.Sleep(5 * time.Millisecond)
+		deliverer := supervisor.NewWebhookDeliverer(sqlStore, time.Millisecond, 0, 0, model.NewID(), testlib.MakeLogger(t))
+		require.NoError(t, deliverer.Do())
+		require.Empty(t, receiver.receivedIDs())
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage, State: model.WebhookDeliveryStateFailed})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		require.Equal(t, payload2.ID, deliveries[0].Payload.ID)
+		require.Equal(t, "not delivered within 1ms", deliveries[0].LastError)
+		require.Equal(t, "not delivered within 1ms: connection refused", deliveries[1].LastError)
+	})
+
+	t.Run("redelivery of an old payload is signed when sent", func(t *testing.T) {
+		logger := testlib.MakeLogger(t)
+		sqlStore := store.MakeTestSQLStore(t, logger)
+		defer store.CloseConnection(t, sqlStore)
+
+		var verified []*model.WebhookPayload
+		var verifyErrors []error
+		var lock sync.Mutex
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			body, err := ioutil.ReadAll(r.Body)
+			var payload *model.WebhookPayload
+			if err == nil {
+				payload, err = model.VerifyWebhookSignature("secret", r.Header, body, time.Minute)
+			}
+			if err != nil {
+				verifyErrors = append(verifyErrors, err)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			verified = append(verified, payload)
+		}))
+		defer ts.Close()
+
+		hook := &model.Webhook{OwnerID: "owner", URL: ts.URL, Secret: "secret"}
+		require.NoError(t, sqlStore.CreateWebhook(hook))
+
+		payload := &model.WebhookPayload{
+			Type:      model.TypeAccount,
+			ID:        model.NewID(),
+			NewState:  model.AccountStateStable,
+			Timestamp: time.Now().Add(-48 * time.Hour).UnixNano(),
+		}
+		require.NoError(t, sqlStore.EnqueueWebhookPayload(payload))
+
+		delivery, err := sqlStore.GetNextWebhookDelivery(hook.ID)
+		require.NoError(t, err)
+		delivery.State = model.WebhookDeliveryStateFailed
+		require.NoError(t, sqlStore.UpdateWebhookDelivery(delivery))
+
+		_, err = sqlStore.RedeliverWebhookDelivery(delivery)
+		require.NoError(t, err)
+
+		deliverer := supervisor.NewWebhookDeliverer(sqlStore, time.Hour, 0, 0, model.NewID(), logger)
+		require.NoError(t, deliverer.Do())
+
+		lock.Lock()
+		defer lock.Unlock()
+		require.Empty(t, verifyErrors)
+		require.Equal(t, []*model.WebhookPayload{payload}, verified)
+	})
+
+	t.Run("old deliveries are deleted", func(t *testing.T) {
+		sqlStore, _, receiver, teardown := setup(t, http.StatusOK)
+		defer teardown()
+
+		enqueue(t, sqlStore, 1)
+		deliverer := supervisor.NewWebhookDeliverer(sqlStore, time.Hour, time.Hour, 0, model.NewID(), testlib.MakeLogger(t))
+		require.NoError(t, deliverer.Do())
+		require.Len(t, receiver.receivedIDs(), 1)
+
+		time.Sleep(5 * time.Millisecond)
+		payload2 := enqueue(t, sqlStore, 2)
+		deliverer = supervisor.NewWebhookDeliverer(sqlStore, time.Hour, time.Millisecond, 0, model.NewID(), testlib.MakeLogger(t))
+		require.NoError(t, deliverer.Do())
+		require.Len(t, receiver.receivedIDs(), 2)
+
+		deliveries, err := sqlStore.GetWebhookDeliveries(&model.WebhookDeliveryFilter{PerPage: model.AllPerPage})
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		require.Equal(t, payload2.ID, deliveries[0].Payload.ID)
+	})
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package supervisor
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+type webhookLockStore interface {
+	LockWebhook(webhookID, lockerID string) (bool, error)
+	UnlockWebhook(webhookID, lockerID string, force bool) (bool, error)
+}
+
+type webhookLock struct {
+	webhookID string
+	lockerID  string
+	store     webhookLockStore
+	logger    log.FieldLogger
+}
+
+func newWebhookLock(webhookID, lockerID string, store webhookLockStore, logger log.FieldLogger) *webhookLock {
+	return &webhookLock{
+		webhookID: webhookID,
+		lockerID:  lockerID,
+		store:     store,
+		logger:    logger,
+	}
+}
+
+func (l *webhookLock) TryLock() bool {
+	locked, err := l.store.LockWebhook(l.webhookID, l.lockerID)
+	if err != nil {
+		l.logger.WithError(err).Error("failed to lock webhook")
+		return false
+	}
+
+	return locked
+}
+
+func (l *webhookLock) Unlock() {
+	unlocked, err := l.store.UnlockWebhook(l.webhookID, l.lockerID, false)
+	if err != nil {
+		l.logger.WithError(err).Error("failed to unlock webhook")
+	} else if !unlocked {
+		l.logger.Error("failed to release lock for webhook")
+	}
+}
@@ -35,13 +35,15 @@ func (c *Cmd) Init(remoteKey string) error {
 }
 
 // Plan invokes terraform Plan.
-func (c *Cmd) Plan(accountProvision model.AccountProvision, subnet, accountID string) error {
+func (c *Cmd) Plan(accountProvision model.AccountProvision, subnet, subnetIPv6, accountID string) error {
 	if _, _, err := c.run(
 		"plan",
 		arg("input", "false"),
 		arg("var", fmt.Sprintf("region=%s", DefaultAWSRegion)),
 		arg("var", fmt.Sprintf("environment=%s", accountProvision.Environment)),
 		arg("var", fmt.Sprintf("vpc_cidr=%s", subnet)),
+		arg("var", fmt.Sprintf("vpc_ipv6_cidr=%s", subnetIPv6)),
+		arg("var", fmt.Sprintf("vpc_ipv6_pool_id=%s", accountProvision.IPv6PoolID)),
 		arg("var", fmt.Sprintf("transit_gateway_id=%s", accountProvision.TransitGatewayID)),
 		arg("var", fmt.Sprintf("transit_gtw_route_destinations=%s", accountProvision.TransitGatewayRoutes)),
 		arg("var", fmt.Sprintf("teleport_cidr=%s", accountProvision.TeleportCIDR)),
@@ -56,13 +58,15 @@ func (c *Cmd) Plan(accountProvision model.AccountProvision, subnet, accountID st
 }
 
 // Apply invokes terraform apply.
-func (c *Cmd) Apply(accountProvision model.AccountProvision, subnet, accountID string) error {
+func (c *Cmd) Apply(accountProvision model.AccountProvision, subnet, subnetIPv6, accountID string) error {
 	if _, _, err := c.run(
 		"apply",
 		arg("input", "false"),
 		arg("var", fmt.Sprintf("region=%s", DefaultAWSRegion)),
 		arg("var", fmt.Sprintf("environment=%s", accountProvision.Environment)),
 		arg("var", fmt.Sprintf("vpc_cidr=%s", subnet)),
+		arg("var", fmt.Sprintf("vpc_ipv6_cidr=%s", subnetIPv6)),
+		arg("var", fmt.Sprintf("vpc_ipv6_pool_id=%s", accountProvision.IPv6PoolID)),
 		arg("var", fmt.Sprintf("transit_gateway_id=%s", accountProvision.TransitGatewayID)),
 		arg("var", fmt.Sprintf("transit_gtw_route_destinations=%s", accountProvision.TransitGatewayRoutes)),
 		arg("var", fmt.Sprintf("teleport_cidr=%s", accountProvision.TeleportCIDR)),
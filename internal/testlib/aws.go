@@ -16,6 +16,7 @@ type AWSMockedAPI struct {
 	STS            *mocks.MockSTSAPI
 	ServiceCatalog *mocks.MockServiceCatalogAPI
 	RAM            *mocks.MockRAMAPI
+	Organizations  *mocks.MockOrganizationsAPI
 }
 
 // NewAWSMockedAPI returns an instance of AWSMockedAPI.
@@ -26,5 +27,6 @@ func NewAWSMockedAPI(ctrl *gomock.Controller) *AWSMockedAPI {
 		STS:            mocks.NewMockSTSAPI(ctrl),
 		ServiceCatalog: mocks.NewMockServiceCatalogAPI(ctrl),
 		RAM:            mocks.NewMockRAMAPI(ctrl),
+		Organizations:  mocks.NewMockOrganizationsAPI(ctrl),
 	}
 }
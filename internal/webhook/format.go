@@ -0,0 +1,160 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	chatUsername = "Genesis"
+
+	chatColorFailed     = "#d24b4e"
+	chatColorWarning    = "#ffbc1f"
+	chatColorInProgress = "#2389d7"
+	chatColorSuccess    = "#06d6a0"
+	chatColorInactive   = "#8b8b8b"
+)
+
+// chatMessage is the body of a Mattermost or Slack incoming webhook request.
+type chatMessage struct {
+	Username    string           `json:"username"`
+	Text        string           `json:"text,omitempty"`
+	Attachments []chatAttachment `json:"attachments"`
+}
+
+type chatAttachment struct {
+	Fallback  string      `json:"fallback"`
+	Color     string      `json:"color"`
+	Title     string      `json:"title"`
+	Text      string      `json:"text,omitempty"`
+	Fields    []chatField `json:"fields,omitempty"`
+	Footer    string      `json:"footer,omitempty"`
+	Timestamp int64       `json:"ts,omitempty"`
+}
+
+type chatField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// formatPayload returns the body sent to a webhook with the given format.
+func formatPayload(format string, payload *model.WebhookPayload) ([]byte, error) {
+	switch format {
+	case "", model.WebhookFormatGenesis:
+		payloadStr, err := payload.ToJSON()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(payloadStr), nil
+	case model.WebhookFormatMattermost, model.WebhookFormatSlack:
+		return json.Marshal(newChatMessage(format, payload))
+	default:
+		return nil, errors.Errorf("unsupported webhook format %s", format)
+	}
+}
+
+// newChatMessage renders the given payload as a message attachment coloured by
+// the new state of the resource. Mattermost and Slack only differ by the
+// markdown flavour they use for bold text.
+func newChatMessage(format string, payload *model.WebhookPayload) *chatMessage {
+	bold := func(s string) string {
+		if format == model.WebhookFormatSlack {
+			return "*" + s + "*"
+		}
+		return "**" + s + "**"
+	}
+
+	title := chatTitle(payload)
+	attachment := chatAttachment{
+		Fallback: title,
+		Color:    chatColor(payload),
+		Title:    title,
+		Footer:   chatUsername,
+	}
+	if payload.OldState != "" && payload.NewState != "" {
+		attachment.Text = fmt.Sprintf("%s → %s", bold(payload.OldState), bold(payload.NewState))
+	} else if payload.NewState != "" {
+		attachment.Text = bold(payload.NewState)
+	}
+	if format == model.WebhookFormatSlack {
+		attachment.Timestamp = time.Unix(0, payload.Timestamp).Unix()
+	}
+
+	attachment.Fields = append(attachment.Fields, chatField{Title: "Type", Value: payload.Type, Short: true})
+	attachment.Fields = append(attachment.Fields, chatField{Title: "ID", Value: payload.ID, Short: true})
+	accountID := payload.ExtraData["AccountID"]
+	if payload.Type == model.TypeAccount {
+		accountID = payload.ID
+	}
+	if accountID != "" {
+		attachment.Fields = append(attachment.Fields, chatField{Title: "Account ID", Value: accountID, Short: true})
+	}
+	if environment := payload.ExtraData["Environment"]; environment != "" {
+		attachment.Fields = append(attachment.Fields, chatField{Title: "Environment", Value: environment, Short: true})
+	}
+
+	var keys []string
+	for key := range payload.ExtraData {
+		if key != "AccountID" && key != "Environment" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attachment.Fields = append(attachment.Fields, chatField{Title: key, Value: payload.ExtraData[key], Short: true})
+	}
+
+	return &chatMessage{
+		Username:    chatUsername,
+		Attachments: []chatAttachment{attachment},
+	}
+}
+
+// chatTitle returns a one line summary of the given payload.
+func chatTitle(payload *model.WebhookPayload) string {
+	switch payload.Type {
+	case model.TypeAccount:
+		return fmt.Sprintf("Account %s is %s", payload.ID, payload.NewState)
+	case model.TypeSubnet:
+		return fmt.Sprintf("Subnet %s %s", payload.ExtraData["CIDR"], payload.NewState)
+	case model.TypeWebhookPing:
+		return "Genesis webhook ping"
+	case model.TypeParentSubnet:
+		if payload.ExtraData["Event"] == "low-watermark" {
+			return fmt.Sprintf("Parent subnet %s is below %s%% free", payload.ExtraData["CIDR"], payload.ExtraData["Watermark"])
+		}
+		return fmt.Sprintf("Parent subnet %s added", payload.ExtraData["CIDR"])
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%s %s %s", payload.Type, payload.ID, payload.NewState))
+	}
+}
+
+// chatColor returns the attachment colour of the given payload: red for
+// failures, yellow for warnings, blue for work in progress, green for stable
+// resources and grey for resources that are gone.
+func chatColor(payload *model.WebhookPayload) string {
+	switch {
+	case strings.HasSuffix(payload.NewState, "-failed"):
+		return chatColorFailed
+	case payload.ExtraData["Event"] == "low-watermark":
+		return chatColorWarning
+	case payload.NewState == model.AccountStateStable, payload.NewState == model.SubnetStateClaimed:
+		return chatColorSuccess
+	case payload.NewState == model.AccountStateDeleted, payload.NewState == model.SubnetStateReleased,
+		payload.NewState == model.SubnetStateQuarantined:
+		return chatColorInactive
+	default:
+		return chatColorInProgress
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattermost/genesis/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatPayload(t *testing.T) {
+	payload := &model.WebhookPayload{
+		Type:      model.TypeAccount,
+		ID:        "account1",
+		OldState:  model.AccountStateCreationRequested,
+		NewState:  model.AccountStateCreationFailed,
+		Timestamp: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC).UnixNano(),
+		ExtraData: map[string]string{"Environment": "prod"},
+	}
+
+	t.Run("genesis", func(t *testing.T) {
+		body, err := formatPayload(model.WebhookFormatGenesis, payload)
+		require.NoError(t, err)
+
+		payloadStr, err := payload.ToJSON()
+		require.NoError(t, err)
+		require.Equal(t, payloadStr, string(body))
+	})
+
+	t.Run("mattermost", func(t *testing.T) {
+		body, err := formatPayload(model.WebhookFormatMattermost, payload)
+		require.NoError(t, err)
+
+		var message chatMessage
+		require.NoError(t, json.Unmarshal(body, &message))
+		require.Equal(t, chatMessage{
+			Username: "Genesis",
+			Attachments: []chatAttachment{{
+				Fallback: "Account account1 is creation-failed",
+				Color:    chatColorFailed,
+				Title:    "Account account1 is creation-failed",
+				Text:     "**creation-requested** → **creation-failed**",
+				Fields: []chatField{
+					{Title: "Type", Value: model.TypeAccount, Short: true},
+					{Title: "ID", Value: "account1", Short: true},
+					{Title: "Account ID", Value: "account1", Short: true},
+					{Title: "Environment", Value: "prod", Short: true},
+				},
+				Footer: "Genesis",
+			}},
+		}, message)
+	})
+
+	t.Run("slack", func(t *testing.T) {
+		subnetPayload := &model.WebhookPayload{
+			Type:      model.TypeSubnet,
+			ID:        "subnet1",
+			NewState:  model.SubnetStateClaimed,
+			Timestamp: payload.Timestamp,
+			ExtraData: map[string]string{"CIDR": "10.0.0.0/24", "ParentSubnet": "10.0.0.0/16", "AccountID": "account1"},
+		}
+		body, err := formatPayload(model.WebhookFormatSlack, subnetPayload)
+		require.NoError(t, err)
+
+		var message chatMessage
+		require.NoError(t, json.Unmarshal(body, &message))
+		require.Len(t, message.Attachments, 1)
+		attachment := message.Attachments[0]
+		require.Equal(t, "Subnet 10.0.0.0/24 claimed", attachment.Title)
+		require.Equal(t, "*claimed*", attachment.Text)
+		require.Equal(t, chatColorSuccess, attachment.Color)
+		require.EqualValues(t, 1609556645, attachment.Timestamp)
+		require.Equal(t, []chatField{
+			{Title: "Type", Value: model.TypeSubnet, Short: true},
+			{Title: "ID", Value: "subnet1", Short: true},
+			{Title: "Account ID", Value: "account1", Short: true},
+			{Title: "CIDR", Value: "10.0.0.0/24", Short: true},
+			{Title: "ParentSubnet", Value: "10.0.0.0/16", Short: true},
+		}, attachment.Fields)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := formatPayload("teams", payload)
+		require.EqualError(t, err, "unsupported webhook format teams")
+	})
+}
+
+func TestChatColor(t *testing.T) {
+	var testCases = []struct {
+		payload  *model.WebhookPayload
+		expected string
+	}{
+		{&model.WebhookPayload{NewState: model.AccountStateDeletionFailed}, chatColorFailed},
+		{&model.WebhookPayload{ExtraData: map[string]string{"Event": "low-watermark"}}, chatColorWarning},
+		{&model.WebhookPayload{NewState: model.AccountStateStable}, chatColorSuccess},
+		{&model.WebhookPayload{NewState: model.SubnetStateClaimed}, chatColorSuccess},
+		{&model.WebhookPayload{NewState: model.AccountStateDeleted}, chatColorInactive},
+		{&model.WebhookPayload{NewState: model.SubnetStateQuarantined}, chatColorInactive},
+		{&model.WebhookPayload{NewState: model.AccountStateCreationRequested}, chatColorInProgress},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.payload.NewState, func(t *testing.T) {
+			require.Equal(t, tc.expected, chatColor(tc.payload))
+		})
+	}
+}
+
+func TestDeliverChatFormat(t *testing.T) {
+	var message chatMessage
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+	}))
+	defer ts.Close()
+
+	payload := &model.WebhookPayload{Type: model.TypeAccount, ID: "account1", NewState: model.AccountStateStable, Timestamp: time.Now().UnixNano()}
+	_, err := Deliver(&model.Webhook{ID: model.NewID(), URL: ts.URL, Format: model.WebhookFormatMattermost}, payload)
+	require.NoError(t, err)
+	require.Equal(t, "Account account1 is stable", message.Attachments[0].Title)
+}
@@ -6,7 +6,10 @@ package webhook
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/mattermost/genesis/model"
@@ -14,58 +17,94 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// maxResponseSnippet is the number of bytes of the response of a webhook
+// receiver kept in ping results.
+const maxResponseSnippet = 512
+
 type webhookStore interface {
-	GetWebhooks(filter *model.WebhookFilter) ([]*model.Webhook, error)
+	EnqueueWebhookPayload(payload *model.WebhookPayload) error
 }
 
-// SendToAllWebhooks sends a given payload to all webhooks.
+// SendToAllWebhooks adds the given payload to the outbox of all webhooks. The
+// payload is delivered in the background by the webhook deliverer, which retries
+// failed deliveries.
 func SendToAllWebhooks(store webhookStore, payload *model.WebhookPayload, logger *log.Entry) error {
-	hooks, err := store.GetWebhooks(&model.WebhookFilter{
-		PerPage:        model.AllPerPage,
-		IncludeDeleted: false,
-	})
-	if err != nil {
-		return errors.Wrap(err, "Failed to find webhooks")
+	if err := store.EnqueueWebhookPayload(payload); err != nil {
+		return errors.Wrap(err, "Failed to enqueue webhook payload")
 	}
 
-	sendWebhooks(hooks, payload, logger)
+	logger.Debug("Enqueued webhook payload")
 
 	return nil
 }
 
-// sendWebhooks sends webhooks via fire-and-forget goroutines. The send-webhook
-// failures are logged, but not handled.
-func sendWebhooks(hooks []*model.Webhook, payload *model.WebhookPayload, logger *log.Entry) {
-	if len(hooks) == 0 {
-		return
-	}
-
-	logger.Debugf("Sending %d webhook(s)", len(hooks))
+// Deliver sends the given payload to the given webhook in the format of the
+// webhook, with its custom headers and credentials, signed with the secret of
+// the webhook if it has one, and returns the status code of the response, or 0
+// if the receiver did not respond. Responses with a status code other than 2xx
+// are failures.
+func Deliver(hook *model.Webhook, payload *model.WebhookPayload) (int, error) {
+	statusCode, _, err := send(hook, payload)
+	return statusCode, err
+}
 
-	for _, hook := range hooks {
-		go sendWebhook(hook, payload, logger) //nolint
+// Ping synchronously sends a ping payload to the given webhook and reports how
+// the receiver responded.
+func Ping(hook *model.Webhook) *model.WebhookPingResult {
+	start := time.Now()
+	statusCode, response, err := send(hook, model.NewWebhookPingPayload(hook.ID))
+	result := &model.WebhookPingResult{
+		StatusCode: statusCode,
+		Latency:    int64(time.Since(start) / time.Millisecond),
+		Response:   response,
 	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	return result
 }
 
-func sendWebhook(hook *model.Webhook, payload *model.WebhookPayload, logger *log.Entry) error {
-	payloadStr, err := payload.ToJSON()
+// send posts the given payload to the given webhook and returns the status code
+// and the beginning of the body of the response.
+func send(hook *model.Webhook, payload *model.WebhookPayload) (int, string, error) {
+	body, err := formatPayload(hook.Format, payload)
 	if err != nil {
-		logger.WithField("webhookURL", hook.URL).WithError(err).Error("Unable to create payload string to send to webhook")
-		return errors.Wrap(err, "unable to create payload string to send to webhook")
+		return 0, "", errors.Wrap(err, "unable to create payload string to send to webhook")
 	}
 
-	req, err := http.NewRequest("POST", hook.URL, bytes.NewBuffer([]byte(payloadStr)))
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewBuffer(body))
 	if err != nil {
-		logger.WithField("webhookURL", hook.URL).WithError(err).Error("Unable to create webhook request")
-		return errors.Wrap(err, "unable to create webhook request")
+		return 0, "", errors.Wrap(err, "unable to create webhook request")
+	}
+	for name, value := range hook.Headers {
+		req.Header.Set(name, value)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	hook.Auth.Apply(req)
+	if hook.IsSigned() {
+		// Payloads are signed when they are sent, so that retries and
+		// redeliveries of old payloads are not rejected as replays.
+		sentAt := time.Now().UnixNano()
+		req.Header.Set(model.WebhookTimestampHeader, strconv.FormatInt(sentAt, 10))
+		req.Header.Set(model.WebhookSignatureHeader, model.SignWebhookPayload(hook.Secret, sentAt, body))
+	}
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	if _, err = client.Do(req); err != nil {
-		logger.WithField("webhookURL", hook.URL).WithError(err).Error("Unable to send webhook")
-		return errors.Wrap(err, "unable to send webhook")
+	client := &http.Client{Timeout: hook.Timeout()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", errors.Wrap(err, "unable to send webhook")
 	}
+	defer resp.Body.Close()
 
-	return nil
+	response, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSnippet))
+	if err != nil {
+		return resp.StatusCode, "", errors.Wrap(err, "unable to read webhook response")
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(response), errors.Errorf("webhook receiver responded with status code %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, string(response), nil
 }
@@ -5,73 +5,61 @@
 package webhook
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/mattermost/genesis/internal/testlib"
 	"github.com/mattermost/genesis/model"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
 
 type mockWebhookStore struct {
-	Webhooks []*model.Webhook
+	Payloads     []*model.WebhookPayload
+	EnqueueError error
 }
 
-func (s *mockWebhookStore) GetWebhooks(filter *model.WebhookFilter) ([]*model.Webhook, error) {
-	return s.Webhooks, nil
+func (s *mockWebhookStore) EnqueueWebhookPayload(payload *model.WebhookPayload) error {
+	if s.EnqueueError != nil {
+		return s.EnqueueError
+	}
+	s.Payloads = append(s.Payloads, payload)
+
+	return nil
 }
 
-func TestGetAndSendWebhooks(t *testing.T) {
+func TestSendToAllWebhooks(t *testing.T) {
 	mockStore := &mockWebhookStore{}
 	logger := testlib.MakeLogger(t).WithFields(log.Fields{
 		"webhooks-tests": true,
 	})
 
-	t.Run("no webhooks", func(t *testing.T) {
-		err := SendToAllWebhooks(mockStore, nil, logger)
-		require.NoError(t, err)
-	})
-
-	mockStore.Webhooks = append(mockStore.Webhooks, &model.Webhook{
-		ID:       model.NewID(),
-		OwnerID:  model.NewID(),
-		URL:      "https://test.com",
-		CreateAt: 10,
-		DeleteAt: 0,
-	})
+	payload := &model.WebhookPayload{
+		Type:      "type",
+		ID:        model.NewID(),
+		Timestamp: time.Now().UnixNano(),
+	}
 
-	t.Run("1 webhook", func(t *testing.T) {
-		err := SendToAllWebhooks(mockStore, nil, logger)
+	t.Run("enqueued", func(t *testing.T) {
+		err := SendToAllWebhooks(mockStore, payload, logger)
 		require.NoError(t, err)
+		require.Equal(t, []*model.WebhookPayload{payload}, mockStore.Payloads)
 	})
 
-	mockStore.Webhooks = append(mockStore.Webhooks, &model.Webhook{
-		ID:       model.NewID(),
-		OwnerID:  model.NewID(),
-		URL:      "https://test2.com",
-		CreateAt: 10,
-		DeleteAt: 0,
-	})
-
-	t.Run("2 webhooks", func(t *testing.T) {
-		err := SendToAllWebhooks(mockStore, nil, logger)
-		require.NoError(t, err)
+	t.Run("store error", func(t *testing.T) {
+		mockStore.EnqueueError = errors.New("database is down")
+		err := SendToAllWebhooks(mockStore, payload, logger)
+		require.Error(t, err)
 	})
 }
 
-// TODO: add happy-path test.
-func TestSendWebhooks(t *testing.T) {
-	logger := testlib.MakeLogger(t).WithFields(log.Fields{
-		"webhooks-tests": true,
-	})
-	hook := &model.Webhook{
-		ID:       model.NewID(),
-		OwnerID:  model.NewID(),
-		URL:      "https://not-a-real-host",
-		CreateAt: 10,
-		DeleteAt: 0,
-	}
+func TestDeliver(t *testing.T) {
 	payload := &model.WebhookPayload{
 		Type:      "type",
 		ID:        model.NewID(),
@@ -81,6 +69,135 @@ func TestSendWebhooks(t *testing.T) {
 		ExtraData: map[string]string{"AccountID": model.NewID()},
 	}
 
-	err := sendWebhook(hook, payload, logger)
-	require.Contains(t, err.Error(), "unable to send webhook")
+	t.Run("unreachable receiver", func(t *testing.T) {
+		hook := &model.Webhook{
+			ID:  model.NewID(),
+			URL: "https://not-a-real-host",
+		}
+
+		statusCode, err := Deliver(hook, payload)
+		require.Contains(t, err.Error(), "unable to send webhook")
+		require.Equal(t, 0, statusCode)
+	})
+
+	t.Run("delivered", func(t *testing.T) {
+		var received *model.WebhookPayload
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var err error
+			received, err = model.WebhookPayloadFromReader(r.Body)
+			require.NoError(t, err)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+
+		statusCode, err := Deliver(&model.Webhook{ID: model.NewID(), URL: ts.URL}, payload)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNoContent, statusCode)
+		require.Equal(t, payload, received)
+	})
+
+	t.Run("signed", func(t *testing.T) {
+		var verified *model.WebhookPayload
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			verified, err = model.VerifyWebhookSignature("secret", r.Header, body, time.Minute)
+			require.NoError(t, err)
+		}))
+		defer ts.Close()
+
+		_, err := Deliver(&model.Webhook{ID: model.NewID(), URL: ts.URL, Secret: "secret"}, payload)
+		require.NoError(t, err)
+		require.Equal(t, payload, verified)
+	})
+
+	t.Run("headers and auth", func(t *testing.T) {
+		var header http.Header
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header
+		}))
+		defer ts.Close()
+
+		hook := &model.Webhook{
+			ID:      model.NewID(),
+			URL:     ts.URL,
+			Headers: map[string]string{"X-Tenant": "genesis"},
+			Auth:    &model.WebhookAuth{Type: model.WebhookAuthBearer, Token: "token"},
+		}
+		_, err := Deliver(hook, payload)
+		require.NoError(t, err)
+		require.Equal(t, "genesis", header.Get("X-Tenant"))
+		require.Equal(t, "Bearer token", header.Get("Authorization"))
+		require.Equal(t, "application/json", header.Get("Content-Type"))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		done := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-done
+		}))
+		defer ts.Close()
+		defer close(done)
+
+		hook := &model.Webhook{ID: model.NewID(), URL: ts.URL, TimeoutSeconds: 1}
+		start := time.Now()
+		statusCode, err := Deliver(hook, payload)
+		require.Error(t, err)
+		require.Equal(t, 0, statusCode)
+		require.Less(t, int64(time.Since(start)), int64(model.DefaultWebhookTimeoutSeconds*time.Second))
+	})
+
+	t.Run("error status code", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		statusCode, err := Deliver(&model.Webhook{ID: model.NewID(), URL: ts.URL}, payload)
+		require.EqualError(t, err, "webhook receiver responded with status code 503")
+		require.Equal(t, http.StatusServiceUnavailable, statusCode)
+	})
+}
+
+func TestPing(t *testing.T) {
+	t.Run("accepted", func(t *testing.T) {
+		var received *model.WebhookPayload
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var err error
+			received, err = model.WebhookPayloadFromReader(r.Body)
+			require.NoError(t, err)
+			fmt.Fprint(w, "pong")
+		}))
+		defer ts.Close()
+
+		hook := &model.Webhook{ID: model.NewID(), URL: ts.URL}
+		result := Ping(hook)
+		require.True(t, result.IsSuccessful())
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		require.Equal(t, "pong", result.Response)
+		require.GreaterOrEqual(t, result.Latency, int64(0))
+		require.Equal(t, model.TypeWebhookPing, received.Type)
+		require.Equal(t, hook.ID, received.ID)
+	})
+
+	t.Run("rejected with a long response", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, strings.Repeat("a", 2*maxResponseSnippet))
+		}))
+		defer ts.Close()
+
+		result := Ping(&model.Webhook{ID: model.NewID(), URL: ts.URL})
+		require.False(t, result.IsSuccessful())
+		require.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		require.Equal(t, "webhook receiver responded with status code 401", result.Error)
+		require.Len(t, result.Response, maxResponseSnippet)
+	})
+
+	t.Run("unreachable receiver", func(t *testing.T) {
+		result := Ping(&model.Webhook{ID: model.NewID(), URL: "https://not-a-real-host"})
+		require.False(t, result.IsSuccessful())
+		require.Equal(t, 0, result.StatusCode)
+		require.Contains(t, result.Error, "unable to send webhook")
+	})
 }
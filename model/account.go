@@ -45,6 +45,7 @@ type AccountProvision struct {
 	BindServerIPs        string
 	ResourceShareID      string
 	CoreAccountID        string
+	IPv6PoolID           string
 }
 
 // Clone returns a deep copy the account.
@@ -8,8 +8,10 @@ import "encoding/json"
 
 // AccountMetadata is the provider metadata stored in a model.Account.
 type AccountMetadata struct {
-	Provision bool
-	Subnet    string
+	Provision    bool
+	Subnet       string
+	SubnetIPv6   string            `json:",omitempty"`
+	PoolSelector map[string]string `json:",omitempty"`
 }
 
 // NewAccountMetadata creates an instance of AccountMetadata given the raw provider metadata.
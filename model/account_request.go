@@ -7,6 +7,7 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"net"
 	"net/url"
 	"strconv"
 
@@ -15,11 +16,15 @@ import (
 
 // CreateAccountRequest specifies the parameters for a new account.
 type CreateAccountRequest struct {
-	Provider                string `json:"provider,omitempty"`
-	ServiceCatalogProductID string `json:"serviceCatalogProductID,omitempty"`
-	Provision               bool   `json:"provision,omitempty"`
-	Subnet                  string `json:"subnet,omitempty"`
-	APISecurityLock         bool   `json:"api-security-lock,omitempty"`
+	Provider                string            `json:"provider,omitempty"`
+	ServiceCatalogProductID string            `json:"serviceCatalogProductID,omitempty"`
+	Provision               bool              `json:"provision,omitempty"`
+	Subnet                  string            `json:"subnet,omitempty"`
+	SubnetPrefixLength      int               `json:"subnetPrefixLength,omitempty"`
+	PoolSelector            map[string]string `json:"poolSelector,omitempty"`
+	DualStack               bool              `json:"dualStack,omitempty"`
+	SubnetIPv6              string            `json:"subnetIPv6,omitempty"`
+	APISecurityLock         bool              `json:"api-security-lock,omitempty"`
 }
 
 // SetDefaults sets the default values for an account create request.
@@ -39,6 +44,41 @@ func (request *CreateAccountRequest) Validate() error {
 		return errors.New("Service Catalog Product ID cannot be empty")
 	}
 
+	if err := ValidateLabels(request.PoolSelector); err != nil {
+		return errors.Wrap(err, "invalid pool selector")
+	}
+
+	return validateAccountSubnets(request.Subnet, request.SubnetPrefixLength, request.SubnetIPv6, request.DualStack)
+}
+
+// validateAccountSubnets validates the requested subnets of an account.
+func validateAccountSubnets(subnet string, prefixLength int, subnetIPv6 string, dualStack bool) error {
+	if subnet != "" {
+		if SubnetFamily(subnet) != SubnetFamilyIPv4 {
+			return errors.Errorf("subnet %s is not an IPv4 subnet", subnet)
+		}
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return errors.Wrapf(err, "invalid subnet %s", subnet)
+		}
+		if ones, _ := ipNet.Mask.Size(); prefixLength != 0 && ones != prefixLength {
+			return errors.Errorf("subnet %s does not match the requested prefix length /%d", subnet, prefixLength)
+		}
+	}
+
+	if prefixLength != 0 && !IsValidSubnetPrefixLength(prefixLength) {
+		return errors.Errorf("subnet prefix length must be between /%d and /%d", MinSubnetPrefixLength, MaxSubnetPrefixLength)
+	}
+
+	if subnetIPv6 != "" {
+		if !dualStack {
+			return errors.New("an IPv6 subnet can only be requested for dual-stack accounts")
+		}
+		if SubnetFamily(subnetIPv6) != SubnetFamilyIPv6 {
+			return errors.Errorf("subnet %s is not an IPv6 subnet", subnetIPv6)
+		}
+	}
+
 	return nil
 }
 
@@ -79,7 +119,20 @@ func (request *GetAccountsRequest) ApplyToURL(u *url.URL) {
 
 // ProvisionAccountRequest contains metadata related to changing the installed account state.
 type ProvisionAccountRequest struct {
-	Subnet string
+	Subnet             string
+	SubnetPrefixLength int               `json:",omitempty"`
+	PoolSelector       map[string]string `json:",omitempty"`
+	DualStack          bool              `json:",omitempty"`
+	SubnetIPv6         string            `json:",omitempty"`
+}
+
+// Validate validates the values of an account provision request.
+func (request *ProvisionAccountRequest) Validate() error {
+	if err := ValidateLabels(request.PoolSelector); err != nil {
+		return errors.Wrap(err, "invalid pool selector")
+	}
+
+	return validateAccountSubnets(request.Subnet, request.SubnetPrefixLength, request.SubnetIPv6, request.DualStack)
 }
 
 // NewProvisionAccountRequestFromReader will create an UpdateAccountRequest from an io.Reader with JSON data.
@@ -89,5 +142,10 @@ func NewProvisionAccountRequestFromReader(reader io.Reader) (*ProvisionAccountRe
 	if err != nil && err != io.EOF {
 		return nil, errors.Wrap(err, "failed to decode provision account request")
 	}
+
+	if err = provisionAccountRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "provision account request failed validation")
+	}
+
 	return &provisionAccountRequest, nil
 }
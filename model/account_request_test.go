@@ -20,6 +20,16 @@ func TestCreateAccountRequestValid(t *testing.T) {
 		{"defaults", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345"}, false},
 		{"invalid provider", &model.CreateAccountRequest{Provider: "blah"}, true},
 		{"invalid service catalog product id", &model.CreateAccountRequest{ServiceCatalogProductID: ""}, true},
+		{"dual stack", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", DualStack: true, Subnet: "10.0.0.0/24", SubnetIPv6: "2600:1f18:1000::/56"}, false},
+		{"ipv6 subnet without dual stack", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", SubnetIPv6: "2600:1f18:1000::/56"}, true},
+		{"ipv6 subnet as ipv4 subnet", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", Subnet: "2600:1f18:1000::/56"}, true},
+		{"ipv4 subnet as ipv6 subnet", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", DualStack: true, SubnetIPv6: "10.0.0.0/24"}, true},
+		{"prefix length", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", SubnetPrefixLength: 20}, false},
+		{"prefix length matching subnet", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", Subnet: "10.0.0.0/20", SubnetPrefixLength: 20}, false},
+		{"prefix length not matching subnet", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", Subnet: "10.0.0.0/24", SubnetPrefixLength: 20}, true},
+		{"prefix length too short", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", SubnetPrefixLength: 8}, true},
+		{"prefix length too long", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", SubnetPrefixLength: 28}, true},
+		{"invalid subnet", &model.CreateAccountRequest{ServiceCatalogProductID: "prod-12345", Subnet: "10.0.0.0//24"}, true},
 	}
 
 	for _, tc := range testCases {
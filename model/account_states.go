@@ -15,6 +15,8 @@ const (
 	AccountStateProvisioningRequested = "provisioning-requested"
 	// AccountStateRefreshMetadata is a account that will have metadata refreshed.
 	AccountStateRefreshMetadata = "refresh-metadata"
+	// AccountStateRefreshMetadataFailed is a account that failed to have its metadata refreshed.
+	AccountStateRefreshMetadataFailed = "refresh-metadata-failed"
 	// AccountStateProvisioningFailed is a account that failed provisioning.
 	AccountStateProvisioningFailed = "provisioning-failed"
 	// AccountStateDeletionRequested is a account in the process of being deleted.
@@ -31,6 +33,7 @@ const (
 var AllAccountStates = []string{
 	AccountStateStable,
 	AccountStateRefreshMetadata,
+	AccountStateRefreshMetadataFailed,
 	AccountStateCreationRequested,
 	AccountStateCreationFailed,
 	AccountStateProvisioningRequested,
@@ -60,6 +63,7 @@ var AllAccountStatesPendingWork = []string{
 var AllAccountRequestStates = []string{
 	AccountStateCreationRequested,
 	AccountStateProvisioningRequested,
+	AccountStateRefreshMetadata,
 	AccountStateDeletionRequested,
 }
 
@@ -71,6 +75,8 @@ func (c *Account) ValidTransitionState(newState string) bool {
 		return validTransitionToAccountStateCreationRequested(c.State)
 	case AccountStateProvisioningRequested:
 		return validTransitionToAccountStateProvisioningRequested(c.State)
+	case AccountStateRefreshMetadata:
+		return validTransitionToAccountStateRefreshMetadata(c.State)
 	case AccountStateDeletionRequested:
 		return validTransitionToAccountStateDeletionRequested(c.State)
 	}
@@ -92,7 +98,22 @@ func validTransitionToAccountStateProvisioningRequested(currentState string) boo
 	switch currentState {
 	case AccountStateStable,
 		AccountStateProvisioningFailed,
-		AccountStateProvisioningRequested:
+		AccountStateProvisioningRequested,
+		AccountStateRefreshMetadataFailed:
+		return true
+	}
+
+	return false
+}
+
+// validTransitionToAccountStateRefreshMetadata does not allow accounts whose
+// provisioning failed, as a successful refresh moves the account to stable and
+// would hide the failure.
+func validTransitionToAccountStateRefreshMetadata(currentState string) bool {
+	switch currentState {
+	case AccountStateStable,
+		AccountStateRefreshMetadata,
+		AccountStateRefreshMetadataFailed:
 		return true
 	}
 
@@ -105,6 +126,7 @@ func validTransitionToAccountStateDeletionRequested(currentState string) bool {
 		AccountStateCreationRequested,
 		AccountStateCreationFailed,
 		AccountStateProvisioningFailed,
+		AccountStateRefreshMetadataFailed,
 		AccountStateDeletionRequested,
 		AccountStateDeletionFailed:
 		return true
@@ -0,0 +1,33 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountValidTransitionStateRefreshMetadata(t *testing.T) {
+	var testCases = []struct {
+		State    string
+		Expected bool
+	}{
+		{AccountStateStable, true},
+		{AccountStateRefreshMetadata, true},
+		{AccountStateRefreshMetadataFailed, true},
+		{AccountStateProvisioningFailed, false},
+		{AccountStateProvisioningRequested, false},
+		{AccountStateCreationFailed, false},
+		{AccountStateDeletionRequested, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.State, func(t *testing.T) {
+			account := &Account{State: tc.State}
+			require.Equal(t, tc.Expected, account.ValidTransitionState(AccountStateRefreshMetadata))
+		})
+	}
+}
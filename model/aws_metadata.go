@@ -8,9 +8,17 @@ import "encoding/json"
 
 // AWSMetadata is the provider metadata stored in a model.Account.
 type AWSMetadata struct {
-	ServiceCatalogProductID string
-	AWSAccountID            string
-	AccountProductID        string
+	ServiceCatalogProductID       string
+	AWSAccountID                  string
+	AccountProductID              string
+	AccountProductStatus          string   `json:",omitempty"`
+	AccountAliases                []string `json:",omitempty"`
+	OrganizationalUnit            string   `json:",omitempty"`
+	VPCID                         string   `json:",omitempty"`
+	SubnetIDs                     []string `json:",omitempty"`
+	TransitGatewayAttachmentID    string   `json:",omitempty"`
+	TransitGatewayAttachmentState string   `json:",omitempty"`
+	MetadataRefreshedAt           int64    `json:",omitempty"`
 }
 
 // NewAWSMetadata creates an instance of AWSMetadata given the raw provider metadata.
@@ -147,6 +147,24 @@ func (c *Client) ProvisionAccount(accountID string, request *ProvisionAccountReq
 	}
 }
 
+// RefreshAccount requests a refresh of the metadata of an account from the
+// configured genesis server.
+func (c *Client) RefreshAccount(accountID string) (*Account, error) {
+	resp, err := c.doPost(c.buildURL("/api/account/%s/refresh", accountID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return AccountFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
 // GetAccount fetches the specified account from the configured genesis server.
 func (c *Client) GetAccount(accountID string) (*Account, error) {
 	resp, err := c.doGet(c.buildURL("/api/account/%s", accountID))
@@ -286,6 +304,85 @@ func (c *Client) DeleteWebhook(webhookID string) error {
 	}
 }
 
+// GetWebhookDeliveries fetches the list of deliveries of the given webhook, with
+// their attempts, from the configured genesis server.
+func (c *Client) GetWebhookDeliveries(webhookID string, request *GetWebhookDeliveriesRequest) ([]*WebhookDelivery, error) {
+	u, err := url.Parse(c.buildURL("/api/webhook/%s/deliveries", webhookID))
+	if err != nil {
+		return nil, err
+	}
+
+	request.ApplyToURL(u)
+
+	resp, err := c.doGet(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return WebhookDeliveriesFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// RedeliverWebhookDelivery requests a new delivery of the payload of the given
+// webhook delivery.
+func (c *Client) RedeliverWebhookDelivery(webhookID, deliveryID string) (*WebhookDelivery, error) {
+	resp, err := c.doPost(c.buildURL("/api/webhook/%s/deliveries/%s/redeliver", webhookID, deliveryID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return WebhookDeliveryFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// EnableWebhook pings the given webhook and enables it again if the ping is
+// delivered.
+func (c *Client) EnableWebhook(webhookID string) (*Webhook, error) {
+	resp, err := c.doPost(c.buildURL("/api/webhook/%s/enable", webhookID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return WebhookFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// PingWebhook sends a ping to the given webhook and returns how the receiver
+// responded.
+func (c *Client) PingWebhook(webhookID string) (*WebhookPingResult, error) {
+	resp, err := c.doPost(c.buildURL("/api/webhook/%s/ping", webhookID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return WebhookPingResultFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
 // LockAPIForAccount locks API changes for a given account.
 func (c *Client) LockAPIForAccount(accountID string) error {
 	return c.makeSecurityCall("account", accountID, "api", "lock")
@@ -373,6 +470,120 @@ func (c *Client) GetParentSubnet(subnet string) (*ParentSubnet, error) {
 	}
 }
 
+// GetParentSubnetUsage fetches the usage of the specified parent subnet from the configured genesis server.
+func (c *Client) GetParentSubnetUsage(parentSubnetID string) (*ParentSubnetUsage, error) {
+	resp, err := c.doGet(c.buildURL("/api/subnets/parent/%s/usage", parentSubnetID))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ParentSubnetUsageFromReader(resp.Body)
+
+	case http.StatusNotFound:
+		return nil, nil
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// GetParentSubnetsUsage fetches the usage of all parent subnets from the configured genesis server.
+func (c *Client) GetParentSubnetsUsage() ([]*ParentSubnetUsage, error) {
+	resp, err := c.doGet(c.buildURL("/api/subnets/parent/usage"))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ParentSubnetUsagesFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// DeleteParentSubnet deletes the given parent subnet from the configured genesis server.
+func (c *Client) DeleteParentSubnet(parentSubnetID string) error {
+	resp, err := c.doDelete(c.buildURL("/api/subnet/parent/%s", parentSubnetID))
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	default:
+		return errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// UpdateParentSubnetLabels replaces the labels of the given parent subnet on the configured genesis server.
+func (c *Client) UpdateParentSubnetLabels(parentSubnetID string, request *UpdateParentSubnetLabelsRequest) (*ParentSubnet, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnet/parent/%s/labels", parentSubnetID), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ParentSubnetFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// UpdateParentSubnetAllocationStrategy sets the allocation strategy of the given parent subnet.
+func (c *Client) UpdateParentSubnetAllocationStrategy(parentSubnetID string, request *UpdateParentSubnetAllocationStrategyRequest) (*ParentSubnet, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnet/parent/%s/allocation-strategy", parentSubnetID), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ParentSubnetFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// RetireParentSubnet stops new subnets from being claimed from the given parent subnet.
+func (c *Client) RetireParentSubnet(parentSubnetID string) error {
+	return c.makeParentSubnetCall(parentSubnetID, "retire")
+}
+
+// UnretireParentSubnet allows subnets to be claimed again from the given parent subnet.
+func (c *Client) UnretireParentSubnet(parentSubnetID string) error {
+	return c.makeParentSubnetCall(parentSubnetID, "unretire")
+}
+
+func (c *Client) makeParentSubnetCall(parentSubnetID, action string) error {
+	resp, err := c.doPost(c.buildURL("/api/subnet/parent/%s/%s", parentSubnetID, action), nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	default:
+		return errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
 // GetSubnets fetches the list of subnets from the configured genesis server.
 func (c *Client) GetSubnets(request *GetSubnetsRequest) ([]*Subnet, error) {
 	u, err := url.Parse(c.buildURL("/api/subnets"))
@@ -397,6 +608,34 @@ func (c *Client) GetSubnets(request *GetSubnetsRequest) ([]*Subnet, error) {
 	}
 }
 
+// GetSubnetOwners fetches the subnets containing the given IP address along with
+// their parent subnet and the account they are claimed for from the configured
+// genesis server.
+func (c *Client) GetSubnetOwners(address string) ([]*SubnetOwner, error) {
+	u, err := url.Parse(c.buildURL("/api/subnets"))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Add("contains", address)
+	u.RawQuery = q.Encode()
+
+	resp, err := c.doGet(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetOwnersFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
 // GetSubnet fetches the specified subnet from the configured genesis server.
 func (c *Client) GetSubnet(subnet string) (*Subnet, error) {
 	resp, err := c.doGet(c.buildURL("/api/subnet/%s", subnet))
@@ -416,3 +655,220 @@ func (c *Client) GetSubnet(subnet string) (*Subnet, error) {
 		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
 	}
 }
+
+// ReserveSubnet reserves a free subnet on the configured genesis server so that it cannot be claimed by accounts.
+func (c *Client) ReserveSubnet(request *ReserveSubnetRequest) (*Subnet, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnets/reserve"), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return SubnetFromReader(resp.Body)
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// ReconcileSubnets reports the subnet claims out of sync with the accounts on the
+// configured genesis server and fixes them if requested.
+func (c *Client) ReconcileSubnets(request *ReconcileSubnetsRequest) ([]*SubnetDrift, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnets/reconcile"), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetDriftsFromReader(resp.Body)
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// DiscoverSubnets discovers the VPCs of AWS accounts on the configured genesis
+// server, marking the subnets they use as externally used unless only a report
+// is requested.
+func (c *Client) DiscoverSubnets(request *DiscoverSubnetsRequest) (*SubnetDiscovery, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnets/discover"), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetDiscoveryFromReader(resp.Body)
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// GetSubnetAllocations fetches the list of subnet allocations from the configured genesis server.
+func (c *Client) GetSubnetAllocations(request *GetSubnetAllocationsRequest) ([]*SubnetAllocation, error) {
+	u, err := url.Parse(c.buildURL("/api/subnets/history"))
+	if err != nil {
+		return nil, err
+	}
+
+	request.ApplyToURL(u)
+
+	resp, err := c.doGet(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetAllocationsFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// GetSubnetExclusions fetches the list of subnet exclusions from the configured genesis server.
+func (c *Client) GetSubnetExclusions(request *GetSubnetExclusionsRequest) ([]*SubnetExclusion, error) {
+	u, err := url.Parse(c.buildURL("/api/subnets/exclusions"))
+	if err != nil {
+		return nil, err
+	}
+
+	request.ApplyToURL(u)
+
+	resp, err := c.doGet(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetExclusionsFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// GetSubnetExclusion fetches the specified subnet exclusion from the configured genesis server.
+func (c *Client) GetSubnetExclusion(exclusionID string) (*SubnetExclusion, error) {
+	resp, err := c.doGet(c.buildURL("/api/subnet/exclusion/%s", exclusionID))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetExclusionFromReader(resp.Body)
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// AddSubnetExclusion excludes a range from the subnets of the configured genesis server.
+func (c *Client) AddSubnetExclusion(request *AddSubnetExclusionRequest) (*SubnetExclusion, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnets/exclusions"), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetExclusionFromReader(resp.Body)
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// DeleteSubnetExclusion deletes the given subnet exclusion from the configured genesis server.
+func (c *Client) DeleteSubnetExclusion(exclusionID string) error {
+	resp, err := c.doDelete(c.buildURL("/api/subnet/exclusion/%s", exclusionID))
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	default:
+		return errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// ReserveExistingSubnet reserves a stored subnet on the configured genesis server or updates its reservation.
+func (c *Client) ReserveExistingSubnet(subnetID string, request *ReserveSubnetRequest) (*Subnet, error) {
+	resp, err := c.doPost(c.buildURL("/api/subnet/%s/reserve", subnetID), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return SubnetFromReader(resp.Body)
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// ReleaseSubnet releases a subnet reservation on the configured genesis server.
+func (c *Client) ReleaseSubnet(subnetID string) error {
+	resp, err := c.doPost(c.buildURL("/api/subnet/%s/release", subnetID), nil)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	default:
+		return errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// ExportIPAM exports the parent subnets, subnets and subnet exclusions from the configured genesis server.
+func (c *Client) ExportIPAM() (*IPAMExport, error) {
+	resp, err := c.doGet(c.buildURL("/api/ipam/export"))
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return IPAMExportFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
+
+// ImportIPAM imports an IPAM export into the configured genesis server.
+func (c *Client) ImportIPAM(request *ImportIPAMRequest) (*IPAMImportReport, error) {
+	resp, err := c.doPost(c.buildURL("/api/ipam/import"), request)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return IPAMImportReportFromReader(resp.Body)
+
+	default:
+		return nil, errors.Errorf("failed with status code %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// IPAMExportVersion is the version of the IPAM exports written by this
+	// version of Genesis.
+	IPAMExportVersion = 1
+
+	// IPAMExportFormatJSON is the JSON format of IPAM exports.
+	IPAMExportFormatJSON = "json"
+	// IPAMExportFormatCSV is the CSV format of IPAM exports, with one record
+	// per parent subnet, subnet and subnet exclusion.
+	IPAMExportFormatCSV = "csv"
+)
+
+const (
+	// IPAMRecordParentSubnet is the type of the parent subnet records.
+	IPAMRecordParentSubnet = "parent-subnet"
+	// IPAMRecordSubnet is the type of the claimed and reserved subnet records.
+	IPAMRecordSubnet = "subnet"
+	// IPAMRecordExclusion is the type of the subnet exclusion records.
+	IPAMRecordExclusion = "exclusion"
+)
+
+// ipamCSVVersionRecord is the type of the first record of CSV exports, which
+// holds the export version and time.
+const ipamCSVVersionRecord = "version"
+
+// ipamCSVHeader is the header of the records of CSV exports.
+var ipamCSVHeader = []string{
+	"Type", "ID", "CIDR", "ParentSubnet", "SplitRange", "Retired", "Labels", "AllocationStrategy",
+	"AccountID", "ReservationOwner", "ReservationReason", "ReservationExpiresAt", "Source", "Reason", "CreateAt",
+}
+
+// IPAMExport is the state of the parent subnets, of their claimed and reserved
+// subnets and of the subnet exclusions, used to back it up or to move it to
+// another Genesis instance.
+type IPAMExport struct {
+	Version       int
+	ExportedAt    int64
+	ParentSubnets []*ParentSubnet
+	// Subnets are the subnets claimed by accounts and the reserved subnets.
+	Subnets    []*Subnet
+	Exclusions []*SubnetExclusion
+}
+
+// Validate validates that the export is of a supported version and that its
+// ranges are consistent with each other.
+func (e *IPAMExport) Validate() error {
+	if e.Version != IPAMExportVersion {
+		return errors.Errorf("unsupported IPAM export version %d", e.Version)
+	}
+
+	parentNets := make([]*net.IPNet, 0, len(e.ParentSubnets))
+	for _, parentSubnet := range e.ParentSubnets {
+		_, parentNet, err := net.ParseCIDR(parentSubnet.CIDR)
+		if err != nil {
+			return errors.Wrapf(err, "invalid parent subnet CIDR %s", parentSubnet.CIDR)
+		}
+		ones, bits := parentNet.Mask.Size()
+		if parentSubnet.SplitRange < ones || parentSubnet.SplitRange > bits {
+			return errors.Errorf("split range /%d must be between /%d and /%d for parent subnet %s", parentSubnet.SplitRange, ones, bits, parentSubnet.CIDR)
+		}
+		if parentSubnet.AllocationStrategy != "" && !IsValidSubnetAllocationStrategy(parentSubnet.AllocationStrategy) {
+			return errors.Errorf("unsupported allocation strategy %s of parent subnet %s", parentSubnet.AllocationStrategy, parentSubnet.CIDR)
+		}
+		if err = ValidateLabels(parentSubnet.Labels); err != nil {
+			return errors.Wrapf(err, "invalid labels of parent subnet %s", parentSubnet.CIDR)
+		}
+		for i, otherNet := range parentNets {
+			if netsOverlap(parentNet, otherNet) {
+				return errors.Errorf("parent subnet %s overlaps parent subnet %s", parentSubnet.CIDR, e.ParentSubnets[i].CIDR)
+			}
+		}
+		parentNets = append(parentNets, parentNet)
+	}
+
+	subnetNets := make([]*net.IPNet, 0, len(e.Subnets))
+	for _, subnet := range e.Subnets {
+		_, subnetNet, err := net.ParseCIDR(subnet.CIDR)
+		if err != nil {
+			return errors.Wrapf(err, "invalid subnet CIDR %s", subnet.CIDR)
+		}
+		if subnet.AccountID == "" && !subnet.IsReserved() {
+			return errors.Errorf("subnet %s is neither claimed nor reserved", subnet.CIDR)
+		}
+		inParent := false
+		for i, parentNet := range parentNets {
+			if e.ParentSubnets[i].CIDR == subnet.ParentSubnet && netContains(parentNet, subnetNet) {
+				inParent = true
+				break
+			}
+		}
+		if !inParent {
+			return errors.Errorf("subnet %s is not in its parent subnet %s", subnet.CIDR, subnet.ParentSubnet)
+		}
+		for i, otherNet := range subnetNets {
+			if netsOverlap(subnetNet, otherNet) {
+				return errors.Errorf("subnet %s overlaps subnet %s", subnet.CIDR, e.Subnets[i].CIDR)
+			}
+		}
+		subnetNets = append(subnetNets, subnetNet)
+	}
+
+	for _, exclusion := range e.Exclusions {
+		if _, _, err := net.ParseCIDR(exclusion.CIDR); err != nil {
+			return errors.Wrapf(err, "invalid subnet exclusion CIDR %s", exclusion.CIDR)
+		}
+		if exclusion.Source == "" {
+			return errors.Errorf("subnet exclusion %s has no source", exclusion.CIDR)
+		}
+	}
+
+	return nil
+}
+
+// netsOverlap returns true if the given networks have addresses in common.
+func netsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// netContains returns true if the given parent network contains the whole child network.
+func netContains(parent, child *net.IPNet) bool {
+	parentOnes, parentBits := parent.Mask.Size()
+	childOnes, childBits := child.Mask.Size()
+	return parentBits == childBits && childOnes >= parentOnes && parent.Contains(child.IP)
+}
+
+// WriteCSV writes the export in CSV format to the given io.Writer. The first
+// record holds the export version and time, and is followed by a header and by
+// one record per parent subnet, subnet and subnet exclusion.
+func (e *IPAMExport) WriteCSV(writer io.Writer) error {
+	w := csv.NewWriter(writer)
+
+	records := [][]string{
+		{ipamCSVVersionRecord, strconv.Itoa(e.Version), strconv.FormatInt(e.ExportedAt, 10)},
+		ipamCSVHeader,
+	}
+	for _, parentSubnet := range e.ParentSubnets {
+		var labels string
+		if len(parentSubnet.Labels) != 0 {
+			labelsJSON, err := json.Marshal(parentSubnet.Labels)
+			if err != nil {
+				return errors.Wrapf(err, "failed to encode labels of parent subnet %s", parentSubnet.CIDR)
+			}
+			labels = string(labelsJSON)
+		}
+		records = append(records, []string{
+			IPAMRecordParentSubnet, parentSubnet.ID, parentSubnet.CIDR, "",
+			strconv.Itoa(parentSubnet.SplitRange), strconv.FormatBool(parentSubnet.Retired), labels, parentSubnet.AllocationStrategy,
+			"", "", "", "", "", "", strconv.FormatInt(parentSubnet.CreateAt, 10),
+		})
+	}
+	for _, subnet := range e.Subnets {
+		records = append(records, []string{
+			IPAMRecordSubnet, subnet.ID, subnet.CIDR, subnet.ParentSubnet,
+			"", "", "", "",
+			subnet.AccountID, subnet.ReservationOwner, subnet.ReservationReason, strconv.FormatInt(subnet.ReservationExpiresAt, 10),
+			"", "", strconv.FormatInt(subnet.CreateAt, 10),
+		})
+	}
+	for _, exclusion := range e.Exclusions {
+		records = append(records, []string{
+			IPAMRecordExclusion, exclusion.ID, exclusion.CIDR, "",
+			"", "", "", "",
+			"", "", "", "",
+			exclusion.Source, exclusion.Reason, strconv.FormatInt(exclusion.CreateAt, 10),
+		})
+	}
+
+	if err := w.WriteAll(records); err != nil {
+		return errors.Wrap(err, "failed to write CSV records")
+	}
+
+	return nil
+}
+
+// IPAMExportFromCSV decodes a CSV-encoded IPAM export from the given io.Reader.
+func IPAMExportFromCSV(reader io.Reader) (*IPAMExport, error) {
+	r := csv.NewReader(reader)
+	r.FieldsPerRecord = -1
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CSV records")
+	}
+	if len(records) < 2 || len(records[0]) != 3 || records[0][0] != ipamCSVVersionRecord {
+		return nil, errors.New("missing IPAM export version record")
+	}
+
+	export := &IPAMExport{}
+	if export.Version, err = strconv.Atoi(records[0][1]); err != nil {
+		return nil, errors.Wrap(err, "invalid IPAM export version")
+	}
+	if export.ExportedAt, err = strconv.ParseInt(records[0][2], 10, 64); err != nil {
+		return nil, errors.Wrap(err, "invalid IPAM export time")
+	}
+
+	columns := make(map[string]int, len(records[1]))
+	for i, name := range records[1] {
+		columns[name] = i
+	}
+	for _, name := range ipamCSVHeader {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.Errorf("missing CSV column %s", name)
+		}
+	}
+
+	for i, record := range records[2:] {
+		line := i + 3
+		if len(record) != len(records[1]) {
+			return nil, errors.Errorf("record on line %d has %d fields instead of %d", line, len(record), len(records[1]))
+		}
+		field := func(name string) string {
+			return record[columns[name]]
+		}
+		createAt, err := parseOptionalInt(field("CreateAt"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid creation time on line %d", line)
+		}
+
+		switch field("Type") {
+		case IPAMRecordParentSubnet:
+			splitRange, err := strconv.Atoi(field("SplitRange"))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid split range on line %d", line)
+			}
+			retired, err := strconv.ParseBool(field("Retired"))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid retired flag on line %d", line)
+			}
+			var labels map[string]string
+			if field("Labels") != "" {
+				labels, err = NewParentSubnetLabels([]byte(field("Labels")))
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid labels on line %d", line)
+				}
+			}
+			export.ParentSubnets = append(export.ParentSubnets, &ParentSubnet{
+				ID:                 field("ID"),
+				CIDR:               field("CIDR"),
+				SplitRange:         splitRange,
+				Retired:            retired,
+				Labels:             labels,
+				AllocationStrategy: field("AllocationStrategy"),
+				CreateAt:           createAt,
+			})
+		case IPAMRecordSubnet:
+			expiresAt, err := parseOptionalInt(field("ReservationExpiresAt"))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid reservation expiry on line %d", line)
+			}
+			export.Subnets = append(export.Subnets, &Subnet{
+				ID:                   field("ID"),
+				CIDR:                 field("CIDR"),
+				ParentSubnet:         field("ParentSubnet"),
+				AccountID:            field("AccountID"),
+				ReservationOwner:     field("ReservationOwner"),
+				ReservationReason:    field("ReservationReason"),
+				ReservationExpiresAt: expiresAt,
+				CreateAt:             createAt,
+			})
+		case IPAMRecordExclusion:
+			export.Exclusions = append(export.Exclusions, &SubnetExclusion{
+				ID:       field("ID"),
+				CIDR:     field("CIDR"),
+				Source:   field("Source"),
+				Reason:   field("Reason"),
+				CreateAt: createAt,
+			})
+		default:
+			return nil, errors.Errorf("unknown record type %q on line %d", field("Type"), line)
+		}
+	}
+
+	return export, nil
+}
+
+// parseOptionalInt parses the given integer, which is zero if empty.
+func parseOptionalInt(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// IPAMExportFromReader decodes a json-encoded IPAM export from the given io.Reader.
+func IPAMExportFromReader(reader io.Reader) (*IPAMExport, error) {
+	export := IPAMExport{}
+	decoder := json.NewDecoder(reader)
+	err := decoder.Decode(&export)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// IPAMImportConflict is a range of an IPAM export that cannot be imported
+// because of the existing parent subnets, subnets or subnet exclusions.
+type IPAMImportConflict struct {
+	// Type is the type of the conflicting record, one of the IPAM record types.
+	Type   string
+	CIDR   string
+	Reason string
+}
+
+// IPAMImportReport reports what an IPAM import added, or would add in dry-run
+// mode. Records already present are counted as existing and left unchanged.
+type IPAMImportReport struct {
+	DryRun bool
+	// Applied is true if the import was applied, which only happens outside
+	// of dry-run mode and when there are no conflicts.
+	Applied               bool
+	ParentSubnetsAdded    int
+	ParentSubnetsExisting int
+	SubnetsAdded          int
+	SubnetsExisting       int
+	ExclusionsAdded       int
+	ExclusionsExisting    int
+	Conflicts             []*IPAMImportConflict
+}
+
+// IPAMImportReportFromReader decodes a json-encoded IPAM import report from the given io.Reader.
+func IPAMImportReportFromReader(reader io.Reader) (*IPAMImportReport, error) {
+	report := IPAMImportReport{}
+	decoder := json.NewDecoder(reader)
+	err := decoder.Decode(&report)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &report, nil
+}
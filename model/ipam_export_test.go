@@ -0,0 +1,91 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIPAMExport() *IPAMExport {
+	return &IPAMExport{
+		Version:    IPAMExportVersion,
+		ExportedAt: 1600000000000,
+		ParentSubnets: []*ParentSubnet{
+			{ID: "parent1", CIDR: "10.0.0.0/22", SplitRange: 24, Labels: map[string]string{"region": "us-east-1"}, CreateAt: 10},
+			{ID: "parent2", CIDR: "2600:1f18:1000::/52", SplitRange: 56, Retired: true, AllocationStrategy: SubnetAllocationBestFit, CreateAt: 20},
+		},
+		Subnets: []*Subnet{
+			{ID: "subnet1", CIDR: "10.0.0.0/24", ParentSubnet: "10.0.0.0/22", AccountID: "account1", CreateAt: 30},
+			{ID: "subnet2", CIDR: "10.0.1.0/24", ParentSubnet: "10.0.0.0/22", ReservationOwner: "network", ReservationReason: "VPN, on-prem", ReservationExpiresAt: 1700000000000, CreateAt: 40},
+		},
+		Exclusions: []*SubnetExclusion{
+			{ID: "exclusion1", CIDR: "10.0.3.0/26", Source: SubnetExclusionSourceAPI, Reason: "transit gateway", CreateAt: 50},
+		},
+	}
+}
+
+func TestIPAMExportCSV(t *testing.T) {
+	export := newTestIPAMExport()
+
+	var buffer bytes.Buffer
+	require.NoError(t, export.WriteCSV(&buffer))
+	require.True(t, strings.HasPrefix(buffer.String(), "version,1,1600000000000\n"))
+
+	decoded, err := IPAMExportFromCSV(&buffer)
+	require.NoError(t, err)
+	require.Equal(t, export, decoded)
+
+	t.Run("missing version record", func(t *testing.T) {
+		_, err := IPAMExportFromCSV(strings.NewReader("Type,ID,CIDR\n"))
+		require.Error(t, err)
+	})
+
+	t.Run("unknown record type", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, (&IPAMExport{Version: IPAMExportVersion}).WriteCSV(&buffer))
+		buffer.WriteString("vpc,,10.0.0.0/16,,,,,,,,,,,,\n")
+
+		_, err := IPAMExportFromCSV(&buffer)
+		require.EqualError(t, err, `unknown record type "vpc" on line 3`)
+	})
+}
+
+func TestIPAMExportValidate(t *testing.T) {
+	var testCases = []struct {
+		description string
+		change      func(export *IPAMExport)
+		expectError bool
+	}{
+		{"valid", func(export *IPAMExport) {}, false},
+		{"unsupported version", func(export *IPAMExport) { export.Version = 2 }, true},
+		{"invalid parent subnet", func(export *IPAMExport) { export.ParentSubnets[0].CIDR = "10.0.0.0/33" }, true},
+		{"invalid split range", func(export *IPAMExport) { export.ParentSubnets[0].SplitRange = 20 }, true},
+		{"overlapping parent subnets", func(export *IPAMExport) {
+			export.ParentSubnets = append(export.ParentSubnets, &ParentSubnet{CIDR: "10.0.2.0/23", SplitRange: 24})
+		}, true},
+		{"subnet outside its parent subnet", func(export *IPAMExport) { export.Subnets[0].CIDR = "10.0.4.0/24" }, true},
+		{"subnet of an unknown parent subnet", func(export *IPAMExport) { export.Subnets[0].ParentSubnet = "10.1.0.0/22" }, true},
+		{"overlapping subnets", func(export *IPAMExport) { export.Subnets[1].CIDR = "10.0.0.128/25" }, true},
+		{"subnet neither claimed nor reserved", func(export *IPAMExport) { export.Subnets[0].AccountID = "" }, true},
+		{"exclusion without source", func(export *IPAMExport) { export.Exclusions[0].Source = "" }, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			export := newTestIPAMExport()
+			tc.change(export)
+			err := export.Validate()
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
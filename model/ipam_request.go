@@ -0,0 +1,44 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ImportIPAMRequest specifies an IPAM export to import and whether to apply
+// it. Imports only report what they would change unless Apply is set.
+type ImportIPAMRequest struct {
+	Apply  bool        `json:"apply,omitempty"`
+	Export *IPAMExport `json:"export,omitempty"`
+}
+
+// Validate validates the values of an IPAM import request.
+func (request *ImportIPAMRequest) Validate() error {
+	if request.Export == nil {
+		return errors.New("IPAM export cannot be empty")
+	}
+
+	return request.Export.Validate()
+}
+
+// NewImportIPAMRequestFromReader will create an ImportIPAMRequest from an
+// io.Reader with JSON data.
+func NewImportIPAMRequestFromReader(reader io.Reader) (*ImportIPAMRequest, error) {
+	var importIPAMRequest ImportIPAMRequest
+	err := json.NewDecoder(reader).Decode(&importIPAMRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode import IPAM request")
+	}
+
+	if err = importIPAMRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "import IPAM request failed validation")
+	}
+
+	return &importIPAMRequest, nil
+}
@@ -7,16 +7,30 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"math/big"
+	"regexp"
+
+	"github.com/pkg/errors"
 )
 
+// ErrParentSubnetOverlap is returned when a parent subnet overlaps an
+// existing parent subnet.
+var ErrParentSubnetOverlap = errors.New("parent subnet overlaps an existing parent subnet")
+
+// labelPattern is the pattern that label keys and values must match.
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
 // ParentSubnet represents a parent subnet range.
 type ParentSubnet struct {
-	ID             string
-	CIDR           string
-	SplitRange     int
-	CreateAt       int64
-	LockAcquiredBy *string
-	LockAcquiredAt int64
+	ID                 string
+	CIDR               string
+	SplitRange         int
+	Retired            bool
+	Labels             map[string]string `json:",omitempty"`
+	AllocationStrategy string            `json:",omitempty"`
+	CreateAt           int64
+	LockAcquiredBy     *string
+	LockAcquiredAt     int64
 }
 
 // Clone returns a deep copy of the parent subnet.
@@ -34,6 +48,51 @@ func (c *ParentSubnet) Clone() (*ParentSubnet, error) {
 	return &clone, nil
 }
 
+// MatchesSelector returns true if the parent subnet has all the labels of the
+// given selector. An empty selector matches every parent subnet.
+func (c *ParentSubnet) MatchesSelector(selector map[string]string) bool {
+	for key, value := range selector {
+		if labelValue, ok := c.Labels[key]; !ok || labelValue != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewParentSubnetLabels creates the labels of a parent subnet given their raw JSON.
+func NewParentSubnetLabels(labelsBytes []byte) (map[string]string, error) {
+	if labelsBytes == nil || string(labelsBytes) == "null" {
+		return nil, nil
+	}
+
+	var labels map[string]string
+	err := json.Unmarshal(labelsBytes, &labels)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	return labels, nil
+}
+
+// ValidateLabels validates the keys and values of parent subnet labels or of a
+// parent subnet selector.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelPattern.MatchString(key) {
+			return errors.Errorf("invalid label key %q", key)
+		}
+		if !labelPattern.MatchString(value) {
+			return errors.Errorf("invalid value %q of label %s", value, key)
+		}
+	}
+
+	return nil
+}
+
 // ParentSubnetFromReader decodes a json-encoded parent subnet from the given io.Reader.
 func ParentSubnetFromReader(reader io.Reader) (*ParentSubnet, error) {
 	account := ParentSubnet{}
@@ -59,6 +118,49 @@ func ParentSubnetsFromReader(reader io.Reader) ([]*ParentSubnet, error) {
 	return parentSubnets, nil
 }
 
+// ParentSubnetUsage reports how much of a parent subnet is allocated. All the
+// counts are in subnets of the split range of the parent subnet, and a subnet
+// of the split range that is partly claimed, reserved or excluded is counted
+// once, so that Claimed, Reserved, Excluded and Free add up to Total.
+type ParentSubnetUsage struct {
+	ParentSubnetID string
+	CIDR           string
+	SplitRange     int
+	Retired        bool
+	Labels         map[string]string `json:",omitempty"`
+	Total          *big.Int
+	Free           *big.Int
+	Claimed        *big.Int
+	Reserved       *big.Int
+	Excluded       *big.Int
+	FreePercent    float64
+}
+
+// ParentSubnetUsageFromReader decodes a json-encoded parent subnet usage from the given io.Reader.
+func ParentSubnetUsageFromReader(reader io.Reader) (*ParentSubnetUsage, error) {
+	usage := ParentSubnetUsage{}
+	decoder := json.NewDecoder(reader)
+	err := decoder.Decode(&usage)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// ParentSubnetUsagesFromReader decodes a json-encoded list of parent subnet usages from the given io.Reader.
+func ParentSubnetUsagesFromReader(reader io.Reader) ([]*ParentSubnetUsage, error) {
+	usages := []*ParentSubnetUsage{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&usages)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return usages, nil
+}
+
 // ParentSubnetFilter describes the parameters used to constrain a set of parent subnets.
 type ParentSubnetFilter struct {
 	Page    int
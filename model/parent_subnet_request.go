@@ -7,6 +7,7 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"net"
 	"net/url"
 	"strconv"
 
@@ -15,12 +16,28 @@ import (
 
 // AddParentSubnetRequest specifies the parameters for a new parent subnet.
 type AddParentSubnetRequest struct {
-	CIDR       string `json:"cidr,omitempty"`
-	SplitRange int    `json:"splitRange,omitempty"`
+	CIDR               string            `json:"cidr,omitempty"`
+	SplitRange         int               `json:"splitRange,omitempty"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	AllocationStrategy string            `json:"allocationStrategy,omitempty"`
 }
 
+const (
+	// DefaultSplitRangeIPv4 is the default split range of IPv4 parent subnets.
+	DefaultSplitRangeIPv4 = 24
+	// DefaultSplitRangeIPv6 is the default split range of IPv6 parent subnets,
+	// which is the size of the IPv6 block AWS assigns to a VPC.
+	DefaultSplitRangeIPv6 = 56
+)
+
 // SetDefaults sets the default values for a parent subnet create request.
 func (request *AddParentSubnetRequest) SetDefaults() {
+	if request.SplitRange == 0 {
+		request.SplitRange = DefaultSplitRangeIPv4
+		if SubnetFamily(request.CIDR) == SubnetFamilyIPv6 {
+			request.SplitRange = DefaultSplitRangeIPv6
+		}
+	}
 }
 
 // Validate validates the values of a parent subnet create request.
@@ -29,7 +46,24 @@ func (request *AddParentSubnetRequest) Validate() error {
 		return errors.New("Parent CIDR cannot be empty")
 	}
 
-	return nil
+	ip, ipNet, err := net.ParseCIDR(request.CIDR)
+	if err != nil {
+		return errors.Wrapf(err, "invalid parent CIDR %s", request.CIDR)
+	}
+	if !ip.Equal(ipNet.IP) {
+		return errors.Errorf("parent CIDR %s has host bits set, did you mean %s", request.CIDR, ipNet)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if request.SplitRange < ones || request.SplitRange > bits {
+		return errors.Errorf("split range /%d must be between /%d and /%d for parent CIDR %s", request.SplitRange, ones, bits, request.CIDR)
+	}
+
+	if request.AllocationStrategy != "" && !IsValidSubnetAllocationStrategy(request.AllocationStrategy) {
+		return errors.Errorf("unsupported allocation strategy %s", request.AllocationStrategy)
+	}
+
+	return ValidateLabels(request.Labels)
 }
 
 // NewAddParentSubnetRequestFromReader will create a AddParentSubnetRequest from an
@@ -62,3 +96,55 @@ func (request *GetParentSubnetsRequest) ApplyToURL(u *url.URL) {
 	q.Add("per_page", strconv.Itoa(request.PerPage))
 	u.RawQuery = q.Encode()
 }
+
+// UpdateParentSubnetLabelsRequest specifies the labels that replace the labels of a parent subnet.
+type UpdateParentSubnetLabelsRequest struct {
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewUpdateParentSubnetLabelsRequestFromReader will create an UpdateParentSubnetLabelsRequest
+// from an io.Reader with JSON data.
+func NewUpdateParentSubnetLabelsRequestFromReader(reader io.Reader) (*UpdateParentSubnetLabelsRequest, error) {
+	var updateParentSubnetLabelsRequest UpdateParentSubnetLabelsRequest
+	err := json.NewDecoder(reader).Decode(&updateParentSubnetLabelsRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode update parent subnet labels request")
+	}
+
+	if err = ValidateLabels(updateParentSubnetLabelsRequest.Labels); err != nil {
+		return nil, errors.Wrap(err, "update parent subnet labels request failed validation")
+	}
+
+	return &updateParentSubnetLabelsRequest, nil
+}
+
+// UpdateParentSubnetAllocationStrategyRequest specifies the allocation strategy of
+// a parent subnet. An empty allocation strategy removes it.
+type UpdateParentSubnetAllocationStrategyRequest struct {
+	AllocationStrategy string `json:"allocationStrategy,omitempty"`
+}
+
+// Validate validates the values of a parent subnet allocation strategy update request.
+func (request *UpdateParentSubnetAllocationStrategyRequest) Validate() error {
+	if request.AllocationStrategy != "" && !IsValidSubnetAllocationStrategy(request.AllocationStrategy) {
+		return errors.Errorf("unsupported allocation strategy %s", request.AllocationStrategy)
+	}
+
+	return nil
+}
+
+// NewUpdateParentSubnetAllocationStrategyRequestFromReader will create an
+// UpdateParentSubnetAllocationStrategyRequest from an io.Reader with JSON data.
+func NewUpdateParentSubnetAllocationStrategyRequestFromReader(reader io.Reader) (*UpdateParentSubnetAllocationStrategyRequest, error) {
+	var updateRequest UpdateParentSubnetAllocationStrategyRequest
+	err := json.NewDecoder(reader).Decode(&updateRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode update parent subnet allocation strategy request")
+	}
+
+	if err = updateRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "update parent subnet allocation strategy request failed validation")
+	}
+
+	return &updateRequest, nil
+}
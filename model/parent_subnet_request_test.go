@@ -19,6 +19,14 @@ func TestAddParentSubnetRequestValid(t *testing.T) {
 	}{
 		{"defaults", &model.AddParentSubnetRequest{CIDR: "10.0.0.0/8"}, false},
 		{"empty CIDR", &model.AddParentSubnetRequest{CIDR: ""}, true},
+		{"ipv6 defaults", &model.AddParentSubnetRequest{CIDR: "2600:1f18:1000::/48"}, false},
+		{"invalid CIDR", &model.AddParentSubnetRequest{CIDR: "10.0.0.0//8"}, true},
+		{"host bits set", &model.AddParentSubnetRequest{CIDR: "10.0.0.5/8", SplitRange: 24}, true},
+		{"split range smaller than parent", &model.AddParentSubnetRequest{CIDR: "10.0.0.0/16", SplitRange: 8}, true},
+		{"split range larger than address", &model.AddParentSubnetRequest{CIDR: "10.0.0.0/16", SplitRange: 33}, true},
+		{"split range equal to parent", &model.AddParentSubnetRequest{CIDR: "10.0.0.0/16", SplitRange: 16}, false},
+		{"allocation strategy", &model.AddParentSubnetRequest{CIDR: "10.0.0.0/16", AllocationStrategy: model.SubnetAllocationBestFit}, false},
+		{"unsupported allocation strategy", &model.AddParentSubnetRequest{CIDR: "10.0.0.0/16", AllocationStrategy: "first-fit"}, true},
 	}
 
 	for _, tc := range testCases {
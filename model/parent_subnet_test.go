@@ -76,3 +76,25 @@ func TestParentSubnetsFromReader(t *testing.T) {
 		}, parentSubnet)
 	})
 }
+
+func TestParentSubnetMatchesSelector(t *testing.T) {
+	parentSubnet := &ParentSubnet{
+		Labels: map[string]string{"environment": "production", "region": "us-east-1"},
+	}
+
+	require.True(t, parentSubnet.MatchesSelector(nil))
+	require.True(t, parentSubnet.MatchesSelector(map[string]string{"environment": "production"}))
+	require.True(t, parentSubnet.MatchesSelector(map[string]string{"environment": "production", "region": "us-east-1"}))
+	require.False(t, parentSubnet.MatchesSelector(map[string]string{"environment": "sandbox"}))
+	require.False(t, parentSubnet.MatchesSelector(map[string]string{"tier": "enterprise"}))
+	require.False(t, (&ParentSubnet{}).MatchesSelector(map[string]string{"environment": "production"}))
+}
+
+func TestValidateLabels(t *testing.T) {
+	require.NoError(t, ValidateLabels(nil))
+	require.NoError(t, ValidateLabels(map[string]string{"environment": "production", "team/owner": "cloud.sre-1"}))
+	require.Error(t, ValidateLabels(map[string]string{"": "production"}))
+	require.Error(t, ValidateLabels(map[string]string{"environment": ""}))
+	require.Error(t, ValidateLabels(map[string]string{"environment": "prod uction"}))
+	require.Error(t, ValidateLabels(map[string]string{"-environment": "production"}))
+}
@@ -7,17 +7,102 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SubnetFamilyIPv4 is the address family of IPv4 subnets.
+	SubnetFamilyIPv4 = "ipv4"
+	// SubnetFamilyIPv6 is the address family of IPv6 subnets.
+	SubnetFamilyIPv6 = "ipv6"
+
+	// MinSubnetPrefixLength is the shortest prefix length that can be requested
+	// for an account VPC, which is the largest VPC allowed by AWS.
+	MinSubnetPrefixLength = 16
+	// MaxSubnetPrefixLength is the longest prefix length that can be requested
+	// for an account VPC, so that each of its eight subnets is at least a /28.
+	MaxSubnetPrefixLength = 25
+)
+
+var (
+	// ErrSubnetCapacityExhausted is returned when no parent subnet has a free
+	// subnet left for a claim.
+	ErrSubnetCapacityExhausted = errors.New("subnet capacity exhausted")
+	// ErrSubnetUnavailable is returned when a requested subnet is already
+	// claimed or reserved.
+	ErrSubnetUnavailable = errors.New("subnet is already claimed or reserved")
+	// ErrSubnetOutsideParentSubnets is returned when a requested subnet is not
+	// a subnet of any parent subnet.
+	ErrSubnetOutsideParentSubnets = errors.New("subnet is not a subnet of any parent subnet")
+	// ErrSubnetExcluded is returned when a requested subnet overlaps a subnet
+	// exclusion.
+	ErrSubnetExcluded = errors.New("subnet overlaps a subnet exclusion")
+)
+
+const (
+	// SubnetStateClaimed is the state of the subnets claimed by an account.
+	SubnetStateClaimed = "claimed"
+	// SubnetStateReserved is the state of the reserved subnets.
+	SubnetStateReserved = "reserved"
+	// SubnetStateQuarantined is the state of the released subnets kept in
+	// quarantine before they can be claimed again.
+	SubnetStateQuarantined = "quarantined"
+	// SubnetStateReleased is the state of the subnets returned to the pool,
+	// which are no longer stored.
+	SubnetStateReleased = "released"
+)
+
+const (
+	// SubnetAllocationSequential allocates the free subnet with the lowest
+	// address, from the oldest parent subnet that has one.
+	SubnetAllocationSequential = "sequential"
+	// SubnetAllocationRandom allocates a uniformly random free subnet.
+	SubnetAllocationRandom = "random"
+	// SubnetAllocationSpread allocates from the parent subnet with the largest
+	// share of free subnets, as far as possible from the claimed subnets.
+	SubnetAllocationSpread = "spread"
+	// SubnetAllocationBestFit allocates from the smallest run of free subnets,
+	// keeping the largest runs available for larger subnets.
+	SubnetAllocationBestFit = "best-fit"
+	// DefaultSubnetAllocationStrategy is the allocation strategy used when
+	// none is configured.
+	DefaultSubnetAllocationStrategy = SubnetAllocationRandom
 )
 
 // Subnet represents a parent subnet range.
 type Subnet struct {
-	ID             string
-	CIDR           string
-	AccountID      string
-	ParentSubnet   string
-	CreateAt       int64
-	LockAcquiredBy *string
-	LockAcquiredAt int64
+	ID                   string
+	CIDR                 string
+	AccountID            string
+	ParentSubnet         string
+	ReservationOwner     string `json:",omitempty"`
+	ReservationReason    string `json:",omitempty"`
+	ReservationExpiresAt int64  `json:",omitempty"`
+	CreateAt             int64
+	LockAcquiredBy       *string
+	LockAcquiredAt       int64
+}
+
+// SubnetClaim describes the subnet to claim for an account.
+type SubnetClaim struct {
+	// CIDR is the subnet to claim. A free subnet is allocated if it is empty.
+	CIDR string
+	// Family is the address family of the subnet.
+	Family string
+	// AccountID is the account the subnet is claimed for.
+	AccountID string
+	// PrefixLength is the size of the subnet to allocate. The split range of
+	// the parent subnet is used if it is zero.
+	PrefixLength int
+	// PoolSelector restricts the parent subnets the subnet is claimed from
+	// to the ones that have all of its labels.
+	PoolSelector map[string]string
+	// AllocationStrategy chooses the parent subnet to claim a free subnet
+	// from, and the subnet in parent subnets without an allocation strategy.
+	// The default allocation strategy is used if it is empty.
+	AllocationStrategy string
 }
 
 // Clone returns a deep copy of the subnet.
@@ -35,6 +120,71 @@ func (c *Subnet) Clone() (*Subnet, error) {
 	return &clone, nil
 }
 
+// Family returns the address family of the subnet.
+func (c *Subnet) Family() string {
+	return SubnetFamily(c.CIDR)
+}
+
+// IsReserved returns true if the subnet is reserved instead of claimed by an account.
+func (c *Subnet) IsReserved() bool {
+	return c.ReservationOwner != ""
+}
+
+// State returns whether the subnet is claimed, reserved or quarantined, as sent
+// in subnet webhooks.
+func (c *Subnet) State() string {
+	switch {
+	case c.IsQuarantined():
+		return SubnetStateQuarantined
+	case c.IsReserved():
+		return SubnetStateReserved
+	default:
+		return SubnetStateClaimed
+	}
+}
+
+// IsQuarantined returns true if the subnet was released and is in quarantine
+// before it can be claimed again.
+func (c *Subnet) IsQuarantined() bool {
+	return c.ReservationOwner == SubnetReservationOwnerQuarantine
+}
+
+// IsReservationExpired returns true if the subnet reservation has an expiry
+// that is not after the given time in milliseconds.
+func (c *Subnet) IsReservationExpired(now int64) bool {
+	return c.IsReserved() && c.ReservationExpiresAt != 0 && c.ReservationExpiresAt <= now
+}
+
+// SubnetFamily returns the address family of the given CIDR.
+func SubnetFamily(cidr string) string {
+	if strings.Contains(cidr, ":") {
+		return SubnetFamilyIPv6
+	}
+
+	return SubnetFamilyIPv4
+}
+
+// IsValidSubnetFamily returns true if the given address family is supported.
+func IsValidSubnetFamily(family string) bool {
+	return family == SubnetFamilyIPv4 || family == SubnetFamilyIPv6
+}
+
+// IsValidSubnetPrefixLength returns true if a VPC subnet of the given prefix
+// length can be requested for an account.
+func IsValidSubnetPrefixLength(prefixLength int) bool {
+	return prefixLength >= MinSubnetPrefixLength && prefixLength <= MaxSubnetPrefixLength
+}
+
+// IsValidSubnetAllocationStrategy returns true if the given allocation strategy is supported.
+func IsValidSubnetAllocationStrategy(strategy string) bool {
+	switch strategy {
+	case SubnetAllocationSequential, SubnetAllocationRandom, SubnetAllocationSpread, SubnetAllocationBestFit:
+		return true
+	}
+
+	return false
+}
+
 // SubnetFromReader decodes a json-encoded subnet from the given io.Reader.
 func SubnetFromReader(reader io.Reader) (*Subnet, error) {
 	account := Subnet{}
@@ -60,9 +210,43 @@ func SubnetsFromReader(reader io.Reader) ([]*Subnet, error) {
 	return subnets, nil
 }
 
+// SubnetOwner describes a stored subnet containing an IP address along with the
+// parent subnet it was allocated from and the account it is claimed for.
+type SubnetOwner struct {
+	Subnet *Subnet
+	// ParentSubnet is the parent subnet of the subnet, with its labels.
+	ParentSubnet *ParentSubnet `json:",omitempty"`
+	// Account is the account the subnet is claimed for, with its AWS account
+	// ID. It is empty for reserved subnets and for claims of unknown accounts.
+	Account *Account `json:",omitempty"`
+}
+
+// SubnetOwnersFromReader decodes a json-encoded list of subnet owners from the given io.Reader.
+func SubnetOwnersFromReader(reader io.Reader) ([]*SubnetOwner, error) {
+	owners := []*SubnetOwner{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&owners)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+// MaxFreeSubnetsPerPage is the largest page of free subnets that can be listed.
+// Free subnets are computed rather than stored, and a parent subnet can hold
+// billions of them, so they are always paginated.
+const MaxFreeSubnetsPerPage = 1000
+
 // SubnetFilter describes the parameters used to constrain a set of subnets.
 type SubnetFilter struct {
-	Page    int
-	PerPage int
-	Free    bool
+	Page            int
+	PerPage         int
+	Free            bool
+	Family          string
+	ParentSubnet    string
+	Reserved        bool
+	Quarantined     bool
+	IncludeReserved bool
 }
@@ -0,0 +1,52 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SubnetReservationOwnerQuarantine is the reservation owner of the released
+// subnets that cannot be claimed again until their quarantine expires.
+const SubnetReservationOwnerQuarantine = "quarantine"
+
+// SubnetAllocation records an account holding a subnet. Allocations are kept
+// after the subnet is released to find the past tenants of a CIDR.
+type SubnetAllocation struct {
+	ID           string
+	CIDR         string
+	ParentSubnet string
+	AccountID    string
+	ClaimedAt    int64
+	// ReleasedAt is zero while the account holds the subnet.
+	ReleasedAt int64
+}
+
+// IsReleased returns true if the subnet of the allocation was released.
+func (a *SubnetAllocation) IsReleased() bool {
+	return a.ReleasedAt != 0
+}
+
+// SubnetAllocationFilter describes the parameters used to constrain a set of subnet allocations.
+type SubnetAllocationFilter struct {
+	Page      int
+	PerPage   int
+	CIDR      string
+	AccountID string
+}
+
+// SubnetAllocationsFromReader decodes a json-encoded list of subnet allocations from the given io.Reader.
+func SubnetAllocationsFromReader(reader io.Reader) ([]*SubnetAllocation, error) {
+	allocations := []*SubnetAllocation{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&allocations)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return allocations, nil
+}
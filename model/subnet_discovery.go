@@ -0,0 +1,88 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	// SubnetReservationOwnerExternal is the reservation owner of the subnets
+	// marked as used by VPCs that Genesis does not manage.
+	SubnetReservationOwnerExternal = "external"
+)
+
+const (
+	// SubnetOverlapMarked is a VPC CIDR that was marked as externally used,
+	// or would be if the discovery was not only reported.
+	SubnetOverlapMarked = "marked"
+	// SubnetOverlapAlreadyMarked is a VPC CIDR that was already marked as
+	// externally used.
+	SubnetOverlapAlreadyMarked = "already-marked"
+	// SubnetOverlapManaged is a VPC CIDR inside a subnet claimed by an account,
+	// which is expected for the VPCs of the accounts created by Genesis.
+	SubnetOverlapManaged = "managed"
+	// SubnetOverlapConflicting is a VPC CIDR overlapping subnets claimed or
+	// reserved in Genesis. It is never marked automatically.
+	SubnetOverlapConflicting = "conflicting"
+)
+
+// DiscoveredVPC is a VPC found in an AWS account by a subnet discovery.
+type DiscoveredVPC struct {
+	AWSAccountID string
+	Region       string
+	VPCID        string
+	// CIDRs are the IPv4 and IPv6 CIDR blocks associated with the VPC.
+	CIDRs []string
+}
+
+// SubnetOverlap describes a CIDR block of a discovered VPC overlapping a
+// parent subnet.
+type SubnetOverlap struct {
+	AWSAccountID string
+	Region       string
+	VPCID        string
+	// CIDR is the CIDR block of the VPC.
+	CIDR string
+	// ParentSubnet is the parent subnet the CIDR block overlaps.
+	ParentSubnet string
+	// SubnetCIDR is the part of the parent subnet used by the VPC, which is
+	// the range marked as externally used.
+	SubnetCIDR string
+	// Subnets are the claimed or reserved subnets the CIDR block overlaps.
+	Subnets []string `json:",omitempty"`
+	// Status is the outcome of the overlap.
+	Status string
+}
+
+// SubnetDiscoveryFailure describes an AWS account or region whose VPCs could
+// not be discovered.
+type SubnetDiscoveryFailure struct {
+	AWSAccountID string
+	Region       string `json:",omitempty"`
+	Error        string
+}
+
+// SubnetDiscovery is the report of a subnet discovery.
+type SubnetDiscovery struct {
+	// VPCCount is the number of VPCs discovered.
+	VPCCount int
+	Overlaps []*SubnetOverlap
+	Failures []*SubnetDiscoveryFailure
+}
+
+// SubnetDiscoveryFromReader decodes a json-encoded subnet discovery report from the given io.Reader.
+func SubnetDiscoveryFromReader(reader io.Reader) (*SubnetDiscovery, error) {
+	discovery := SubnetDiscovery{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&discovery)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &discovery, nil
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	// SubnetDriftOrphanedClaim is a claimed subnet that no live account uses.
+	SubnetDriftOrphanedClaim = "orphaned-claim"
+	// SubnetDriftUnboundClaim is a claimed subnet used by a live account that
+	// is not bound to the ID of that account.
+	SubnetDriftUnboundClaim = "unbound-claim"
+	// SubnetDriftMissingClaim is a subnet used by a live account that is not claimed.
+	SubnetDriftMissingClaim = "missing-claim"
+	// SubnetDriftConflictingClaim is a subnet used by a live account that is
+	// reserved or claimed by another live account. It is never fixed automatically.
+	SubnetDriftConflictingClaim = "conflicting-claim"
+)
+
+// SubnetDrift describes a subnet claim that is out of sync with the accounts.
+type SubnetDrift struct {
+	// Type is the kind of drift.
+	Type string
+	// CIDR is the subnet range out of sync.
+	CIDR string
+	// SubnetID is the ID of the claim of the subnet, if it is claimed.
+	SubnetID string `json:",omitempty"`
+	// ClaimAccountID is the account the subnet is claimed for, if it is claimed.
+	ClaimAccountID string `json:",omitempty"`
+	// AccountID is the live account that uses the subnet, if any.
+	AccountID string `json:",omitempty"`
+	// Fixed is true if the drift was fixed.
+	Fixed bool
+	// Error is the reason the drift could not be fixed.
+	Error string `json:",omitempty"`
+}
+
+// SubnetDriftsFromReader decodes a json-encoded list of subnet drifts from the given io.Reader.
+func SubnetDriftsFromReader(reader io.Reader) ([]*SubnetDrift, error) {
+	drifts := []*SubnetDrift{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&drifts)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SubnetExclusionSourceAPI is the source of the subnet exclusions added
+	// through the API. Exclusions seeded from the server flags have the name
+	// of their flag as source.
+	SubnetExclusionSourceAPI = "api"
+)
+
+// ErrSubnetExclusionExists is returned when a range is already excluded.
+var ErrSubnetExclusionExists = errors.New("subnet exclusion already exists")
+
+// SubnetExclusion is a range used by shared infrastructure that is never
+// handed out as a subnet, whatever the parent subnets containing it.
+type SubnetExclusion struct {
+	ID       string
+	CIDR     string
+	Source   string
+	Reason   string
+	CreateAt int64
+}
+
+// SubnetExclusionFilter describes the parameters used to constrain a set of subnet exclusions.
+type SubnetExclusionFilter struct {
+	Page    int
+	PerPage int
+	// Source restricts the subnet exclusions to the ones of the given source.
+	Source string
+}
+
+// IsSeeded returns true if the subnet exclusion is seeded from a server flag
+// instead of added through the API.
+func (e *SubnetExclusion) IsSeeded() bool {
+	return e.Source != SubnetExclusionSourceAPI
+}
+
+// ParseExclusionCIDRs parses the ranges of a server flag, given either as a
+// JSON list or as a comma separated list. IP addresses are turned into host
+// prefixes and all the ranges are returned in canonical CIDR notation.
+func ParseExclusionCIDRs(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	var entries []string
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &entries); err != nil {
+			return nil, errors.Wrap(err, "failed to decode list of ranges")
+		}
+	} else {
+		entries = strings.Split(value, ",")
+	}
+
+	var cidrs []string
+	for _, entry := range entries {
+		entry = strings.Trim(strings.TrimSpace(entry), `"`)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.Errorf("invalid IP address %s", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid CIDR %s", entry)
+		}
+		cidrs = append(cidrs, ipNet.String())
+	}
+
+	return cidrs, nil
+}
+
+// SubnetExclusionFromReader decodes a json-encoded subnet exclusion from the given io.Reader.
+func SubnetExclusionFromReader(reader io.Reader) (*SubnetExclusion, error) {
+	exclusion := SubnetExclusion{}
+	decoder := json.NewDecoder(reader)
+	err := decoder.Decode(&exclusion)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &exclusion, nil
+}
+
+// SubnetExclusionsFromReader decodes a json-encoded list of subnet exclusions from the given io.Reader.
+func SubnetExclusionsFromReader(reader io.Reader) ([]*SubnetExclusion, error) {
+	exclusions := []*SubnetExclusion{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&exclusions)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return exclusions, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// GetSubnetExclusionsRequest describes the parameters to request a list of subnet exclusions.
+type GetSubnetExclusionsRequest struct {
+	Page    int
+	PerPage int
+	Source  string
+}
+
+// ApplyToURL modifies the given url to include query string parameters for the request.
+func (request *GetSubnetExclusionsRequest) ApplyToURL(u *url.URL) {
+	q := u.Query()
+	q.Add("page", strconv.Itoa(request.Page))
+	q.Add("per_page", strconv.Itoa(request.PerPage))
+	if request.Source != "" {
+		q.Add("source", request.Source)
+	}
+	u.RawQuery = q.Encode()
+}
+
+// AddSubnetExclusionRequest specifies the range to exclude from the subnets.
+type AddSubnetExclusionRequest struct {
+	CIDR   string `json:"cidr,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate validates the values of a subnet exclusion request.
+func (request *AddSubnetExclusionRequest) Validate() error {
+	if _, _, err := net.ParseCIDR(request.CIDR); err != nil {
+		return errors.Wrapf(err, "invalid CIDR %s", request.CIDR)
+	}
+	if request.Reason == "" {
+		return errors.New("exclusion reason cannot be empty")
+	}
+
+	return nil
+}
+
+// NewAddSubnetExclusionRequestFromReader will create an AddSubnetExclusionRequest
+// from an io.Reader with JSON data.
+func NewAddSubnetExclusionRequestFromReader(reader io.Reader) (*AddSubnetExclusionRequest, error) {
+	var addSubnetExclusionRequest AddSubnetExclusionRequest
+	err := json.NewDecoder(reader).Decode(&addSubnetExclusionRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode add subnet exclusion request")
+	}
+
+	if err = addSubnetExclusionRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "add subnet exclusion request failed validation")
+	}
+
+	return &addSubnetExclusionRequest, nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExclusionCIDRs(t *testing.T) {
+	var testCases = []struct {
+		description string
+		value       string
+		expected    []string
+		expectError bool
+	}{
+		{"empty", "", nil, false},
+		{"json list", `["10.0.0.0/8", "172.16.1.0/24"]`, []string{"10.0.0.0/8", "172.16.1.0/24"}, false},
+		{"comma separated", "10.0.0.0/8, 172.16.1.0/24", []string{"10.0.0.0/8", "172.16.1.0/24"}, false},
+		{"single CIDR", "100.64.0.0/16", []string{"100.64.0.0/16"}, false},
+		{"IP addresses", `["10.1.0.2", "2600:1f18::53"]`, []string{"10.1.0.2/32", "2600:1f18::53/128"}, false},
+		{"unaligned CIDR", "10.0.1.7/24", []string{"10.0.1.0/24"}, false},
+		{"invalid json", `["10.0.0.0/8"`, nil, true},
+		{"invalid IP", "10.0.0.256", nil, true},
+		{"invalid CIDR", "10.0.0.0/33", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			cidrs, err := ParseExclusionCIDRs(tc.value)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, cidrs)
+		})
+	}
+}
+
+func TestAddSubnetExclusionRequestValidate(t *testing.T) {
+	require.NoError(t, (&AddSubnetExclusionRequest{CIDR: "10.0.0.0/24", Reason: "VPN"}).Validate())
+	require.Error(t, (&AddSubnetExclusionRequest{CIDR: "10.0.0.0", Reason: "VPN"}).Validate())
+	require.Error(t, (&AddSubnetExclusionRequest{CIDR: "10.0.0.0/24"}).Validate())
+}
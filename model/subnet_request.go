@@ -5,15 +5,23 @@
 package model
 
 import (
+	"encoding/json"
+	"io"
 	"net/url"
 	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 // GetSubnetsRequest describes the parameters to request a list of subnets.
 type GetSubnetsRequest struct {
-	Page    int
-	PerPage int
-	Free    bool
+	Page        int
+	PerPage     int
+	Free        bool
+	Family      string
+	Reserved    bool
+	Quarantined bool
 }
 
 // ApplyToURL modifies the given url to include query string parameters for the request.
@@ -24,5 +32,188 @@ func (request *GetSubnetsRequest) ApplyToURL(u *url.URL) {
 	if request.Free {
 		q.Add("show_free", "true")
 	}
+	if request.Family != "" {
+		q.Add("family", request.Family)
+	}
+	if request.Reserved {
+		q.Add("reserved", "true")
+	}
+	if request.Quarantined {
+		q.Add("quarantined", "true")
+	}
+	u.RawQuery = q.Encode()
+}
+
+// GetSubnetAllocationsRequest describes the parameters to request a list of subnet allocations.
+type GetSubnetAllocationsRequest struct {
+	Page      int
+	PerPage   int
+	CIDR      string
+	AccountID string
+}
+
+// ApplyToURL modifies the given url to include query string parameters for the request.
+func (request *GetSubnetAllocationsRequest) ApplyToURL(u *url.URL) {
+	q := u.Query()
+	q.Add("page", strconv.Itoa(request.Page))
+	q.Add("per_page", strconv.Itoa(request.PerPage))
+	if request.CIDR != "" {
+		q.Add("cidr", request.CIDR)
+	}
+	if request.AccountID != "" {
+		q.Add("account", request.AccountID)
+	}
 	u.RawQuery = q.Encode()
 }
+
+// ReserveSubnetRequest specifies the parameters to reserve a subnet so that it
+// cannot be claimed by accounts.
+type ReserveSubnetRequest struct {
+	CIDR      string `json:"cidr,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
+}
+
+// Validate validates the values of a subnet reservation request.
+func (request *ReserveSubnetRequest) Validate() error {
+	if request.Owner == "" {
+		return errors.New("reservation owner cannot be empty")
+	}
+	if request.Reason == "" {
+		return errors.New("reservation reason cannot be empty")
+	}
+	if request.ExpiresAt < 0 {
+		return errors.New("reservation expiry cannot be negative")
+	}
+
+	return nil
+}
+
+// NewReserveSubnetRequestFromReader will create a ReserveSubnetRequest from an
+// io.Reader with JSON data.
+func NewReserveSubnetRequestFromReader(reader io.Reader) (*ReserveSubnetRequest, error) {
+	var reserveSubnetRequest ReserveSubnetRequest
+	err := json.NewDecoder(reader).Decode(&reserveSubnetRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode reserve subnet request")
+	}
+
+	if err = reserveSubnetRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "reserve subnet request failed validation")
+	}
+
+	return &reserveSubnetRequest, nil
+}
+
+// DefaultSubnetReconcileMinClaimAge is the age in milliseconds a claim must
+// have to be considered orphaned when a reconciliation request sets none.
+const DefaultSubnetReconcileMinClaimAge = int64(10 * time.Minute / time.Millisecond)
+
+// ReconcileSubnetsRequest specifies the parameters to reconcile the subnet
+// claims with the accounts.
+type ReconcileSubnetsRequest struct {
+	// Fix releases orphaned claims, binds unbound claims to the accounts that
+	// use them and claims the subnets of accounts that have none.
+	Fix bool `json:"fix,omitempty"`
+	// MinClaimAge is the age in milliseconds a claim must have to be
+	// considered orphaned, so that the claims of accounts being created are
+	// not released.
+	MinClaimAge int64 `json:"minClaimAge,omitempty"`
+}
+
+// SetDefaults sets the default values for a subnet reconciliation request.
+func (request *ReconcileSubnetsRequest) SetDefaults() {
+	if request.MinClaimAge == 0 {
+		request.MinClaimAge = DefaultSubnetReconcileMinClaimAge
+	}
+}
+
+// Validate validates the values of a subnet reconciliation request.
+func (request *ReconcileSubnetsRequest) Validate() error {
+	if request.MinClaimAge < 0 {
+		return errors.New("minimum claim age cannot be negative")
+	}
+
+	return nil
+}
+
+// NewReconcileSubnetsRequestFromReader will create a ReconcileSubnetsRequest
+// from an io.Reader with JSON data.
+func NewReconcileSubnetsRequestFromReader(reader io.Reader) (*ReconcileSubnetsRequest, error) {
+	var reconcileSubnetsRequest ReconcileSubnetsRequest
+	err := json.NewDecoder(reader).Decode(&reconcileSubnetsRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode reconcile subnets request")
+	}
+
+	reconcileSubnetsRequest.SetDefaults()
+	if err = reconcileSubnetsRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "reconcile subnets request failed validation")
+	}
+
+	return &reconcileSubnetsRequest, nil
+}
+
+// DiscoverSubnetsRequest specifies the AWS accounts and regions whose VPCs are
+// discovered to mark the subnets they use as externally used.
+type DiscoverSubnetsRequest struct {
+	// AWSAccountIDs are the AWS accounts to discover. All the active accounts
+	// of the organisation are discovered if it is empty.
+	AWSAccountIDs []string `json:"awsAccountIDs,omitempty"`
+	// Regions are the regions to discover. All the regions enabled in each
+	// account are discovered if it is empty.
+	Regions []string `json:"regions,omitempty"`
+	// RoleName is the IAM role assumed in the discovered accounts. The role
+	// configured on the server is used if it is empty.
+	RoleName string `json:"roleName,omitempty"`
+	// ReportOnly reports the overlapping VPCs without marking their subnets.
+	ReportOnly bool `json:"reportOnly,omitempty"`
+}
+
+// Validate validates the values of a subnet discovery request.
+func (request *DiscoverSubnetsRequest) Validate() error {
+	for _, awsAccountID := range request.AWSAccountIDs {
+		if !isValidAWSAccountID(awsAccountID) {
+			return errors.Errorf("invalid AWS account ID %s", awsAccountID)
+		}
+	}
+	for _, region := range request.Regions {
+		if region == "" {
+			return errors.New("region cannot be empty")
+		}
+	}
+
+	return nil
+}
+
+// isValidAWSAccountID returns true if the given string is made of the twelve
+// digits of an AWS account ID.
+func isValidAWSAccountID(awsAccountID string) bool {
+	if len(awsAccountID) != 12 {
+		return false
+	}
+	for _, c := range awsAccountID {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewDiscoverSubnetsRequestFromReader will create a DiscoverSubnetsRequest
+// from an io.Reader with JSON data.
+func NewDiscoverSubnetsRequestFromReader(reader io.Reader) (*DiscoverSubnetsRequest, error) {
+	var discoverSubnetsRequest DiscoverSubnetsRequest
+	err := json.NewDecoder(reader).Decode(&discoverSubnetsRequest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to decode discover subnets request")
+	}
+
+	if err = discoverSubnetsRequest.Validate(); err != nil {
+		return nil, errors.Wrap(err, "discover subnets request failed validation")
+	}
+
+	return &discoverSubnetsRequest, nil
+}
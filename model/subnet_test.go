@@ -75,3 +75,31 @@ func TestSubnetsFromReader(t *testing.T) {
 		}, subnet)
 	})
 }
+
+func TestSubnetReservation(t *testing.T) {
+	subnet := &Subnet{CIDR: "10.0.0.0/24", AccountID: "account"}
+	require.False(t, subnet.IsReserved())
+	require.False(t, subnet.IsReservationExpired(100))
+	require.Equal(t, SubnetStateClaimed, subnet.State())
+
+	subnet.AccountID = ""
+	subnet.ReservationOwner = "network-team"
+	require.True(t, subnet.IsReserved())
+	require.False(t, subnet.IsReservationExpired(100))
+	require.Equal(t, SubnetStateReserved, subnet.State())
+
+	subnet.ReservationExpiresAt = 100
+	require.False(t, subnet.IsReservationExpired(99))
+	require.True(t, subnet.IsReservationExpired(100))
+
+	subnet.ReservationOwner = SubnetReservationOwnerQuarantine
+	require.Equal(t, SubnetStateQuarantined, subnet.State())
+}
+
+func TestIsValidSubnetAllocationStrategy(t *testing.T) {
+	for _, strategy := range []string{SubnetAllocationSequential, SubnetAllocationRandom, SubnetAllocationSpread, SubnetAllocationBestFit} {
+		require.True(t, IsValidSubnetAllocationStrategy(strategy))
+	}
+	require.False(t, IsValidSubnetAllocationStrategy(""))
+	require.False(t, IsValidSubnetAllocationStrategy("first-fit"))
+}
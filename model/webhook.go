@@ -7,6 +7,7 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"time"
 )
 
 const (
@@ -15,13 +16,49 @@ const (
 
 	// TypeParentSubnet is the string value that represents a parent subnet
 	TypeParentSubnet = "parent_subnet"
+
+	// TypeSubnet is the string value that represents a subnet
+	TypeSubnet = "subnet"
+
+	// TypeWebhookPing is the string value that represents the test payload
+	// sent to check that a webhook receiver is reachable
+	TypeWebhookPing = "ping"
+)
+
+const (
+	// WebhookFormatGenesis sends the webhook payloads as they are.
+	WebhookFormatGenesis = "genesis"
+	// WebhookFormatMattermost sends the webhook payloads as Mattermost
+	// incoming webhook posts.
+	WebhookFormatMattermost = "mattermost"
+	// WebhookFormatSlack sends the webhook payloads as Slack incoming webhook
+	// messages.
+	WebhookFormatSlack = "slack"
 )
 
 // Webhook represents a genesis webhook
 type Webhook struct {
-	ID       string
-	OwnerID  string
-	URL      string
+	ID      string
+	OwnerID string
+	URL     string
+	// Secret signs the payloads sent to the webhook. It is never returned.
+	Secret string `json:"-"`
+	// Subscription filters the events sent to the webhook. Every event is
+	// sent when it is nil.
+	Subscription *WebhookSubscription `json:",omitempty"`
+	// Format is the format of the payloads sent to the webhook.
+	Format string
+	// Headers are the custom headers set on the requests sent to the webhook.
+	Headers map[string]string `json:",omitempty"`
+	// Auth authenticates the requests sent to the webhook, if set.
+	Auth *WebhookAuth `json:",omitempty"`
+	// TimeoutSeconds is how long the receiver has to respond.
+	TimeoutSeconds int
+	// ConsecutiveFailures is the number of delivery attempts that failed since
+	// the last successful one.
+	ConsecutiveFailures int
+	// Disabled webhooks are not sent any payload until they are enabled again.
+	Disabled bool
 	CreateAt int64
 	DeleteAt int64
 }
@@ -44,6 +81,30 @@ type WebhookPayload struct {
 	ExtraData map[string]string `json:"extra_data,omitempty"`
 }
 
+// IsSigned returns whether the payloads sent to the webhook are signed or not.
+func (w *Webhook) IsSigned() bool {
+	return w.Secret != ""
+}
+
+// IsValidWebhookFormat returns true if the given format of webhook payloads is supported.
+func IsValidWebhookFormat(format string) bool {
+	switch format {
+	case WebhookFormatGenesis, WebhookFormatMattermost, WebhookFormatSlack:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewWebhookPingPayload returns the test payload sent to the given webhook.
+func NewWebhookPingPayload(webhookID string) *WebhookPayload {
+	return &WebhookPayload{
+		Type:      TypeWebhookPing,
+		ID:        webhookID,
+		Timestamp: time.Now().UnixNano(),
+	}
+}
+
 // IsDeleted returns whether the webhook was marked as deleted or not.
 func (w *Webhook) IsDeleted() bool {
 	return w.DeleteAt != 0
@@ -0,0 +1,145 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// WebhookAuthBasic authenticates the requests sent to a webhook with a
+	// username and a password.
+	WebhookAuthBasic = "basic"
+	// WebhookAuthBearer authenticates the requests sent to a webhook with a
+	// bearer token.
+	WebhookAuthBearer = "bearer"
+
+	// WebhookRedacted replaces the header values and credentials of webhooks
+	// returned by the API.
+	WebhookRedacted = "********"
+
+	// DefaultWebhookTimeoutSeconds is how long a webhook receiver has to respond
+	// when the webhook has no timeout of its own.
+	DefaultWebhookTimeoutSeconds = 5
+	// MaxWebhookTimeoutSeconds is the longest timeout a webhook can be given.
+	MaxWebhookTimeoutSeconds = 60
+)
+
+// reservedWebhookHeaders are the headers set by genesis itself, which cannot be
+// overridden by the custom headers of a webhook.
+var reservedWebhookHeaders = []string{
+	"Authorization",
+	"Content-Length",
+	"Content-Type",
+	"Host",
+	WebhookSignatureHeader,
+	WebhookTimestampHeader,
+}
+
+// WebhookAuth describes how the requests sent to a webhook are authenticated.
+type WebhookAuth struct {
+	// Type is either basic or bearer.
+	Type     string
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	Token    string `json:",omitempty"`
+}
+
+// Validate validates the type and the credentials of the authentication.
+func (a *WebhookAuth) Validate() error {
+	if a == nil {
+		return nil
+	}
+
+	switch a.Type {
+	case WebhookAuthBasic:
+		if a.Username == "" {
+			return errors.New("basic auth requires a username")
+		}
+		if a.Token != "" {
+			return errors.New("basic auth does not take a token")
+		}
+	case WebhookAuthBearer:
+		if a.Token == "" {
+			return errors.New("bearer auth requires a token")
+		}
+		if a.Username != "" || a.Password != "" {
+			return errors.New("bearer auth does not take a username or a password")
+		}
+	default:
+		return errors.Errorf("unsupported webhook auth type %s", a.Type)
+	}
+
+	return nil
+}
+
+// Apply sets the credentials on the given webhook request.
+func (a *WebhookAuth) Apply(req *http.Request) {
+	if a == nil {
+		return
+	}
+
+	switch a.Type {
+	case WebhookAuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case WebhookAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+}
+
+// ValidateWebhookHeaders validates the custom headers of a webhook.
+func ValidateWebhookHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:\"(),/;<=>?@[\\]{}") {
+			return errors.Errorf("invalid webhook header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return errors.Errorf("invalid value for webhook header %s", name)
+		}
+		for _, reserved := range reservedWebhookHeaders {
+			if http.CanonicalHeaderKey(name) == reserved {
+				return errors.Errorf("webhook header %s is reserved", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Timeout returns how long the receiver of the webhook has to respond.
+func (w *Webhook) Timeout() time.Duration {
+	if w.TimeoutSeconds <= 0 {
+		return DefaultWebhookTimeoutSeconds * time.Second
+	}
+
+	return time.Duration(w.TimeoutSeconds) * time.Second
+}
+
+// Redacted returns a copy of the webhook whose header values and credentials
+// are replaced, so that it can be returned by the API.
+func (w *Webhook) Redacted() *Webhook {
+	redacted := *w
+	if w.Headers != nil {
+		redacted.Headers = make(map[string]string, len(w.Headers))
+		for name := range w.Headers {
+			redacted.Headers[name] = WebhookRedacted
+		}
+	}
+	if w.Auth != nil {
+		redacted.Auth = &WebhookAuth{Type: w.Auth.Type, Username: w.Auth.Username}
+		if w.Auth.Password != "" {
+			redacted.Auth.Password = WebhookRedacted
+		}
+		if w.Auth.Token != "" {
+			redacted.Auth.Token = WebhookRedacted
+		}
+	}
+
+	return &redacted
+}
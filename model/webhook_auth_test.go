@@ -0,0 +1,105 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookAuthValidate(t *testing.T) {
+	var testCases = []struct {
+		description string
+		auth        *WebhookAuth
+		valid       bool
+	}{
+		{"nil", nil, true},
+		{"basic", &WebhookAuth{Type: WebhookAuthBasic, Username: "user", Password: "password"}, true},
+		{"basic without username", &WebhookAuth{Type: WebhookAuthBasic, Password: "password"}, false},
+		{"basic with token", &WebhookAuth{Type: WebhookAuthBasic, Username: "user", Token: "token"}, false},
+		{"bearer", &WebhookAuth{Type: WebhookAuthBearer, Token: "token"}, true},
+		{"bearer without token", &WebhookAuth{Type: WebhookAuthBearer}, false},
+		{"bearer with username", &WebhookAuth{Type: WebhookAuthBearer, Username: "user", Token: "token"}, false},
+		{"unknown type", &WebhookAuth{Type: "digest"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := tc.auth.Validate()
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestWebhookAuthApply(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "https://example.com", nil)
+		require.NoError(t, err)
+		(&WebhookAuth{Type: WebhookAuthBasic, Username: "user", Password: "password"}).Apply(req)
+
+		username, password, ok := req.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", username)
+		require.Equal(t, "password", password)
+	})
+
+	t.Run("bearer", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "https://example.com", nil)
+		require.NoError(t, err)
+		(&WebhookAuth{Type: WebhookAuthBearer, Token: "token"}).Apply(req)
+		require.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "https://example.com", nil)
+		require.NoError(t, err)
+		var auth *WebhookAuth
+		auth.Apply(req)
+		require.Empty(t, req.Header.Get("Authorization"))
+	})
+}
+
+func TestValidateWebhookHeaders(t *testing.T) {
+	require.NoError(t, ValidateWebhookHeaders(nil))
+	require.NoError(t, ValidateWebhookHeaders(map[string]string{"X-Tenant": "genesis", "X-Empty": ""}))
+	require.EqualError(t, ValidateWebhookHeaders(map[string]string{"X Tenant": "genesis"}), `invalid webhook header name "X Tenant"`)
+	require.EqualError(t, ValidateWebhookHeaders(map[string]string{"X-Tenant": "a\r\nb"}), "invalid value for webhook header X-Tenant")
+	require.EqualError(t, ValidateWebhookHeaders(map[string]string{"authorization": "Bearer token"}), "webhook header authorization is reserved")
+	require.EqualError(t, ValidateWebhookHeaders(map[string]string{"x-genesis-signature": "sha256="}), "webhook header x-genesis-signature is reserved")
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	require.Equal(t, 5*time.Second, (&Webhook{}).Timeout())
+	require.Equal(t, 30*time.Second, (&Webhook{TimeoutSeconds: 30}).Timeout())
+}
+
+func TestWebhookRedacted(t *testing.T) {
+	webhook := &Webhook{
+		ID:      NewID(),
+		URL:     "https://example.com",
+		Headers: map[string]string{"X-Tenant": "genesis"},
+		Auth:    &WebhookAuth{Type: WebhookAuthBasic, Username: "user", Password: "password"},
+	}
+
+	redacted := webhook.Redacted()
+	require.Equal(t, webhook.ID, redacted.ID)
+	require.Equal(t, map[string]string{"X-Tenant": WebhookRedacted}, redacted.Headers)
+	require.Equal(t, &WebhookAuth{Type: WebhookAuthBasic, Username: "user", Password: WebhookRedacted}, redacted.Auth)
+
+	// The original webhook is left untouched.
+	require.Equal(t, "genesis", webhook.Headers["X-Tenant"])
+	require.Equal(t, "password", webhook.Auth.Password)
+
+	bearer := (&Webhook{Auth: &WebhookAuth{Type: WebhookAuthBearer, Token: "token"}}).Redacted()
+	require.Equal(t, &WebhookAuth{Type: WebhookAuthBearer, Token: WebhookRedacted}, bearer.Auth)
+	require.Nil(t, bearer.Headers)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	// WebhookDeliveryStatePending is the state of the webhook deliveries that
+	// are waiting for their first or next attempt.
+	WebhookDeliveryStatePending = "pending"
+	// WebhookDeliveryStateDelivered is the state of the webhook deliveries
+	// acknowledged with a 2xx response.
+	WebhookDeliveryStateDelivered = "delivered"
+	// WebhookDeliveryStateFailed is the state of the webhook deliveries given
+	// up on after reaching the maximum delivery age.
+	WebhookDeliveryStateFailed = "failed"
+)
+
+// WebhookDelivery is a webhook payload in the outbox of a webhook. Deliveries
+// of a webhook are sent one at a time in the order of their sequence, so that
+// a failing delivery holds back the following ones until it is retried.
+type WebhookDelivery struct {
+	ID        string
+	WebhookID string
+	Payload   *WebhookPayload
+	// Sequence orders the deliveries of a webhook. It is the time of the
+	// event in nanoseconds.
+	Sequence      int64
+	State         string
+	Attempts      int
+	NextAttemptAt int64
+	LastAttemptAt int64
+	LastError     string `json:",omitempty"`
+	CreateAt      int64
+	// AttemptLog lists the attempts of the delivery, the most recent first.
+	// It is only returned by the API.
+	AttemptLog []*WebhookDeliveryAttempt `json:",omitempty"`
+}
+
+// WebhookDeliveryAttempt records an attempt to deliver a webhook payload.
+type WebhookDeliveryAttempt struct {
+	ID         string
+	DeliveryID string
+	WebhookID  string
+	PayloadID  string
+	// StatusCode is the status code of the response of the receiver, or 0 if
+	// it did not respond.
+	StatusCode int
+	// Latency is how long the receiver took to respond, in milliseconds.
+	Latency  int64
+	Error    string `json:",omitempty"`
+	CreateAt int64
+}
+
+// IsSuccessful returns true if the attempt delivered its payload.
+func (a *WebhookDeliveryAttempt) IsSuccessful() bool {
+	return a.Error == ""
+}
+
+// IsPending returns true if the webhook delivery has not been delivered or given up on yet.
+func (d *WebhookDelivery) IsPending() bool {
+	return d.State == WebhookDeliveryStatePending
+}
+
+// WebhookDeliveryFilter describes the parameters used to constrain a set of webhook deliveries.
+type WebhookDeliveryFilter struct {
+	Page      int
+	PerPage   int
+	WebhookID string
+	State     string
+}
+
+// WebhookDeliveryFromReader decodes a json-encoded webhook delivery from the given io.Reader.
+func WebhookDeliveryFromReader(reader io.Reader) (*WebhookDelivery, error) {
+	delivery := WebhookDelivery{}
+	decoder := json.NewDecoder(reader)
+	err := decoder.Decode(&delivery)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// WebhookDeliveriesFromReader decodes a json-encoded list of webhook deliveries from the given io.Reader.
+func WebhookDeliveriesFromReader(reader io.Reader) ([]*WebhookDelivery, error) {
+	deliveries := []*WebhookDelivery{}
+	decoder := json.NewDecoder(reader)
+
+	err := decoder.Decode(&deliveries)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
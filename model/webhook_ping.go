@@ -0,0 +1,39 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WebhookPingResult describes how a webhook receiver responded to a ping.
+type WebhookPingResult struct {
+	// StatusCode is the status code of the response of the receiver, or 0 if
+	// it did not respond.
+	StatusCode int
+	// Latency is how long the receiver took to respond, in milliseconds.
+	Latency int64
+	// Response is the beginning of the body of the response.
+	Response string `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// IsSuccessful returns true if the receiver accepted the ping.
+func (r *WebhookPingResult) IsSuccessful() bool {
+	return r.Error == ""
+}
+
+// WebhookPingResultFromReader decodes a json-encoded webhook ping result from the given io.Reader.
+func WebhookPingResultFromReader(reader io.Reader) (*WebhookPingResult, error) {
+	result := WebhookPingResult{}
+	decoder := json.NewDecoder(reader)
+	err := decoder.Decode(&result)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &result, nil
+}
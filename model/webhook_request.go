@@ -18,6 +18,18 @@ import (
 type CreateWebhookRequest struct {
 	OwnerID string
 	URL     string
+	// Secret is used to sign the payloads sent to the webhook, if set.
+	Secret string `json:",omitempty"`
+	// Subscription filters the events sent to the webhook, if set.
+	Subscription *WebhookSubscription `json:",omitempty"`
+	// Format is the format of the payloads sent to the webhook, genesis by default.
+	Format string `json:",omitempty"`
+	// Headers are set on the requests sent to the webhook.
+	Headers map[string]string `json:",omitempty"`
+	// Auth authenticates the requests sent to the webhook, if set.
+	Auth *WebhookAuth `json:",omitempty"`
+	// TimeoutSeconds is how long the receiver has to respond, 5 seconds by default.
+	TimeoutSeconds int `json:",omitempty"`
 }
 
 // NewCreateWebhookRequestFromReader will create a CreateWebhookRequest from an io.Reader with JSON data.
@@ -46,6 +58,30 @@ func NewCreateWebhookRequestFromReader(reader io.Reader) (*CreateWebhookRequest,
 	if uri.Host == "" {
 		return nil, errors.New("must specify host")
 	}
+	if err = createWebhookRequest.Subscription.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid subscription")
+	}
+	if createWebhookRequest.Format == "" {
+		createWebhookRequest.Format = WebhookFormatGenesis
+	}
+	if !IsValidWebhookFormat(createWebhookRequest.Format) {
+		return nil, errors.Errorf("unsupported webhook format %s", createWebhookRequest.Format)
+	}
+	if createWebhookRequest.Secret != "" && createWebhookRequest.Format != WebhookFormatGenesis {
+		return nil, errors.New("webhook secrets are only supported with the genesis format")
+	}
+	if err = ValidateWebhookHeaders(createWebhookRequest.Headers); err != nil {
+		return nil, errors.Wrap(err, "invalid headers")
+	}
+	if err = createWebhookRequest.Auth.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid auth")
+	}
+	if createWebhookRequest.TimeoutSeconds == 0 {
+		createWebhookRequest.TimeoutSeconds = DefaultWebhookTimeoutSeconds
+	}
+	if createWebhookRequest.TimeoutSeconds < 0 || createWebhookRequest.TimeoutSeconds > MaxWebhookTimeoutSeconds {
+		return nil, errors.Errorf("webhook timeout must be between 1 and %d seconds", MaxWebhookTimeoutSeconds)
+	}
 
 	return &createWebhookRequest, nil
 }
@@ -69,3 +105,22 @@ func (request *GetWebhooksRequest) ApplyToURL(u *url.URL) {
 	}
 	u.RawQuery = q.Encode()
 }
+
+// GetWebhookDeliveriesRequest describes the parameters to request a list of
+// deliveries of a webhook.
+type GetWebhookDeliveriesRequest struct {
+	Page    int
+	PerPage int
+	State   string
+}
+
+// ApplyToURL modifies the given url to include query string parameters for the request.
+func (request *GetWebhookDeliveriesRequest) ApplyToURL(u *url.URL) {
+	q := u.Query()
+	q.Add("page", strconv.Itoa(request.Page))
+	q.Add("per_page", strconv.Itoa(request.PerPage))
+	if request.State != "" {
+		q.Add("state", request.State)
+	}
+	u.RawQuery = q.Encode()
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// WebhookSignatureHeader is the header carrying the HMAC-SHA256 signature
+	// of the payloads sent to webhooks with a secret.
+	WebhookSignatureHeader = "X-Genesis-Signature"
+	// WebhookTimestampHeader is the header carrying the time the payload was
+	// sent, in nanoseconds, covered by the signature.
+	WebhookTimestampHeader = "X-Genesis-Timestamp"
+
+	webhookSignaturePrefix = "sha256="
+)
+
+// SignWebhookPayload returns the signature of the given webhook payload body
+// sent at the given timestamp, as set in the signature header.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature checks the signature of a webhook request made with
+// the given secret and returns its payload. Unless maxAge is zero, the request
+// must have been sent less than maxAge ago so that captured requests cannot be
+// replayed later on. The timestamp of the payload is the time of the event, and
+// is older than the signed timestamp for retried or redelivered payloads.
+func VerifyWebhookSignature(secret string, header http.Header, body []byte, maxAge time.Duration) (*WebhookPayload, error) {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid webhook timestamp header")
+	}
+
+	expected := SignWebhookPayload(secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(WebhookSignatureHeader))) {
+		return nil, errors.New("invalid webhook signature")
+	}
+
+	if maxAge > 0 {
+		age := time.Since(time.Unix(0, timestamp))
+		if age > maxAge || age < -maxAge {
+			return nil, errors.Errorf("webhook timestamp is not within %s", maxAge)
+		}
+	}
+
+	payload, err := WebhookPayloadFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode webhook payload")
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	sign := func(payload *WebhookPayload, secret string) (http.Header, []byte) {
+		body, err := payload.ToJSON()
+		require.NoError(t, err)
+
+		header := http.Header{}
+		header.Set(WebhookTimestampHeader, strconv.FormatInt(payload.Timestamp, 10))
+		header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, payload.Timestamp, []byte(body)))
+
+		return header, []byte(body)
+	}
+
+	payload := &WebhookPayload{
+		Timestamp: time.Now().UnixNano(),
+		ID:        "id",
+		Type:      TypeAccount,
+		NewState:  "stable",
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		header, body := sign(payload, "secret")
+		verified, err := VerifyWebhookSignature("secret", header, body, time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, payload, verified)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		header, body := sign(payload, "other")
+		_, err := VerifyWebhookSignature("secret", header, body, time.Minute)
+		require.EqualError(t, err, "invalid webhook signature")
+	})
+
+	t.Run("missing headers", func(t *testing.T) {
+		_, body := sign(payload, "secret")
+		_, err := VerifyWebhookSignature("secret", http.Header{}, body, time.Minute)
+		require.Error(t, err)
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		header, _ := sign(payload, "secret")
+		tampered := *payload
+		tampered.NewState = "deleted"
+		_, body := sign(&tampered, "secret")
+		_, err := VerifyWebhookSignature("secret", header, body, time.Minute)
+		require.EqualError(t, err, "invalid webhook signature")
+	})
+
+	t.Run("old payload sent now", func(t *testing.T) {
+		old := *payload
+		old.Timestamp = time.Now().Add(-48 * time.Hour).UnixNano()
+		body, err := old.ToJSON()
+		require.NoError(t, err)
+
+		sentAt := time.Now().UnixNano()
+		header := http.Header{}
+		header.Set(WebhookTimestampHeader, strconv.FormatInt(sentAt, 10))
+		header.Set(WebhookSignatureHeader, SignWebhookPayload("secret", sentAt, []byte(body)))
+
+		verified, err := VerifyWebhookSignature("secret", header, []byte(body), time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, &old, verified)
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		old := *payload
+		old.Timestamp = time.Now().Add(-time.Hour).UnixNano()
+		header, body := sign(&old, "secret")
+		_, err := VerifyWebhookSignature("secret", header, body, time.Minute)
+		require.EqualError(t, err, "webhook timestamp is not within 1m0s")
+
+		verified, err := VerifyWebhookSignature("secret", header, body, 0)
+		require.NoError(t, err)
+		require.Equal(t, &old, verified)
+	})
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"encoding/json"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookSubscription filters the events sent to a webhook. Each empty field
+// matches every event.
+type WebhookSubscription struct {
+	// Types are the resource types of the events, i.e. account.
+	Types []string `json:",omitempty"`
+	// States are the new states of the events. They may contain shell
+	// patterns, i.e. *-failed.
+	States []string `json:",omitempty"`
+	// Environment is the environment of the events. It only filters the
+	// events of the types carrying an environment, i.e. account, since the
+	// subnet events are not tied to one.
+	Environment string `json:",omitempty"`
+}
+
+// webhookTypes are the resource types webhooks can subscribe to.
+var webhookTypes = []string{TypeAccount, TypeParentSubnet, TypeSubnet}
+
+// environmentWebhookTypes are the resource types whose events carry an
+// environment.
+var environmentWebhookTypes = []string{TypeAccount}
+
+// IsEmpty returns true if the subscription matches every event.
+func (s *WebhookSubscription) IsEmpty() bool {
+	return s == nil || (len(s.Types) == 0 && len(s.States) == 0 && s.Environment == "")
+}
+
+// Validate validates the types and state patterns of the subscription.
+func (s *WebhookSubscription) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	for _, webhookType := range s.Types {
+		if !contains(webhookTypes, webhookType) {
+			return errors.Errorf("unsupported webhook type %s", webhookType)
+		}
+	}
+	for _, state := range s.States {
+		if state == "" {
+			return errors.New("webhook state must not be empty")
+		}
+		if _, err := path.Match(state, ""); err != nil {
+			return errors.Wrapf(err, "invalid webhook state pattern %s", state)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the given payload is part of the subscription. A nil
+// subscription matches every payload.
+func (s *WebhookSubscription) Matches(payload *WebhookPayload) bool {
+	if s == nil {
+		return true
+	}
+
+	if len(s.Types) != 0 && !contains(s.Types, payload.Type) {
+		return false
+	}
+	if len(s.States) != 0 {
+		var matched bool
+		for _, state := range s.States {
+			if ok, _ := path.Match(state, payload.NewState); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if s.Environment != "" && contains(environmentWebhookTypes, payload.Type) && payload.ExtraData["Environment"] != s.Environment {
+		return false
+	}
+
+	return true
+}
+
+// NewWebhookSubscription creates the subscription of a webhook given its raw JSON.
+func NewWebhookSubscription(subscriptionBytes []byte) (*WebhookSubscription, error) {
+	if subscriptionBytes == nil || string(subscriptionBytes) == "null" {
+		return nil, nil
+	}
+
+	var subscription WebhookSubscription
+	err := json.Unmarshal(subscriptionBytes, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.IsEmpty() {
+		return nil, nil
+	}
+
+	return &subscription, nil
+}
+
+// contains returns true if the given value is in the given list.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
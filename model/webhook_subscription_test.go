@@ -0,0 +1,75 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+//
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSubscriptionMatches(t *testing.T) {
+	failed := &WebhookPayload{
+		Type:      TypeAccount,
+		NewState:  "creation-failed",
+		ExtraData: map[string]string{"Environment": "prod"},
+	}
+	stable := &WebhookPayload{
+		Type:      TypeAccount,
+		NewState:  "stable",
+		ExtraData: map[string]string{"Environment": "test"},
+	}
+	parentSubnet := &WebhookPayload{
+		Type:      TypeParentSubnet,
+		ExtraData: map[string]string{"CIDR": "10.0.0.0/16"},
+	}
+
+	var testCases = []struct {
+		Description  string
+		Subscription *WebhookSubscription
+		Expected     []bool
+	}{
+		{"nil subscription", nil, []bool{true, true, true}},
+		{"empty subscription", &WebhookSubscription{}, []bool{true, true, true}},
+		{"types", &WebhookSubscription{Types: []string{TypeParentSubnet}}, []bool{false, false, true}},
+		{"state pattern", &WebhookSubscription{States: []string{"*-failed"}}, []bool{true, false, false}},
+		{"states", &WebhookSubscription{States: []string{"stable", "deleted"}}, []bool{false, true, false}},
+		{"environment", &WebhookSubscription{Environment: "prod"}, []bool{true, false, true}},
+		{"all", &WebhookSubscription{Types: []string{TypeAccount}, States: []string{"*"}, Environment: "test"}, []bool{false, true, false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Description, func(t *testing.T) {
+			require.Equal(t, tc.Expected[0], tc.Subscription.Matches(failed))
+			require.Equal(t, tc.Expected[1], tc.Subscription.Matches(stable))
+			require.Equal(t, tc.Expected[2], tc.Subscription.Matches(parentSubnet))
+		})
+	}
+}
+
+func TestWebhookSubscriptionValidate(t *testing.T) {
+	require.NoError(t, (*WebhookSubscription)(nil).Validate())
+	require.NoError(t, (&WebhookSubscription{Types: []string{TypeAccount}, States: []string{"*-failed"}}).Validate())
+	require.EqualError(t, (&WebhookSubscription{Types: []string{"cluster"}}).Validate(), "unsupported webhook type cluster")
+	require.EqualError(t, (&WebhookSubscription{States: []string{""}}).Validate(), "webhook state must not be empty")
+	require.Error(t, (&WebhookSubscription{States: []string{"[creation"}}).Validate())
+}
+
+func TestNewWebhookSubscription(t *testing.T) {
+	subscription, err := NewWebhookSubscription(nil)
+	require.NoError(t, err)
+	require.Nil(t, subscription)
+
+	subscription, err = NewWebhookSubscription([]byte(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, subscription)
+
+	subscription, err = NewWebhookSubscription([]byte(`{"States":["*-failed"]}`))
+	require.NoError(t, err)
+	require.Equal(t, &WebhookSubscription{States: []string{"*-failed"}}, subscription)
+
+	_, err = NewWebhookSubscription([]byte(`invalid`))
+	require.Error(t, err)
+}
@@ -7,3 +7,15 @@ resource "aws_internet_gateway" "internet_gtw" {
     var.tags
   )
 }
+
+resource "aws_egress_only_internet_gateway" "egress_only_internet_gtw" {
+  count = local.ipv6_enabled ? 1 : 0
+
+  vpc_id = aws_vpc.vpc.id
+  tags = merge(
+    {
+      "Name" = format("%s-%s", var.name, join("", split(".", split("/", var.vpc_cidr)[0]))),
+    },
+    var.tags
+  )
+}
@@ -41,6 +41,30 @@ resource "aws_route" "private_nat_gateway" {
   }
 }
 
+resource "aws_route" "public_internet_gateway_ipv6" {
+  count = local.ipv6_enabled ? 1 : 0
+
+  route_table_id              = aws_route_table.public.id
+  destination_ipv6_cidr_block = "::/0"
+  gateway_id                  = aws_internet_gateway.internet_gtw.id
+
+  timeouts {
+    create = "5m"
+  }
+}
+
+resource "aws_route" "private_egress_only_internet_gateway_ipv6" {
+  count = local.ipv6_enabled ? 1 : 0
+
+  route_table_id              = aws_route_table.private.id
+  destination_ipv6_cidr_block = "::/0"
+  egress_only_gateway_id      = aws_egress_only_internet_gateway.egress_only_internet_gtw[0].id
+
+  timeouts {
+    create = "5m"
+  }
+}
+
 resource "aws_route" "transit_gateway_private" {
   for_each = toset(var.transit_gtw_route_destinations)
 
@@ -1,6 +1,8 @@
 resource "aws_subnet" "private_1a" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[0], 2, 0)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 0) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[0]
   tags = merge(
     {
@@ -19,6 +21,8 @@ resource "aws_subnet" "private_1a" {
 resource "aws_subnet" "private_1b" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[0], 2, 1)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 1) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[1]
   tags = merge(
     {
@@ -37,6 +41,8 @@ resource "aws_subnet" "private_1b" {
 resource "aws_subnet" "private_1c" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[0], 2, 2)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 2) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[2]
   tags = merge(
     {
@@ -55,6 +61,8 @@ resource "aws_subnet" "private_1c" {
 resource "aws_subnet" "private_1d" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[0], 2, 3)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 3) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[3]
   tags = merge(
     {
@@ -75,6 +83,8 @@ resource "aws_subnet" "private_1d" {
 resource "aws_subnet" "public_1a" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[1], 2, 0)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 4) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[0]
   tags = merge(
     {
@@ -93,6 +103,8 @@ resource "aws_subnet" "public_1a" {
 resource "aws_subnet" "public_1b" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[1], 2, 1)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 5) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[1]
   tags = merge(
     {
@@ -111,6 +123,8 @@ resource "aws_subnet" "public_1b" {
 resource "aws_subnet" "public_1c" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[1], 2, 2)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 6) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[2]
   tags = merge(
     {
@@ -129,6 +143,8 @@ resource "aws_subnet" "public_1c" {
 resource "aws_subnet" "public_1d" {
   vpc_id            = aws_vpc.vpc.id
   cidr_block        = cidrsubnet(cidrsubnets(var.vpc_cidr, 1, 1)[1], 2, 3)
+  ipv6_cidr_block   = local.ipv6_enabled ? cidrsubnet(var.vpc_ipv6_cidr, local.ipv6_subnet_newbits, 7) : null
+  depends_on        = [aws_vpc_ipv6_cidr_block_association.ipv6]
   availability_zone = var.vpc_azs[3]
   tags = merge(
     {
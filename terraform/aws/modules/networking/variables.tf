@@ -1,5 +1,9 @@
 variable "vpc_cidr" {}
 
+variable "vpc_ipv6_cidr" {}
+
+variable "vpc_ipv6_pool_id" {}
+
 variable "vpc_azs" {}
 
 variable "environment" {}
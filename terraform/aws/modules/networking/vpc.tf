@@ -1,3 +1,9 @@
+locals {
+  ipv6_enabled = var.vpc_ipv6_cidr != ""
+  # Each subnet gets a /64 out of the VPC IPv6 block. Private subnets use the
+  # first four and public subnets the following four.
+  ipv6_subnet_newbits = local.ipv6_enabled ? 64 - tonumber(split("/", var.vpc_ipv6_cidr)[1]) : 0
+}
 
 resource "aws_vpc" "vpc" {
   cidr_block           = var.vpc_cidr
@@ -22,5 +28,10 @@ resource "aws_vpc" "vpc" {
   }
 }
 
+resource "aws_vpc_ipv6_cidr_block_association" "ipv6" {
+  count = local.ipv6_enabled ? 1 : 0
 
-
+  vpc_id            = aws_vpc.vpc.id
+  ipv6_cidr_block   = var.vpc_ipv6_cidr
+  ipv6_ipam_pool_id = var.vpc_ipv6_pool_id
+}
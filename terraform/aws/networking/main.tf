@@ -7,7 +7,7 @@ terraform {
     profile = "central-monitoring-test"
   }
   required_providers {
-    aws = "~> 3.15.0"
+    aws = "~> 3.70"
   }
 }
 
@@ -25,6 +25,8 @@ module "networking" {
   source                                   = "../modules/networking"
   environment                              = var.environment
   vpc_cidr                                 = var.vpc_cidr
+  vpc_ipv6_cidr                            = var.vpc_ipv6_cidr
+  vpc_ipv6_pool_id                         = var.vpc_ipv6_pool_id
   vpc_azs                                  = var.vpc_azs
   name                                     = "mattermost-cloud-${var.environment}-enterprise"
   enable_dns_hostnames                     = true
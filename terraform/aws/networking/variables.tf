@@ -13,6 +13,18 @@ variable "vpc_cidr" {
   type    = string
 }
 
+variable "vpc_ipv6_cidr" {
+  default     = ""
+  type        = string
+  description = "The IPv6 CIDR block of the VPC. When empty the VPC is IPv4 only"
+}
+
+variable "vpc_ipv6_pool_id" {
+  default     = ""
+  type        = string
+  description = "The IPv6 address pool the VPC IPv6 CIDR block is allocated from"
+}
+
 variable "vpc_azs" {
   default = ["us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d"]
   type    = list(string)